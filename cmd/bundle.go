@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// bundleHeader starts every bundle. A bundle is a multi-document yaml, in which each document is a single,
+// self-contained konf. This keeps bundles readable and allows them to be reviewed like any other kubeconfig
+const bundleHeader = "# konf bundle v1\n"
+
+// exportBundle creates a bundle of the konfs with the supplied ids and returns it together with the number of
+// bundled konfs. If no ids are supplied, all konfs of the store are bundled
+func exportBundle(f afero.Fs, ids []string) ([]byte, int, error) {
+	if len(ids) == 0 {
+		konfs, err := fetchKonfs(f)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, k := range konfs {
+			ids = append(ids, k.ID())
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(bundleHeader)
+	for i, id := range ids {
+		path := utils.ResolveStorePath(f, id)
+		b, err := readStoreFile(f, path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, 0, &KonfNotFound{ID: id}
+			}
+			return nil, 0, err
+		}
+		var conf k8s.Config
+		err = yaml.Unmarshal(b, &conf)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not parse konf %q: %v", id, err)
+		}
+
+		// relative paths in a kubeconfig are relative to the kubeconfig itself
+		err = flattenKonf(f, &conf, filepath.Dir(path))
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not flatten konf %q: %v", id, err)
+		}
+		for _, u := range conf.AuthInfos {
+			if u.AuthInfo.Exec != nil {
+				log.Warn("Konf %q uses the credential plugin %q, which needs to be installed on every machine the bundle is imported on\n", id, u.AuthInfo.Exec.Command)
+			}
+		}
+
+		out, err := yaml.Marshal(conf)
+		if err != nil {
+			return nil, 0, err
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(out)
+	}
+
+	return buf.Bytes(), len(ids), nil
+}
+
+// flattenKonf replaces all references to files in conf with their content, so conf does not depend on the
+// machine it has been created on anymore
+func flattenKonf(f afero.Fs, conf *k8s.Config, dir string) error {
+	read := func(path string) ([]byte, error) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		return afero.ReadFile(f, path)
+	}
+
+	for i := range conf.Clusters {
+		cl := &conf.Clusters[i].Cluster
+		if cl.CertificateAuthority != "" {
+			b, err := read(cl.CertificateAuthority)
+			if err != nil {
+				return err
+			}
+			cl.CertificateAuthorityData = b
+			cl.CertificateAuthority = ""
+		}
+	}
+
+	for i := range conf.AuthInfos {
+		u := &conf.AuthInfos[i].AuthInfo
+		if u.ClientCertificate != "" {
+			b, err := read(u.ClientCertificate)
+			if err != nil {
+				return err
+			}
+			u.ClientCertificateData = b
+			u.ClientCertificate = ""
+		}
+		if u.ClientKey != "" {
+			b, err := read(u.ClientKey)
+			if err != nil {
+				return err
+			}
+			u.ClientKeyData = b
+			u.ClientKey = ""
+		}
+		if u.TokenFile != "" {
+			b, err := read(u.TokenFile)
+			if err != nil {
+				return err
+			}
+			u.Token = strings.TrimSpace(string(b))
+			u.TokenFile = ""
+		}
+	}
+
+	return nil
+}
+
+// readBundle returns the konfs of a bundle. Each document is split just like any other kubeconfig, which
+// means bundles that have been edited by hand end up in the store correctly as well
+func readBundle(b []byte, splitUsers bool) ([]*konfFile, error) {
+	if !bytes.HasPrefix(normalizeKubeconfig(b), []byte(bundleHeader)) {
+		return nil, fmt.Errorf("the file is not a konf bundle. Bundles can be created using 'konf import --export-bundle'")
+	}
+
+	confs := []*konfFile{}
+	r := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(b)))
+	for {
+		doc, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(stripYAMLComments(doc))) == 0 {
+			continue
+		}
+
+		split, err := splitConfigs(doc, splitUsers)
+		if err != nil {
+			return nil, err
+		}
+		confs = append(confs, split...)
+	}
+	return confs, nil
+}
+
+// stripYAMLComments removes all lines that only consist of a comment
+func stripYAMLComments(b []byte) []byte {
+	lines := [][]byte{}
+	for _, l := range bytes.Split(b, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimSpace(l), []byte("#")) {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
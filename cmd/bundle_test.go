@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+var prodWithFiles = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://prod.example.com
+    certificate-authority: ca.crt
+  name: prod-1
+contexts:
+- context:
+    cluster: prod-1
+    user: prod
+  name: prod
+current-context: prod
+users:
+- name: prod
+  user:
+    client-key: /keys/prod.key
+    tokenFile: /keys/token
+`
+
+func TestBundle(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	src := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU,
+		importSource(utils.StorePathForID("prod_prod-1"), prodWithFiles),
+		importSource("./konf/store/ca.crt", "my-ca"),
+		importSource("/keys/prod.key", "my-key"),
+		importSource("/keys/token", "my-token\n"),
+	)
+
+	ec := newImportCmd()
+	ec.fs = src
+	ec.exportBundle = "./team.yaml"
+	err := ec.cmd.RunE(ec.cmd, []string{"prod_prod-1", "dev-eu_dev-eu-1"})
+	if err != nil {
+		t.Fatalf("Exp no error on export, got %q", err)
+	}
+
+	b, err := afero.ReadFile(src, "./team.yaml")
+	if err != nil {
+		t.Fatalf("Exp bundle to be written, got %q", err)
+	}
+	if !strings.HasPrefix(string(b), bundleHeader) {
+		t.Errorf("Exp bundle to start with %q, got %q", bundleHeader, string(b))
+	}
+	for _, path := range []string{"ca.crt", "/keys/prod.key", "/keys/token"} {
+		if strings.Contains(string(b), path) {
+			t.Errorf("Exp bundle to be self-contained, but it still references %q", path)
+		}
+	}
+
+	// the teammate already has a different version of dev-eu, which should be kept
+	devEU := strings.Replace(string(b[bytes.LastIndex(b, []byte("---\n"))+4:]), "https://10.1.1.0", "https://10.2.2.0", 1)
+	dst := testhelper.FSWithFiles(fm.StoreDir,
+		importSource(utils.StorePathForID("dev-eu_dev-eu-1"), devEU),
+		importSource("./team.yaml", string(b)),
+	)
+	ic := newImportCmd()
+	ic.fs = dst
+	ic.bundle = true
+	ic.onCollision = collisionSkip
+	err = ic.cmd.RunE(ic.cmd, []string{"./team.yaml"})
+	if err != nil {
+		t.Fatalf("Exp no error on import, got %q", err)
+	}
+
+	prod, err := afero.ReadFile(dst, utils.StorePathForID("prod_prod-1"))
+	if err != nil {
+		t.Fatalf("Exp prod to be imported, got %q", err)
+	}
+	conf := parseKonf(t, prod)
+	if string(conf.Clusters[0].Cluster.CertificateAuthorityData) != "my-ca" || string(conf.AuthInfos[0].AuthInfo.ClientKeyData) != "my-key" || conf.AuthInfos[0].AuthInfo.Token != "my-token" {
+		t.Errorf("Exp credentials to be embedded, got %+v and %+v", conf.Clusters[0].Cluster, conf.AuthInfos[0].AuthInfo)
+	}
+
+	eu, err := afero.ReadFile(dst, utils.StorePathForID("dev-eu_dev-eu-1"))
+	if err != nil {
+		t.Fatalf("Exp dev-eu to be kept, got %q", err)
+	}
+	if string(eu) != devEU {
+		t.Errorf("Exp dev-eu not to be overwritten, got %q", string(eu))
+	}
+}
+
+func TestBundleErrors(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		bundle       bool
+		exportBundle string
+		args         []string
+		expErr       error
+	}{
+		"export unknown konf": {
+			false, "./team.yaml",
+			[]string{"no-konf"},
+			&KonfNotFound{ID: "no-konf"},
+		},
+		"export and import": {
+			true, "./team.yaml",
+			[]string{},
+			fmt.Errorf("--export-bundle cannot be combined with --bundle or --url"),
+		},
+		"import a kubeconfig as bundle": {
+			true, "",
+			[]string{"./import/kubeconfig.yaml"},
+			fmt.Errorf("the file is not a konf bundle. Bundles can be created using 'konf import --export-bundle'"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			ic := newImportCmd()
+			ic.fs = testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, importSource("./import/kubeconfig.yaml", prodWithFiles))
+			ic.bundle = tc.bundle
+			ic.exportBundle = tc.exportBundle
+
+			err := ic.cmd.RunE(ic.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+		})
+	}
+}
@@ -2,10 +2,12 @@ package cmd
 
 import (
 	"errors"
-	"fmt"
 	"io/fs"
+	"net"
+	"net/url"
 	"os"
-	"strconv"
+	"sort"
+	"time"
 
 	"github.com/mitchellh/go-ps"
 	"github.com/simontheleg/konf-go/config"
@@ -13,6 +15,8 @@ import (
 	"github.com/simontheleg/konf-go/utils"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
 )
 
 // cleanupCmd represents the cleanup command
@@ -20,10 +24,15 @@ var cleanupCmd = &cobra.Command{
 	Use:   "cleanup",
 	Short: "Cleanup inactive kubeconfigs",
 	Long: `This command cleans up any unused active configs (stored in konfDir/active).
-An active config is considered unused when no process points to it anymore`,
+An active config is considered unused when no process points to it anymore.
+
+Additionally all expired scratch konfs (see 'konf import --scratch --ttl') are removed from the store.
+With --scratch, scratch konfs whose cluster is not reachable anymore are removed as well. As the
+shellwrapper runs cleanup whenever a shell exits, this is never done implicitly. Scratch konfs that
+are active in a running shell are always kept.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		fs := afero.NewOsFs()
+		fs := utils.NewFs()
 		err := cleanLeftOvers(fs)
 		if err != nil {
 			return err
@@ -34,6 +43,24 @@ An active config is considered unused when no process points to it anymore`,
 			return err
 		}
 
+		// a cluster might just be unreachable for a moment, e.g. while offline, which is why this needs to be
+		// requested explicitly
+		var reachable func(string) bool
+		if scratch, _ := cmd.Flags().GetBool("scratch"); scratch {
+			reachable = clusterReachable
+		}
+		inUse, err := activeKonfIDs(fs, processAlive)
+		if err != nil {
+			return err
+		}
+		removed, err := pruneScratchKonfs(fs, clock.Now(), reachable, inUse)
+		if err != nil {
+			return err
+		}
+		for _, id := range removed {
+			log.Info("Removed scratch konf %q\n", id)
+		}
+
 		return nil
 	},
 }
@@ -45,7 +72,11 @@ An active config is considered unused when no process points to it anymore`,
 func selfClean(f afero.Fs) error {
 	pid := os.Getppid()
 
-	fpath := utils.ActivePathForID(fmt.Sprint(pid))
+	fpath := utils.ActivePathForPID(pid)
+	// the state of the latest konf is only a convenience for 'konf set -', which should not prevent the cleanup
+	if err := snapshotLatestState(f, fpath); err != nil {
+		log.Warn("could not keep the state of the latest konf: %v\n", err)
+	}
 	err := f.Remove(fpath)
 
 	if errors.Is(err, fs.ErrNotExist) {
@@ -73,11 +104,9 @@ func cleanLeftOvers(f afero.Fs) error {
 	}
 
 	for _, konf := range konfs {
-		// We need to trim of the .yaml file extension to get to the PID
-		sPid := utils.IDFromFileInfo(konf)
-		pid, err := strconv.Atoi(sPid)
-		if err != nil {
-			log.Warn("file '%s' could not be converted into an int, and therefore cannot be a valid process id. Skip for cleanup", konf.Name())
+		pid, ok := utils.PIDFromActiveFile(konf.Name())
+		if !ok {
+			log.Warn("file '%s' does not contain a valid process id. Skip for cleanup", konf.Name())
 			continue
 		}
 
@@ -87,7 +116,10 @@ func cleanLeftOvers(f afero.Fs) error {
 		}
 
 		if p == nil {
-			err := f.Remove(utils.ActivePathForID(fmt.Sprint(pid)))
+			if err := snapshotLatestState(f, utils.ActivePathForPID(pid)); err != nil {
+				log.Warn("could not keep the state of the latest konf: %v\n", err)
+			}
+			err := f.Remove(utils.ActivePathForPID(pid))
 			if err != nil {
 				return err
 			}
@@ -97,6 +129,143 @@ func cleanLeftOvers(f afero.Fs) error {
 	return nil
 }
 
+// processAlive reports whether a process with the supplied pid is currently running
+func processAlive(pid int) (bool, error) {
+	p, err := ps.FindProcess(pid)
+	if err != nil {
+		return false, err
+	}
+	return p != nil, nil
+}
+
+// liveShells returns the pids of all shells that have an active konf and are still running
+func liveShells(f afero.Fs, alive func(int) (bool, error)) ([]int, error) {
+	konfs, err := afero.ReadDir(f, config.ActiveDir())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []int{}, nil
+		}
+		return nil, err
+	}
+
+	pids := []int{}
+	for _, konf := range konfs {
+		pid, ok := utils.PIDFromActiveFile(konf.Name())
+		if !ok {
+			continue
+		}
+		ok, err := alive(pid)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// activeKonfIDs returns the ids of all konfs that are active in at least one running shell
+func activeKonfIDs(f afero.Fs, alive func(int) (bool, error)) (map[string]bool, error) {
+	pids, err := liveShells(f, alive)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string]bool{}
+	for _, pid := range pids {
+		id, err := activeKonfIDFromFile(f, utils.ActivePathForPID(pid))
+		// the shell might have just switched away and the file has been removed in the meantime
+		if err != nil || id == "" {
+			continue
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// pruneScratchKonfs removes all scratch konfs from the store, that have expired at now. If reachable is set, scratch
+// konfs whose cluster is not reachable anymore are removed as well. Konfs in inUse are always kept, as they are
+// still active in a running shell. It returns the ids of all removed konfs
+func pruneScratchKonfs(f afero.Fs, now time.Time, reachable func(server string) bool, inUse map[string]bool) ([]string, error) {
+	m, err := loadMetadata(f)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for id, km := range m.Konfs {
+		if km.Scratch && !inUse[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	removed := []string{}
+	for _, id := range ids {
+		km := m.Konfs[id]
+		b, err := readStoreFile(f, utils.ResolveStorePath(f, id))
+		if errors.Is(err, fs.ErrNotExist) {
+			// the konf has been deleted already, so its metadata is going to be pruned on the next reindex
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		expired := km.Expires != nil && now.After(*km.Expires)
+		if !expired && reachable == nil {
+			continue
+		}
+		if !expired {
+			var conf k8s.Config
+			err = yaml.Unmarshal(b, &conf)
+			// a broken scratch konf is of no use anymore either
+			if err == nil && len(conf.Clusters) != 0 && reachable(conf.Clusters[0].Cluster.Server) {
+				continue
+			}
+		}
+
+		err = deleteKonf(f, id)
+		if err != nil {
+			return nil, err
+		}
+		// deleteKonf has saved the metadata already, but m is saved once more below
+		m.removeKonf(id)
+		removed = append(removed, id)
+	}
+
+	if len(removed) == 0 {
+		return removed, nil
+	}
+	return removed, saveMetadata(f, m)
+}
+
+// clusterReachable reports whether a connection to the server of a cluster can be established
+func clusterReachable(server string) bool {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	conn, err := net.DialTimeout("tcp", host, config.Timeout())
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
 func init() {
+	cleanupCmd.Flags().Bool("scratch", false, "also remove scratch konfs, whose cluster is not reachable anymore")
 	rootCmd.AddCommand(cleanupCmd)
 }
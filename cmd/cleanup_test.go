@@ -8,7 +8,9 @@ import (
 	"os/exec"
 	"syscall"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/simontheleg/konf-go/config"
 	"github.com/simontheleg/konf-go/testhelper"
 	"github.com/simontheleg/konf-go/utils"
@@ -245,3 +247,133 @@ func cleanUpRunningCmds(t *testing.T, cmds []*exec.Cmd) {
 		t.Fatalf("Cleanup went wrong, please manually check the following processes: %v", rogueProcesses)
 	}
 }
+
+func TestPruneScratchKonfs(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	euScratch := metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    scratch: true\n")
+	euExpired := metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    scratch: true\n    expires: \"2022-06-01T11:00:00Z\"\n")
+
+	tt := map[string]struct {
+		fs         afero.Fs
+		checkReach bool
+		reachable  bool
+		inUse      map[string]bool
+		expRemoved []string
+		expKept    []string
+	}{
+		"cluster unreachable": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, euScratch),
+			true, false, map[string]bool{},
+			[]string{"dev-eu_dev-eu-1"},
+			[]string{"dev-asia_dev-asia-1"},
+		},
+		"cluster reachable": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    scratch: true\n    expires: \"2022-06-02T12:00:00Z\"\n")),
+			true, true, map[string]bool{},
+			[]string{},
+			[]string{"dev-eu_dev-eu-1"},
+		},
+		"reachability not checked": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, euScratch),
+			false, false, map[string]bool{},
+			[]string{},
+			[]string{"dev-eu_dev-eu-1"},
+		},
+		"expired": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, euExpired),
+			false, true, map[string]bool{},
+			[]string{"dev-eu_dev-eu-1"},
+			[]string{},
+		},
+		"unreachable but active in a shell": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, euScratch),
+			true, false, map[string]bool{"dev-eu_dev-eu-1": true},
+			[]string{},
+			[]string{"dev-eu_dev-eu-1"},
+		},
+		"expired but active in a shell": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, euExpired),
+			false, true, map[string]bool{"dev-eu_dev-eu-1": true},
+			[]string{},
+			[]string{"dev-eu_dev-eu-1"},
+		},
+		"konf not in store anymore": {
+			testhelper.FSWithFiles(fm.StoreDir, euScratch),
+			true, false, map[string]bool{},
+			[]string{},
+			[]string{},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			var reachable func(string) bool
+			if tc.checkReach {
+				reachable = func(string) bool { return tc.reachable }
+			}
+			removed, err := pruneScratchKonfs(tc.fs, now, reachable, tc.inUse)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if !cmp.Equal(removed, tc.expRemoved) {
+				t.Errorf("Exp and given removed konfs differ: \n '%s'", cmp.Diff(tc.expRemoved, removed))
+			}
+
+			for _, id := range tc.expRemoved {
+				if _, err := tc.fs.Stat(utils.StorePathForID(id)); !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("Exp konf %q to be removed from the store, but it still exists", id)
+				}
+				m, err := loadMetadata(tc.fs)
+				if err != nil {
+					t.Fatalf("Could not load metadata: %q", err)
+				}
+				if _, ok := m.Konfs[id]; ok {
+					t.Errorf("Exp metadata of konf %q to be removed, but it still exists", id)
+				}
+			}
+			for _, id := range tc.expKept {
+				if _, err := tc.fs.Stat(utils.StorePathForID(id)); err != nil {
+					t.Errorf("Exp konf %q to be kept, got %q", id, err)
+				}
+			}
+		})
+	}
+}
+
+func TestScratchExpiry(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	fc := testhelper.NewFakeClock(time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC))
+	clock = fc
+	t.Cleanup(func() { clock = utils.RealClock{} })
+
+	f := testhelper.FSWithFiles(importSource("./kubeconfig.yaml", sm.SingleClusterSingleContextEU()))
+	icmd := newImportCmd()
+	icmd.fs = f
+	icmd.scratch = true
+	icmd.ttl = time.Hour
+	err := icmd.cmd.RunE(icmd.cmd, []string{"./kubeconfig.yaml"})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	// the exit hook of the shellwrapper only removes expired konfs
+	steps := []struct {
+		advance    time.Duration
+		expRemoved []string
+	}{
+		{59 * time.Minute, []string{}},
+		{2 * time.Minute, []string{"dev-eu_dev-eu-1"}},
+	}
+	for _, s := range steps {
+		fc.Advance(s.advance)
+		removed, err := pruneScratchKonfs(f, clock.Now(), nil, map[string]bool{})
+		if err != nil {
+			t.Fatalf("Exp no error, got %q", err)
+		}
+		if !cmp.Equal(removed, s.expRemoved) {
+			t.Errorf("Exp konfs %v to be removed at %s, got %v", s.expRemoved, clock.Now(), removed)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/simontheleg/konf-go/config"
+)
+
+// clipboardTool is a command that prints the content of the clipboard
+type clipboardTool struct {
+	name string
+	args []string
+}
+
+// clipboardTools returns the tools that can read the clipboard on the current platform, in the order they
+// should be tried
+func clipboardTools(goos string, wayland bool) []clipboardTool {
+	switch goos {
+	case "darwin":
+		return []clipboardTool{{"pbpaste", nil}}
+	case "windows":
+		return []clipboardTool{{"powershell.exe", []string{"-NoProfile", "-Command", "Get-Clipboard -Raw"}}}
+	}
+
+	tools := []clipboardTool{
+		{"xclip", []string{"-selection", "clipboard", "-out"}},
+		{"xsel", []string{"--clipboard", "--output"}},
+	}
+	if wayland {
+		tools = append([]clipboardTool{{"wl-paste", []string{"--no-newline"}}}, tools...)
+	}
+	return tools
+}
+
+// readClipboard returns the content of the clipboard. There is no portable way to access the clipboard without
+// cgo, so we rely on the same tools that clipboard libraries use under the hood
+func readClipboard() ([]byte, error) {
+	tools := clipboardTools(runtime.GOOS, os.Getenv("WAYLAND_DISPLAY") != "")
+	for _, t := range tools {
+		path, err := exec.LookPath(t.name)
+		if err != nil {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), config.Timeout())
+		defer cancel()
+		out, err := exec.CommandContext(ctx, path, t.args...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("could not read the clipboard via %q: %v", t.name, err)
+		}
+		if len(bytes.TrimSpace(out)) == 0 {
+			return nil, fmt.Errorf("the clipboard is empty")
+		}
+		return out, nil
+	}
+
+	names := []string{}
+	for _, t := range tools {
+		names = append(names, t.name)
+	}
+	return nil, fmt.Errorf("could not read the clipboard. Please install one of %q", names)
+}
+
+// splitClipboard validates the content of the clipboard and splits it just like a kubeconfig from a file
+func splitClipboard(b []byte, splitUsers bool) ([]*konfFile, error) {
+	confs, err := splitConfigs(b, splitUsers)
+	if err != nil {
+		return nil, fmt.Errorf("the clipboard does not contain a valid kubeconfig: %v", err)
+	}
+	return confs, nil
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestClipboardTools(t *testing.T) {
+	tt := map[string]struct {
+		goos     string
+		wayland  bool
+		expTools []string
+	}{
+		"darwin":  {"darwin", false, []string{"pbpaste"}},
+		"windows": {"windows", false, []string{"powershell.exe"}},
+		"x11":     {"linux", false, []string{"xclip", "xsel"}},
+		"wayland": {"linux", true, []string{"wl-paste", "xclip", "xsel"}},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			names := []string{}
+			for _, tool := range clipboardTools(tc.goos, tc.wayland) {
+				names = append(names, tool.name)
+			}
+			if !cmp.Equal(tc.expTools, names) {
+				t.Errorf("Exp tools %v, got %v", tc.expTools, names)
+			}
+		})
+	}
+}
+
+func TestSetClipboard(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+
+	tt := map[string]struct {
+		clipboard  string
+		clipErr    error
+		args       []string
+		sel        int
+		expErr     error
+		expContext string
+	}{
+		"single context": {
+			sm.SingleClusterSingleContextEU(),
+			nil,
+			[]string{},
+			0,
+			nil,
+			"dev-eu",
+		},
+		"multiple contexts": {
+			sm.MultiClusterMultiContext(),
+			nil,
+			[]string{},
+			1,
+			nil,
+			"dev-eu",
+		},
+		"clipboard and id": {
+			sm.SingleClusterSingleContextEU(),
+			nil,
+			[]string{"dev-eu_dev-eu-1"},
+			0,
+			fmt.Errorf("--from-clipboard cannot be combined with a konf id or --file"),
+			"",
+		},
+		"no kubeconfig": {
+			"just some text",
+			nil,
+			[]string{},
+			0,
+			fmt.Errorf("the clipboard does not contain a valid kubeconfig: error unmarshaling JSON: while decoding JSON: json: cannot unmarshal string into Go value of type v1.Config"),
+			"",
+		},
+		"no contexts": {
+			"apiVersion: v1\nkind: Config\n",
+			nil,
+			[]string{},
+			0,
+			fmt.Errorf("no contexts found in the clipboard"),
+			"",
+		},
+		"unreadable clipboard": {
+			"",
+			fmt.Errorf("the clipboard is empty"),
+			[]string{},
+			0,
+			fmt.Errorf("the clipboard is empty"),
+			"",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := afero.NewMemMapFs()
+			sc := newSetCommand()
+			sc.fs = f
+			sc.fromClip = true
+			sc.clipboard = func() ([]byte, error) { return []byte(tc.clipboard), tc.clipErr }
+			sc.selector = promptFunc(func(*promptui.Select) (int, error) { return tc.sel, nil })
+
+			err := sc.cmd.RunE(sc.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			if _, err := f.Stat(config.StoreDir()); err == nil {
+				t.Errorf("Exp store to stay untouched, but it was created")
+			}
+
+			if tc.expErr != nil {
+				return
+			}
+			b, err := afero.ReadFile(f, utils.ActivePathForPID(os.Getppid()))
+			if err != nil {
+				t.Fatalf("Could not read active file: %q", err)
+			}
+			var conf k8s.Config
+			err = yaml.Unmarshal(b, &conf)
+			if err != nil {
+				t.Fatalf("Could not unmarshal active file: %q", err)
+			}
+			if len(conf.Contexts) != 1 || conf.CurrentContext != tc.expContext {
+				t.Errorf("Exp active file to only contain context %q, got %v", tc.expContext, conf.Contexts)
+			}
+		})
+	}
+}
+
+func TestImportClipboard(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+
+	tt := map[string]struct {
+		args     []string
+		url      string
+		expErr   error
+		expKonfs []string
+	}{
+		"clipboard only": {
+			[]string{},
+			"",
+			nil,
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+		},
+		"clipboard and file": {
+			[]string{"./kubeconfig.yaml"},
+			"",
+			fmt.Errorf("--from-clipboard cannot be combined with a file, --url, --bundle, --alias-filename or --fetch-command"),
+			nil,
+		},
+		"clipboard and url": {
+			[]string{},
+			"https://example.com/kubeconfig",
+			fmt.Errorf("--from-clipboard cannot be combined with a file, --url, --bundle, --alias-filename or --fetch-command"),
+			nil,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := afero.NewMemMapFs()
+			icmd := newImportCmd()
+			icmd.fs = f
+			icmd.fromClip = true
+			icmd.url = tc.url
+			icmd.clipboard = func() ([]byte, error) { return []byte(sm.MultiClusterMultiContext()), nil }
+
+			err := icmd.cmd.RunE(icmd.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			for _, id := range tc.expKonfs {
+				if _, err := f.Stat(utils.StorePathForID(id)); err != nil {
+					t.Errorf("Exp konf %q to be imported, got %q", id, err)
+				}
+			}
+		})
+	}
+}
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/simontheleg/konf-go/config"
 	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/spf13/cobra"
 )
 
 func TestCompletionCmd(t *testing.T) {
@@ -40,3 +44,45 @@ func TestCompletionCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestKonfDirCompletion(t *testing.T) {
+	// completing runs the initialization of the root command, which replaces the config with the one from the home
+	// directory. Overriding nothing still restores the current one afterwards
+	testhelper.OverrideConfig(t, func(*config.Config) {})
+	t.Cleanup(func() {
+		konfDir = ""
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+	})
+
+	tt := map[string]struct {
+		args         []string
+		expDirective cobra.ShellCompDirective
+	}{
+		"flag value": {
+			[]string{"set", "--konf-dir", ""},
+			cobra.ShellCompDirectiveFilterDirs,
+		},
+		"konf id after the flag": {
+			[]string{"set", "--konf-dir", t.TempDir(), ""},
+			cobra.ShellCompDirectiveNoFileComp,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			rootCmd.SetOut(&out)
+			rootCmd.SetArgs(append([]string{cobra.ShellCompRequestCmd}, tc.args...))
+
+			err := rootCmd.Execute()
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			exp := fmt.Sprintf(":%d\n", tc.expDirective)
+			if !strings.HasSuffix(out.String(), exp) {
+				t.Errorf("Exp completion to end with directive %q, got %q", exp, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/tools/clientcmd"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// checkKubectlContext loads konf the same way kubectl does and makes sure it agrees with konf on the current context.
+// konf decodes kubeconfigs leniently, which is why a subtly malformed file might look fine to konf, while kubectl
+// ends up with a different or unusable context
+func checkKubectlContext(konf []byte) error {
+	var conf k8s.Config
+	err := yaml.Unmarshal(konf, &conf)
+	if err != nil {
+		return err
+	}
+
+	loaded, err := clientcmd.Load(konf)
+	if err != nil {
+		return fmt.Errorf("kubectl cannot load the konf: %v", err)
+	}
+	if loaded.CurrentContext != conf.CurrentContext {
+		return fmt.Errorf("kubectl uses the context %q, but konf has set %q", loaded.CurrentContext, conf.CurrentContext)
+	}
+	_, err = clientcmd.NewDefaultClientConfig(*loaded, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return fmt.Errorf("kubectl cannot use the context %q: %v", loaded.CurrentContext, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestCheckKubectlContext(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	valid := sm.SingleClusterSingleContextEU()
+
+	tt := map[string]struct {
+		konf   string
+		expErr error
+	}{
+		"valid": {
+			valid,
+			nil,
+		},
+		// konf decodes field names case-insensitively, while kubectl does not
+		"mismatching context": {
+			strings.Replace(valid, "current-context: dev-eu", "Current-Context: dev-eu", 1),
+			fmt.Errorf("kubectl uses the context \"\", but konf has set \"dev-eu\""),
+		},
+		"missing context": {
+			strings.Replace(valid, "current-context: dev-eu", "current-context: dev-asia", 1),
+			fmt.Errorf("kubectl cannot use the context \"dev-asia\": invalid configuration: [context was not found for specified context: dev-asia, cluster has no server defined]"),
+		},
+		"missing server": {
+			strings.Replace(valid, "server: https://10.1.1.0", "insecure-skip-tls-verify: true", 1),
+			fmt.Errorf("kubectl cannot use the context \"dev-eu\": invalid configuration: no server found for cluster \"dev-eu-1\""),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			err := checkKubectlContext([]byte(tc.konf))
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+		})
+	}
+}
+
+func TestSetCheckContext(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	valid := sm.SingleClusterSingleContextEU()
+
+	tt := map[string]struct {
+		konf   string
+		expErr error
+	}{
+		"kubectl agrees": {
+			valid,
+			nil,
+		},
+		"kubectl disagrees": {
+			strings.Replace(valid, "current-context: dev-eu", "Current-Context: dev-eu", 1),
+			fmt.Errorf("konf \"dev-eu_dev-eu-1\" has been set, but kubectl does not agree with it: kubectl uses the context \"\", but konf has set \"dev-eu\""),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := afero.NewMemMapFs()
+			afero.WriteFile(f, utils.StorePathForID("dev-eu_dev-eu-1"), []byte(tc.konf), utils.KonfPerm)
+
+			sc := newSetCommand()
+			sc.fs = f
+			sc.checkContext = true
+			err := sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+		})
+	}
+}
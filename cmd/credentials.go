@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// refreshCredentials runs the exec credential plugin of a kubeconfig once, so that cached credentials
+// are refreshed before the user runs the first command. It reports whether the kubeconfig uses an exec
+// plugin at all.
+// The plugin is invoked through the regular client-go transport, however the request never leaves konf,
+// which means the cluster does not need to be reachable for this to work
+func refreshCredentials(konf []byte, timeout time.Duration) (bool, error) {
+	rc, err := clientcmd.RESTConfigFromKubeConfig(konf)
+	if err != nil {
+		return false, err
+	}
+
+	if rc.ExecProvider == nil {
+		return false, nil
+	}
+
+	tc, err := rc.TransportConfig()
+	if err != nil {
+		return true, err
+	}
+	if tc.WrapTransport == nil {
+		return true, fmt.Errorf("could not hook into the exec credential flow")
+	}
+	rt := tc.WrapTransport(&noopRoundTripper{})
+
+	req, err := http.NewRequest(http.MethodGet, rc.Host, nil)
+	if err != nil {
+		return true, err
+	}
+
+	// client-go does not allow us to cancel a running plugin, so we have to stop waiting on it ourselves
+	done := make(chan error, 1)
+	go func() {
+		_, err := rt.RoundTrip(req)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return true, fmt.Errorf("exec credential plugin %q failed: %v", rc.ExecProvider.Command, err)
+		}
+		return true, nil
+	case <-time.After(timeout):
+		return true, fmt.Errorf("exec credential plugin %q did not finish within %s", rc.ExecProvider.Command, timeout)
+	}
+}
+
+// oidcLogin makes sure the user is logged in to the OIDC provider of a kubeconfig, so the first command after a switch
+// does not fail or stop for a login. It reports whether the kubeconfig uses OIDC at all.
+// For exec plugins like kubelogin, the plugin is run, which starts its login flow if the refresh token has expired.
+// The legacy oidc auth-provider cannot be logged in from konf, which is why only an expired id-token is reported
+func oidcLogin(konf []byte, timeout time.Duration) (bool, error) {
+	var conf k8s.Config
+	err := yaml.Unmarshal(konf, &conf)
+	if err != nil {
+		return false, err
+	}
+	if len(conf.Contexts) == 0 {
+		return false, nil
+	}
+	var user k8s.AuthInfo
+	for _, u := range conf.AuthInfos {
+		if u.Name == conf.Contexts[0].Context.AuthInfo {
+			user = u.AuthInfo
+			break
+		}
+	}
+
+	switch {
+	case user.Exec != nil && isOIDCExec(user.Exec):
+		return refreshCredentials(konf, timeout)
+	case user.AuthProvider != nil && user.AuthProvider.Name == "oidc":
+		exp, err := tokenExpiry(user.AuthProvider.Config["id-token"])
+		if err != nil {
+			return true, err
+		}
+		if clock.Now().After(exp) {
+			return true, fmt.Errorf("the id-token has expired on %s. Please log in to %q again", exp.Format(time.RFC3339), user.AuthProvider.Config["idp-issuer-url"])
+		}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// isOIDCExec reports whether an exec plugin is one of the common OIDC login plugins
+func isOIDCExec(e *k8s.ExecConfig) bool {
+	cmd := filepath.Base(e.Command)
+	if cmd == "kubelogin" || cmd == "kubectl-oidc_login" {
+		return true
+	}
+	for _, a := range e.Args {
+		if a == "oidc-login" {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenExpiry returns the expiry of a JWT. The signature is not verified, as the token is only inspected
+func tokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("the id-token is not a valid JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not decode the id-token: %v", err)
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not decode the id-token: %v", err)
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// noopRoundTripper answers every request without sending it anywhere
+type noopRoundTripper struct{}
+
+func (*noopRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/simontheleg/konf-go/testhelper"
+)
+
+func execKonf(command string, args ...string) []byte {
+	argLines := ""
+	for _, a := range args {
+		argLines += fmt.Sprintf("\n          - %q", a)
+	}
+
+	return []byte(fmt.Sprintf(`
+apiVersion: v1
+clusters:
+  - cluster:
+      server: https://10.1.1.0
+    name: dev-eu-1
+contexts:
+  - context:
+      cluster: dev-eu-1
+      user: dev-eu
+    name: dev-eu
+current-context: dev-eu
+kind: Config
+users:
+  - name: dev-eu
+    user:
+      exec:
+        apiVersion: client.authentication.k8s.io/v1beta1
+        command: %s
+        args:%s
+`, command, argLines))
+}
+
+func TestRefreshCredentials(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	validCred := `echo '{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","status":{"token":"my-token"}}'`
+
+	tt := map[string]struct {
+		konf    []byte
+		timeout time.Duration
+		expUsed bool
+		expErr  error
+	}{
+		"no exec plugin": {
+			[]byte(sm.SingleClusterSingleContextEU()),
+			time.Second,
+			false,
+			nil,
+		},
+		"plugin succeeds": {
+			execKonf("sh", "-c", validCred),
+			5 * time.Second,
+			true,
+			nil,
+		},
+		"plugin fails": {
+			execKonf("sh", "-c", "exit 1"),
+			5 * time.Second,
+			true,
+			fmt.Errorf("exec credential plugin \"sh\" failed: getting credentials: exec: executable sh failed with exit code 1"),
+		},
+		"plugin times out": {
+			execKonf("sh", "-c", "sleep 1"),
+			50 * time.Millisecond,
+			true,
+			fmt.Errorf("exec credential plugin \"sh\" did not finish within 50ms"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			used, err := refreshCredentials(tc.konf, tc.timeout)
+
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			if used != tc.expUsed {
+				t.Errorf("Exp used to be %t, got %t", tc.expUsed, used)
+			}
+		})
+	}
+}
+
+func oidcProviderKonf(exp time.Time) []byte {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"iss":"https://idp.example.com","exp":%d}`, exp.Unix())))
+	return []byte(fmt.Sprintf(`
+apiVersion: v1
+clusters:
+  - cluster:
+      server: https://10.1.1.0
+    name: dev-eu-1
+contexts:
+  - context:
+      cluster: dev-eu-1
+      user: dev-eu
+    name: dev-eu
+current-context: dev-eu
+kind: Config
+users:
+  - name: dev-eu
+    user:
+      auth-provider:
+        name: oidc
+        config:
+          idp-issuer-url: https://idp.example.com
+          id-token: eyJhbGciOiJSUzI1NiJ9.%s.c2lnbmF0dXJl
+`, payload))
+}
+
+func TestOIDCLogin(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	validCred := `echo '{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","status":{"token":"my-token"}}'`
+	expired := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tt := map[string]struct {
+		konf    []byte
+		expUsed bool
+		expErr  error
+	}{
+		"no oidc": {
+			[]byte(sm.SingleClusterSingleContextEU()),
+			false,
+			nil,
+		},
+		"exec plugin without oidc": {
+			execKonf("sh", "-c", "exit 1"),
+			false,
+			nil,
+		},
+		"kubelogin plugin": {
+			execKonf("sh", "-c", validCred, "oidc-login"),
+			true,
+			nil,
+		},
+		"failing kubelogin plugin": {
+			execKonf("sh", "-c", "exit 1", "oidc-login"),
+			true,
+			fmt.Errorf("exec credential plugin \"sh\" failed: getting credentials: exec: executable sh failed with exit code 1"),
+		},
+		"valid id-token": {
+			oidcProviderKonf(time.Now().Add(time.Hour)),
+			true,
+			nil,
+		},
+		"expired id-token": {
+			oidcProviderKonf(expired),
+			true,
+			fmt.Errorf("the id-token has expired on %s. Please log in to \"https://idp.example.com\" again", expired.Local().Format(time.RFC3339)),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			used, err := oidcLogin(tc.konf, 5*time.Second)
+
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if used != tc.expUsed {
+				t.Errorf("Exp used to be %t, got %t", tc.expUsed, used)
+			}
+		})
+	}
+}
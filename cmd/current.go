@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+type currentCmd struct {
+	fs afero.Fs
+
+	// sleep is called between two polls of the active file in watch mode
+	sleep func(time.Duration)
+
+	watch     bool
+	path      bool
+	namespace bool
+	cluster   bool
+	context   bool
+	pid       int
+	interval  time.Duration
+
+	cmd *cobra.Command
+}
+
+func newCurrentCmd() *currentCmd {
+	cc := &currentCmd{
+		fs:    utils.NewFs(),
+		sleep: time.Sleep,
+	}
+
+	cc.cmd = &cobra.Command{
+		Use:   "current",
+		Short: "Print the konf that is active in the current shell",
+		Long: `Print the konf that is active in the current shell
+
+With --watch the active file is followed and the konf is printed whenever it changes, one line per change.
+This is meant to be consumed by prompt tools. As these are not called through the konf shellwrapper, the
+pid of the shell has to be supplied explicitly in that case.
+
+Examples:
+	-> 'current' print the id of the active konf
+	-> 'konf-go current --watch --pid $$' follow the active konf of the current shell
+	-> 'konf-go current --path --pid $$' print the path of the active konf of the current shell
+	-> 'konf-go current --namespace --pid $$' print only the namespace of the active konf, e.g. for a prompt
+
+The name of the active konf can be customized via activeFileTemplate in the config file. It is a Go
+template, which supports .PID, .User and .Host and has to contain .PID, e.g. '{{.User}}-{{.PID}}'.
+`,
+		Args: cobra.NoArgs,
+		RunE: cc.current,
+	}
+
+	cc.cmd.Flags().BoolVarP(&cc.watch, "watch", "w", false, "keep running and print the konf whenever it changes")
+	cc.cmd.Flags().BoolVar(&cc.path, "path", false, "print the path of the active konf instead of its id. The path is printed even if no konf has been set yet")
+	cc.cmd.Flags().BoolVar(&cc.namespace, "namespace", false, "print the namespace of the active konf instead of its id. Prints an empty line if the konf does not set a namespace")
+	cc.cmd.Flags().BoolVar(&cc.cluster, "cluster", false, "print the cluster of the active konf instead of its id")
+	cc.cmd.Flags().BoolVar(&cc.context, "context", false, "print the context of the active konf instead of its id")
+	cc.cmd.Flags().IntVar(&cc.pid, "pid", os.Getppid(), "pid of the shell whose active konf should be printed")
+	cc.cmd.Flags().DurationVar(&cc.interval, "interval", 500*time.Millisecond, "how often the active file is checked for changes in watch mode")
+
+	return cc
+}
+
+func (c *currentCmd) current(cmd *cobra.Command, args []string) error {
+	path := utils.ActivePathForPID(c.pid)
+
+	fields := 0
+	for _, set := range []bool{c.path, c.namespace, c.cluster, c.context} {
+		if set {
+			fields++
+		}
+	}
+	if fields > 1 {
+		return fmt.Errorf("please use only one of --path, --namespace, --cluster or --context")
+	}
+
+	if c.path {
+		if c.watch {
+			return fmt.Errorf("--path cannot be combined with --watch")
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), path)
+		return nil
+	}
+
+	if c.watch {
+		if fields > 0 {
+			return fmt.Errorf("--namespace, --cluster and --context cannot be combined with --watch")
+		}
+		return c.watchKonf(cmd, path)
+	}
+
+	if fields > 0 {
+		return c.printField(cmd, path)
+	}
+
+	id, err := activeKonfIDFromFile(c.fs, path)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("no konf is active in this shell. Please run 'konf set' first")
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), id)
+
+	return nil
+}
+
+// printField prints a single field of the context of the active konf
+func (c *currentCmd) printField(cmd *cobra.Command, path string) error {
+	b, err := afero.ReadFile(c.fs, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no konf is active in this shell. Please run 'konf set' first")
+	}
+	if err != nil {
+		return err
+	}
+	var conf k8s.Config
+	err = yaml.Unmarshal(b, &conf)
+	if err != nil {
+		return err
+	}
+
+	// a konf only contains a single context, unless it has been imported with --split-by cluster
+	var ctx *k8s.NamedContext
+	for i, nc := range conf.Contexts {
+		if nc.Name == conf.CurrentContext {
+			ctx = &conf.Contexts[i]
+		}
+	}
+	if ctx == nil {
+		return fmt.Errorf("the active konf at %q does not contain its current-context %q", path, conf.CurrentContext)
+	}
+
+	switch {
+	case c.namespace:
+		fmt.Fprintln(cmd.OutOrStdout(), ctx.Context.Namespace)
+	case c.cluster:
+		fmt.Fprintln(cmd.OutOrStdout(), ctx.Context.Cluster)
+	case c.context:
+		fmt.Fprintln(cmd.OutOrStdout(), ctx.Name)
+	}
+	return nil
+}
+
+// watchKonf polls the active file and prints the konf id on each change. It waits for the file to be
+// created and returns once the file is removed
+func (c *currentCmd) watchKonf(cmd *cobra.Command, path string) error {
+	var last string
+	for {
+		id, err := activeKonfIDFromFile(c.fs, path)
+		// konf overwrites active files in place, so we might catch a partial write. We simply try again on the next poll
+		if err == nil {
+			if id == "" && last != "" {
+				return nil
+			}
+			if id != last {
+				fmt.Fprintln(cmd.OutOrStdout(), id)
+				last = id
+			}
+		}
+		c.sleep(c.interval)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(newCurrentCmd().cmd)
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestCurrent(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	var activeFor = func(pid, konf string) func(afero.Fs) {
+		return func(f afero.Fs) {
+			afero.WriteFile(f, utils.ActivePathForID(pid), []byte(konf), utils.KonfPerm)
+		}
+	}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		path   bool
+		expOut string
+		expErr error
+	}{
+		"active konf": {
+			testhelper.FSWithFiles(fm.ActiveDir, activeFor("1234", sm.SingleClusterSingleContextEU())),
+			false,
+			"dev-eu_dev-eu-1\n",
+			nil,
+		},
+		"no active konf": {
+			testhelper.FSWithFiles(fm.ActiveDir),
+			false,
+			"",
+			fmt.Errorf("no konf is active in this shell. Please run 'konf set' first"),
+		},
+		"path": {
+			testhelper.FSWithFiles(fm.ActiveDir),
+			true,
+			"./konf/active/1234.yaml\n",
+			nil,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			cc := newCurrentCmd()
+			cc.fs = tc.fs
+			cc.pid = 1234
+			cc.path = tc.path
+			out := &bytes.Buffer{}
+			cc.cmd.SetOut(out)
+
+			err := cc.cmd.RunE(cc.cmd, []string{})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if out.String() != tc.expOut {
+				t.Errorf("Exp output %q, got %q", tc.expOut, out.String())
+			}
+		})
+	}
+}
+
+func TestCurrentField(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	var active = func(konf string) func(afero.Fs) {
+		return func(f afero.Fs) {
+			afero.WriteFile(f, utils.ActivePathForID("1234"), []byte(konf), utils.KonfPerm)
+		}
+	}
+	noNamespace := strings.Replace(sm.SingleClusterSingleContextEU(), "namespace: kube-public", "", 1)
+
+	tt := map[string]struct {
+		fs        afero.Fs
+		namespace bool
+		cluster   bool
+		context   bool
+		path      bool
+		expOut    string
+		expErr    error
+	}{
+		"namespace": {
+			testhelper.FSWithFiles(fm.ActiveDir, active(sm.SingleClusterSingleContextEU())),
+			true, false, false, false,
+			"kube-public\n",
+			nil,
+		},
+		"no namespace": {
+			testhelper.FSWithFiles(fm.ActiveDir, active(noNamespace)),
+			true, false, false, false,
+			"\n",
+			nil,
+		},
+		"cluster": {
+			testhelper.FSWithFiles(fm.ActiveDir, active(sm.SingleClusterSingleContextEU())),
+			false, true, false, false,
+			"dev-eu-1\n",
+			nil,
+		},
+		"context": {
+			testhelper.FSWithFiles(fm.ActiveDir, active(sm.SingleClusterSingleContextEU())),
+			false, false, true, false,
+			"dev-eu\n",
+			nil,
+		},
+		"no active konf": {
+			testhelper.FSWithFiles(fm.ActiveDir),
+			false, true, false, false,
+			"",
+			fmt.Errorf("no konf is active in this shell. Please run 'konf set' first"),
+		},
+		"multiple fields": {
+			testhelper.FSWithFiles(fm.ActiveDir, active(sm.SingleClusterSingleContextEU())),
+			true, false, false, true,
+			"",
+			fmt.Errorf("please use only one of --path, --namespace, --cluster or --context"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			cc := newCurrentCmd()
+			cc.fs = tc.fs
+			cc.pid = 1234
+			cc.namespace = tc.namespace
+			cc.cluster = tc.cluster
+			cc.context = tc.context
+			cc.path = tc.path
+			out := &bytes.Buffer{}
+			cc.cmd.SetOut(out)
+
+			err := cc.cmd.RunE(cc.cmd, []string{})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if out.String() != tc.expOut {
+				t.Errorf("Exp output %q, got %q", tc.expOut, out.String())
+			}
+		})
+	}
+}
+
+func TestCurrentWatch(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	path := utils.ActivePathForID("1234")
+
+	var write = func(konf string) func(afero.Fs) {
+		return func(f afero.Fs) { afero.WriteFile(f, path, []byte(konf), utils.KonfPerm) }
+	}
+	var remove = func(f afero.Fs) { f.Remove(path) }
+	var noop = func(afero.Fs) {}
+
+	tt := map[string]struct {
+		// each step is executed in between two polls
+		steps  []func(afero.Fs)
+		expOut string
+	}{
+		"waits for the file and exits on removal": {
+			[]func(afero.Fs){noop, write(sm.SingleClusterSingleContextEU()), remove},
+			"dev-eu_dev-eu-1\n",
+		},
+		"prints one line per change": {
+			[]func(afero.Fs){
+				write(sm.SingleClusterSingleContextEU()),
+				write(sm.SingleClusterSingleContextEU()),
+				write(sm.SingleClusterSingleContextASIA()),
+				write(sm.SingleClusterSingleContextEU()),
+				remove,
+			},
+			"dev-eu_dev-eu-1\ndev-asia_dev-asia-1\ndev-eu_dev-eu-1\n",
+		},
+		"skips partial writes": {
+			[]func(afero.Fs){write(sm.SingleClusterSingleContextEU()), write("I am no valid yaml"), write(sm.SingleClusterSingleContextEU()), remove},
+			"dev-eu_dev-eu-1\n",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := afero.NewMemMapFs()
+			cc := newCurrentCmd()
+			cc.fs = f
+			cc.pid = 1234
+			cc.watch = true
+			out := &bytes.Buffer{}
+			cc.cmd.SetOut(out)
+
+			polls := 0
+			cc.sleep = func(time.Duration) {
+				if polls >= len(tc.steps) {
+					t.Fatalf("watch did not exit after all steps were executed")
+				}
+				tc.steps[polls](f)
+				polls++
+			}
+
+			err := cc.cmd.RunE(cc.cmd, []string{})
+			if err != nil {
+				t.Errorf("Exp no error, got %q", err)
+			}
+			if out.String() != tc.expOut {
+				t.Errorf("Exp output %q, got %q", tc.expOut, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/manifoldco/promptui"
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/prompt"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type deleteCmd struct {
+	fs afero.Fs
+
+	promptFunc  promptFunc
+	confirmFunc prompt.ConfirmFunc
+
+	cmd *cobra.Command
+}
+
+func newDeleteCommand() *deleteCmd {
+
+	dc := &deleteCmd{
+		fs:          utils.NewFs(),
+		promptFunc:  pickerPrompt,
+		confirmFunc: prompt.TerminalConfirm,
+	}
+
+	dc.cmd = &cobra.Command{
+		Use:   `delete`,
+		Short: "Delete konfs from the store",
+		Long: `Delete konfs from the store or start picker dialogue.
+
+Deleting the konf that is currently active in your shell requires a confirmation, as your
+shell would keep on using a copy of it, which is not tracked by the store anymore.
+
+Examples:
+	-> 'delete' run konf selection
+	-> 'delete <konfig id> [<konfig id>...]' delete specific konfs
+`,
+		RunE:              dc.delete,
+		ValidArgsFunction: dc.completeDelete,
+	}
+
+	return dc
+}
+
+func (c *deleteCmd) delete(cmd *cobra.Command, args []string) error {
+	ids := args
+
+	if len(ids) == 0 {
+		id, err := selectContext(c.fs, c.promptFunc)
+		if err != nil {
+			return err
+		}
+		ids = []string{id}
+	}
+
+	active, err := activeKonfID(c.fs)
+	if err != nil {
+		// not being able to determine the active konf should not prevent the user from deleting
+		log.Warn("could not determine the active konf of this shell: %v\n", err)
+	}
+
+	for _, id := range ids {
+		if id == active {
+			p := &promptui.Prompt{
+				Label:     fmt.Sprintf("Konf %q is currently active in this shell. Your shell will keep on using an orphaned copy of it. Delete anyway", id),
+				IsConfirm: true,
+				Stdout:    os.Stderr,
+			}
+			ok, err := c.confirmFunc(p)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				log.Info("Skipping deletion of konf %q\n", id)
+				continue
+			}
+		}
+
+		err := deleteKonf(c.fs, id)
+		if err != nil {
+			return err
+		}
+		log.Info("Deleted konf %q\n", id)
+	}
+
+	return nil
+}
+
+func (c *deleteCmd) completeDelete(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	konfs, err := fetchKonfs(c.fs)
+	if err != nil {
+		// if the store is just empty, return no suggestions, instead of throwing an error
+		if errors.Is(err, &EmptyStore{}) {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	active, err := activeKonfID(c.fs)
+	if err != nil {
+		cobra.CompDebugln(err.Error(), true)
+	}
+
+	// ids that have already been supplied should not be suggested again
+	supplied := map[string]bool{}
+	for _, a := range args {
+		supplied[a] = true
+	}
+
+	sug := []string{}
+	for _, konf := range konfs {
+		id := utils.IDFromClusterAndContext(konf.Cluster, konf.Context)
+		if supplied[id] {
+			continue
+		}
+		if id == active {
+			// cobra uses tabs to separate the completion from its description
+			id += "\tcurrently active in this shell"
+		}
+		sug = append(sug, id)
+	}
+
+	return sug, cobra.ShellCompDirectiveNoFileComp
+}
+
+// deleteKonf removes the konf with the supplied id from the store together with its metadata, so for example its
+// aliases do not point to a missing konf. Konfs of shared stores cannot be deleted
+func deleteKonf(f afero.Fs, id string) error {
+	path, err := ownStorePath(f, id)
+	if err != nil {
+		return err
+	}
+	err = f.Remove(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &KonfNotFound{ID: id}
+		}
+		return err
+	}
+
+	m, err := loadMetadata(f)
+	if err != nil {
+		return err
+	}
+	m.removeKonf(id)
+	return saveMetadata(f, m)
+}
+
+func init() {
+	rootCmd.AddCommand(newDeleteCommand().cmd)
+}
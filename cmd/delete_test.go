@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// activeEU sets the EU konf as the active konf of the current shell
+func activeEU(f afero.Fs) {
+	sm := testhelper.SampleKonfManager{}
+	afero.WriteFile(f, utils.ActivePathForID(fmt.Sprint(os.Getppid())), []byte(sm.SingleClusterSingleContextEU()), utils.KonfPerm)
+}
+
+func TestDelete(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		args       []string
+		confirm    bool
+		expErr     error
+		expDeleted []string
+		expKept    []string
+	}{
+		"inactive konf": {
+			[]string{"dev-asia_dev-asia-1"},
+			false,
+			nil,
+			[]string{"dev-asia_dev-asia-1"},
+			[]string{"dev-eu_dev-eu-1"},
+		},
+		"active konf confirmed": {
+			[]string{"dev-eu_dev-eu-1"},
+			true,
+			nil,
+			[]string{"dev-eu_dev-eu-1"},
+			[]string{"dev-asia_dev-asia-1"},
+		},
+		"active konf declined": {
+			[]string{"dev-eu_dev-eu-1", "dev-asia_dev-asia-1"},
+			false,
+			nil,
+			[]string{"dev-asia_dev-asia-1"},
+			[]string{"dev-eu_dev-eu-1"},
+		},
+		"konf does not exist": {
+			[]string{"i-dont-exist"},
+			false,
+			&KonfNotFound{ID: "i-dont-exist"},
+			[]string{},
+			[]string{"dev-eu_dev-eu-1", "dev-asia_dev-asia-1"},
+		},
+		"picker": {
+			[]string{},
+			false,
+			nil,
+			[]string{"dev-asia_dev-asia-1"},
+			[]string{"dev-eu_dev-eu-1"},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, activeEU,
+				metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    note: eu\n  dev-asia_dev-asia-1:\n    favorite: true\n    env:\n      AWS_PROFILE: asia\ntoggle:\n- dev-eu_dev-eu-1\n- dev-asia_dev-asia-1\naliases:\n  eu: dev-eu_dev-eu-1\n  asia: dev-asia_dev-asia-1\n"))
+
+			dcmd := newDeleteCommand()
+			dcmd.fs = f
+			dcmd.promptFunc = func(*promptui.Select) (int, error) { return 0, nil }
+			dcmd.confirmFunc = func(*promptui.Prompt) (bool, error) { return tc.confirm, nil }
+			cmd := dcmd.cmd
+
+			err := cmd.RunE(cmd, tc.args)
+			if !testhelper.EqualError(tc.expErr, err) {
+				t.Errorf("Exp error %q, got %q", tc.expErr, err)
+			}
+
+			for _, id := range tc.expDeleted {
+				if _, err := f.Stat(utils.StorePathForID(id)); err == nil {
+					t.Errorf("Exp konf %q to be deleted, but it still exists", id)
+				}
+			}
+			for _, id := range tc.expKept {
+				if _, err := f.Stat(utils.StorePathForID(id)); err != nil {
+					t.Errorf("Exp konf %q to still exist, but got %q", id, err)
+				}
+			}
+
+			m, err := loadMetadata(f)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			targets := map[string]bool{}
+			for _, id := range m.Aliases {
+				targets[id] = true
+			}
+			for _, id := range tc.expDeleted {
+				if _, ok := m.Konfs[id]; ok || targets[id] || len(m.Toggle) != 0 {
+					t.Errorf("Exp all metadata of konf %q to be deleted, got %v", id, m)
+				}
+			}
+			for _, id := range tc.expKept {
+				if _, ok := m.Konfs[id]; !ok || !targets[id] {
+					t.Errorf("Exp metadata of konf %q to be kept, got %v", id, m)
+				}
+			}
+		})
+	}
+}
+
+func TestCompleteDelete(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs           afero.Fs
+		args         []string
+		expComp      []string
+		expCompDirec cobra.ShellCompDirective
+	}{
+		"active konf is annotated": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextASIA, fm.SingleClusterSingleContextEU, activeEU),
+			[]string{},
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1\tcurrently active in this shell"},
+			cobra.ShellCompDirectiveNoFileComp,
+		},
+		"already supplied ids are left out": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextASIA, fm.SingleClusterSingleContextEU),
+			[]string{"dev-asia_dev-asia-1"},
+			[]string{"dev-eu_dev-eu-1"},
+			cobra.ShellCompDirectiveNoFileComp,
+		},
+		"no results": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			[]string{},
+			[]string{},
+			cobra.ShellCompDirectiveNoFileComp,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			dcmd := newDeleteCommand()
+			dcmd.fs = tc.fs
+
+			res, compdirec := dcmd.completeDelete(dcmd.cmd, tc.args, "")
+
+			if !cmp.Equal(res, tc.expComp) {
+				t.Errorf("Exp and given comps differ: \n '%s'", cmp.Diff(tc.expComp, res))
+			}
+
+			if compdirec != tc.expCompDirec {
+				t.Errorf("Exp compdirec %q, got %q", tc.expCompDirec, compdirec)
+			}
+		})
+	}
+}
@@ -0,0 +1,532 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/config"
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/prompt"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// doctorCheck describes a single check that is run by 'konf doctor'. A check returns one finding per issue
+// it has detected. Findings are only reported and never fatal
+type doctorCheck struct {
+	name string
+	run  func(afero.Fs) ([]string, error)
+	// fix repairs all issues the check detects and returns a description of each repair. Checks without
+	// a fix can only be reported
+	fix func(afero.Fs) ([]string, error)
+	// destructive fixes remove or rename files the user might still care about, which is why they need to be confirmed
+	destructive bool
+}
+
+var doctorChecks = []doctorCheck{
+	{name: "shared certificate authorities", run: checkSharedCA},
+	{name: "undefined users", run: checkUndefinedUsers},
+	{name: "unknown fields", run: checkUnknownFields},
+	{name: "dangling latest konf", run: checkDanglingLatestKonf, fix: fixDanglingLatestKonf},
+	{name: "orphaned active konfs", run: checkOrphanedActiveKonfs, fix: fixOrphanedActiveKonfs},
+	{name: "active konfs missing from the store", run: checkMissingActiveKonfs},
+	{name: "junk files", run: checkJunkFiles, fix: fixJunkFiles, destructive: true},
+	{name: "mismatched file names", run: checkMismatchedFileNames, fix: fixMismatchedFileNames, destructive: true},
+}
+
+type doctorCmd struct {
+	fs afero.Fs
+
+	checks      []doctorCheck
+	confirmFunc prompt.ConfirmFunc
+
+	fix bool
+	yes bool
+
+	cmd *cobra.Command
+}
+
+func newDoctorCmd() *doctorCmd {
+	dc := &doctorCmd{
+		fs:          utils.NewFs(),
+		checks:      doctorChecks,
+		confirmFunc: prompt.TerminalConfirm,
+	}
+
+	dc.cmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the konf store for common issues",
+		Long: `Check the konf store for common issues
+
+All findings are hints, which can also be ignored. Some issues can be repaired automatically
+by running 'konf doctor --fix'. Fixes that delete or rename files in the store ask for confirmation first.`,
+		Args: cobra.NoArgs,
+		RunE: dc.doctor,
+	}
+
+	dc.cmd.Flags().BoolVar(&dc.fix, "fix", false, "repair all issues that can be repaired automatically")
+	dc.cmd.Flags().BoolVarP(&dc.yes, "yes", "y", false, "do not ask for confirmation before applying fixes that delete or rename files")
+
+	return dc
+}
+
+func (c *doctorCmd) doctor(cmd *cobra.Command, args []string) error {
+	total := 0
+	for _, check := range c.checks {
+		findings, err := check.run(c.fs)
+		if err != nil {
+			return fmt.Errorf("could not run check %q: %v", check.name, err)
+		}
+		for _, f := range findings {
+			log.Warn("%s: %s\n", check.name, f)
+		}
+		total += len(findings)
+
+		if c.fix && check.fix != nil && len(findings) > 0 {
+			err := c.applyFix(check, len(findings))
+			if err != nil {
+				return fmt.Errorf("could not fix %q: %v", check.name, err)
+			}
+		}
+	}
+
+	if total == 0 {
+		log.Info("No issues found\n")
+		return nil
+	}
+	log.Info("Found %d potential issues\n", total)
+
+	return nil
+}
+
+// applyFix runs the fix of check. Destructive fixes are only applied after the user has confirmed them
+func (c *doctorCmd) applyFix(check doctorCheck, n int) error {
+	if check.destructive && !c.yes {
+		p := &promptui.Prompt{
+			Label:     fmt.Sprintf("Fix %d issues of %q", n, check.name),
+			IsConfirm: true,
+			Stdout:    os.Stderr,
+		}
+		ok, err := c.confirmFunc(p)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Info("Skipping fix of %q\n", check.name)
+			return nil
+		}
+	}
+
+	fixes, err := check.fix(c.fs)
+	if err != nil {
+		return err
+	}
+	for _, f := range fixes {
+		log.Info("%s: %s\n", check.name, f)
+	}
+	return nil
+}
+
+// readStore returns the parsed content of all konfs in the store
+func readStore(f afero.Fs) ([]*konfFile, error) {
+	konfs, err := fetchKonfs(f)
+	if err != nil {
+		return nil, err
+	}
+
+	kfs := []*konfFile{}
+	for _, k := range konfs {
+		b, err := readStoreFile(f, k.File)
+		if err != nil {
+			return nil, err
+		}
+		kf := &konfFile{FilePath: k.File}
+		err = yaml.Unmarshal(b, &kf.Content)
+		if err != nil {
+			return nil, err
+		}
+		kfs = append(kfs, kf)
+	}
+
+	return kfs, nil
+}
+
+func checkSharedCA(f afero.Fs) ([]string, error) {
+	kfs, err := readStore(f)
+	if errors.Is(err, &EmptyStore{}) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sharedCAFindings(kfs), nil
+}
+
+// sharedCAFindings detects clusters that use the same certificate authority, but point to different servers.
+// This is a common sign of a misconfiguration, for example when using bastion hosts
+func sharedCAFindings(kfs []*konfFile) []string {
+	type sighting struct {
+		clusters []string
+		servers  map[string]bool
+	}
+	byCA := map[string]*sighting{}
+	cas := []string{}
+
+	for _, kf := range kfs {
+		for _, cl := range kf.Content.Clusters {
+			ca := caIdentity(cl.Cluster)
+			if ca == "" {
+				continue
+			}
+			s, ok := byCA[ca]
+			if !ok {
+				s = &sighting{servers: map[string]bool{}}
+				byCA[ca] = s
+				cas = append(cas, ca)
+			}
+			s.clusters = append(s.clusters, cl.Name)
+			s.servers[cl.Cluster.Server] = true
+		}
+	}
+
+	findings := []string{}
+	for _, ca := range cas {
+		s := byCA[ca]
+		if len(s.servers) < 2 {
+			continue
+		}
+		servers := []string{}
+		for srv := range s.servers {
+			servers = append(servers, srv)
+		}
+		sort.Strings(servers)
+		findings = append(findings, fmt.Sprintf("clusters %q share the same certificate authority, but point to different servers %q", s.clusters, servers))
+	}
+
+	return findings
+}
+
+func checkUndefinedUsers(f afero.Fs) ([]string, error) {
+	kfs, err := readStore(f)
+	if errors.Is(err, &EmptyStore{}) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return undefinedUserFindings(kfs), nil
+}
+
+// undefinedUserFindings detects konfs whose context references a user that is not part of the konf itself.
+// Every konf should carry its own credentials, so it can be revoked independently
+func undefinedUserFindings(kfs []*konfFile) []string {
+	findings := []string{}
+	for _, kf := range kfs {
+		for _, ctx := range kf.Content.Contexts {
+			defined := false
+			for _, u := range kf.Content.AuthInfos {
+				if u.Name == ctx.Context.AuthInfo {
+					defined = true
+					break
+				}
+			}
+			if !defined {
+				findings = append(findings, fmt.Sprintf("konf %q references the user %q, which is not defined in it. Please re-import it", kf.FilePath, ctx.Context.AuthInfo))
+			}
+		}
+	}
+	return findings
+}
+
+// checkUnknownFields validates all konfs in the store strictly. konf itself ignores unknown fields, so a typo in
+// a hand-edited konf would otherwise only show up as missing data
+func checkUnknownFields(f afero.Fs) ([]string, error) {
+	konfs, err := fetchKonfs(f)
+	if errors.Is(err, &EmptyStore{}) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []string{}
+	for _, k := range konfs {
+		b, err := readStoreFile(f, k.File)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateKubeconfig(b); err != nil {
+			findings = append(findings, fmt.Sprintf("konf %q contains an unknown or misspelled field: %v", k.File, err))
+		}
+	}
+	return findings, nil
+}
+
+// danglingLatestKonf returns the id of the latest konf if it is not in the store anymore
+func danglingLatestKonf(f afero.Fs) (string, error) {
+	b, err := afero.ReadFile(f, config.LatestKonfFile())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	id := string(b)
+	_, err = f.Stat(utils.ResolveStorePath(f, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return id, nil
+	}
+	return "", err
+}
+
+func checkDanglingLatestKonf(f afero.Fs) ([]string, error) {
+	id, err := danglingLatestKonf(f)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("the latest konf %q is not in the store anymore, so 'konf set -' will fail", id)}, nil
+}
+
+func fixDanglingLatestKonf(f afero.Fs) ([]string, error) {
+	id, err := danglingLatestKonf(f)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	err = f.Remove(config.LatestKonfFile())
+	if err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("cleared the latest konf %q", id)}, nil
+}
+
+// orphanedActiveKonfs returns the paths of all active konfs, whose shell is not running anymore
+func orphanedActiveKonfs(f afero.Fs) ([]string, error) {
+	files, err := afero.ReadDir(f, config.ActiveDir())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, file := range files {
+		pid, ok := utils.PIDFromActiveFile(file.Name())
+		if !ok {
+			continue
+		}
+		alive, err := processAlive(pid)
+		if err != nil {
+			return nil, err
+		}
+		if !alive {
+			paths = append(paths, utils.ActivePathForPID(pid))
+		}
+	}
+	return paths, nil
+}
+
+func checkOrphanedActiveKonfs(f afero.Fs) ([]string, error) {
+	paths, err := orphanedActiveKonfs(f)
+	if err != nil {
+		return nil, err
+	}
+	findings := []string{}
+	for _, p := range paths {
+		findings = append(findings, fmt.Sprintf("active konf %q belongs to a shell that is not running anymore", p))
+	}
+	return findings, nil
+}
+
+func fixOrphanedActiveKonfs(f afero.Fs) ([]string, error) {
+	paths, err := orphanedActiveKonfs(f)
+	if err != nil {
+		return nil, err
+	}
+	return removeFiles(f, paths)
+}
+
+// checkMissingActiveKonfs reports all running shells, whose active konf is not in the store anymore. Only the user
+// can switch another shell to a different konf, which is why there is no fix
+func checkMissingActiveKonfs(f afero.Fs) ([]string, error) {
+	pids, err := liveShells(f, processAlive)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []string{}
+	for _, pid := range pids {
+		id, err := activeKonfIDFromFile(f, utils.ActivePathForPID(pid))
+		// a broken active konf cannot be traced back to the store at all
+		if err != nil || id == "" {
+			continue
+		}
+		if _, err := f.Stat(utils.ResolveStorePath(f, id)); err == nil {
+			continue
+		}
+		findings = append(findings, fmt.Sprintf("shell %d uses konf %q, which is not in the store. Please run 'konf set' in this shell again, unless the konf has been set via --file or --from-clipboard", pid, id))
+	}
+	return findings, nil
+}
+
+// junkFileNames contains files that are created by operating systems and are never konfs
+var junkFileNames = map[string]bool{
+	"Thumbs.db":   true,
+	"desktop.ini": true,
+}
+
+// junkFiles returns the paths of all files in the store, that are ignored by konf, like .DS_Store
+func junkFiles(f afero.Fs) ([]string, error) {
+	files, err := afero.ReadDir(f, config.StoreDir())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(file.Name(), ".") || junkFileNames[file.Name()] {
+			paths = append(paths, config.StoreDir()+"/"+file.Name())
+		}
+	}
+	return paths, nil
+}
+
+func checkJunkFiles(f afero.Fs) ([]string, error) {
+	paths, err := junkFiles(f)
+	if err != nil {
+		return nil, err
+	}
+	findings := []string{}
+	for _, p := range paths {
+		findings = append(findings, fmt.Sprintf("file %q is not a konf and ignored", p))
+	}
+	return findings, nil
+}
+
+func fixJunkFiles(f afero.Fs) ([]string, error) {
+	paths, err := junkFiles(f)
+	if err != nil {
+		return nil, err
+	}
+	return removeFiles(f, paths)
+}
+
+func removeFiles(f afero.Fs, paths []string) ([]string, error) {
+	fixes := []string{}
+	for _, p := range paths {
+		err := f.Remove(p)
+		if err != nil {
+			return fixes, err
+		}
+		fixes = append(fixes, fmt.Sprintf("removed %q", p))
+	}
+	return fixes, nil
+}
+
+// mismatch describes a konf whose file name does not match the id of its content
+type mismatch struct {
+	fileID    string
+	contentID string
+}
+
+// mismatchedFileNames returns all konfs in the own store, whose file name does not match the id of their content.
+// Konfs of shared stores cannot be renamed by konf, which is why they are left alone
+func mismatchedFileNames(f afero.Fs) ([]mismatch, error) {
+	kfs, err := readStore(f)
+	if errors.Is(err, &EmptyStore{}) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mms := []mismatch{}
+	for _, kf := range kfs {
+		// konfs with multiple contexts are reported on every usage anyway, so we leave them alone here
+		if len(kf.Content.Contexts) != 1 || !utils.IsStorePath(kf.FilePath) {
+			continue
+		}
+		contentID, err := idFromKonf(&kf.Content)
+		if err != nil {
+			continue
+		}
+		fileID := kf.ID()
+		if fileID != contentID {
+			mms = append(mms, mismatch{fileID: fileID, contentID: contentID})
+		}
+	}
+	return mms, nil
+}
+
+func checkMismatchedFileNames(f afero.Fs) ([]string, error) {
+	mms, err := mismatchedFileNames(f)
+	if err != nil {
+		return nil, err
+	}
+	findings := []string{}
+	for _, mm := range mms {
+		findings = append(findings, fmt.Sprintf("konf %q contains the context of konf %q", mm.fileID, mm.contentID))
+	}
+	return findings, nil
+}
+
+// fixMismatchedFileNames renames konfs after their content. Konfs whose new name is already taken are skipped
+func fixMismatchedFileNames(f afero.Fs) ([]string, error) {
+	mms, err := mismatchedFileNames(f)
+	if err != nil {
+		return nil, err
+	}
+	m, err := loadMetadata(f)
+	if err != nil {
+		return nil, err
+	}
+
+	fixes := []string{}
+	for _, mm := range mms {
+		// a konf of a shared store would be shadowed by the renamed one
+		if _, err := f.Stat(utils.ResolveStorePath(f, mm.contentID)); err == nil {
+			log.Warn("could not rename konf %q to %q, because it already exists\n", mm.fileID, mm.contentID)
+			continue
+		}
+		err = f.Rename(utils.StorePathForID(mm.fileID), utils.StorePathForID(mm.contentID))
+		if err != nil {
+			return fixes, err
+		}
+		m.renameKonf(mm.fileID, mm.contentID)
+		fixes = append(fixes, fmt.Sprintf("renamed konf %q to %q", mm.fileID, mm.contentID))
+	}
+
+	return fixes, saveMetadata(f, m)
+}
+
+// caIdentity returns a string that identifies the certificate authority of a cluster
+// Clusters without a certificate authority return an empty string
+func caIdentity(c k8s.Cluster) string {
+	if len(c.CertificateAuthorityData) != 0 {
+		return "data:" + string(bytes.TrimSpace(c.CertificateAuthorityData))
+	}
+	if c.CertificateAuthority != "" {
+		return "file:" + strings.TrimSpace(c.CertificateAuthority)
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(newDoctorCmd().cmd)
+}
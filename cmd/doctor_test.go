@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+)
+
+func TestDoctor(t *testing.T) {
+	var findingCheck = doctorCheck{
+		name: "finding check",
+		run:  func(afero.Fs) ([]string, error) { return []string{"something is off"}, nil },
+	}
+	var failingCheck = doctorCheck{
+		name: "failing check",
+		run:  func(afero.Fs) ([]string, error) { return nil, fmt.Errorf("big bad error") },
+	}
+
+	tt := map[string]struct {
+		checks []doctorCheck
+		expErr error
+	}{
+		"no checks": {
+			[]doctorCheck{},
+			nil,
+		},
+		"findings are not fatal": {
+			[]doctorCheck{findingCheck},
+			nil,
+		},
+		"failing check": {
+			[]doctorCheck{findingCheck, failingCheck},
+			fmt.Errorf("could not run check \"failing check\": big bad error"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			dc := newDoctorCmd()
+			dc.fs = afero.NewMemMapFs()
+			dc.checks = tc.checks
+
+			err := dc.cmd.RunE(dc.cmd, []string{})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+		})
+	}
+}
+
+func TestDoctorFix(t *testing.T) {
+	var fixCheck = func(destructive bool, fixed *bool) doctorCheck {
+		return doctorCheck{
+			name:        "fixable check",
+			run:         func(afero.Fs) ([]string, error) { return []string{"something is off"}, nil },
+			fix:         func(afero.Fs) ([]string, error) { *fixed = true; return []string{"fixed it"}, nil },
+			destructive: destructive,
+		}
+	}
+
+	tt := map[string]struct {
+		fix         bool
+		yes         bool
+		destructive bool
+		confirm     bool
+		expFixed    bool
+	}{
+		"report only": {
+			false, false, false, true, false,
+		},
+		"fix": {
+			true, false, false, false, true,
+		},
+		"destructive fix confirmed": {
+			true, false, true, true, true,
+		},
+		"destructive fix declined": {
+			true, false, true, false, false,
+		},
+		"destructive fix with yes": {
+			true, true, true, false, true,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			fixed := false
+			dc := newDoctorCmd()
+			dc.fs = afero.NewMemMapFs()
+			dc.checks = []doctorCheck{fixCheck(tc.destructive, &fixed)}
+			dc.fix = tc.fix
+			dc.yes = tc.yes
+			dc.confirmFunc = func(*promptui.Prompt) (bool, error) { return tc.confirm, nil }
+
+			err := dc.cmd.RunE(dc.cmd, []string{})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if fixed != tc.expFixed {
+				t.Errorf("Exp fixed to be %t, got %t", tc.expFixed, fixed)
+			}
+		})
+	}
+}
+
+func TestDoctorFixes(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	// pids are limited to 2^22 on linux, so this one can never be running
+	deadPid := "99999999"
+	alivePid := fmt.Sprint(os.Getpid())
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextASIA, func(f afero.Fs) {
+		afero.WriteFile(f, config.LatestKonfFile(), []byte("deleted_konf"), utils.KonfPerm)
+		afero.WriteFile(f, utils.ActivePathForID(deadPid), []byte{}, utils.KonfPerm)
+		afero.WriteFile(f, utils.ActivePathForID(alivePid), []byte{}, utils.KonfPerm)
+		afero.WriteFile(f, config.StoreDir()+"/.DS_Store", []byte{}, utils.KonfPerm)
+		afero.WriteFile(f, utils.StorePathForID("wrong_name"), []byte(sm.SingleClusterSingleContextEU()), utils.KonfPerm)
+	}, metadataFile("konfs:\n  wrong_name:\n    favorite: true\n"))
+
+	dc := newDoctorCmd()
+	dc.fs = f
+	dc.fix = true
+	dc.yes = true
+
+	err := dc.cmd.RunE(dc.cmd, []string{})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	gone := []string{config.LatestKonfFile(), utils.ActivePathForID(deadPid), config.StoreDir() + "/.DS_Store", utils.StorePathForID("wrong_name")}
+	for _, p := range gone {
+		if _, err := f.Stat(p); err == nil {
+			t.Errorf("Exp %q to be removed", p)
+		}
+	}
+	kept := []string{utils.ActivePathForID(alivePid), utils.StorePathForID("dev-eu_dev-eu-1"), utils.StorePathForID("dev-asia_dev-asia-1")}
+	for _, p := range kept {
+		if _, err := f.Stat(p); err != nil {
+			t.Errorf("Exp %q to exist, got %q", p, err)
+		}
+	}
+	m, err := loadMetadata(f)
+	if err != nil {
+		t.Fatalf("Could not load metadata: %q", err)
+	}
+	if !cmp.Equal(m.favorites(), []string{"dev-eu_dev-eu-1"}) {
+		t.Errorf("Exp favorite to be carried over to the renamed konf, got %q", m.favorites())
+	}
+
+	for _, check := range doctorChecks {
+		findings, err := check.run(f)
+		if err != nil || len(findings) != 0 {
+			t.Errorf("Exp no findings of %q after fixing, got %q, %v", check.name, findings, err)
+		}
+	}
+}
+
+func TestCheckSharedCA(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	var konfWithCA = func(id, cluster, server, ca string) func(afero.Fs) {
+		return func(f afero.Fs) {
+			konf := fmt.Sprintf(`
+apiVersion: v1
+clusters:
+  - cluster:
+      server: %s
+      certificate-authority-data: %s
+    name: %s
+contexts:
+  - context:
+      cluster: %s
+      user: user
+    name: context
+kind: Config
+users:
+  - name: user
+    user: {}
+`, server, ca, cluster, cluster)
+			afero.WriteFile(f, utils.StorePathForID(id), []byte(konf), utils.KonfPerm)
+		}
+	}
+
+	tt := map[string]struct {
+		fs          afero.Fs
+		expFindings int
+	}{
+		"empty store": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			0,
+		},
+		"no certificate authorities": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			0,
+		},
+		"shared ca and different servers": {
+			testhelper.FSWithFiles(fm.StoreDir, konfWithCA("context_a", "a", "https://10.0.0.1", "Y2E="), konfWithCA("context_b", "b", "https://10.0.0.2", "Y2E=")),
+			1,
+		},
+		"shared ca and same server": {
+			testhelper.FSWithFiles(fm.StoreDir, konfWithCA("context_a", "a", "https://10.0.0.1", "Y2E="), konfWithCA("context_b", "b", "https://10.0.0.1", "Y2E=")),
+			0,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			findings, err := checkSharedCA(tc.fs)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if len(findings) != tc.expFindings {
+				t.Errorf("Exp %d findings, got %d: %q", tc.expFindings, len(findings), findings)
+			}
+		})
+	}
+}
+
+func TestCheckUnknownFields(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	var typo = func(f afero.Fs) {
+		konf := strings.Replace(sm.SingleClusterSingleContextASIA(), "namespace: kube-public", "namespce: kube-public", 1)
+		afero.WriteFile(f, utils.StorePathForID("dev-asia_dev-asia-1"), []byte(konf), utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		fs          afero.Fs
+		expFindings []string
+	}{
+		"empty store": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			nil,
+		},
+		"valid konfs": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{},
+		},
+		"misspelled field": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, typo),
+			[]string{"konf \"./konf/store/dev-asia_dev-asia-1.yaml\" contains an unknown or misspelled field: unknown field \"namespce\""},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			findings, err := checkUnknownFields(tc.fs)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if !cmp.Equal(findings, tc.expFindings) {
+				t.Errorf("Exp and given findings differ: \n '%s'", cmp.Diff(tc.expFindings, findings))
+			}
+		})
+	}
+}
+
+func TestSharedCAFindings(t *testing.T) {
+	var kf = func(cluster, server, caFile string, caData []byte) *konfFile {
+		return &konfFile{Content: k8s.Config{Clusters: []k8s.NamedCluster{
+			{Name: cluster, Cluster: k8s.Cluster{Server: server, CertificateAuthority: caFile, CertificateAuthorityData: caData}},
+		}}}
+	}
+
+	tt := map[string]struct {
+		in  []*konfFile
+		exp []string
+	}{
+		"no ca": {
+			[]*konfFile{kf("a", "https://10.0.0.1", "", nil), kf("b", "https://10.0.0.2", "", nil)},
+			[]string{},
+		},
+		"different ca data": {
+			[]*konfFile{kf("a", "https://10.0.0.1", "", []byte("ca1")), kf("b", "https://10.0.0.2", "", []byte("ca2"))},
+			[]string{},
+		},
+		"shared ca data": {
+			[]*konfFile{kf("a", "https://10.0.0.1", "", []byte("ca")), kf("b", "https://10.0.0.2", "", []byte("ca"))},
+			[]string{`clusters ["a" "b"] share the same certificate authority, but point to different servers ["https://10.0.0.1" "https://10.0.0.2"]`},
+		},
+		"shared ca file": {
+			[]*konfFile{kf("a", "https://10.0.0.1", "/ca.crt", nil), kf("b", "https://10.0.0.2", "/ca.crt", nil)},
+			[]string{`clusters ["a" "b"] share the same certificate authority, but point to different servers ["https://10.0.0.1" "https://10.0.0.2"]`},
+		},
+		"shared ca and server": {
+			[]*konfFile{kf("a", "https://10.0.0.1", "", []byte("ca")), kf("b", "https://10.0.0.1", "", []byte("ca"))},
+			[]string{},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res := sharedCAFindings(tc.in)
+			if !cmp.Equal(res, tc.exp) {
+				t.Errorf("Exp and given findings differ:\n%s", cmp.Diff(tc.exp, res))
+			}
+		})
+	}
+}
+
+func TestUndefinedUserFindings(t *testing.T) {
+	var kf = func(path, user string, defined ...string) *konfFile {
+		k := &konfFile{FilePath: path}
+		k.Content.Contexts = []k8s.NamedContext{{Name: "context", Context: k8s.Context{AuthInfo: user}}}
+		for _, d := range defined {
+			k.Content.AuthInfos = append(k.Content.AuthInfos, k8s.NamedAuthInfo{Name: d})
+		}
+		return k
+	}
+
+	tt := map[string]struct {
+		in  []*konfFile
+		exp []string
+	}{
+		"defined user": {
+			[]*konfFile{kf("a.yaml", "user", "user")},
+			[]string{},
+		},
+		"undefined user": {
+			[]*konfFile{kf("a.yaml", "user", "someone-else"), kf("b.yaml", "user", "user")},
+			[]string{`konf "a.yaml" references the user "user", which is not defined in it. Please re-import it`},
+		},
+		"no users at all": {
+			[]*konfFile{kf("a.yaml", "user")},
+			[]string{`konf "a.yaml" references the user "user", which is not defined in it. Please re-import it`},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res := undefinedUserFindings(tc.in)
+			if !cmp.Equal(res, tc.exp) {
+				t.Errorf("Exp and given findings differ:\n%s", cmp.Diff(tc.exp, res))
+			}
+		})
+	}
+}
+
+func TestCheckMissingActiveKonfs(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs          afero.Fs
+		expFindings []string
+	}{
+		"active konf in store": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU, activeEU),
+			[]string{},
+		},
+		"active konf deleted from store": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextASIA, activeEU),
+			[]string{fmt.Sprintf("shell %d uses konf \"dev-eu_dev-eu-1\", which is not in the store. Please run 'konf set' in this shell again, unless the konf has been set via --file or --from-clipboard", os.Getppid())},
+		},
+		"no active konfs": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir),
+			[]string{},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			findings, err := checkMissingActiveKonfs(tc.fs)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if !cmp.Equal(findings, tc.expFindings) {
+				t.Errorf("Exp findings %q, got %q", tc.expFindings, findings)
+			}
+		})
+	}
+}
+
+func TestDoctorSharedStores(t *testing.T) {
+	testhelper.OverrideConfig(t, func(c *config.Config) { c.SharedStoreDirs = []string{"/team/store"} })
+
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, func(f afero.Fs) {
+		afero.WriteFile(f, "/team/store/dev-asia_dev-asia-1.yaml", []byte(sm.SingleClusterSingleContextASIA()), utils.KonfPerm)
+		afero.WriteFile(f, "/team/store/wrong_name.yaml", []byte(sm.SingleClusterSingleContextASIA()), utils.KonfPerm)
+		afero.WriteFile(f, config.LatestKonfFile(), []byte("dev-asia_dev-asia-1"), utils.KonfPerm)
+	})
+
+	dc := newDoctorCmd()
+	dc.fs = f
+	dc.fix = true
+	dc.yes = true
+	err := dc.cmd.RunE(dc.cmd, []string{})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	kept := []string{config.LatestKonfFile(), "/team/store/dev-asia_dev-asia-1.yaml", "/team/store/wrong_name.yaml"}
+	for _, p := range kept {
+		if _, err := f.Stat(p); err != nil {
+			t.Errorf("Exp %q to be kept, got %q", p, err)
+		}
+	}
+}
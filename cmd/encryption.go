@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedHeader marks encrypted store files. Any other file is treated as plaintext, which means a store can
+// contain both, e.g. right after encryption has been enabled
+const encryptedHeader = "konf-encrypted:v1\n"
+
+const (
+	saltLen  = 16
+	nonceLen = 24
+)
+
+var (
+	// cacheMu guards both caches, as 'konf serve' reads konfs concurrently
+	cacheMu sync.Mutex
+	// derivedKeys caches keys per passphrase and salt, as scrypt is slow on purpose
+	derivedKeys = map[string]*[32]byte{}
+	// passphrases caches the output of passphrase commands, so the user is asked at most once per run
+	passphrases = map[string]string{}
+)
+
+// readStoreFile reads the konf at path and decrypts it if necessary
+func readStoreFile(f afero.Fs, path string) ([]byte, error) {
+	b, err := afero.ReadFile(f, path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStoreFile(path, b)
+}
+
+// decodeStoreFile decrypts the content b of the konf at path if necessary
+func decodeStoreFile(path string, b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, []byte(encryptedHeader)) {
+		return b, nil
+	}
+
+	pass, err := storePassphrase()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := decryptKonf(b, pass)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt konf %q: %v", path, err)
+	}
+	return plain, nil
+}
+
+// storeFileEncrypted reports whether the konf at path is encrypted, which means it cannot be handed to kubectl as is
+func storeFileEncrypted(f afero.Fs, path string) (bool, error) {
+	b, err := afero.ReadFile(f, path)
+	if err != nil {
+		return false, err
+	}
+	return bytes.HasPrefix(b, []byte(encryptedHeader)), nil
+}
+
+// writeStoreFile writes the konf to path. It is encrypted if encryption is enabled
+func writeStoreFile(f afero.Fs, path string, b []byte) error {
+	if config.Encrypt() {
+		pass, err := storePassphrase()
+		if err != nil {
+			return err
+		}
+		salt, err := storeSalt(f)
+		if err != nil {
+			return err
+		}
+		b, err = encryptKonf(b, pass, salt)
+		if err != nil {
+			return err
+		}
+	}
+	return afero.WriteFile(f, path, b, utils.KonfPerm)
+}
+
+// encryptKonf encrypts plain with a key derived from pass and salt. The salt is stored alongside the ciphertext,
+// so each file can be decrypted on its own
+func encryptKonf(plain []byte, pass string, salt []byte) ([]byte, error) {
+	key, err := deriveKey(pass, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [nonceLen]byte
+	_, err = rand.Read(nonce[:])
+	if err != nil {
+		return nil, err
+	}
+
+	raw := append([]byte{}, salt...)
+	raw = append(raw, nonce[:]...)
+	raw = secretbox.Seal(raw, plain, &nonce, key)
+	return []byte(encryptedHeader + base64.StdEncoding.EncodeToString(raw) + "\n"), nil
+}
+
+// decryptKonf reverses encryptKonf
+func decryptKonf(b []byte, pass string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(string(b), encryptedHeader)))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < saltLen+nonceLen+secretbox.Overhead {
+		return nil, fmt.Errorf("the file is truncated")
+	}
+
+	key, err := deriveKey(pass, raw[:saltLen])
+	if err != nil {
+		return nil, err
+	}
+	var nonce [nonceLen]byte
+	copy(nonce[:], raw[saltLen:saltLen+nonceLen])
+
+	plain, ok := secretbox.Open(nil, raw[saltLen+nonceLen:], &nonce, key)
+	if !ok {
+		return nil, fmt.Errorf("wrong passphrase or corrupted file")
+	}
+	return plain, nil
+}
+
+func deriveKey(pass string, salt []byte) (*[32]byte, error) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	id := pass + "\x00" + string(salt)
+	if key, ok := derivedKeys[id]; ok {
+		return key, nil
+	}
+
+	k, err := scrypt.Key([]byte(pass), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	var key [32]byte
+	copy(key[:], k)
+	derivedKeys[id] = &key
+	return &key, nil
+}
+
+// storeSalt returns the salt that is used for all newly encrypted konfs. It is created on first use
+func storeSalt(f afero.Fs) ([]byte, error) {
+	b, err := afero.ReadFile(f, config.SaltFile())
+	if err == nil {
+		if len(b) != saltLen {
+			return nil, fmt.Errorf("the salt in %q is corrupted. Please remove it", config.SaltFile())
+		}
+		return b, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	salt := make([]byte, saltLen)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	return salt, afero.WriteFile(f, config.SaltFile(), salt, utils.KonfPerm)
+}
+
+// storePassphrase returns the passphrase for encrypted konfs from $KONF_PASSPHRASE or the configured passphrase command
+func storePassphrase() (string, error) {
+	if p := os.Getenv("KONF_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+
+	command := config.PassphraseCommand()
+	if command == "" {
+		return "", fmt.Errorf("encrypted konfs require a passphrase. Please set $KONF_PASSPHRASE or passphraseCommand in the config file")
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if p, ok := passphrases[command]; ok {
+		return p, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("could not retrieve the passphrase via %q: %v", command, err)
+	}
+	p := strings.TrimRight(string(out), "\r\n")
+	if p == "" {
+		return "", fmt.Errorf("passphrase command %q returned an empty passphrase", command)
+	}
+	passphrases[command] = p
+	return p, nil
+}
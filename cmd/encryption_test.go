@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestEncryptKonf(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	plain := []byte(sm.SingleClusterSingleContextEU())
+	salt := bytes.Repeat([]byte{1}, saltLen)
+
+	enc, err := encryptKonf(plain, "secret", salt)
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if !bytes.HasPrefix(enc, []byte(encryptedHeader)) || bytes.Contains(enc, []byte("dev-eu")) {
+		t.Errorf("Exp konf to be encrypted, got %q", enc)
+	}
+
+	dec, err := decryptKonf(enc, "secret")
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if !bytes.Equal(dec, plain) {
+		t.Errorf("Exp decrypted konf to equal the original, got %q", dec)
+	}
+
+	_, err = decryptKonf(enc, "wrong")
+	expErr := fmt.Errorf("wrong passphrase or corrupted file")
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp err %q, got %q", expErr, err)
+	}
+
+	_, err = decryptKonf([]byte(encryptedHeader+"AAAA\n"), "secret")
+	expErr = fmt.Errorf("the file is truncated")
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp err %q, got %q", expErr, err)
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	testhelper.OverrideConfig(t, func(c *config.Config) { c.Encrypt = true })
+	t.Setenv("KONF_PASSPHRASE", "secret")
+
+	// plaintext konfs from before encryption was enabled have to stay usable
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextASIA)
+	err := writeStoreFile(f, utils.StorePathForID("dev-eu_dev-eu-1"), []byte(sm.SingleClusterSingleContextEU()))
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	raw, _ := afero.ReadFile(f, utils.StorePathForID("dev-eu_dev-eu-1"))
+	if !bytes.HasPrefix(raw, []byte(encryptedHeader)) {
+		t.Errorf("Exp store file to be encrypted, got %q", raw)
+	}
+	if salt, err := afero.ReadFile(f, config.SaltFile()); err != nil || len(salt) != saltLen {
+		t.Errorf("Exp salt to be created, got %q, %v", salt, err)
+	}
+
+	konfs, err := fetchKonfs(f)
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if len(konfs) != 2 {
+		t.Errorf("Exp 2 konfs, got %d", len(konfs))
+	}
+
+	path, err := setContext("dev-eu_dev-eu-1", f)
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	active, _ := afero.ReadFile(f, path)
+	if string(active) != sm.SingleClusterSingleContextEU() {
+		t.Errorf("Exp active konf to be plaintext, got %q", active)
+	}
+
+	t.Setenv("KONF_PASSPHRASE", "wrong")
+	_, err = fetchKonfs(f)
+	expErr := fmt.Errorf("could not decrypt konf \"./konf/store/dev-eu_dev-eu-1.yaml\": wrong passphrase or corrupted file")
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp err %q, got %q", expErr, err)
+	}
+}
+
+func TestStorePassphrase(t *testing.T) {
+	tt := map[string]struct {
+		env     string
+		command string
+		expPass string
+		expErr  error
+	}{
+		"env":                  {"from-env", "echo from-command", "from-env", nil},
+		"command":              {"", "echo from-command", "from-command", nil},
+		"failing command":      {"", "exit 1", "", fmt.Errorf("could not retrieve the passphrase via \"exit 1\": exit status 1")},
+		"empty command output": {"", "true", "", fmt.Errorf("passphrase command \"true\" returned an empty passphrase")},
+		"nothing configured":   {"", "", "", fmt.Errorf("encrypted konfs require a passphrase. Please set $KONF_PASSPHRASE or passphraseCommand in the config file")},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			testhelper.OverrideConfig(t, func(c *config.Config) { c.PassphraseCommand = tc.command })
+			t.Setenv("KONF_PASSPHRASE", tc.env)
+
+			pass, err := storePassphrase()
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if pass != tc.expPass {
+				t.Errorf("Exp passphrase %q, got %q", tc.expPass, pass)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// envNameRegex matches names that every supported shell accepts as environment variable
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type envCmd struct {
+	fs afero.Fs
+
+	cmd *cobra.Command
+}
+
+func newEnvCmd() *envCmd {
+	ec := &envCmd{
+		fs: utils.NewFs(),
+	}
+
+	ec.cmd = &cobra.Command{
+		Use:   "env",
+		Short: "Add or remove environment variables of a konf",
+		Long: `Add or remove environment variables of a konf
+
+Environment variables are exported by the shellwrapper whenever the konf is set, and unset again on the
+next switch. This is useful for clusters that need additional configuration, like AWS_PROFILE or HTTPS_PROXY.
+
+Examples:
+	-> 'env <konfig id>' list the environment variables of a konf
+	-> 'env <konfig id> AWS_PROFILE=prod HTTPS_PROXY=http://proxy:3128' add or update environment variables
+	-> 'env <konfig id> HTTPS_PROXY-' remove the environment variable HTTPS_PROXY
+`,
+		Args:              cobra.MinimumNArgs(1),
+		RunE:              ec.env,
+		ValidArgsFunction: ec.completeEnv,
+	}
+
+	return ec
+}
+
+func (c *envCmd) env(cmd *cobra.Command, args []string) error {
+	id := args[0]
+	_, err := c.fs.Stat(utils.ResolveStorePath(c.fs, id))
+	if err != nil {
+		return &KonfNotFound{ID: id}
+	}
+
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+	km := m.konf(id)
+
+	if len(args) == 1 {
+		for _, e := range formatLabels(km.Env) {
+			fmt.Fprintln(cmd.OutOrStdout(), e)
+		}
+		return nil
+	}
+
+	for _, arg := range args[1:] {
+		if key := strings.TrimSuffix(arg, "-"); key != arg && !strings.Contains(arg, "=") {
+			delete(km.Env, key)
+			continue
+		}
+		key, value, err := parseEnv(arg)
+		if err != nil {
+			return err
+		}
+		if km.Env == nil {
+			km.Env = map[string]string{}
+		}
+		km.Env[key] = value
+	}
+	if len(km.Env) == 0 {
+		km.Env = nil
+	}
+
+	err = saveMetadata(c.fs, m)
+	if err != nil {
+		return err
+	}
+	log.Info("Environment variables of konf %q are now %q\n", id, formatLabels(km.Env))
+
+	return nil
+}
+
+func (c *envCmd) completeEnv(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return []string{}, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	konfs, err := fetchKonfs(c.fs)
+	if err != nil {
+		if errors.Is(err, &EmptyStore{}) {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	sug := []string{}
+	for _, konf := range konfs {
+		sug = append(sug, konf.ID())
+	}
+	return sug, cobra.ShellCompDirectiveNoFileComp
+}
+
+// parseEnv splits an environment variable in the form of KEY=VALUE. As the shellwrapper receives each variable
+// on its own line, values cannot span multiple lines
+func parseEnv(e string) (string, string, error) {
+	parts := strings.SplitN(e, "=", 2)
+	if len(parts) != 2 || !envNameRegex.MatchString(parts[0]) {
+		return "", "", fmt.Errorf("invalid environment variable %q. Please use the form KEY=VALUE, where KEY consists of letters, digits and underscores only", e)
+	}
+	if strings.ContainsAny(parts[1], "\r\n") {
+		return "", "", fmt.Errorf("invalid environment variable %q. Values cannot contain line breaks", parts[0])
+	}
+	if reservedEnv(parts[0]) {
+		return "", "", fmt.Errorf("the environment variable %q cannot be set for a konf, as it is managed by konf or your shell", parts[0])
+	}
+	return parts[0], parts[1], nil
+}
+
+// reservedEnvNames contains variables that must never be exported or unset by the shellwrapper on a switch
+var reservedEnvNames = map[string]bool{
+	"KUBECONFIG": true,
+	"PATH":       true,
+	"HOME":       true,
+	"SHELL":      true,
+}
+
+// reservedEnv reports whether the environment variable name is used by konf itself or by the shell
+func reservedEnv(name string) bool {
+	return reservedEnvNames[name] || strings.HasPrefix(name, "KONF_")
+}
+
+// konfEnv returns the environment variables of the konf with the supplied id. They are only a convenience, so
+// broken metadata results in a warning instead of preventing the switch
+func konfEnv(f afero.Fs, id string) map[string]string {
+	m, err := loadMetadata(f)
+	if err != nil {
+		log.Warn("could not read the environment variables of konf %q: %v\n", id, err)
+		return nil
+	}
+	km, ok := m.Konfs[id]
+	if !ok {
+		return nil
+	}
+	// the metadata file might have been edited by hand
+	env := map[string]string{}
+	for k, v := range km.Env {
+		if reservedEnv(k) {
+			log.Warn("ignoring the environment variable %q of konf %q, as it is managed by konf or your shell\n", k, id)
+			continue
+		}
+		env[k] = v
+	}
+	return env
+}
+
+// printKonfEnv hands the environment variables of the new konf over to the shellwrapper. It has to be called
+// after printKonfChange, as the shellwrapper unsets the variables of the previous konf when it sees the change
+func printKonfEnv(env map[string]string) {
+	// Just like KUBECONFIGCHANGE, the convention "KONFENV:<KEY>=<VALUE>" is shared with shellwrapper.go
+	for _, e := range formatLabels(env) {
+		fmt.Println("KONFENV:" + e)
+	}
+}
+
+// envStatements returns a statement for every environment variable, that exports it in the supplied shell
+func envStatements(shell string, env map[string]string) ([]string, error) {
+	keys := []string{}
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	stmts := []string{}
+	for _, k := range keys {
+		stmt, err := exportVarStatement(shell, k, env[k])
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+func init() {
+	rootCmd.AddCommand(newEnvCmd().cmd)
+}
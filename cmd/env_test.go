@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestEnv(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		args   []string
+		expErr error
+		expEnv map[string]string
+		expOut string
+	}{
+		"add variables": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"dev-eu_dev-eu-1", "AWS_PROFILE=dev", "HTTPS_PROXY=http://proxy:3128/?a=b"},
+			nil,
+			map[string]string{"AWS_PROFILE": "dev", "HTTPS_PROXY": "http://proxy:3128/?a=b"},
+			"",
+		},
+		"update and remove variables": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    env:\n      AWS_PROFILE: dev\n      HTTPS_PROXY: http://proxy:3128\n")),
+			[]string{"dev-eu_dev-eu-1", "AWS_PROFILE=prod", "HTTPS_PROXY-"},
+			nil,
+			map[string]string{"AWS_PROFILE": "prod"},
+			"",
+		},
+		"list variables": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    env:\n      HTTPS_PROXY: http://proxy:3128\n      AWS_PROFILE: dev\n")),
+			[]string{"dev-eu_dev-eu-1"},
+			nil,
+			map[string]string{"AWS_PROFILE": "dev", "HTTPS_PROXY": "http://proxy:3128"},
+			"AWS_PROFILE=dev\nHTTPS_PROXY=http://proxy:3128\n",
+		},
+		"invalid name": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"dev-eu_dev-eu-1", "AWS-PROFILE=dev"},
+			fmt.Errorf("invalid environment variable \"AWS-PROFILE=dev\". Please use the form KEY=VALUE, where KEY consists of letters, digits and underscores only"),
+			nil,
+			"",
+		},
+		"value with line break": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"dev-eu_dev-eu-1", "AWS_PROFILE=dev\nKUBECONFIGCHANGE:/tmp"},
+			fmt.Errorf("invalid environment variable \"AWS_PROFILE\". Values cannot contain line breaks"),
+			nil,
+			"",
+		},
+		"kubeconfig": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"dev-eu_dev-eu-1", "KUBECONFIG=/tmp/config"},
+			fmt.Errorf("the environment variable \"KUBECONFIG\" cannot be set for a konf, as it is managed by konf or your shell"),
+			nil,
+			"",
+		},
+		"konf variable": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"dev-eu_dev-eu-1", "KONF_ENV_KEYS=PATH"},
+			fmt.Errorf("the environment variable \"KONF_ENV_KEYS\" cannot be set for a konf, as it is managed by konf or your shell"),
+			nil,
+			"",
+		},
+		"shell variable": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"dev-eu_dev-eu-1", "PATH=/opt/bin"},
+			fmt.Errorf("the environment variable \"PATH\" cannot be set for a konf, as it is managed by konf or your shell"),
+			nil,
+			"",
+		},
+		"konf does not exist": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			[]string{"dev-eu_dev-eu-1", "AWS_PROFILE=dev"},
+			&KonfNotFound{ID: "dev-eu_dev-eu-1"},
+			nil,
+			"",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			ec := newEnvCmd()
+			ec.fs = tc.fs
+			var out bytes.Buffer
+			ec.cmd.SetOut(&out)
+
+			err := ec.cmd.RunE(ec.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			if !cmp.Equal(m.konf("dev-eu_dev-eu-1").Env, tc.expEnv) {
+				t.Errorf("Exp and given variables differ: \n '%s'", cmp.Diff(tc.expEnv, m.konf("dev-eu_dev-eu-1").Env))
+			}
+			if out.String() != tc.expOut {
+				t.Errorf("Exp output %q, got %q", tc.expOut, out.String())
+			}
+		})
+	}
+}
+
+func TestSetEchoEnvWithKonfEnv(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	sc := newSetCommand()
+	sc.fs = testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    env:\n      HTTPS_PROXY: http://proxy:3128\n      AWS_PROFILE: it's-dev\n"))
+	sc.echoEnv = true
+	var out bytes.Buffer
+	sc.cmd.SetOut(&out)
+
+	err := sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	exp := "export KUBECONFIG='" + utils.ActivePathForID(fmt.Sprint(os.Getppid())) + "'\n" +
+		"export AWS_PROFILE='it'\\''s-dev'\n" +
+		"export HTTPS_PROXY='http://proxy:3128'\n"
+	if out.String() != exp {
+		t.Errorf("Exp output %q, got %q", exp, out.String())
+	}
+}
+
+func TestKonfEnvSkipsReservedNames(t *testing.T) {
+	f := testhelper.FSWithFiles(metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    env:\n      AWS_PROFILE: dev\n      PATH: /opt/bin\n      KONF_ENV_KEYS: HOME\n"))
+
+	exp := map[string]string{"AWS_PROFILE": "dev"}
+	if env := konfEnv(f, "dev-eu_dev-eu-1"); !cmp.Equal(env, exp) {
+		t.Errorf("Exp env %v, got %v", exp, env)
+	}
+}
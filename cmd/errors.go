@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/simontheleg/konf-go/config"
+)
+
+// All errors in here implement an Is method, so they can be checked using errors.Is(err, &ErrorType{})
+// regardless of their fields and regardless of whether they have been wrapped using fmt.Errorf("%w")
+// Alternatively errors.As can be used to retrieve their fields
+
+// KubeConfigOverload describes a state in which a kubeconfig has multiple Contexts or Clusters
+// This can be undesirable for konf when such a kubeconfig is in its store
+type KubeConfigOverload struct {
+	Path string
+}
+
+func (k *KubeConfigOverload) Error() string {
+	return fmt.Sprintf("Impure Store: The kubeconfig %q spans multiple clusters. Please only use 'konf import' for populating the store. To split it up, run 'konf store import-merge'\n", k.Path)
+}
+
+// Is reports whether target is a KubeConfigOverload
+func (k *KubeConfigOverload) Is(target error) bool {
+	_, ok := target.(*KubeConfigOverload)
+	return ok
+}
+
+// EmptyStore describes a state in which no kubeconfig is inside the store
+// It makes sense to have this in a separate case as it does not matter for some operations (e.g. importing) but detrimental for others (e.g. running the selection prompt)
+type EmptyStore struct{}
+
+func (k *EmptyStore) Error() string {
+	return fmt.Sprintf("The konf store at %q is empty. Please run 'konf import' to populate it", config.StoreDir())
+}
+
+// Is reports whether target is an EmptyStore
+func (k *EmptyStore) Is(target error) bool {
+	_, ok := target.(*EmptyStore)
+	return ok
+}
+
+// SharedKonf describes the attempt to change a konf that lives in a shared store, which konf never writes to
+type SharedKonf struct {
+	ID   string
+	Path string
+}
+
+func (k *SharedKonf) Error() string {
+	return fmt.Sprintf("konf %q is in the shared store at %q, which konf never writes to. Please change it there instead", k.ID, k.Path)
+}
+
+// KonfNotFound describes a state in which a konf with the requested ID does not exist in the store
+type KonfNotFound struct {
+	ID string
+}
+
+func (k *KonfNotFound) Error() string {
+	return fmt.Sprintf("could not find konf %q in the store", k.ID)
+}
+
+// Is reports whether target is a KonfNotFound
+func (k *KonfNotFound) Is(target error) bool {
+	_, ok := target.(*KonfNotFound)
+	return ok
+}
+
+// Unwrap returns fs.ErrNotExist, so that callers only caring about the existence of a konf
+// can keep on using errors.Is(err, fs.ErrNotExist)
+func (k *KonfNotFound) Unwrap() error {
+	return fs.ErrNotExist
+}
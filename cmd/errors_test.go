@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestErrorsIs(t *testing.T) {
+	tt := map[string]struct {
+		err    error
+		target error
+		exp    bool
+	}{
+		"EmptyStore": {
+			&EmptyStore{},
+			&EmptyStore{},
+			true,
+		},
+		"wrapped EmptyStore": {
+			fmt.Errorf("wrapped: %w", &EmptyStore{}),
+			&EmptyStore{},
+			true,
+		},
+		"KubeConfigOverload with different path": {
+			&KubeConfigOverload{Path: "./konf/store/multi_konf.yaml"},
+			&KubeConfigOverload{},
+			true,
+		},
+		"wrapped KonfNotFound": {
+			fmt.Errorf("wrapped: %w", &KonfNotFound{ID: "dev-eu_dev-eu-1"}),
+			&KonfNotFound{},
+			true,
+		},
+		"KonfNotFound is fs.ErrNotExist": {
+			&KonfNotFound{ID: "dev-eu_dev-eu-1"},
+			fs.ErrNotExist,
+			true,
+		},
+		"different types": {
+			&EmptyStore{},
+			&KonfNotFound{},
+			false,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res := errors.Is(tc.err, tc.target)
+			if res != tc.exp {
+				t.Errorf("Exp errors.Is to be %t, got %t", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestErrorsAs(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &KonfNotFound{ID: "dev-eu_dev-eu-1"})
+
+	var nf *KonfNotFound
+	if !errors.As(err, &nf) {
+		t.Fatalf("Exp errors.As to find KonfNotFound in %q", err)
+	}
+	if nf.ID != "dev-eu_dev-eu-1" {
+		t.Errorf("Exp ID to be %q, got %q", "dev-eu_dev-eu-1", nf.ID)
+	}
+
+	err = fmt.Errorf("wrapped: %w", &KubeConfigOverload{Path: "./konf/store/multi_konf.yaml"})
+
+	var ko *KubeConfigOverload
+	if !errors.As(err, &ko) {
+		t.Fatalf("Exp errors.As to find KubeConfigOverload in %q", err)
+	}
+	if ko.Path != "./konf/store/multi_konf.yaml" {
+		t.Errorf("Exp Path to be %q, got %q", "./konf/store/multi_konf.yaml", ko.Path)
+	}
+}
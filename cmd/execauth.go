@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/simontheleg/konf-go/log"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+)
+
+// hasStaticAuth reports whether u carries credentials that are stored in the kubeconfig itself, like client
+// certificates, tokens or basic auth, instead of being obtained on demand
+func hasStaticAuth(u *k8s.AuthInfo) bool {
+	return u.ClientCertificate != "" || len(u.ClientCertificateData) > 0 ||
+		u.ClientKey != "" || len(u.ClientKeyData) > 0 ||
+		u.Token != "" || u.TokenFile != "" ||
+		u.Username != "" || u.Password != ""
+}
+
+// stripStaticAuth removes all static credentials from the users of confs, while exec credential plugins and
+// auth providers are kept
+func stripStaticAuth(confs []*konfFile) {
+	for _, conf := range confs {
+		for i := range conf.Content.AuthInfos {
+			u := &conf.Content.AuthInfos[i].AuthInfo
+			if !hasStaticAuth(u) {
+				continue
+			}
+			u.ClientCertificate, u.ClientCertificateData = "", nil
+			u.ClientKey, u.ClientKeyData = "", nil
+			u.Token, u.TokenFile = "", ""
+			u.Username, u.Password = "", ""
+			log.Info("Stripped static credentials of user %q from konf %q\n", conf.Content.AuthInfos[i].Name, conf.ID())
+		}
+	}
+}
+
+// requireExecAuth returns an error if any context of confs does not authenticate via an exec credential plugin,
+// or if its user still carries static credentials next to the plugin
+func requireExecAuth(confs []*konfFile) error {
+	invalid := []string{}
+	for _, conf := range confs {
+		for _, ctx := range conf.Content.Contexts {
+			var user *k8s.AuthInfo
+			for i, u := range conf.Content.AuthInfos {
+				if u.Name == ctx.Context.AuthInfo {
+					user = &conf.Content.AuthInfos[i].AuthInfo
+					break
+				}
+			}
+			if user == nil || user.Exec == nil || hasStaticAuth(user) {
+				invalid = append(invalid, ctx.Name)
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("the contexts %q do not solely authenticate via an exec credential plugin. Use --strip-static-auth to remove static credentials", invalid)
+	}
+	return nil
+}
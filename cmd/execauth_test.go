@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestImportExecAuth(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	withUser := func(user string) string {
+		return strings.Replace(sm.SingleClusterSingleContextEU(), "    user: {}\n", user, 1)
+	}
+	static := withUser("    user:\n      token: secret\n")
+	exec := withUser("    user:\n      exec:\n        apiVersion: client.authentication.k8s.io/v1beta1\n        command: aws\n")
+	both := withUser("    user:\n      token: secret\n      exec:\n        apiVersion: client.authentication.k8s.io/v1beta1\n        command: aws\n")
+	rejected := fmt.Errorf("the contexts [\"dev-eu\"] do not solely authenticate via an exec credential plugin. Use --strip-static-auth to remove static credentials")
+
+	tt := map[string]struct {
+		source      string
+		requireExec bool
+		stripStatic bool
+		expErr      error
+		expExec     bool
+	}{
+		"static credentials are rejected": {
+			static, true, false,
+			rejected,
+			false,
+		},
+		"exec credentials are accepted": {
+			exec, true, false,
+			nil,
+			true,
+		},
+		"static next to exec credentials are rejected": {
+			both, true, false,
+			rejected,
+			false,
+		},
+		"static next to exec credentials are stripped": {
+			both, true, true,
+			nil,
+			true,
+		},
+		"static credentials are stripped": {
+			static, false, true,
+			nil,
+			false,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(importSource("./import/dev-eu.yaml", tc.source))
+
+			icmd := newImportCmd()
+			icmd.fs = f
+			icmd.requireExec = tc.requireExec
+			icmd.stripStatic = tc.stripStatic
+			err := icmd.cmd.RunE(icmd.cmd, []string{"./import/dev-eu.yaml"})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			b, err := afero.ReadFile(f, utils.StorePathForID("dev-eu_dev-eu-1"))
+			if tc.expErr != nil {
+				if err == nil {
+					t.Errorf("Exp rejected konf to not be written to the store")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Could not read konf: %q", err)
+			}
+			user := parseKonf(t, b).AuthInfos[0].AuthInfo
+			if hasStaticAuth(&user) {
+				t.Errorf("Exp static credentials to be stripped, got %v", user)
+			}
+			if (user.Exec != nil) != tc.expExec {
+				t.Errorf("Exp exec credential plugin to be kept: %t, got %v", tc.expExec, user.Exec)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type favoriteCmd struct {
+	fs afero.Fs
+
+	cmd *cobra.Command
+}
+
+func newFavoriteCmd() *favoriteCmd {
+	fc := &favoriteCmd{
+		fs: utils.NewFs(),
+	}
+
+	fc.cmd = &cobra.Command{
+		Use:     "favorite",
+		Aliases: []string{"fav"},
+		Short:   "Manage favorite konfs",
+		Long: `Manage favorite konfs
+
+Favorites are always shown at the top of the konf picker.
+
+Examples:
+	-> 'favorite add <konfig id> [<konfig id>...]' mark konfs as favorite
+	-> 'favorite remove <konfig id> [<konfig id>...]' unmark konfs as favorite
+`,
+	}
+
+	add := &cobra.Command{
+		Use:               "add",
+		Short:             "Mark konfs as favorite",
+		Args:              cobra.MinimumNArgs(1),
+		RunE:              fc.add,
+		ValidArgsFunction: fc.completeAdd,
+	}
+	remove := &cobra.Command{
+		Use:               "remove",
+		Aliases:           []string{"rm"},
+		Short:             "Unmark konfs as favorite",
+		Args:              cobra.MinimumNArgs(1),
+		RunE:              fc.remove,
+		ValidArgsFunction: fc.completeRemove,
+	}
+	fc.cmd.AddCommand(add, remove)
+
+	return fc
+}
+
+func (c *favoriteCmd) add(cmd *cobra.Command, args []string) error {
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range args {
+		_, err := c.fs.Stat(utils.ResolveStorePath(c.fs, id))
+		if err != nil {
+			return &KonfNotFound{ID: id}
+		}
+		m.konf(id).Favorite = true
+	}
+
+	err = saveMetadata(c.fs, m)
+	if err != nil {
+		return err
+	}
+	log.Info("Added %q to favorites\n", args)
+
+	return nil
+}
+
+func (c *favoriteCmd) remove(cmd *cobra.Command, args []string) error {
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range args {
+		km, ok := m.Konfs[id]
+		if !ok || !km.Favorite {
+			return fmt.Errorf("konf %q is not a favorite", id)
+		}
+		km.Favorite = false
+	}
+
+	err = saveMetadata(c.fs, m)
+	if err != nil {
+		return err
+	}
+	log.Info("Removed %q from favorites\n", args)
+
+	return nil
+}
+
+func (c *favoriteCmd) completeAdd(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	konfs, err := fetchKonfs(c.fs)
+	if err != nil {
+		if errors.Is(err, &EmptyStore{}) {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	sug := []string{}
+	for _, konf := range konfs {
+		if !konf.Favorite {
+			sug = append(sug, utils.IDFromClusterAndContext(konf.Cluster, konf.Context))
+		}
+	}
+
+	return sug, cobra.ShellCompDirectiveNoFileComp
+}
+
+func (c *favoriteCmd) completeRemove(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	return m.favorites(), cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(newFavoriteCmd().cmd)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/spf13/afero"
+)
+
+func TestFavorite(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs      afero.Fs
+		sub     string
+		args    []string
+		expErr  error
+		expFavs []string
+	}{
+		"add": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			"add",
+			[]string{"dev-eu_dev-eu-1", "dev-asia_dev-asia-1"},
+			nil,
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+		},
+		"add konf that does not exist": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			"add",
+			[]string{"i-dont-exist"},
+			&KonfNotFound{ID: "i-dont-exist"},
+			[]string{},
+		},
+		"remove": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    favorite: true\n")),
+			"remove",
+			[]string{"dev-eu_dev-eu-1"},
+			nil,
+			[]string{},
+		},
+		"remove konf that is no favorite": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			"remove",
+			[]string{"dev-eu_dev-eu-1"},
+			fmt.Errorf("konf \"dev-eu_dev-eu-1\" is not a favorite"),
+			[]string{},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			fc := newFavoriteCmd()
+			fc.fs = tc.fs
+
+			var err error
+			switch tc.sub {
+			case "add":
+				err = fc.add(fc.cmd, tc.args)
+			case "remove":
+				err = fc.remove(fc.cmd, tc.args)
+			}
+			if !testhelper.EqualError(tc.expErr, err) {
+				t.Errorf("Exp error %q, got %q", tc.expErr, err)
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if !cmp.Equal(tc.expFavs, m.favorites()) {
+				t.Errorf("Exp and given favorites differ:\n'%s'", cmp.Diff(tc.expFavs, m.favorites()))
+			}
+		})
+	}
+}
+
+func TestCompleteFavorite(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    favorite: true\n"))
+
+	fc := newFavoriteCmd()
+	fc.fs = f
+
+	resAdd, _ := fc.completeAdd(fc.cmd, []string{}, "")
+	if !cmp.Equal([]string{"dev-asia_dev-asia-1"}, resAdd) {
+		t.Errorf("Exp and given add comps differ:\n'%s'", cmp.Diff([]string{"dev-asia_dev-asia-1"}, resAdd))
+	}
+
+	resRemove, _ := fc.completeRemove(fc.cmd, []string{}, "")
+	if !cmp.Equal([]string{"dev-eu_dev-eu-1"}, resRemove) {
+		t.Errorf("Exp and given remove comps differ:\n'%s'", cmp.Diff([]string{"dev-eu_dev-eu-1"}, resRemove))
+	}
+}
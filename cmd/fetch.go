@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/simontheleg/konf-go/config"
+	"github.com/spf13/afero"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// runFetchCommand runs the fetch command of a konf and returns the kubeconfig it printed. Its stderr is passed
+// through, as secret managers might ask the user to unlock them
+func runFetchCommand(command string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("fetch command %q failed: %v", command, err)
+	}
+	return out, nil
+}
+
+// fetchCommandFor returns the fetch command of the konf with the supplied id. It is empty for regular konfs
+func fetchCommandFor(f afero.Fs, id string) (string, error) {
+	m, err := loadMetadata(f)
+	if err != nil {
+		return "", err
+	}
+	if km, ok := m.Konfs[id]; ok {
+		return km.FetchCommand, nil
+	}
+	return "", nil
+}
+
+// fetchKonf runs the fetch command of the konf with the supplied id and makes sure it returned that very konf
+func fetchKonf(id, command string) ([]byte, error) {
+	b, err := runFetchCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	confs, err := splitConfigs(b, true)
+	if err != nil {
+		return nil, fmt.Errorf("fetch command of konf %q did not return a valid kubeconfig: %v", id, err)
+	}
+	if len(confs) != 1 {
+		return nil, fmt.Errorf("fetch command of konf %q has to return a kubeconfig with a single context, got %d", id, len(confs))
+	}
+	if confs[0].ID() != id {
+		return nil, fmt.Errorf("fetch command of konf %q returned konf %q instead", id, confs[0].ID())
+	}
+
+	return yaml.Marshal(confs[0].Content)
+}
+
+// stripCredentials removes everything but the names of the users of kf. The result is a stub, which is enough
+// to list and pick the konf, while its credentials are only fetched on 'konf set'
+func stripCredentials(kf *konfFile) {
+	for i := range kf.Content.AuthInfos {
+		kf.Content.AuthInfos[i].AuthInfo = k8s.AuthInfo{}
+	}
+}
+
+// recordFetchCommand remembers command as the fetch command of all konfs
+func recordFetchCommand(f afero.Fs, confs []*konfFile, command string) error {
+	m, err := loadMetadata(f)
+	if err != nil {
+		return err
+	}
+	for _, conf := range confs {
+		m.konf(conf.ID()).FetchCommand = command
+	}
+	return saveMetadata(f, m)
+}
+
+// ensureNotFetched returns an error if the konf with the supplied id is fetched by a command, as the store only
+// holds a stub of it, which cannot be used by kubectl directly
+func ensureNotFetched(f afero.Fs, id, flag string) error {
+	command, err := fetchCommandFor(f, id)
+	if err != nil {
+		return err
+	}
+	if command != "" {
+		return fmt.Errorf("konf %q is fetched by a command on set, which is why %s cannot point kubectl at it", id, flag)
+	}
+	return nil
+}
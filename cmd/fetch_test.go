@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestFetchCommand(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	// the secret manager is simulated by a file outside of the store
+	secret := filepath.Join(t.TempDir(), "kubeconfig")
+	err := os.WriteFile(secret, []byte(strings.Replace(sm.SingleClusterSingleContextEU(), "user: {}", "user:\n      token: secret-token", 1)), utils.KonfPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := fmt.Sprintf("cat %q", secret)
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir)
+
+	ic := newImportCmd()
+	ic.fs = f
+	ic.fetchCommand = command
+	err = ic.cmd.RunE(ic.cmd, []string{})
+	if err != nil {
+		t.Fatalf("Exp no error on import, got %q", err)
+	}
+
+	stub, err := afero.ReadFile(f, utils.StorePathForID("dev-eu_dev-eu-1"))
+	if err != nil {
+		t.Fatalf("Exp stub to be written, got %q", err)
+	}
+	if strings.Contains(string(stub), "secret-token") {
+		t.Errorf("Exp no credentials in the store, got %q", string(stub))
+	}
+
+	path, err := setContext("dev-eu_dev-eu-1", f)
+	if err != nil {
+		t.Fatalf("Exp no error on set, got %q", err)
+	}
+	active, err := afero.ReadFile(f, path)
+	if err != nil {
+		t.Fatalf("Could not read active konf: %q", err)
+	}
+	if !strings.Contains(string(active), "secret-token") {
+		t.Errorf("Exp the fetched credentials in the active konf, got %q", string(active))
+	}
+
+	// the secret manager might return a different kubeconfig in the meantime
+	err = os.WriteFile(secret, []byte(sm.SingleClusterSingleContextASIA()), utils.KonfPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = setContext("dev-eu_dev-eu-1", f)
+	expErr := fmt.Errorf("fetch command of konf \"dev-eu_dev-eu-1\" returned konf \"dev-asia_dev-asia-1\" instead")
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp err %q, got %q", expErr, err)
+	}
+
+	sc := newSetCommand()
+	sc.fs = f
+	sc.direnv = true
+	err = sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+	expErr = fmt.Errorf("konf \"dev-eu_dev-eu-1\" is fetched by a command on set, which is why --direnv cannot point kubectl at it")
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp err %q, got %q", expErr, err)
+	}
+}
+
+func TestImportFetchCommandErrors(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+	multi := filepath.Join(t.TempDir(), "kubeconfig")
+	err := os.WriteFile(multi, []byte(sm.MultiClusterMultiContext()), utils.KonfPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := map[string]struct {
+		command     string
+		args        []string
+		onCollision string
+		expErr      error
+	}{
+		"multiple contexts": {
+			fmt.Sprintf("cat %q", multi), []string{}, collisionOverwrite,
+			fmt.Errorf("--fetch-command has to return a kubeconfig with a single context, got 2"),
+		},
+		"failing command": {
+			"exit 3", []string{}, collisionOverwrite,
+			fmt.Errorf("fetch command \"exit 3\" failed: exit status 3"),
+		},
+		"with a file": {
+			"true", []string{"./kubeconfig"}, collisionOverwrite,
+			fmt.Errorf("--fetch-command cannot be combined with a file, --url, --bundle or --alias-filename"),
+		},
+		"with rename": {
+			"true", []string{}, collisionRename,
+			fmt.Errorf("--fetch-command cannot be combined with --on-collision rename"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			ic := newImportCmd()
+			ic.fs = testhelper.FSWithFiles(fm.StoreDir)
+			ic.fetchCommand = tc.command
+			ic.onCollision = tc.onCollision
+
+			err := ic.cmd.RunE(ic.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+		})
+	}
+}
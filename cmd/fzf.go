@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/prompt"
+)
+
+// fzfExitNoMatch and fzfExitInterrupt are the exit codes fzf uses if the user did not select anything
+const (
+	fzfExitNoMatch   = 1
+	fzfExitInterrupt = 130
+)
+
+// pickerPrompt runs the picker that is configured by the user for prompts that are not run through a Selector.
+// Prompts that do not pick konfs, like the actions of 'konf tui', always use the built-in picker. Terminals which
+// cannot render the built-in picker get a numbered list instead
+func pickerPrompt(p *promptui.Select) (int, error) {
+	if dumbTerminal(os.Getenv("TERM"), runtime.GOOS) {
+		return newSimplePrompt(os.Stdin, os.Stderr)(p)
+	}
+	if konfs, ok := p.Items.([]tableOutput); ok {
+		if s, ok := selectorFor(os.Getenv("TERM"), runtime.GOOS, config.Picker(), exec.LookPath).(*fzfSelector); ok {
+			return s.Select(konfs)
+		}
+	}
+	return prompt.Terminal(p)
+}
+
+// newFzfPrompt returns a promptFunc, which hands the items of the prompt over to run as one line per item
+// and resolves the line run returns back to the position of its item
+func newFzfPrompt(run func(input string) (string, error)) promptFunc {
+	return func(p *promptui.Select) (int, error) {
+		konfs, ok := p.Items.([]tableOutput)
+		if !ok {
+			return -1, fmt.Errorf("fzf can only pick konfs, got %T", p.Items)
+		}
+
+		// each line is prefixed with the position of its item, which is hidden from the user by fzf
+		var input strings.Builder
+		for i, k := range konfs {
+			fmt.Fprintf(&input, "%d\t%s\t%s\t%s\n", i, k.Display(), k.Cluster, k.File)
+		}
+
+		sel, err := run(input.String())
+		if err != nil {
+			return -1, err
+		}
+		pos, err := strconv.Atoi(strings.SplitN(sel, "\t", 2)[0])
+		if err != nil {
+			return -1, fmt.Errorf("could not parse fzf selection %q", sel)
+		}
+		return pos, nil
+	}
+}
+
+// runFzf runs fzf with input and returns the selected line
+func runFzf(input string) (string, error) {
+	cmd := exec.Command("fzf", "--delimiter=\t", "--with-nth=2..", "--header=context\tcluster\tfile")
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stderr = os.Stderr
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	err := cmd.Run()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && (exitErr.ExitCode() == fzfExitNoMatch || exitErr.ExitCode() == fzfExitInterrupt) {
+			return "", fmt.Errorf("prompt failed %w", promptui.ErrInterrupt)
+		}
+		return "", fmt.Errorf("fzf failed: %w", err)
+	}
+
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/testhelper"
+)
+
+func TestFzfPrompt(t *testing.T) {
+	konfs := []tableOutput{
+		{Context: "dev-eu", Cluster: "dev-eu-1", File: "./konf/store/dev-eu_dev-eu-1.yaml"},
+		{Context: "dev-asia", Cluster: "dev-asia-1", File: "./konf/store/dev-asia_dev-asia-1.yaml", DisplayContext: "asia"},
+	}
+	expInput := "0\tdev-eu\tdev-eu-1\t./konf/store/dev-eu_dev-eu-1.yaml\n" +
+		"1\tasia\tdev-asia-1\t./konf/store/dev-asia_dev-asia-1.yaml\n"
+
+	tt := map[string]struct {
+		items  interface{}
+		sel    string
+		runErr error
+		expPos int
+		expErr error
+	}{
+		"selection": {
+			konfs,
+			"1\tasia\tdev-asia-1\t./konf/store/dev-asia_dev-asia-1.yaml",
+			nil,
+			1,
+			nil,
+		},
+		"aborted": {
+			konfs,
+			"",
+			promptui.ErrInterrupt,
+			-1,
+			promptui.ErrInterrupt,
+		},
+		"unparsable selection": {
+			konfs,
+			"garbage",
+			nil,
+			-1,
+			fmt.Errorf("could not parse fzf selection \"garbage\""),
+		},
+		"no konfs": {
+			[]string{"a", "b"},
+			"",
+			nil,
+			-1,
+			fmt.Errorf("fzf can only pick konfs, got []string"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			run := func(input string) (string, error) {
+				if input != expInput {
+					t.Errorf("Exp fzf input %q, got %q", expInput, input)
+				}
+				return tc.sel, tc.runErr
+			}
+
+			pos, err := newFzfPrompt(run)(&promptui.Select{Items: tc.items})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if pos != tc.expPos {
+				t.Errorf("Exp position %d, got %d", tc.expPos, pos)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	healthBackoffInitial = 250 * time.Millisecond
+	healthBackoffMax     = 5 * time.Second
+)
+
+// readyCheck returns a func that asks the API server of a kubeconfig once whether it is ready, together with the
+// address of the server
+func readyCheck(konf []byte) (func(context.Context) error, string, error) {
+	rc, err := clientcmd.RESTConfigFromKubeConfig(konf)
+	if err != nil {
+		return nil, "", err
+	}
+	cs, err := kubernetes.NewForConfig(rc)
+	if err != nil {
+		return nil, "", err
+	}
+
+	check := func(ctx context.Context) error {
+		_, err := cs.Discovery().RESTClient().Get().AbsPath("/readyz").DoRaw(ctx)
+		return err
+	}
+	return check, rc.Host, nil
+}
+
+// checkHealthy asks the API server of a kubeconfig once whether it is ready
+func checkHealthy(konf []byte, timeout time.Duration) error {
+	check, host, err := readyCheck(konf)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err = check(ctx)
+	if err != nil {
+		return fmt.Errorf("cluster %q is not ready: %v", host, err)
+	}
+	return nil
+}
+
+// waitHealthy polls the API server of a kubeconfig until it reports to be ready or timeout is reached.
+// Between two polls it backs off exponentially, so a control plane that is still starting up is not hammered.
+// The backoff waits on the clock, so tests do not have to sleep
+func waitHealthy(konf []byte, timeout time.Duration) error {
+	check, host, err := readyCheck(konf)
+	if err != nil {
+		return err
+	}
+
+	deadline := clock.Now().Add(timeout)
+	backoff := healthBackoffInitial
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), deadline.Sub(clock.Now()))
+		err = check(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		// there is no point in waiting for a poll, which would only happen after the deadline
+		if backoff >= deadline.Sub(clock.Now()) {
+			return fmt.Errorf("cluster %q did not become ready within %s: %v", host, timeout, err)
+		}
+		<-clock.After(backoff)
+
+		backoff *= 2
+		if backoff > healthBackoffMax {
+			backoff = healthBackoffMax
+		}
+	}
+}
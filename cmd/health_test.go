@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+)
+
+func konfForServer(server string) []byte {
+	return []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Config
+clusters:
+  - cluster:
+      server: %s
+    name: kind
+contexts:
+  - context:
+      cluster: kind
+      user: kind
+    name: kind
+current-context: kind
+users:
+  - name: kind
+    user: {}
+`, server))
+}
+
+func TestWaitHealthy(t *testing.T) {
+	tt := map[string]struct {
+		unreadyPolls int32
+		timeout      time.Duration
+		expErr       bool
+		expPolls     int32
+	}{
+		"ready right away":    {0, time.Second, false, 1},
+		"ready after a while": {2, 5 * time.Second, false, 3},
+		// the second backoff of 500ms would end after the timeout
+		"never ready": {1000, 500 * time.Millisecond, true, 2},
+	}
+	// the backoff waits on the fake clock, so the polls happen right after each other
+	clock = testhelper.NewFakeClock(time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC))
+	t.Cleanup(func() { clock = utils.RealClock{} })
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			var polls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/readyz" {
+					t.Errorf("Exp readiness to be polled on /readyz, got %q", r.URL.Path)
+				}
+				if atomic.AddInt32(&polls, 1) <= tc.unreadyPolls {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, "ok")
+			}))
+			defer srv.Close()
+
+			err := waitHealthy(konfForServer(srv.URL), tc.timeout)
+			if tc.expErr {
+				if err == nil || !strings.Contains(err.Error(), "did not become ready within") {
+					t.Errorf("Exp a timeout error, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Exp no error, got %q", err)
+			}
+			if polls != tc.expPolls {
+				t.Errorf("Exp %d polls, got %d", tc.expPolls, polls)
+			}
+		})
+	}
+}
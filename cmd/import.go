@@ -1,8 +1,21 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
+	"github.com/simontheleg/konf-go/config"
 	log "github.com/simontheleg/konf-go/log"
 	"github.com/simontheleg/konf-go/utils"
 	"github.com/spf13/afero"
@@ -16,22 +29,55 @@ type konfFile struct {
 	Content  k8s.Config
 }
 
+// ID returns the id of the konf, which is derived from its path in the store
+func (k *konfFile) ID() string {
+	return strings.TrimSuffix(filepath.Base(k.FilePath), filepath.Ext(k.FilePath))
+}
+
 type importCmd struct {
 	fs afero.Fs
 
-	determineConfigs func(afero.Fs, string) ([]*konfFile, error)
+	determineConfigs func(afero.Fs, string, bool) ([]*konfFile, error)
 	writeConfig      func(afero.Fs, *konfFile) error
+	fetchURL         func(*http.Client, string, []string) ([]byte, error)
+	clipboard        func() ([]byte, error)
+	healthCheck      func([]byte, time.Duration) error
+
+	url          string
+	headers      []string
+	onCollision  string
+	splitBy      string
+	splitUsers   bool
+	namespace    string
+	forceNS      bool
+	prefix       string
+	aliasFile    bool
+	scratch      bool
+	ttl          time.Duration
+	bundle       bool
+	exportBundle string
+	fetchCommand string
+	fromClip     bool
+	validateOnly bool
+	output       string
+	requireExec  bool
+	stripStatic  bool
+	verify       bool
+	skipUnreach  bool
 
 	cmd *cobra.Command
 }
 
 func newImportCmd() *importCmd {
-	fs := afero.NewOsFs()
+	fs := utils.NewFs()
 
 	ic := &importCmd{
 		fs:               fs,
 		determineConfigs: determineConfigs,
 		writeConfig:      writeConfig,
+		fetchURL:         fetchURL,
+		clipboard:        readClipboard,
+		healthCheck:      checkHealthy,
 	}
 
 	ic.cmd = &cobra.Command{
@@ -40,52 +86,642 @@ func newImportCmd() *importCmd {
 		Long: `Import kubeconfigs into konf store
 
 It is important that you import all configs first, as konf requires each config to only
-contain a single context. Import will take care of splitting if necessary.`,
-		Args: cobra.ExactArgs(1),
+contain a single context. Import will take care of splitting if necessary. With --split-by cluster,
+contexts that share a cluster are kept together instead.
+
+Examples:
+	-> 'import <path-to-kubeconfig>' import a kubeconfig from a file
+	-> 'import --url https://example.com/kubeconfig --header "Authorization: Bearer <token>"' import a kubeconfig served over https
+	-> 'import --export-bundle team.yaml <konfig id> [<konfig id>...]' bundle konfs to share them with your team
+	-> 'import --bundle team.yaml' import all konfs of a bundle
+	-> 'import --context-prefix aws <path-to-kubeconfig>' import the context prod as aws-prod
+	-> 'import --validate-only <path-to-kubeconfig>' check a kubeconfig for misspelled fields without importing it
+	-> 'import --from-clipboard' import the kubeconfig in the clipboard, e.g. after copying it from a web console
+	-> 'import --fetch-command "op read op://k8s/prod/kubeconfig"' keep the credentials of a konf in a secret manager
+	-> 'konf set "$(konf import <path-to-kubeconfig> | head -1)"' import a kubeconfig and switch to it right away
+
+Bundles are self-contained: certificates, keys and tokens that are referenced by path are embedded into
+the bundle. Use --on-collision to decide what happens to konfs that already exist in your store.
+
+The ids of all imported konfs are printed to stdout, one per line, while everything else is logged to stderr.
+Konfs that are skipped because of a collision are left out.`,
+		Args: cobra.ArbitraryArgs,
 		RunE: ic.importf,
 	}
 
+	ic.cmd.Flags().StringVar(&ic.url, "url", "", "download the kubeconfig from an https url instead of reading it from a file")
+	ic.cmd.Flags().StringArrayVar(&ic.headers, "header", []string{}, "additional header in the form of 'Name: value' to send with the --url request. Can be specified multiple times")
+	ic.cmd.Flags().StringVar(&ic.splitBy, "split-by", splitByContext, fmt.Sprintf("how to split the kubeconfig into konfs. With %q, contexts that share a cluster are kept together in a single konf. One of %q, %q", splitByCluster, splitByContext, splitByCluster))
+	ic.cmd.Flags().BoolVar(&ic.splitUsers, "split-users", true, "only copy the user referenced by a context into its konf, so that credentials are never shared between konfs. If disabled, every konf receives all users of the kubeconfig")
+	ic.cmd.Flags().StringVar(&ic.namespace, "default-namespace", "", "namespace to set for all imported contexts, that do not specify a namespace yet")
+	ic.cmd.Flags().BoolVar(&ic.forceNS, "force-namespace", false, "also overwrite the namespace of contexts that already specify one with --default-namespace")
+	ic.cmd.Flags().BoolVar(&ic.validateOnly, "validate-only", false, "only validate the kubeconfig strictly, which also reports unknown or misspelled fields, instead of importing it")
+	ic.cmd.Flags().StringVar(&ic.prefix, "context-prefix", "", "prefix the names of all imported contexts with this value and a dash, e.g. aws turns the context prod into aws-prod. Useful to keep contexts apart that share a name across multiple sources")
+	ic.cmd.Flags().BoolVar(&ic.aliasFile, "alias-filename", false, "register the name of the imported file as an alias for its konf, so 'konf set staging' works for staging.yaml. Kubeconfigs with multiple contexts receive one alias per context in the form of <filename>-<context>")
+	ic.cmd.Flags().BoolVar(&ic.scratch, "scratch", false, "mark the imported konfs as scratch konfs, which are removed by 'konf cleanup --scratch' once their cluster is not reachable anymore. Useful for throwaway clusters like kind or minikube")
+	ic.cmd.Flags().DurationVar(&ic.ttl, "ttl", 0, "additionally remove scratch konfs on 'konf cleanup' after this duration, e.g. 24h")
+	ic.cmd.Flags().BoolVar(&ic.bundle, "bundle", false, "import a bundle created by --export-bundle instead of a kubeconfig")
+	ic.cmd.Flags().StringVar(&ic.exportBundle, "export-bundle", "", "instead of importing, write the konfs supplied as arguments into a bundle at this path, which can be imported via --bundle. Bundles all konfs if none are supplied. Use - for stdout")
+	ic.cmd.Flags().StringVar(&ic.fetchCommand, "fetch-command", "", "import the kubeconfig printed by this command, e.g. of a secret manager. Only a stub without credentials is kept in the store, while the command is run again on every 'konf set'. The kubeconfig has to contain a single context")
+	ic.cmd.Flags().BoolVar(&ic.fromClip, "from-clipboard", false, "import the kubeconfig in the clipboard instead of reading it from a file")
+	ic.cmd.Flags().BoolVar(&ic.requireExec, "require-exec-auth", false, "refuse to import contexts that do not authenticate via an exec credential plugin, or whose user carries static credentials like client certificates, tokens or passwords")
+	ic.cmd.Flags().BoolVar(&ic.stripStatic, "strip-static-auth", false, "remove static credentials like client certificates, tokens or passwords from all imported users. Exec credential plugins and auth providers are kept")
+	ic.cmd.Flags().BoolVar(&ic.verify, "verify", false, "check whether the cluster of each konf is reachable before storing it, just like 'konf verify' does. All konfs are stored regardless of the result")
+	ic.cmd.Flags().BoolVar(&ic.skipUnreach, "skip-unreachable", false, "like --verify, but only store konfs whose cluster is reachable")
+	ic.cmd.Flags().StringVar(&ic.output, "output", outputIDs, fmt.Sprintf("format of the imported konfs printed to stdout. One of %q, %q", outputIDs, outputJSON))
+	ic.cmd.Flags().StringVar(&ic.onCollision, "on-collision", collisionOverwrite, fmt.Sprintf("what to do if a konf with the same id but different content already exists in the store. One of %q, %q, %q. With %q, a numeric suffix is appended to the context name of the imported konf", collisionOverwrite, collisionSkip, collisionRename, collisionRename))
+
 	return ic
 }
 
 // because import is a reserved word, we have to slightly rename this :)
 func (c *importCmd) importf(cmd *cobra.Command, args []string) error {
-	fpath := args[0] // safe, as we specify cobra.ExactArgs(1)
+	var fpath string
+	var confs []*konfFile
+	var err error
 
-	confs, err := c.determineConfigs(c.fs, fpath)
-	if err != nil {
-		return err
+	if c.exportBundle != "" {
+		if c.bundle || c.url != "" {
+			return fmt.Errorf("--export-bundle cannot be combined with --bundle or --url")
+		}
+		return c.writeBundle(cmd.OutOrStdout(), args)
+	}
+
+	if len(args) > 1 {
+		return fmt.Errorf("please supply only a single kubeconfig to import")
+	}
+
+	if c.validateOnly {
+		if len(args) != 1 || c.url != "" || c.fromClip || c.fetchCommand != "" || c.bundle {
+			return fmt.Errorf("--validate-only can only be used with a single kubeconfig file")
+		}
+		return c.validate(args[0])
+	}
+
+	if c.onCollision != collisionOverwrite && c.onCollision != collisionSkip && c.onCollision != collisionRename {
+		return fmt.Errorf("invalid value %q for --on-collision. Must be one of %q, %q, %q", c.onCollision, collisionOverwrite, collisionSkip, collisionRename)
+	}
+
+	if c.output != outputIDs && c.output != outputJSON {
+		return fmt.Errorf("invalid value %q for --output. Must be one of %q, %q", c.output, outputIDs, outputJSON)
+	}
+
+	if c.splitBy != splitByContext && c.splitBy != splitByCluster {
+		return fmt.Errorf("invalid value %q for --split-by. Must be one of %q, %q", c.splitBy, splitByContext, splitByCluster)
+	}
+
+	if c.forceNS && c.namespace == "" {
+		return fmt.Errorf("--force-namespace requires a namespace to be supplied via --default-namespace")
+	}
+
+	if c.ttl != 0 && !c.scratch {
+		return fmt.Errorf("--ttl can only be used together with --scratch")
+	}
+
+	// the context name ends up in the id, which must not contain these characters
+	if strings.ContainsAny(c.prefix, "/:") {
+		return fmt.Errorf("invalid value %q for --context-prefix. It must not contain '/' or ':'", c.prefix)
+	}
+
+	if c.aliasFile && c.url != "" {
+		return fmt.Errorf("--alias-filename can only be used when importing from a file")
+	}
+
+	if c.bundle && c.url != "" {
+		return fmt.Errorf("--bundle can only be used when importing from a file")
+	}
+
+	if c.fromClip {
+		if len(args) != 0 || c.url != "" || c.bundle || c.aliasFile || c.fetchCommand != "" {
+			return fmt.Errorf("--from-clipboard cannot be combined with a file, --url, --bundle, --alias-filename or --fetch-command")
+		}
+		fpath = "clipboard"
+
+		b, err := c.clipboard()
+		if err != nil {
+			return err
+		}
+		confs, err = splitClipboard(b, c.splitUsers)
+		if err != nil {
+			return err
+		}
+	} else if c.fetchCommand != "" {
+		if len(args) != 0 || c.url != "" || c.bundle || c.aliasFile {
+			return fmt.Errorf("--fetch-command cannot be combined with a file, --url, --bundle or --alias-filename")
+		}
+		// the id of a fetched konf has to stay the same, as it is checked against the fetched kubeconfig on every set
+		if c.onCollision == collisionRename {
+			return fmt.Errorf("--fetch-command cannot be combined with --on-collision %s", collisionRename)
+		}
+		if c.prefix != "" {
+			return fmt.Errorf("--fetch-command cannot be combined with --context-prefix")
+		}
+		// fetched konfs never keep any credentials in the store
+		if c.requireExec || c.stripStatic {
+			return fmt.Errorf("--fetch-command cannot be combined with --require-exec-auth or --strip-static-auth")
+		}
+		if c.verify || c.skipUnreach {
+			return fmt.Errorf("--fetch-command cannot be combined with --verify or --skip-unreachable")
+		}
+		fpath = c.fetchCommand
+
+		b, err := runFetchCommand(c.fetchCommand)
+		if err != nil {
+			return err
+		}
+		confs, err = splitConfigs(b, c.splitUsers)
+		if err != nil {
+			return err
+		}
+		if len(confs) > 1 {
+			return fmt.Errorf("--fetch-command has to return a kubeconfig with a single context, got %d", len(confs))
+		}
+		for _, conf := range confs {
+			stripCredentials(conf)
+		}
+	} else if c.url != "" {
+		if len(args) != 0 {
+			return fmt.Errorf("please either supply a file or --url, but not both")
+		}
+		fpath = c.url
+
+		b, err := c.fetchURL(&http.Client{Timeout: config.Timeout()}, c.url, c.headers)
+		if err != nil {
+			return err
+		}
+		confs, err = splitConfigs(b, c.splitUsers)
+		if err != nil {
+			return err
+		}
+	} else {
+		if len(args) != 1 {
+			return fmt.Errorf("please supply the kubeconfig to import either as a file or via --url")
+		}
+		fpath = args[0]
+
+		if c.bundle {
+			b, err := afero.ReadFile(c.fs, fpath)
+			if err != nil {
+				return err
+			}
+			confs, err = readBundle(b, c.splitUsers)
+			if err != nil {
+				return err
+			}
+		} else {
+			confs, err = c.determineConfigs(c.fs, fpath, c.splitUsers)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	if len(confs) == 0 {
 		return fmt.Errorf("no contexts found in file %q", fpath)
 	}
 
-	for _, conf := range confs {
+	if c.prefix != "" {
+		confs = applyContextPrefix(confs, c.prefix)
+	}
+
+	if c.splitBy == splitByCluster {
+		confs = groupByCluster(confs)
+	}
+
+	if c.namespace != "" {
+		applyDefaultNamespace(confs, c.namespace, c.forceNS)
+	}
+
+	if c.stripStatic {
+		stripStaticAuth(confs)
+	}
+	// this is checked before anything is written, so a rejected kubeconfig never partially ends up in the store
+	if c.requireExec {
+		err = requireExecAuth(confs)
+		if err != nil {
+			return err
+		}
+	}
+
+	// healthy holds the result of the check for each konf in confs
+	var healthy []bool
+	if c.verify || c.skipUnreach {
+		healthy = verifyKonfs(confs, c.healthCheck)
+		if c.skipUnreach {
+			confs, healthy = reachableKonfs(confs, healthy)
+			if len(confs) == 0 {
+				return fmt.Errorf("none of the clusters in %q are reachable", fpath)
+			}
+		}
+	}
+
+	counts := map[importResult]int{}
+	imported := []importedKonf{}
+	verified := map[string]bool{}
+	// sourceContexts maps the id of every konf in the store to the name of its context in the source
+	sourceContexts := map[string]string{}
+	for i, conf := range confs {
+		res, err := compareWithStore(c.fs, conf)
+		if err != nil {
+			return err
+		}
+		sourceContext := conf.Content.Contexts[0].Name
+		if res == konfUpdated && c.onCollision == collisionRename {
+			renamed, rres, err := renameUntilFree(c.fs, conf)
+			if err != nil {
+				return err
+			}
+			log.Info("Renamed context %q to %q, as konf %q already exists in the store with a different content\n", conf.Content.Contexts[0].Name, renamed.Content.Contexts[0].Name, conf.ID())
+			// later steps like registering aliases have to refer to the renamed konf
+			confs[i] = renamed
+			conf = renamed
+			counts[konfRenamed]++
+			res = rres
+		}
+
+		if healthy != nil {
+			verified[conf.ID()] = healthy[i]
+		}
+
+		if res != konfUpdated || c.onCollision != collisionSkip {
+			sourceContexts[conf.ID()] = sourceContext
+		}
+
+		if res == konfUnchanged {
+			counts[res]++
+			imported = append(imported, importedKonf{ID: conf.ID(), Result: res.String()})
+			log.Info("Konf %q is already up to date\n", conf.FilePath)
+			continue
+		}
+		if res == konfUpdated && c.onCollision == collisionSkip {
+			delete(verified, conf.ID())
+			counts[konfSkipped]++
+			log.Info("Skipped konf %q, as it already exists in the store with a different content\n", conf.FilePath)
+			continue
+		}
+
 		err = c.writeConfig(c.fs, conf)
 		if err != nil {
 			return err
 		}
+		counts[res]++
+		imported = append(imported, importedKonf{ID: conf.ID(), Result: res.String()})
 		log.Info("Imported konf from %q successfully into %q\n", fpath, conf.FilePath)
 	}
 
+	// the results are shown in the picker, just as if 'konf verify' had been run right after the import
+	if len(verified) > 0 {
+		err = recordVerified(c.fs, verified, clock.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	// a skipped konf is still the one that has been in the store before
+	if c.fetchCommand != "" && counts[konfSkipped] == 0 {
+		err = recordFetchCommand(c.fs, confs, c.fetchCommand)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info("Import finished: %d added, %d updated, %d unchanged, %d skipped, %d renamed\n", counts[konfAdded], counts[konfUpdated], counts[konfUnchanged], counts[konfSkipped], counts[konfRenamed])
+
+	if src := c.source(fpath); src != nil && len(sourceContexts) > 0 {
+		err = recordSources(c.fs, sourceContexts, *src)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.aliasFile {
+		err = registerFileAliases(c.fs, fpath, confs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.scratch {
+		err = markScratch(c.fs, confs, c.ttl, clock.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	// this is only a heuristic, so we leave it up to the user to decide
+	for _, finding := range sharedCAFindings(confs) {
+		log.Warn("%s. This might indicate a misconfiguration\n", finding)
+	}
+
+	return writeImported(cmd.OutOrStdout(), imported, c.output)
+}
+
+const (
+	outputIDs  = "ids"
+	outputJSON = "json"
+)
+
+// importedKonf describes a konf that ended up in the store during an import
+type importedKonf struct {
+	ID     string `json:"id"`
+	Result string `json:"result"`
+}
+
+// writeImported writes the imported konfs to w, so they can be picked up by scripts. With outputIDs, only the
+// ids are written one per line
+func writeImported(w io.Writer, imported []importedKonf, output string) error {
+	if output == outputJSON {
+		return json.NewEncoder(w).Encode(imported)
+	}
+	for _, k := range imported {
+		_, err := fmt.Fprintln(w, k.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks the kubeconfig at fpath strictly, without importing it
+func (c *importCmd) validate(fpath string) error {
+	b, err := readStoreFile(c.fs, fpath)
+	if err != nil {
+		return err
+	}
+	err = validateKubeconfig(b)
+	if err != nil {
+		return fmt.Errorf("kubeconfig %q is invalid: %v", fpath, err)
+	}
+	confs, err := splitConfigs(b, c.splitUsers)
+	if err != nil {
+		return fmt.Errorf("kubeconfig %q is invalid: %v", fpath, err)
+	}
+	if len(confs) == 0 {
+		return fmt.Errorf("no contexts found in file %q", fpath)
+	}
+
+	log.Info("Kubeconfig %q is valid and contains %d contexts\n", fpath, len(confs))
+	return nil
+}
+
+// validateKubeconfig decodes b strictly. Other than the lenient decoding konf uses everywhere else, it fails on
+// unknown fields, which are usually typos that would otherwise silently result in an empty or partial konf
+func validateKubeconfig(b []byte) error {
+	var conf k8s.Config
+	err := yaml.UnmarshalStrict(normalizeKubeconfig(b), &conf)
+	if err != nil {
+		return strictError(err)
+	}
+	return nil
+}
+
+// strictError removes the noise of the json decoder that is used under the hood from err
+func strictError(err error) error {
+	msg := err.Error()
+	for _, prefix := range []string{"error unmarshaling JSON: ", "while decoding JSON: ", "json: "} {
+		msg = strings.TrimPrefix(msg, prefix)
+	}
+	return errors.New(msg)
+}
+
+// writeBundle writes a bundle of the konfs with the supplied ids to the path of --export-bundle
+func (c *importCmd) writeBundle(stdout io.Writer, ids []string) error {
+	b, n, err := exportBundle(c.fs, ids)
+	if err != nil {
+		return err
+	}
+
+	if c.exportBundle == "-" {
+		_, err = stdout.Write(b)
+		return err
+	}
+	// bundles contain credentials, so they are only readable by the user, just like the store
+	err = afero.WriteFile(c.fs, c.exportBundle, b, utils.KonfPerm)
+	if err != nil {
+		return err
+	}
+	log.Info("Exported %d konfs into bundle %q\n", n, c.exportBundle)
 	return nil
 }
 
+// source describes where the konfs of this import come from, so they can be resynced later on. Clipboards, bundles
+// and fetch commands cannot be resynced, which is why nil is returned for them
+func (c *importCmd) source(fpath string) *konfSource {
+	src := &konfSource{Prefix: c.prefix, SplitBy: c.splitBy, SplitUsers: c.splitUsers, StripStaticAuth: c.stripStatic}
+	switch {
+	case c.fromClip || c.fetchCommand != "" || c.bundle:
+		return nil
+	case c.url != "":
+		src.URL = c.url
+	default:
+		abs, err := filepath.Abs(fpath)
+		if err != nil {
+			log.Warn("could not determine the absolute path of %q. The imported konfs cannot be resynced: %v\n", fpath, err)
+			return nil
+		}
+		src.Path = abs
+	}
+	return src
+}
+
+// recordSources records src as the source of every konf in contexts, which maps their ids to the name of their
+// context in the source
+func recordSources(f afero.Fs, contexts map[string]string, src konfSource) error {
+	m, err := loadMetadata(f)
+	if err != nil {
+		return err
+	}
+	for id, ctx := range contexts {
+		s := src
+		s.Context = ctx
+		m.konf(id).Source = &s
+	}
+	return saveMetadata(f, m)
+}
+
+// registerFileAliases registers the base name of the file fpath as an alias for the konfs imported from it
+// Aliases that are taken already are skipped, as they should never silently point to a different konf
+func registerFileAliases(f afero.Fs, fpath string, confs []*konfFile) error {
+	m, err := loadMetadata(f)
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(fpath), filepath.Ext(fpath))
+	for _, conf := range confs {
+		alias := base
+		if len(confs) > 1 {
+			alias = base + "-" + conf.Content.Contexts[0].Name
+		}
+		id := conf.ID()
+
+		err := m.addAlias(f, alias, id)
+		if err != nil {
+			log.Warn("Could not register alias %q for konf %q: %v\n", alias, id, err)
+			continue
+		}
+		log.Info("Registered alias %q for konf %q\n", alias, id)
+	}
+
+	return saveMetadata(f, m)
+}
+
+// markScratch marks the konfs as scratch konfs. If ttl is set, they expire after it
+func markScratch(f afero.Fs, confs []*konfFile, ttl time.Duration, now time.Time) error {
+	m, err := loadMetadata(f)
+	if err != nil {
+		return err
+	}
+
+	for _, conf := range confs {
+		km := m.konf(conf.ID())
+		km.Scratch = true
+		km.Expires = nil
+		if ttl != 0 {
+			exp := now.Add(ttl)
+			km.Expires = &exp
+		}
+	}
+
+	return saveMetadata(f, m)
+}
+
+const (
+	collisionOverwrite = "overwrite"
+	collisionSkip      = "skip"
+	collisionRename    = "rename"
+)
+
+const (
+	splitByContext = "context"
+	splitByCluster = "cluster"
+)
+
+// importResult describes what happens to a konf during import in relation to the existing store
+type importResult int
+
+const (
+	konfAdded importResult = iota
+	konfUpdated
+	konfUnchanged
+	konfSkipped
+	// konfRenamed is only counted in addition to the result of the renamed konf
+	konfRenamed
+)
+
+func (r importResult) String() string {
+	switch r {
+	case konfAdded:
+		return "added"
+	case konfUpdated:
+		return "updated"
+	case konfUnchanged:
+		return "unchanged"
+	case konfSkipped:
+		return "skipped"
+	default:
+		return "renamed"
+	}
+}
+
+// renameUntilFree appends the first numeric suffix to the context of kf, that results in an id which is either
+// free or holds the very same konf already, e.g. because the kubeconfig has been imported with rename before.
+// It returns the renamed konf together with its result in relation to the store
+func renameUntilFree(f afero.Fs, kf *konfFile) (*konfFile, importResult, error) {
+	for n := 2; ; n++ {
+		renamed := renameContext(kf, fmt.Sprintf("%s-%d", kf.Content.Contexts[0].Name, n))
+		res, err := compareWithStore(f, renamed)
+		if err != nil {
+			return nil, res, err
+		}
+		if res != konfUpdated {
+			return renamed, res, nil
+		}
+	}
+}
+
+// renameContext returns a copy of kf, in which its first context is renamed to name. The id is derived from
+// the new name, so the store file matches the context inside of it
+func renameContext(kf *konfFile, name string) *konfFile {
+	renamed := &konfFile{Content: kf.Content}
+	renamed.Content.Contexts = append([]k8s.NamedContext{}, kf.Content.Contexts...)
+
+	old := renamed.Content.Contexts[0].Name
+	renamed.Content.Contexts[0].Name = name
+	if renamed.Content.CurrentContext == old {
+		renamed.Content.CurrentContext = name
+	}
+	renamed.FilePath = utils.StorePathForID(utils.IDFromClusterAndContext(renamed.Content.Contexts[0].Context.Cluster, name))
+	return renamed
+}
+
+// applyContextPrefix prefixes the context names of all confs, which also changes their ids
+func applyContextPrefix(confs []*konfFile, prefix string) []*konfFile {
+	prefixed := []*konfFile{}
+	for _, conf := range confs {
+		prefixed = append(prefixed, renameContext(conf, prefix+"-"+conf.Content.Contexts[0].Name))
+	}
+	return prefixed
+}
+
+// applyDefaultNamespace sets ns for all contexts without a namespace. If force is set, existing namespaces
+// are overwritten as well
+func applyDefaultNamespace(confs []*konfFile, ns string, force bool) {
+	for _, conf := range confs {
+		for i := range conf.Content.Contexts {
+			ctx := &conf.Content.Contexts[i].Context
+			if ctx.Namespace == "" || force {
+				ctx.Namespace = ns
+			}
+		}
+	}
+}
+
+// compareWithStore determines whether a konf is new to the store, differs from what is stored under
+// the same id or is identical to it
+func compareWithStore(f afero.Fs, kf *konfFile) (importResult, error) {
+	b, err := readStoreFile(f, kf.FilePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return konfAdded, nil
+		}
+		return konfAdded, err
+	}
+
+	var stored k8s.Config
+	err = yaml.Unmarshal(b, &stored)
+	if err != nil {
+		// a broken file in the store should simply be replaced by the import
+		return konfUpdated, nil
+	}
+
+	if reflect.DeepEqual(stored, kf.Content) {
+		return konfUnchanged, nil
+	}
+	return konfUpdated, nil
+}
+
 // determineConfigs returns the individual configs from a konfigfile
 // This is required as konfig requires each kubeconfig in its store to
 // only contain a single context
 // If more than one cluster is in a kubeconfig, determineConfig will split it up
 // into multiple konfigFile and returns them as a slice
-func determineConfigs(f afero.Fs, fpath string) ([]*konfFile, error) {
+// If splitUsers is set, each konfigFile only receives its own copy of the user referenced by its context
+func determineConfigs(f afero.Fs, fpath string, splitUsers bool) ([]*konfFile, error) {
 
-	b, err := afero.ReadFile(f, fpath)
+	// 'konf store import-merge' splits files from the store, which might be encrypted
+	b, err := readStoreFile(f, fpath)
 	if err != nil {
 		return nil, err
 	}
 
+	return splitConfigs(b, splitUsers)
+}
+
+// splitConfigs does the actual splitting for determineConfigs on the raw bytes of a kubeconfig
+func splitConfigs(b []byte, splitUsers bool) ([]*konfFile, error) {
 	var origConf k8s.Config
-	err = yaml.Unmarshal(b, &origConf)
+	err := yaml.Unmarshal(normalizeKubeconfig(b), &origConf)
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +732,25 @@ func determineConfigs(f afero.Fs, fpath string) ([]*konfFile, error) {
 	// 3. Find the corresponding user for each context
 	// 4. Create a new konfigFile for each context mapped to its cluster
 
+	// a dangling current-context does not affect the split, as every konf sets its own one. It still hints at a broken source though
+	if origConf.CurrentContext != "" {
+		found := false
+		for _, curCon := range origConf.Contexts {
+			if curCon.Name == origConf.CurrentContext {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Warn("The current-context %q of the kubeconfig does not match any of its contexts\n", origConf.CurrentContext)
+		}
+	}
+
+	// contexts with the same name would end up with the same id, so one of them would silently overwrite the other
+	if dups := duplicateContexts(origConf.Contexts); len(dups) > 0 {
+		return nil, fmt.Errorf("the kubeconfig contains multiple contexts named %q. Please give each context a unique name", dups)
+	}
+
 	var konfs = []*konfFile{}
 	for _, curCon := range origConf.Contexts {
 
@@ -106,25 +761,31 @@ func determineConfigs(f afero.Fs, fpath string) ([]*konfFile, error) {
 				break
 			}
 		}
-		user := k8s.NamedAuthInfo{}
-		for _, curU := range origConf.AuthInfos {
+		var user *k8s.NamedAuthInfo
+		for i, curU := range origConf.AuthInfos {
 			if curU.Name == curCon.Context.AuthInfo {
-				user = curU
+				user = &origConf.AuthInfos[i]
 				break
 			}
 		}
+		if user == nil {
+			log.Warn("Context %q references the user %q, which is not defined in the kubeconfig\n", curCon.Name, curCon.Context.AuthInfo)
+		}
 
 		var konf konfFile
-		// TODO it might make sense to build in a duplicate detection here. This would ensure that the store is trustworthy, which in return makes it easy for
-		// TODO the set command as it does not need any verification
 		id := utils.IDFromClusterAndContext(cluster.Name, curCon.Name)
 		konf.FilePath = utils.StorePathForID(id)
-		konf.Content.AuthInfos = append(konf.Content.AuthInfos, user)
+		if !splitUsers {
+			konf.Content.AuthInfos = append(konf.Content.AuthInfos, origConf.AuthInfos...)
+		} else if user != nil {
+			konf.Content.AuthInfos = append(konf.Content.AuthInfos, *user)
+		}
 		konf.Content.Clusters = append(konf.Content.Clusters, cluster)
 		konf.Content.Contexts = append(konf.Content.Contexts, curCon)
 
 		konf.Content.APIVersion = origConf.APIVersion
 		konf.Content.Kind = origConf.Kind
+		// each konf only contains a single context, which therefore has to be the current one for kubectl to pick it up
 		konf.Content.CurrentContext = curCon.Name
 
 		konfs = append(konfs, &konf)
@@ -133,13 +794,136 @@ func determineConfigs(f afero.Fs, fpath string) ([]*konfFile, error) {
 	return konfs, nil
 }
 
+// groupByCluster merges all konfs that share a cluster into the first of them. The merged konf keeps its id and
+// current-context, which means it is identified by its first context just like any other konf
+func groupByCluster(confs []*konfFile) []*konfFile {
+	grouped := []*konfFile{}
+	byCluster := map[string]*konfFile{}
+	for _, conf := range confs {
+		cluster := conf.Content.Clusters[0].Name
+		first, ok := byCluster[cluster]
+		if !ok {
+			byCluster[cluster] = conf
+			grouped = append(grouped, conf)
+			continue
+		}
+
+		first.Content.Contexts = append(first.Content.Contexts, conf.Content.Contexts...)
+		for _, user := range conf.Content.AuthInfos {
+			if !hasUser(first.Content.AuthInfos, user.Name) {
+				first.Content.AuthInfos = append(first.Content.AuthInfos, user)
+			}
+		}
+	}
+	return grouped
+}
+
+func hasUser(users []k8s.NamedAuthInfo, name string) bool {
+	for _, u := range users {
+		if u.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// duplicateContexts returns the names of all contexts that occur more than once, in order of their first occurrence
+func duplicateContexts(contexts []k8s.NamedContext) []string {
+	seen := map[string]int{}
+	dups := []string{}
+	for _, c := range contexts {
+		seen[c.Name]++
+		if seen[c.Name] == 2 {
+			dups = append(dups, c.Name)
+		}
+	}
+	return dups
+}
+
+// normalizeKubeconfig removes a UTF-8 byte order mark and converts CRLF line endings, which are both common
+// for kubeconfigs that have been edited on Windows
+func normalizeKubeconfig(b []byte) []byte {
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+	return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+}
+
+// refuseDowngrade stops redirects to anything but https, as they would download the kubeconfig unencrypted.
+// Otherwise it behaves like the default policy of http.Client and follows up to 10 redirects
+func refuseDowngrade(req *http.Request, via []*http.Request) error {
+	if req.URL.Scheme != "https" {
+		return fmt.Errorf("refusing to follow the redirect to %q, only https urls are supported", req.URL)
+	}
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// fetchURL downloads a kubeconfig from the supplied url. Only https is allowed, as kubeconfigs
+// usually contain credentials. TLS certificates are verified by the client
+func fetchURL(client *http.Client, rawURL string, headers []string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("refusing to download kubeconfig from %q, only https urls are supported", rawURL)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range headers {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("header %q is not in the form of 'Name: value'", h)
+		}
+		req.Header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+
+	// the client is copied, so redirects can be checked without altering the one of the caller
+	c := *client
+	c.CheckRedirect = refuseDowngrade
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not download kubeconfig from %q: server responded with %q", rawURL, resp.Status)
+	}
+
+	// login pages or error pages are a common mishap with authenticated urls, so we rather stop early
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err == nil && (mt == "text/html" || mt == "application/xhtml+xml") {
+			return nil, fmt.Errorf("the response from %q is of type %q and not a yaml kubeconfig", rawURL, mt)
+		}
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var conf k8s.Config
+	err = yaml.Unmarshal(b, &conf)
+	if err != nil {
+		return nil, fmt.Errorf("the response from %q is not a valid yaml kubeconfig: %v", rawURL, err)
+	}
+
+	return b, nil
+}
+
 func writeConfig(f afero.Fs, kf *konfFile) error {
 	b, err := yaml.Marshal(kf.Content)
 	if err != nil {
 		return err
 	}
 
-	err = afero.WriteFile(f, kf.FilePath, b, utils.KonfPerm)
+	err = writeStoreFile(f, kf.FilePath, b)
 	if err != nil {
 		return err
 	}
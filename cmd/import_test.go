@@ -1,26 +1,46 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/simontheleg/konf-go/config"
 	"github.com/simontheleg/konf-go/testhelper"
 	"github.com/simontheleg/konf-go/utils"
 	"github.com/spf13/afero"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
 )
 
+// importSource creates a kubeconfig outside of the konf store, which can be used as a source for import
+func importSource(path, content string) func(afero.Fs) {
+	return func(f afero.Fs) {
+		afero.WriteFile(f, path, []byte(content), utils.KonfPerm)
+	}
+}
+
 func TestImport(t *testing.T) {
 	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
 	var determineConfigsCalled bool
 	var writeConfigCalledCount int
 	// using just a wrapper here instead of a full mock, makes testing it slightly easier
-	var wrapDetermineConfig = func(f afero.Fs, fpath string) ([]*konfFile, error) {
+	var wrapDetermineConfig = func(f afero.Fs, fpath string, splitUsers bool) ([]*konfFile, error) {
 		determineConfigsCalled = true
-		return determineConfigs(f, fpath)
+		return determineConfigs(f, fpath, splitUsers)
 	}
 	var mockWriteConfig = func(afero.Fs, *konfFile) error { writeConfigCalledCount++; return nil }
 
@@ -35,8 +55,8 @@ func TestImport(t *testing.T) {
 		ExpCalls
 	}{
 		"single context": {
-			[]string{"./konf/store/dev-eu_dev-eu-1.yaml"},
-			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"./import/dev-eu.yaml"},
+			testhelper.FSWithFiles(fm.StoreDir, importSource("./import/dev-eu.yaml", sm.SingleClusterSingleContextEU())),
 			nil,
 			ExpCalls{DetermineConfigs: true, WriteConfig: 1},
 		},
@@ -46,6 +66,42 @@ func TestImport(t *testing.T) {
 			fmt.Errorf("no contexts found in file \"./konf/store/no-context.yaml\""),
 			ExpCalls{DetermineConfigs: true, WriteConfig: 0},
 		},
+		"duplicate context names": {
+			[]string{"./import/duplicates.yaml"},
+			testhelper.FSWithFiles(fm.StoreDir, importSource("./import/duplicates.yaml", `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://10.0.0.1:6443
+  name: dev-eu-1
+contexts:
+- context:
+    cluster: dev-eu-1
+    namespace: default
+    user: dev-eu
+  name: dev-eu
+- context:
+    cluster: dev-eu-1
+    namespace: kube-system
+    user: dev-eu
+  name: dev-eu
+- context:
+    cluster: dev-eu-1
+    user: dev-eu
+  name: dev-eu-admin
+users:
+- name: dev-eu
+  user: {}
+`)),
+			fmt.Errorf("the kubeconfig contains multiple contexts named [\"dev-eu\"]. Please give each context a unique name"),
+			ExpCalls{DetermineConfigs: true, WriteConfig: 0},
+		},
+		"no file and no url": {
+			[]string{},
+			testhelper.FSWithFiles(fm.StoreDir),
+			fmt.Errorf("please supply the kubeconfig to import either as a file or via --url"),
+			ExpCalls{DetermineConfigs: false, WriteConfig: 0},
+		},
 	}
 
 	for name, tc := range tt {
@@ -79,6 +135,182 @@ func TestImport(t *testing.T) {
 	}
 }
 
+func TestImportCollisions(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	// the store version of dev-eu differs from the one in multiClusterMultiContext by its namespace
+	storedEU := strings.Replace(sm.SingleClusterSingleContextEU(), "kube-public", "kube-system", 1)
+
+	tt := map[string]struct {
+		onCollision string
+		expErr      error
+	}{
+		"overwrite": {
+			collisionOverwrite,
+			nil,
+		},
+		"skip": {
+			collisionSkip,
+			nil,
+		},
+		"rename": {
+			collisionRename,
+			nil,
+		},
+		"invalid policy": {
+			"merge",
+			fmt.Errorf("invalid value \"merge\" for --on-collision. Must be one of \"overwrite\", \"skip\", \"rename\""),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(fm.StoreDir, importSource(devEUControlGroup.FilePath, storedEU), importSource("./import/multi.yaml", sm.MultiClusterMultiContext()))
+			icmd := newImportCmd()
+			icmd.fs = f
+			icmd.onCollision = tc.onCollision
+			cmd := icmd.cmd
+
+			err := cmd.RunE(cmd, []string{"./import/multi.yaml"})
+			if !testhelper.EqualError(tc.expErr, err) {
+				t.Fatalf("Exp error %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			res, err := compareWithStore(f, devEUControlGroup)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if tc.onCollision == collisionOverwrite && res != konfUnchanged {
+				t.Errorf("Exp dev-eu to be overwritten by import, but it was not")
+			}
+			if tc.onCollision == collisionSkip && res != konfUpdated {
+				t.Errorf("Exp dev-eu to be skipped during import, but it was overwritten")
+			}
+			if tc.onCollision == collisionRename {
+				if res != konfUpdated {
+					t.Errorf("Exp dev-eu to be kept during import, but it was overwritten")
+				}
+				b, err := afero.ReadFile(f, utils.StorePathForID("dev-eu-2_dev-eu-1"))
+				if err != nil {
+					t.Fatalf("Exp dev-eu to be imported as dev-eu-2, got %q", err)
+				}
+				conf := parseKonf(t, b)
+				if conf.Contexts[0].Name != "dev-eu-2" || conf.CurrentContext != "dev-eu-2" {
+					t.Errorf("Exp the context to be renamed to dev-eu-2, got %q with current-context %q", conf.Contexts[0].Name, conf.CurrentContext)
+				}
+
+				// importing the same kubeconfig again must not create yet another copy
+				err = cmd.RunE(cmd, []string{"./import/multi.yaml"})
+				if err != nil {
+					t.Fatalf("Exp no error on second import, got %q", err)
+				}
+				if _, err := f.Stat(utils.StorePathForID("dev-eu-3_dev-eu-1")); err == nil {
+					t.Errorf("Exp a repeated import to reuse dev-eu-2")
+				}
+			}
+
+			if _, err := f.Stat(devASIAControlGroup.FilePath); err != nil {
+				t.Errorf("Exp new konf %q to be added regardless of policy, but got %q", devASIAControlGroup.FilePath, err)
+			}
+		})
+	}
+}
+
+func TestCompareWithStore(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		kf     *konfFile
+		expRes importResult
+	}{
+		"new konf": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			devEUControlGroup,
+			konfAdded,
+		},
+		"identical konf": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			devEUControlGroup,
+			konfUnchanged,
+		},
+		"changed konf": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			&konfFile{FilePath: devEUControlGroup.FilePath, Content: devASIAControlGroup.Content},
+			konfUpdated,
+		},
+		"invalid konf in store": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.InvalidYaml),
+			&konfFile{FilePath: "./konf/store/no-konf.yaml", Content: devEUControlGroup.Content},
+			konfUpdated,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res, err := compareWithStore(tc.fs, tc.kf)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if res != tc.expRes {
+				t.Errorf("Exp result %d, got %d", tc.expRes, res)
+			}
+		})
+	}
+}
+
+func TestImportFromURL(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	var writeConfigCalledCount int
+	var mockWriteConfig = func(afero.Fs, *konfFile) error { writeConfigCalledCount++; return nil }
+	var mockFetchURL = func(*http.Client, string, []string) ([]byte, error) {
+		return []byte(sm.MultiClusterMultiContext()), nil
+	}
+
+	tt := map[string]struct {
+		Args           []string
+		ExpErr         error
+		ExpWriteConfig int
+	}{
+		"url only": {
+			[]string{},
+			nil,
+			2,
+		},
+		"url and file": {
+			[]string{"./konf/store/dev-eu_dev-eu-1.yaml"},
+			fmt.Errorf("please either supply a file or --url, but not both"),
+			0,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			writeConfigCalledCount = 0
+
+			icmd := newImportCmd()
+			icmd.fs = afero.NewMemMapFs()
+			icmd.writeConfig = mockWriteConfig
+			icmd.fetchURL = mockFetchURL
+			icmd.url = "https://example.com/kubeconfig"
+			cmd := icmd.cmd
+
+			err := cmd.RunE(cmd, tc.Args)
+			if !testhelper.EqualError(tc.ExpErr, err) {
+				t.Errorf("Exp error %q, got %q", tc.ExpErr, err)
+			}
+
+			if tc.ExpWriteConfig != writeConfigCalledCount {
+				t.Errorf("Exp WriteConfigCalled to be %d, but got %d", tc.ExpWriteConfig, writeConfigCalledCount)
+			}
+		})
+	}
+}
+
 var devEUControlGroup = &konfFile{
 	FilePath: utils.StorePathForID(utils.IDFromClusterAndContext("dev-eu-1", "dev-eu")),
 	Content: k8s.Config{
@@ -201,7 +433,7 @@ func TestDetermineConfigs(t *testing.T) {
 
 	for name, tc := range tt {
 		t.Run(name, func(t *testing.T) {
-			res, err := determineConfigs(tc.Fs, tc.konfpath)
+			res, err := determineConfigs(tc.Fs, tc.konfpath, true)
 
 			if !testhelper.EqualError(err, tc.ExpError) {
 				t.Errorf("Want error '%s', got '%s'", tc.ExpError, err)
@@ -273,3 +505,895 @@ users:
 	}
 
 }
+
+func TestFetchURL(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/konf":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/yaml")
+			fmt.Fprint(w, sm.SingleClusterSingleContextEU())
+		case "/login":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<html><body>please login</body></html>")
+		case "/garbage":
+			fmt.Fprint(w, "I am no valid yaml")
+		case "/moved":
+			http.Redirect(w, r, "/konf", http.StatusFound)
+		case "/downgrade":
+			http.Redirect(w, r, "http://"+r.Host+"/konf", http.StatusFound)
+		}
+	}))
+	srv.Config.ErrorLog = stdlog.New(io.Discard, "", 0) // the untrusted certificate case would otherwise spam the test output
+	defer srv.Close()
+
+	tt := map[string]struct {
+		client  *http.Client
+		url     string
+		headers []string
+		expErr  error
+	}{
+		"valid kubeconfig": {
+			srv.Client(),
+			srv.URL + "/konf",
+			[]string{"Authorization: Bearer secret"},
+			nil,
+		},
+		"plain http": {
+			srv.Client(),
+			"http://example.com/konf",
+			[]string{},
+			fmt.Errorf("refusing to download kubeconfig from \"http://example.com/konf\", only https urls are supported"),
+		},
+		"https redirect": {
+			srv.Client(),
+			srv.URL + "/moved",
+			[]string{"Authorization: Bearer secret"},
+			nil,
+		},
+		"redirect to plain http": {
+			srv.Client(),
+			srv.URL + "/downgrade",
+			[]string{"Authorization: Bearer secret"},
+			fmt.Errorf("Get \"http://%s/konf\": refusing to follow the redirect to \"http://%s/konf\", only https urls are supported", srv.Listener.Addr(), srv.Listener.Addr()),
+		},
+		"missing auth": {
+			srv.Client(),
+			srv.URL + "/konf",
+			[]string{},
+			fmt.Errorf("could not download kubeconfig from %q: server responded with \"401 Unauthorized\"", srv.URL+"/konf"),
+		},
+		"malformed header": {
+			srv.Client(),
+			srv.URL + "/konf",
+			[]string{"Authorization"},
+			fmt.Errorf("header \"Authorization\" is not in the form of 'Name: value'"),
+		},
+		"html response": {
+			srv.Client(),
+			srv.URL + "/login",
+			[]string{},
+			fmt.Errorf("the response from %q is of type \"text/html\" and not a yaml kubeconfig", srv.URL+"/login"),
+		},
+		"no kubeconfig": {
+			srv.Client(),
+			srv.URL + "/garbage",
+			[]string{},
+			fmt.Errorf("the response from %q is not a valid yaml kubeconfig: error unmarshaling JSON: while decoding JSON: json: cannot unmarshal string into Go value of type v1.Config", srv.URL+"/garbage"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res, err := fetchURL(tc.client, tc.url, tc.headers)
+
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp error %q, got %q", tc.expErr, err)
+			}
+
+			if tc.expErr == nil && string(res) != sm.SingleClusterSingleContextEU() {
+				t.Errorf("Exp content %q, got %q", sm.SingleClusterSingleContextEU(), res)
+			}
+		})
+	}
+
+	// the error message of an invalid certificate differs between go versions, so we check the type instead
+	t.Run("untrusted certificate", func(t *testing.T) {
+		_, err := fetchURL(&http.Client{}, srv.URL+"/konf", []string{"Authorization: Bearer secret"})
+		if !errors.As(err, &x509.UnknownAuthorityError{}) {
+			t.Errorf("Exp error to be of type x509.UnknownAuthorityError, got %q", err)
+		}
+	})
+}
+
+func TestSplitUsers(t *testing.T) {
+	var sharedUser = `
+apiVersion: v1
+clusters:
+  - cluster:
+      server: https://10.1.1.0
+    name: dev-eu-1
+  - cluster:
+      server: https://192.168.0.1
+    name: dev-asia-1
+contexts:
+  - context:
+      cluster: dev-eu-1
+      user: shared
+    name: dev-eu
+  - context:
+      cluster: dev-asia-1
+      user: shared
+    name: dev-asia
+current-context: dev-eu
+kind: Config
+users:
+  - name: shared
+    user:
+      token: secret
+  - name: unused
+    user:
+      token: other-secret
+`
+
+	tt := map[string]struct {
+		splitUsers bool
+		expUsers   []string
+	}{
+		"split users": {
+			true,
+			[]string{"shared"},
+		},
+		"do not split users": {
+			false,
+			[]string{"shared", "unused"},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			konfs, err := splitConfigs([]byte(sharedUser), tc.splitUsers)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if len(konfs) != 2 {
+				t.Fatalf("Exp 2 konfs, got %d", len(konfs))
+			}
+
+			for _, k := range konfs {
+				users := []string{}
+				for _, u := range k.Content.AuthInfos {
+					users = append(users, u.Name)
+				}
+				if !cmp.Equal(users, tc.expUsers) {
+					t.Errorf("Exp users of konf %q to be %q, got %q", k.FilePath, tc.expUsers, users)
+				}
+				if findings := undefinedUserFindings([]*konfFile{k}); len(findings) != 0 {
+					t.Errorf("Exp konf %q to define all its users, got %q", k.FilePath, findings)
+				}
+			}
+
+			// each konf must carry its own copy of the credentials, so they can be revoked independently
+			konfs[0].Content.AuthInfos[0].AuthInfo.Token = "revoked"
+			if konfs[1].Content.AuthInfos[0].AuthInfo.Token != "secret" {
+				t.Errorf("Exp konfs to not share credentials")
+			}
+		})
+	}
+}
+
+func TestImportedCurrentContext(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs    afero.Fs
+		fpath string
+		expID string
+	}{
+		"current-context of another context": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.MultiClusterMultiContext),
+			"./konf/store/multi_multi_konf.yaml",
+			"dev-asia_dev-asia-1",
+		},
+		"dangling current-context": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.MultiClusterSingleContext),
+			"./konf/store/multi_konf.yaml",
+			"dev-asia_dev-asia-1",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			icmd := newImportCmd()
+			icmd.fs = tc.fs
+			err := icmd.cmd.RunE(icmd.cmd, []string{tc.fpath})
+			if err != nil {
+				t.Fatalf("Exp no error during import, got %q", err)
+			}
+
+			active, err := setContext(tc.expID, tc.fs)
+			if err != nil {
+				t.Fatalf("Exp no error during set, got %q", err)
+			}
+
+			b, err := afero.ReadFile(tc.fs, active)
+			if err != nil {
+				t.Fatalf("Could not read active file: %q", err)
+			}
+			var conf k8s.Config
+			err = yaml.Unmarshal(b, &conf)
+			if err != nil {
+				t.Fatalf("Could not unmarshal active file: %q", err)
+			}
+
+			if len(conf.Contexts) != 1 {
+				t.Fatalf("Exp active file to contain a single context, got %d", len(conf.Contexts))
+			}
+			if conf.CurrentContext != conf.Contexts[0].Name {
+				t.Errorf("Exp current-context to be %q, got %q", conf.Contexts[0].Name, conf.CurrentContext)
+			}
+		})
+	}
+}
+
+func TestImportWindowsKubeconfig(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+
+	tt := map[string]struct {
+		content string
+	}{
+		"byte order mark": {
+			"\xef\xbb\xbf" + sm.SingleClusterSingleContextEU(),
+		},
+		"crlf line endings": {
+			strings.ReplaceAll(sm.SingleClusterSingleContextEU(), "\n", "\r\n"),
+		},
+		"byte order mark and crlf line endings": {
+			"\xef\xbb\xbf" + strings.ReplaceAll(sm.SingleClusterSingleContextEU(), "\n", "\r\n"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(importSource("./import/dev-eu.yaml", tc.content))
+
+			icmd := newImportCmd()
+			icmd.fs = f
+			err := icmd.cmd.RunE(icmd.cmd, []string{"./import/dev-eu.yaml"})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			b, err := afero.ReadFile(f, "./konf/store/dev-eu_dev-eu-1.yaml")
+			if err != nil {
+				t.Fatalf("Could not read store file: %q", err)
+			}
+			if bytes.HasPrefix(b, []byte("\xef\xbb\xbf")) || bytes.Contains(b, []byte("\r")) {
+				t.Errorf("Exp store file to be normalized, got %q", b)
+			}
+			if !cmp.Equal(devEUControlGroup.Content, parseKonf(t, b)) {
+				t.Errorf("Exp and given konfs differ:\n%s", cmp.Diff(devEUControlGroup.Content, parseKonf(t, b)))
+			}
+		})
+	}
+}
+
+func parseKonf(t *testing.T, b []byte) k8s.Config {
+	var conf k8s.Config
+	err := yaml.Unmarshal(b, &conf)
+	if err != nil {
+		t.Fatalf("Could not unmarshal konf: %q", err)
+	}
+	return conf
+}
+
+func TestNormalizeKubeconfig(t *testing.T) {
+	tt := map[string]struct {
+		in  string
+		exp string
+	}{
+		"unix":            {"apiVersion: v1\nkind: Config\n", "apiVersion: v1\nkind: Config\n"},
+		"byte order mark": {"\xef\xbb\xbfapiVersion: v1\n", "apiVersion: v1\n"},
+		"crlf":            {"apiVersion: v1\r\nkind: Config\r\n", "apiVersion: v1\nkind: Config\n"},
+		"lone cr is kept": {"token: a\rb\n", "token: a\rb\n"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res := string(normalizeKubeconfig([]byte(tc.in)))
+			if res != tc.exp {
+				t.Errorf("Exp %q, got %q", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestImportDefaultNamespace(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	noNamespace := strings.Replace(sm.MultiClusterMultiContext(), "namespace: kube-system", "", 1)
+
+	tt := map[string]struct {
+		namespace string
+		force     bool
+		expErr    error
+		expNS     map[string]string
+	}{
+		"no default namespace": {
+			"",
+			false,
+			nil,
+			map[string]string{"dev-asia_dev-asia-1": "", "dev-eu_dev-eu-1": "kube-public"},
+		},
+		"default namespace": {
+			"team",
+			false,
+			nil,
+			map[string]string{"dev-asia_dev-asia-1": "team", "dev-eu_dev-eu-1": "kube-public"},
+		},
+		"forced namespace": {
+			"team",
+			true,
+			nil,
+			map[string]string{"dev-asia_dev-asia-1": "team", "dev-eu_dev-eu-1": "team"},
+		},
+		"force without namespace": {
+			"",
+			true,
+			fmt.Errorf("--force-namespace requires a namespace to be supplied via --default-namespace"),
+			map[string]string{},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(importSource("./import/multi.yaml", noNamespace))
+
+			icmd := newImportCmd()
+			icmd.fs = f
+			icmd.namespace = tc.namespace
+			icmd.forceNS = tc.force
+			err := icmd.cmd.RunE(icmd.cmd, []string{"./import/multi.yaml"})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			for id, ns := range tc.expNS {
+				b, err := afero.ReadFile(f, utils.StorePathForID(id))
+				if err != nil {
+					t.Fatalf("Could not read konf %q: %q", id, err)
+				}
+				if res := parseKonf(t, b).Contexts[0].Context.Namespace; res != ns {
+					t.Errorf("Exp namespace of konf %q to be %q, got %q", id, ns, res)
+				}
+			}
+		})
+	}
+}
+
+func TestImportAuthProvider(t *testing.T) {
+	gcp := `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://34.1.2.3
+  name: gke_project_europe-west1_prod
+contexts:
+- context:
+    cluster: gke_project_europe-west1_prod
+    user: gke_project_europe-west1_prod
+  name: gke_project_europe-west1_prod
+current-context: gke_project_europe-west1_prod
+users:
+- name: gke_project_europe-west1_prod
+  user:
+    auth-provider:
+      name: gcp
+      config:
+        access-token: ya29.token
+        cmd-args: config config-helper --format=json
+        cmd-path: /usr/lib/google-cloud-sdk/bin/gcloud
+        expiry: "2022-01-01T12:00:00Z"
+        expiry-key: '{.credential.token_expiry}'
+        token-key: '{.credential.access_token}'
+`
+	expAuthProvider := &k8s.AuthProviderConfig{
+		Name: "gcp",
+		Config: map[string]string{
+			"access-token": "ya29.token",
+			"cmd-args":     "config config-helper --format=json",
+			"cmd-path":     "/usr/lib/google-cloud-sdk/bin/gcloud",
+			"expiry":       "2022-01-01T12:00:00Z",
+			"expiry-key":   "{.credential.token_expiry}",
+			"token-key":    "{.credential.access_token}",
+		},
+	}
+	id := "gke_project_europe-west1_prod_gke_project_europe-west1_prod"
+
+	f := testhelper.FSWithFiles(importSource("./gke.yaml", gcp))
+	icmd := newImportCmd()
+	icmd.fs = f
+	err := icmd.cmd.RunE(icmd.cmd, []string{"./gke.yaml"})
+	if err != nil {
+		t.Fatalf("Exp no error during import, got %q", err)
+	}
+
+	active, err := setContext(id, f)
+	if err != nil {
+		t.Fatalf("Exp no error during set, got %q", err)
+	}
+
+	for _, path := range []string{utils.StorePathForID(id), active} {
+		b, err := afero.ReadFile(f, path)
+		if err != nil {
+			t.Fatalf("Could not read %q: %q", path, err)
+		}
+		conf := parseKonf(t, b)
+		if len(conf.AuthInfos) != 1 {
+			t.Fatalf("Exp %q to contain a single user, got %d", path, len(conf.AuthInfos))
+		}
+		if !cmp.Equal(conf.AuthInfos[0].AuthInfo.AuthProvider, expAuthProvider) {
+			t.Errorf("Exp auth-provider of %q to be preserved: \n '%s'", path, cmp.Diff(expAuthProvider, conf.AuthInfos[0].AuthInfo.AuthProvider))
+		}
+	}
+}
+
+func TestImportAliasFilename(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	tt := map[string]struct {
+		fs         afero.Fs
+		fpath      string
+		expAliases map[string]string
+	}{
+		"single context": {
+			testhelper.FSWithFiles(fm.StoreDir, importSource("./import/staging.yaml", sm.SingleClusterSingleContextEU())),
+			"./import/staging.yaml",
+			map[string]string{"staging": "dev-eu_dev-eu-1"},
+		},
+		"multiple contexts": {
+			testhelper.FSWithFiles(fm.StoreDir, importSource("./import/staging.yaml", sm.MultiClusterMultiContext())),
+			"./import/staging.yaml",
+			map[string]string{"staging-dev-asia": "dev-asia_dev-asia-1", "staging-dev-eu": "dev-eu_dev-eu-1"},
+		},
+		"alias is taken": {
+			testhelper.FSWithFiles(fm.StoreDir, importSource("./import/staging.yaml", sm.SingleClusterSingleContextEU()), metadataFile("aliases:\n  staging: dev-asia_dev-asia-1\n")),
+			"./import/staging.yaml",
+			map[string]string{"staging": "dev-asia_dev-asia-1"},
+		},
+		"alias is a konf id": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextASIA, importSource("./import/dev-asia_dev-asia-1.yaml", sm.SingleClusterSingleContextEU())),
+			"./import/dev-asia_dev-asia-1.yaml",
+			nil,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			icmd := newImportCmd()
+			icmd.fs = tc.fs
+			icmd.aliasFile = true
+
+			err := icmd.cmd.RunE(icmd.cmd, []string{tc.fpath})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			if !cmp.Equal(m.Aliases, tc.expAliases, cmpopts.EquateEmpty()) {
+				t.Errorf("Exp and given aliases differ: \n '%s'", cmp.Diff(tc.expAliases, m.Aliases))
+			}
+		})
+	}
+
+	icmd := newImportCmd()
+	icmd.fs = testhelper.FSWithFiles(fm.StoreDir)
+	icmd.aliasFile = true
+	icmd.url = "https://example.com/staging.yaml"
+	expErr := fmt.Errorf("--alias-filename can only be used when importing from a file")
+	err := icmd.cmd.RunE(icmd.cmd, []string{})
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp err %q, got %q", expErr, err)
+	}
+}
+
+func TestMarkScratch(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	exp := now.Add(24 * time.Hour)
+
+	tt := map[string]struct {
+		ttl     time.Duration
+		expMeta *konfMetadata
+	}{
+		"without ttl": {0, &konfMetadata{Scratch: true}},
+		"with ttl":    {24 * time.Hour, &konfMetadata{Scratch: true, Expires: &exp}},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(fm.StoreDir)
+			confs := []*konfFile{{FilePath: utils.StorePathForID("dev-eu_dev-eu-1")}}
+
+			err := markScratch(f, confs, tc.ttl, now)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			m, err := loadMetadata(f)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			if !cmp.Equal(m.Konfs["dev-eu_dev-eu-1"], tc.expMeta) {
+				t.Errorf("Exp and given metadata differ: \n '%s'", cmp.Diff(tc.expMeta, m.Konfs["dev-eu_dev-eu-1"]))
+			}
+		})
+	}
+
+	icmd := newImportCmd()
+	icmd.fs = testhelper.FSWithFiles(fm.StoreDir)
+	icmd.ttl = time.Hour
+	expErr := fmt.Errorf("--ttl can only be used together with --scratch")
+	err := icmd.cmd.RunE(icmd.cmd, []string{"./import/staging.yaml"})
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp err %q, got %q", expErr, err)
+	}
+}
+
+var sharedCluster = `
+apiVersion: v1
+kind: Config
+clusters:
+  - cluster:
+      server: https://prod.example.com
+    name: prod
+  - cluster:
+      server: https://staging.example.com
+    name: staging
+contexts:
+  - context:
+      cluster: prod
+      user: admin
+    name: prod-admin
+  - context:
+      cluster: staging
+      user: admin
+    name: staging
+  - context:
+      cluster: prod
+      user: viewer
+    name: prod-view
+current-context: staging
+users:
+  - name: admin
+    user: {}
+  - name: viewer
+    user: {}
+`
+
+func TestImportSplitByCluster(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	f := testhelper.FSWithFiles(fm.StoreDir, importSource("./import/kubeconfig.yaml", sharedCluster))
+
+	icmd := newImportCmd()
+	icmd.fs = f
+	icmd.splitBy = splitByCluster
+	err := icmd.cmd.RunE(icmd.cmd, []string{"./import/kubeconfig.yaml"})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	tt := map[string]struct {
+		expContexts []string
+		expUsers    []string
+	}{
+		"prod-admin_prod": {[]string{"prod-admin", "prod-view"}, []string{"admin", "viewer"}},
+		"staging_staging": {[]string{"staging"}, []string{"admin"}},
+	}
+	for id, tc := range tt {
+		b, err := afero.ReadFile(f, utils.StorePathForID(id))
+		if err != nil {
+			t.Fatalf("Exp konf %q to be imported, got %q", id, err)
+		}
+		conf := parseKonf(t, b)
+
+		contexts := []string{}
+		for _, c := range conf.Contexts {
+			contexts = append(contexts, c.Name)
+		}
+		users := []string{}
+		for _, u := range conf.AuthInfos {
+			users = append(users, u.Name)
+		}
+		if !cmp.Equal(contexts, tc.expContexts) {
+			t.Errorf("Exp and given contexts of konf %q differ: \n '%s'", id, cmp.Diff(tc.expContexts, contexts))
+		}
+		if !cmp.Equal(users, tc.expUsers) {
+			t.Errorf("Exp and given users of konf %q differ: \n '%s'", id, cmp.Diff(tc.expUsers, users))
+		}
+		if len(conf.Clusters) != 1 || conf.CurrentContext != tc.expContexts[0] {
+			t.Errorf("Exp konf %q to contain a single cluster and its first context as current-context, got %d clusters and %q", id, len(conf.Clusters), conf.CurrentContext)
+		}
+	}
+
+	// konfs that are split by cluster have to be usable just like any other konf
+	konfs, err := fetchKonfs(f)
+	if err != nil {
+		t.Errorf("Exp konfs split by cluster to be valid, got %q", err)
+	}
+	if len(konfs) != 2 {
+		t.Errorf("Exp 2 konfs in the store, got %d", len(konfs))
+	}
+	_, err = setContext("prod-admin_prod", f)
+	if err != nil {
+		t.Errorf("Exp konf split by cluster to be set, got %q", err)
+	}
+
+	icmd = newImportCmd()
+	icmd.fs = f
+	icmd.splitBy = "user"
+	expErr := fmt.Errorf("invalid value \"user\" for --split-by. Must be one of \"context\", \"cluster\"")
+	err = icmd.cmd.RunE(icmd.cmd, []string{"./import/kubeconfig.yaml"})
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp err %q, got %q", expErr, err)
+	}
+}
+
+func TestImportContextPrefix(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+
+	tt := map[string]struct {
+		prefix   string
+		splitBy  string
+		expErr   error
+		expKonfs map[string]string
+	}{
+		"prefix": {
+			"aws",
+			splitByContext,
+			nil,
+			map[string]string{"aws-dev-asia_dev-asia-1": "aws-dev-asia", "aws-dev-eu_dev-eu-1": "aws-dev-eu"},
+		},
+		"prefix with split by cluster": {
+			"gcp",
+			splitByCluster,
+			nil,
+			map[string]string{"gcp-dev-asia_dev-asia-1": "gcp-dev-asia", "gcp-dev-eu_dev-eu-1": "gcp-dev-eu"},
+		},
+		"invalid prefix": {
+			"aws/prod",
+			splitByContext,
+			fmt.Errorf("invalid value \"aws/prod\" for --context-prefix. It must not contain '/' or ':'"),
+			map[string]string{},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(importSource("./import/multi.yaml", sm.MultiClusterMultiContext()))
+
+			icmd := newImportCmd()
+			icmd.fs = f
+			icmd.prefix = tc.prefix
+			icmd.splitBy = tc.splitBy
+			err := icmd.cmd.RunE(icmd.cmd, []string{"./import/multi.yaml"})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			for id, context := range tc.expKonfs {
+				b, err := afero.ReadFile(f, utils.StorePathForID(id))
+				if err != nil {
+					t.Fatalf("Could not read konf %q: %q", id, err)
+				}
+				conf := parseKonf(t, b)
+				if conf.Contexts[0].Name != context || conf.CurrentContext != context {
+					t.Errorf("Exp konf %q to contain context %q, got %q with current-context %q", id, context, conf.Contexts[0].Name, conf.CurrentContext)
+				}
+			}
+		})
+	}
+}
+
+func TestImportValidateOnly(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	typo := strings.Replace(sm.SingleClusterSingleContextEU(), "cluster: dev-eu-1", "clustr: dev-eu-1", 1)
+
+	tt := map[string]struct {
+		content string
+		url     string
+		expErr  error
+	}{
+		"valid": {
+			sm.MultiClusterMultiContext(),
+			"",
+			nil,
+		},
+		"misspelled field": {
+			typo,
+			"",
+			fmt.Errorf("kubeconfig \"./import/konf.yaml\" is invalid: unknown field \"clustr\""),
+		},
+		"no contexts": {
+			"apiVersion: v1\nkind: Config\n",
+			"",
+			fmt.Errorf("no contexts found in file \"./import/konf.yaml\""),
+		},
+		"combined with url": {
+			sm.MultiClusterMultiContext(),
+			"https://example.com/kubeconfig",
+			fmt.Errorf("--validate-only can only be used with a single kubeconfig file"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(importSource("./import/konf.yaml", tc.content))
+
+			icmd := newImportCmd()
+			icmd.fs = f
+			icmd.validateOnly = true
+			icmd.url = tc.url
+			err := icmd.cmd.RunE(icmd.cmd, []string{"./import/konf.yaml"})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			if _, err := f.Stat(config.StoreDir()); err == nil {
+				t.Errorf("Exp store to stay untouched, but it was created")
+			}
+		})
+	}
+}
+
+func TestImportOutput(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	collision := func(f afero.Fs) {
+		afero.WriteFile(f, utils.StorePathForID("dev-eu_dev-eu-1"), []byte(strings.Replace(sm.SingleClusterSingleContextEU(), "kube-public", "other", 1)), utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		fs          afero.Fs
+		output      string
+		onCollision string
+		expOut      string
+		expErr      error
+	}{
+		"ids": {
+			testhelper.FSWithFiles(importSource("./import/multi.yaml", sm.MultiClusterMultiContext())),
+			outputIDs,
+			collisionOverwrite,
+			"dev-asia_dev-asia-1\ndev-eu_dev-eu-1\n",
+			nil,
+		},
+		"json": {
+			testhelper.FSWithFiles(importSource("./import/multi.yaml", sm.MultiClusterMultiContext()), collision),
+			outputJSON,
+			collisionOverwrite,
+			`[{"id":"dev-asia_dev-asia-1","result":"added"},{"id":"dev-eu_dev-eu-1","result":"updated"}]` + "\n",
+			nil,
+		},
+		"skipped konfs are left out": {
+			testhelper.FSWithFiles(importSource("./import/multi.yaml", sm.MultiClusterMultiContext()), collision),
+			outputIDs,
+			collisionSkip,
+			"dev-asia_dev-asia-1\n",
+			nil,
+		},
+		"invalid output": {
+			testhelper.FSWithFiles(importSource("./import/multi.yaml", sm.MultiClusterMultiContext())),
+			"yaml",
+			collisionOverwrite,
+			"",
+			fmt.Errorf("invalid value \"yaml\" for --output. Must be one of \"ids\", \"json\""),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			icmd := newImportCmd()
+			icmd.fs = tc.fs
+			icmd.output = tc.output
+			icmd.onCollision = tc.onCollision
+			icmd.cmd.SetOut(&out)
+
+			err := icmd.cmd.RunE(icmd.cmd, []string{"./import/multi.yaml"})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if out.String() != tc.expOut {
+				t.Errorf("Exp output %q, got %q", tc.expOut, out.String())
+			}
+		})
+	}
+}
+
+func TestImportVerify(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+
+	tt := map[string]struct {
+		verify      bool
+		skipUnreach bool
+		unreachable string
+		expErr      error
+		expStored   []string
+		expVerified map[string]bool
+	}{
+		"verify": {
+			true, false,
+			"https://192.168.0.1",
+			nil,
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+			map[string]bool{"dev-asia_dev-asia-1": false, "dev-eu_dev-eu-1": true},
+		},
+		"skip unreachable": {
+			false, true,
+			"https://192.168.0.1",
+			nil,
+			[]string{"dev-eu_dev-eu-1"},
+			map[string]bool{"dev-eu_dev-eu-1": true},
+		},
+		"nothing reachable": {
+			false, true,
+			"",
+			fmt.Errorf("none of the clusters in \"./import/multi.yaml\" are reachable"),
+			[]string{},
+			map[string]bool{},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(importSource("./import/multi.yaml", sm.MultiClusterMultiContext()))
+
+			icmd := newImportCmd()
+			icmd.fs = f
+			icmd.verify = tc.verify
+			icmd.skipUnreach = tc.skipUnreach
+			icmd.healthCheck = func(b []byte, timeout time.Duration) error {
+				conf := parseKonf(t, b)
+				if tc.unreachable == "" || conf.Clusters[0].Cluster.Server == tc.unreachable {
+					return fmt.Errorf("connection refused")
+				}
+				return nil
+			}
+			err := icmd.cmd.RunE(icmd.cmd, []string{"./import/multi.yaml"})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			stored := []string{}
+			for _, id := range []string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"} {
+				if _, err := f.Stat(utils.StorePathForID(id)); err == nil {
+					stored = append(stored, id)
+				}
+			}
+			if !cmp.Equal(tc.expStored, stored) {
+				t.Errorf("Exp konfs %v to be stored, got %v", tc.expStored, stored)
+			}
+
+			m, err := loadMetadata(f)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			verified := map[string]bool{}
+			for id, km := range m.Konfs {
+				if km.Verified != nil {
+					verified[id] = km.Verified.Healthy
+				}
+			}
+			if !cmp.Equal(tc.expVerified, verified) {
+				t.Errorf("Exp verify results %v, got %v", tc.expVerified, verified)
+			}
+		})
+	}
+}
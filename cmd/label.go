@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type labelCmd struct {
+	fs afero.Fs
+
+	cmd *cobra.Command
+}
+
+func newLabelCmd() *labelCmd {
+	lc := &labelCmd{
+		fs: utils.NewFs(),
+	}
+
+	lc.cmd = &cobra.Command{
+		Use:   "label",
+		Short: "Add or remove labels of a konf",
+		Long: `Add or remove labels of a konf
+
+Labels are stored next to the konf store and can be used to select konfs via 'konf set --label'.
+
+Examples:
+	-> 'label <konfig id> env=prod team=platform' add or update labels
+	-> 'label <konfig id> env-' remove the label env
+`,
+		Args:              cobra.MinimumNArgs(2),
+		RunE:              lc.label,
+		ValidArgsFunction: lc.completeLabel,
+	}
+
+	return lc
+}
+
+func (c *labelCmd) label(cmd *cobra.Command, args []string) error {
+	id := args[0]
+	_, err := c.fs.Stat(utils.ResolveStorePath(c.fs, id))
+	if err != nil {
+		return &KonfNotFound{ID: id}
+	}
+
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+	km := m.konf(id)
+
+	for _, arg := range args[1:] {
+		if key := strings.TrimSuffix(arg, "-"); key != arg && !strings.Contains(arg, "=") {
+			delete(km.Labels, key)
+			continue
+		}
+		key, value, err := parseLabel(arg)
+		if err != nil {
+			return err
+		}
+		if km.Labels == nil {
+			km.Labels = map[string]string{}
+		}
+		km.Labels[key] = value
+	}
+	// an empty map would keep the entry alive, even if it does not hold any information anymore
+	if len(km.Labels) == 0 {
+		km.Labels = nil
+	}
+
+	err = saveMetadata(c.fs, m)
+	if err != nil {
+		return err
+	}
+	log.Info("Labels of konf %q are now %q\n", id, formatLabels(km.Labels))
+
+	return nil
+}
+
+func (c *labelCmd) completeLabel(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return []string{}, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	konfs, err := fetchKonfs(c.fs)
+	if err != nil {
+		if errors.Is(err, &EmptyStore{}) {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	sug := []string{}
+	for _, konf := range konfs {
+		sug = append(sug, konf.ID())
+	}
+	return sug, cobra.ShellCompDirectiveNoFileComp
+}
+
+// parseLabel splits a label in the form of key=value
+func parseLabel(l string) (string, string, error) {
+	parts := strings.SplitN(l, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("invalid label %q. Please use the form key=value", l)
+	}
+	return parts[0], parts[1], nil
+}
+
+// formatLabels returns all labels in the form of key=value, sorted by their key
+func formatLabels(labels map[string]string) []string {
+	ls := []string{}
+	for k, v := range labels {
+		ls = append(ls, k+"="+v)
+	}
+	sort.Strings(ls)
+	return ls
+}
+
+// filterByLabels returns all konfs that carry every one of the supplied labels
+func filterByLabels(konfs []tableOutput, selectors []string) ([]tableOutput, error) {
+	want := map[string]string{}
+	for _, s := range selectors {
+		key, value, err := parseLabel(s)
+		if err != nil {
+			return nil, err
+		}
+		want[key] = value
+	}
+
+	matches := []tableOutput{}
+	for _, k := range konfs {
+		match := true
+		for key, value := range want {
+			if v, ok := k.Labels[key]; !ok || v != value {
+				match = false
+				break
+			}
+		}
+		if match {
+			matches = append(matches, k)
+		}
+	}
+	return matches, nil
+}
+
+// selectByLabels selects the konf that carries all of the supplied labels. If multiple konfs match, the user
+// can pick from them
+func selectByLabels(f afero.Fs, selectors []string, s Selector) (string, error) {
+	konfs, err := fetchKonfs(f)
+	if err != nil {
+		return "", err
+	}
+
+	matches, err := filterByLabels(konfs, selectors)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no konf matches the labels %q", selectors)
+	case 1:
+		return matches[0].ID(), nil
+	default:
+		return selectKonf(matches, s)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(newLabelCmd().cmd)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/spf13/afero"
+)
+
+func TestLabel(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs        afero.Fs
+		args      []string
+		expErr    error
+		expLabels map[string]string
+	}{
+		"add labels": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"dev-eu_dev-eu-1", "env=dev", "team=platform"},
+			nil,
+			map[string]string{"env": "dev", "team": "platform"},
+		},
+		"update and remove labels": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    labels:\n      env: dev\n      team: platform\n")),
+			[]string{"dev-eu_dev-eu-1", "env=prod", "team-"},
+			nil,
+			map[string]string{"env": "prod"},
+		},
+		"remove last label": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    labels:\n      env: dev\n")),
+			[]string{"dev-eu_dev-eu-1", "env-"},
+			nil,
+			nil,
+		},
+		"invalid label": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"dev-eu_dev-eu-1", "env"},
+			fmt.Errorf("invalid label \"env\". Please use the form key=value"),
+			nil,
+		},
+		"konf does not exist": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			[]string{"dev-eu_dev-eu-1", "env=dev"},
+			&KonfNotFound{ID: "dev-eu_dev-eu-1"},
+			nil,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			lc := newLabelCmd()
+			lc.fs = tc.fs
+
+			err := lc.cmd.RunE(lc.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			if !cmp.Equal(m.konf("dev-eu_dev-eu-1").Labels, tc.expLabels) {
+				t.Errorf("Exp and given labels differ: \n '%s'", cmp.Diff(tc.expLabels, m.konf("dev-eu_dev-eu-1").Labels))
+			}
+		})
+	}
+}
+
+func TestSelectByLabels(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	labels := metadataFile(`konfs:
+  dev-eu_dev-eu-1:
+    labels:
+      env: dev
+      region: eu
+  dev-asia_dev-asia-1:
+    labels:
+      env: dev
+      region: asia
+`)
+
+	tt := map[string]struct {
+		selectors    []string
+		sel          int
+		expID        string
+		expErr       error
+		expPrompted  bool
+		expNumPicked int
+	}{
+		"unique match": {
+			[]string{"env=dev", "region=eu"},
+			0,
+			"dev-eu_dev-eu-1",
+			nil,
+			false,
+			0,
+		},
+		"multiple matches open the picker": {
+			[]string{"env=dev"},
+			1,
+			"dev-eu_dev-eu-1",
+			nil,
+			true,
+			2,
+		},
+		"no match": {
+			[]string{"env=prod"},
+			0,
+			"",
+			fmt.Errorf("no konf matches the labels [\"env=prod\"]"),
+			false,
+			0,
+		},
+		"all labels have to match": {
+			[]string{"env=dev", "region=us"},
+			0,
+			"",
+			fmt.Errorf("no konf matches the labels [\"env=dev\" \"region=us\"]"),
+			false,
+			0,
+		},
+		"invalid selector": {
+			[]string{"env"},
+			0,
+			"",
+			fmt.Errorf("invalid label \"env\". Please use the form key=value"),
+			false,
+			0,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, labels)
+			prompted := false
+			numPicked := 0
+			pf := promptFunc(func(p *promptui.Select) (int, error) {
+				prompted = true
+				numPicked = len(p.Items.([]tableOutput))
+				return tc.sel, nil
+			})
+
+			id, err := selectByLabels(f, tc.selectors, pf)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if id != tc.expID {
+				t.Errorf("Exp id %q, got %q", tc.expID, id)
+			}
+			if prompted != tc.expPrompted || numPicked != tc.expNumPicked {
+				t.Errorf("Exp picker to be opened %t with %d konfs, got %t with %d", tc.expPrompted, tc.expNumPicked, prompted, numPicked)
+			}
+		})
+	}
+}
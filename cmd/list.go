@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/simontheleg/konf-go/config"
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// listSortKeys contains all columns the list can be sorted by
+var listSortKeys = map[string]func(a, b tableOutput) bool{
+	"context": func(a, b tableOutput) bool { return a.Context < b.Context },
+	"cluster": func(a, b tableOutput) bool { return a.Cluster < b.Cluster },
+	"file":    func(a, b tableOutput) bool { return a.File < b.File },
+}
+
+const (
+	outputTable = "table"
+	outputWide  = "wide"
+)
+
+// listedKonf describes a konf in the json output of 'konf ls'
+type listedKonf struct {
+	ID       string            `json:"id"`
+	Context  string            `json:"context"`
+	Cluster  string            `json:"cluster"`
+	File     string            `json:"file"`
+	Favorite bool              `json:"favorite"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Note     string            `json:"note,omitempty"`
+	Status   string            `json:"status,omitempty"`
+	// IsLatest is set for the konf that 'konf set -' switches to
+	IsLatest bool `json:"isLatest"`
+	// IsActive is set for the konf that is active in the current shell
+	IsActive bool `json:"isActive"`
+}
+
+// konfState holds the ids of the latest konf and of the konf that is active in the current shell
+type konfState struct {
+	latest string
+	active string
+}
+
+type listCmd struct {
+	fs afero.Fs
+
+	processAlive func(int) (bool, error)
+
+	format    string
+	output    string
+	sort      string
+	filter    string
+	active    bool
+	inactive  bool
+	number    bool
+	noHeaders bool
+
+	cmd *cobra.Command
+}
+
+func newListCmd() *listCmd {
+	lc := &listCmd{
+		fs:           utils.NewFs(),
+		processAlive: processAlive,
+	}
+
+	lc.cmd = &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List all konfs in the store",
+		Long: `List all konfs in the store
+
+Examples:
+	-> 'ls' list all konfs as a table
+	-> 'ls --filter eu --sort cluster' list all konfs matching eu, sorted by their cluster
+	-> 'ls --format '{{.Context}} -> {{.Cluster}}'' list all konfs in a custom format
+	-> 'ls --active' list all konfs that are in use by any running shell
+	-> 'ls --number' list all konfs with the index that 'set --index' selects them by
+	-> 'ls --output wide' list all konfs and mark the latest konf and the konf active in the current shell
+	-> 'ls --output json' list all konfs as json
+	-> 'ls --no-headers' list all konfs as a table without the header row, e.g. for awk
+
+--format takes a Go template, which is applied to every konf. Besides the sprig functions, the following
+fields are available: .ID, .Context, .Cluster, .File, .Favorite, .Labels, .Note and .Status
+`,
+		Args: cobra.NoArgs,
+		RunE: lc.list,
+	}
+
+	lc.cmd.Flags().StringVar(&lc.format, "format", "", "Go template that is applied to every konf, e.g. '{{.Context}} -> {{.Cluster}}'")
+	lc.cmd.Flags().StringVar(&lc.output, "output", outputTable, fmt.Sprintf("output format. One of %q, %q, %q", outputTable, outputWide, outputJSON))
+	lc.cmd.Flags().StringVar(&lc.sort, "sort", "", "sort konfs by one of context, cluster or file. By default favorites are listed first")
+	lc.cmd.Flags().BoolVar(&lc.active, "active", false, "only list konfs that are active in any running shell")
+	lc.cmd.Flags().BoolVar(&lc.inactive, "inactive", false, "only list konfs that are not active in any running shell")
+	lc.cmd.Flags().BoolVar(&lc.number, "number", false, "prefix each konf with the index that 'konf set --index' selects it by")
+	lc.cmd.Flags().BoolVar(&lc.noHeaders, "no-headers", false, "do not print the header row of the table")
+	lc.cmd.Flags().StringVar(&lc.filter, "filter", "", "only list konfs that fuzzy match this term, just like the search of the picker")
+
+	return lc
+}
+
+func (c *listCmd) list(cmd *cobra.Command, args []string) error {
+	var tmpl *template.Template
+	if c.format != "" {
+		var err error
+		tmpl, err = template.New("format").Funcs(newTemplateFuncMap()).Parse(c.format)
+		if err != nil {
+			return fmt.Errorf("invalid --format template: %v", err)
+		}
+	}
+
+	less, ok := listSortKeys[c.sort]
+	if c.sort != "" && !ok {
+		return fmt.Errorf("cannot sort by %q. Please use one of context, cluster or file", c.sort)
+	}
+
+	if c.number && tmpl != nil {
+		return fmt.Errorf("--number cannot be combined with --format")
+	}
+
+	if c.output != outputTable && c.output != outputWide && c.output != outputJSON {
+		return fmt.Errorf("invalid value %q for --output. Must be one of %q, %q, %q", c.output, outputTable, outputWide, outputJSON)
+	}
+	if c.output != outputTable && tmpl != nil {
+		return fmt.Errorf("--output %s cannot be combined with --format", c.output)
+	}
+	if c.output == outputJSON && c.number {
+		return fmt.Errorf("--output %s cannot be combined with --number", c.output)
+	}
+	if c.noHeaders && (c.output == outputJSON || tmpl != nil) {
+		return fmt.Errorf("--no-headers can only be used with the table outputs")
+	}
+
+	if c.active && c.inactive {
+		return fmt.Errorf("please use either --active or --inactive, but not both")
+	}
+	var inUse map[string]bool
+	if c.active || c.inactive {
+		var err error
+		inUse, err = activeKonfIDs(c.fs, c.processAlive)
+		if err != nil {
+			return err
+		}
+	}
+
+	konfs, err := fetchKonfs(c.fs)
+	// an empty store is no error when listing konfs, but an empty table would leave the user wondering why.
+	// Scripts on the other hand should still receive valid json
+	if errors.Is(err, &EmptyStore{}) {
+		log.Info("%s\n", err)
+		if c.output == outputJSON {
+			return printJSON(cmd.OutOrStdout(), []tableOutput{}, &konfState{})
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// numbers are assigned before filtering and sorting, so they always match what 'set --index' picks
+	var numbers map[string]int
+	if c.number {
+		numbers = map[string]int{}
+		for i := range konfs {
+			numbers[konfs[i].ID()] = i + 1
+		}
+	}
+
+	rows := []tableOutput{}
+	for i := range konfs {
+		if c.filter != "" && !searchKonf(c.filter, &konfs[i]) {
+			continue
+		}
+		if inUse != nil && inUse[konfs[i].ID()] != c.active {
+			continue
+		}
+		rows = append(rows, konfs[i])
+	}
+	if less != nil {
+		sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+	}
+
+	if tmpl != nil {
+		return printFormatted(cmd.OutOrStdout(), tmpl, rows)
+	}
+
+	var state *konfState
+	if c.output != outputTable {
+		state, err = currentKonfState(c.fs)
+		if err != nil {
+			return err
+		}
+	}
+	if c.output == outputJSON {
+		return printJSON(cmd.OutOrStdout(), rows, state)
+	}
+	return printTable(cmd.OutOrStdout(), rows, numbers, state, !c.noHeaders)
+}
+
+// currentKonfState determines the latest konf and the konf that is active in the current shell. Both are empty if
+// no such konf exists
+func currentKonfState(f afero.Fs) (*konfState, error) {
+	state := &konfState{}
+	b, err := afero.ReadFile(f, config.LatestKonfFile())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	state.latest = string(b)
+
+	state.active, err = activeKonfIDFromFile(f, utils.ActivePathForPID(os.Getppid()))
+	if err != nil {
+		return nil, fmt.Errorf("could not determine the active konf of the current shell: %v", err)
+	}
+	return state, nil
+}
+
+// printJSON prints rows as a json array
+func printJSON(w io.Writer, rows []tableOutput, state *konfState) error {
+	konfs := []listedKonf{}
+	for _, r := range rows {
+		id := r.ID()
+		konfs = append(konfs, listedKonf{
+			ID:       id,
+			Context:  r.Context,
+			Cluster:  r.Cluster,
+			File:     r.File,
+			Favorite: r.Favorite,
+			Labels:   r.Labels,
+			Note:     r.Note,
+			Status:   r.Status,
+			IsLatest: id == state.latest,
+			IsActive: id == state.active,
+		})
+	}
+	b, err := json.MarshalIndent(konfs, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// printFormatted applies tmpl to every row and prints each result on its own line
+func printFormatted(w io.Writer, tmpl *template.Template, rows []tableOutput) error {
+	for _, r := range rows {
+		var sb strings.Builder
+		err := tmpl.Execute(&sb, r)
+		if err != nil {
+			return fmt.Errorf("could not apply --format to konf %q: %v", r.ID(), err)
+		}
+		_, err = fmt.Fprintln(w, sb.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printTable prints rows as a table. If numbers is set, each row is prefixed with the number of its konf. If state is
+// set, the latest konf and the konf active in the current shell are marked in additional columns. The header row is
+// only printed if headers is set
+func printTable(w io.Writer, rows []tableOutput, numbers map[string]int, state *konfState, headers bool) error {
+	status := false
+	for _, r := range rows {
+		status = status || r.Status != ""
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if headers {
+		if numbers != nil {
+			fmt.Fprint(tw, "#\t")
+		}
+		if status {
+			fmt.Fprint(tw, "STATUS\t")
+		}
+		fmt.Fprint(tw, "CONTEXT\tCLUSTER\tFILE")
+		if state != nil {
+			fmt.Fprint(tw, "\tLATEST\tACTIVE")
+		}
+		fmt.Fprintln(tw)
+	}
+	for _, r := range rows {
+		if numbers != nil {
+			fmt.Fprintf(tw, "%d\t", numbers[r.ID()])
+		}
+		if status {
+			fmt.Fprintf(tw, "%s\t", r.Status)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s", r.Display(), r.Cluster, r.File)
+		if state != nil {
+			fmt.Fprintf(tw, "\t%s\t%s", mark(r.ID() == state.latest), mark(r.ID() == state.active))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// mark returns the marker for set columns of the wide table
+func mark(set bool) string {
+	if set {
+		return "*"
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(newListCmd().cmd)
+}
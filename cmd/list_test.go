@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestList(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	storeFS := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA)
+	var prodKonf = func(f afero.Fs) {
+		konf := `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://prod.example.com
+  name: prod-1
+contexts:
+- context:
+    cluster: prod-1
+    user: prod
+  name: prod
+users:
+- name: prod
+  user: {}
+`
+		afero.WriteFile(f, utils.StorePathForID("prod_prod-1"), []byte(konf), utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		format string
+		sort   string
+		filter string
+		number bool
+		expOut string
+		expErr error
+	}{
+		"table": {
+			storeFS,
+			"", "", "", false,
+			"CONTEXT   CLUSTER     FILE\n" +
+				"dev-asia  dev-asia-1  ./konf/store/dev-asia_dev-asia-1.yaml\n" +
+				"dev-eu    dev-eu-1    ./konf/store/dev-eu_dev-eu-1.yaml\n",
+			nil,
+		},
+		"table with status": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    verified:\n      healthy: true\n      at: "+time.Now().Format(time.RFC3339)+"\n")),
+			"", "", "", false,
+			"STATUS  CONTEXT   CLUSTER     FILE\n" +
+				"?       dev-asia  dev-asia-1  ./konf/store/dev-asia_dev-asia-1.yaml\n" +
+				"✓       dev-eu    dev-eu-1    ./konf/store/dev-eu_dev-eu-1.yaml\n",
+			nil,
+		},
+		"table with numbers": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, prodKonf),
+			"", "", "prod", true,
+			"#  CONTEXT  CLUSTER  FILE\n" +
+				"3  prod     prod-1   ./konf/store/prod_prod-1.yaml\n",
+			nil,
+		},
+		"numbers with format": {
+			storeFS,
+			"{{.ID}}", "", "", true,
+			"",
+			fmt.Errorf("--number cannot be combined with --format"),
+		},
+		"format": {
+			storeFS,
+			"{{.Context}} -> {{.Cluster | upper}} ({{.ID}})", "", "", false,
+			"dev-asia -> DEV-ASIA-1 (dev-asia_dev-asia-1)\ndev-eu -> DEV-EU-1 (dev-eu_dev-eu-1)\n",
+			nil,
+		},
+		"format with sort and filter": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, prodKonf),
+			"{{.ID}}", "cluster", "dev", false,
+			"dev-asia_dev-asia-1\ndev-eu_dev-eu-1\n",
+			nil,
+		},
+		"filter without match": {
+			storeFS,
+			"{{.ID}}", "", "prod", false,
+			"",
+			nil,
+		},
+		"empty store": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			"{{.ID}}", "", "", false,
+			"",
+			nil,
+		},
+		"invalid template": {
+			storeFS,
+			"{{.Context", "", "", false,
+			"",
+			fmt.Errorf("invalid --format template: template: format:1: unclosed action"),
+		},
+		"unknown field": {
+			storeFS,
+			"{{.Namespace}}", "", "", false,
+			"",
+			fmt.Errorf("could not apply --format to konf \"dev-asia_dev-asia-1\": template: format:1:2: executing \"format\" at <.Namespace>: can't evaluate field Namespace in type cmd.tableOutput"),
+		},
+		"unknown sort key": {
+			storeFS,
+			"", "namespace", "", false,
+			"",
+			fmt.Errorf("cannot sort by \"namespace\". Please use one of context, cluster or file"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			lc := newListCmd()
+			lc.fs = tc.fs
+			lc.format = tc.format
+			lc.sort = tc.sort
+			lc.filter = tc.filter
+			lc.number = tc.number
+			var out bytes.Buffer
+			lc.cmd.SetOut(&out)
+
+			err := lc.cmd.RunE(lc.cmd, []string{})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+			if !cmp.Equal(out.String(), tc.expOut) {
+				t.Errorf("Exp and given output differ: \n '%s'", cmp.Diff(tc.expOut, out.String()))
+			}
+		})
+	}
+}
+
+func TestListActive(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+	var shells = func(f afero.Fs) {
+		afero.WriteFile(f, utils.ActivePathForID("100"), []byte(sm.SingleClusterSingleContextEU()), utils.KonfPerm)
+		// the shell of this active file is not running anymore
+		afero.WriteFile(f, utils.ActivePathForID("200"), []byte(sm.SingleClusterSingleContextASIA()), utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		active   bool
+		inactive bool
+		expOut   string
+		expErr   error
+	}{
+		"active":   {true, false, "dev-eu_dev-eu-1\n", nil},
+		"inactive": {false, true, "dev-asia_dev-asia-1\n", nil},
+		"both":     {true, true, "", fmt.Errorf("please use either --active or --inactive, but not both")},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			lc := newListCmd()
+			lc.fs = testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, shells)
+			lc.processAlive = func(pid int) (bool, error) { return pid == 100, nil }
+			lc.format = "{{.ID}}"
+			lc.active = tc.active
+			lc.inactive = tc.inactive
+			var out bytes.Buffer
+			lc.cmd.SetOut(&out)
+
+			err := lc.cmd.RunE(lc.cmd, []string{})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if out.String() != tc.expOut {
+				t.Errorf("Exp output %q, got %q", tc.expOut, out.String())
+			}
+		})
+	}
+}
+
+func TestListOutput(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	var latestASIA = func(f afero.Fs) {
+		afero.WriteFile(f, config.LatestKonfFile(), []byte("dev-asia_dev-asia-1"), utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		output string
+		format string
+		number bool
+		expOut string
+		expErr error
+	}{
+		"wide": {
+			outputWide, "", true,
+			"#  CONTEXT   CLUSTER     FILE                                   LATEST  ACTIVE\n" +
+				"1  dev-asia  dev-asia-1  ./konf/store/dev-asia_dev-asia-1.yaml  *       \n" +
+				"2  dev-eu    dev-eu-1    ./konf/store/dev-eu_dev-eu-1.yaml              *\n",
+			nil,
+		},
+		"json": {
+			outputJSON, "", false,
+			`[
+  {
+    "id": "dev-asia_dev-asia-1",
+    "context": "dev-asia",
+    "cluster": "dev-asia-1",
+    "file": "./konf/store/dev-asia_dev-asia-1.yaml",
+    "favorite": false,
+    "isLatest": true,
+    "isActive": false
+  },
+  {
+    "id": "dev-eu_dev-eu-1",
+    "context": "dev-eu",
+    "cluster": "dev-eu-1",
+    "file": "./konf/store/dev-eu_dev-eu-1.yaml",
+    "favorite": false,
+    "isLatest": false,
+    "isActive": true
+  }
+]
+`,
+			nil,
+		},
+		"json with format": {
+			outputJSON, "{{.ID}}", false,
+			"",
+			fmt.Errorf("--output json cannot be combined with --format"),
+		},
+		"json with number": {
+			outputJSON, "", true,
+			"",
+			fmt.Errorf("--output json cannot be combined with --number"),
+		},
+		"invalid output": {
+			"yaml", "", false,
+			"",
+			fmt.Errorf("invalid value \"yaml\" for --output. Must be one of \"table\", \"wide\", \"json\""),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			lc := newListCmd()
+			lc.fs = testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, activeEU, latestASIA)
+			lc.output = tc.output
+			lc.format = tc.format
+			lc.number = tc.number
+			var out bytes.Buffer
+			lc.cmd.SetOut(&out)
+
+			err := lc.cmd.RunE(lc.cmd, []string{})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if !cmp.Equal(out.String(), tc.expOut) {
+				t.Errorf("Exp and given output differ: \n '%s'", cmp.Diff(tc.expOut, out.String()))
+			}
+		})
+	}
+}
+
+func TestListNoHeaders(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		output string
+		format string
+		expOut string
+		expErr error
+	}{
+		"table": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			outputTable, "",
+			"dev-asia  dev-asia-1  ./konf/store/dev-asia_dev-asia-1.yaml\n" +
+				"dev-eu    dev-eu-1    ./konf/store/dev-eu_dev-eu-1.yaml\n",
+			nil,
+		},
+		"json": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			outputJSON, "",
+			"",
+			fmt.Errorf("--no-headers can only be used with the table outputs"),
+		},
+		"format": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			outputTable, "{{.ID}}",
+			"",
+			fmt.Errorf("--no-headers can only be used with the table outputs"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			lc := newListCmd()
+			lc.fs = tc.fs
+			lc.output = tc.output
+			lc.format = tc.format
+			lc.noHeaders = true
+			var out bytes.Buffer
+			lc.cmd.SetOut(&out)
+
+			err := lc.cmd.RunE(lc.cmd, []string{})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if !cmp.Equal(out.String(), tc.expOut) {
+				t.Errorf("Exp and given output differ: \n '%s'", cmp.Diff(tc.expOut, out.String()))
+			}
+		})
+	}
+}
+
+func TestListEmptyStore(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		output string
+		expOut string
+	}{
+		"table": {outputTable, ""},
+		"wide":  {outputWide, ""},
+		"json":  {outputJSON, "[]\n"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			lc := newListCmd()
+			lc.fs = testhelper.FSWithFiles(fm.StoreDir)
+			lc.output = tc.output
+			var out bytes.Buffer
+			lc.cmd.SetOut(&out)
+
+			err := lc.cmd.RunE(lc.cmd, []string{})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if out.String() != tc.expOut {
+				t.Errorf("Exp output %q, got %q", tc.expOut, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// mergeKonfs combines the kubeconfigs target and current into a single kubeconfig, whose current-context is the one
+// of target. The contexts of target come first, so the merged kubeconfig is still identified as target by konf.
+// Clusters, users and contexts that are part of both are only kept once. If they differ, the ones of target win
+func mergeKonfs(target, current []byte) ([]byte, error) {
+	var merged, cur k8s.Config
+	err := yaml.Unmarshal(target, &merged)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(current, &cur)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cl := range cur.Clusters {
+		if i := findCluster(merged.Clusters, cl.Name); i < 0 {
+			merged.Clusters = append(merged.Clusters, cl)
+		} else if !reflect.DeepEqual(merged.Clusters[i], cl) {
+			log.Warn("Both konfs contain a different cluster %q. Keeping the one of the konf you switch to\n", cl.Name)
+		}
+	}
+	for _, u := range cur.AuthInfos {
+		if i := findUser(merged.AuthInfos, u.Name); i < 0 {
+			merged.AuthInfos = append(merged.AuthInfos, u)
+		} else if !reflect.DeepEqual(merged.AuthInfos[i], u) {
+			log.Warn("Both konfs contain a different user %q. Keeping the one of the konf you switch to\n", u.Name)
+		}
+	}
+	for _, ctx := range cur.Contexts {
+		if i := findContext(merged.Contexts, ctx.Name); i < 0 {
+			merged.Contexts = append(merged.Contexts, ctx)
+		} else if !reflect.DeepEqual(merged.Contexts[i], ctx) {
+			log.Warn("Both konfs contain a different context %q. Keeping the one of the konf you switch to\n", ctx.Name)
+		}
+	}
+
+	return yaml.Marshal(merged)
+}
+
+func findCluster(clusters []k8s.NamedCluster, name string) int {
+	for i, cl := range clusters {
+		if cl.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func findUser(users []k8s.NamedAuthInfo, name string) int {
+	for i, u := range users {
+		if u.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func findContext(contexts []k8s.NamedContext, name string) int {
+	for i, ctx := range contexts {
+		if ctx.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeWithCurrent merges the konf at path with current, which is the konf that was active before, and writes the
+// result back to path
+func mergeWithCurrent(f afero.Fs, path string, current []byte) error {
+	target, err := afero.ReadFile(f, path)
+	if err != nil {
+		return err
+	}
+	merged, err := mergeKonfs(target, current)
+	if err != nil {
+		return fmt.Errorf("could not merge konfs: %v", err)
+	}
+	return afero.WriteFile(f, path, merged, utils.KonfPerm)
+}
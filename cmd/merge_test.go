@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestSetMergeWithCurrent(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+	// shares the cluster with the EU konf
+	admin := func(f afero.Fs) {
+		konf := strings.ReplaceAll(sm.SingleClusterSingleContextEU(), "name: dev-eu\n", "name: dev-eu-admin\n")
+		konf = strings.Replace(konf, "current-context: dev-eu", "current-context: dev-eu-admin", 1)
+		konf = strings.Replace(konf, "user: dev-eu\n", "user: dev-eu-admin\n", 1)
+		afero.WriteFile(f, utils.StorePathForID("dev-eu-admin_dev-eu-1"), []byte(konf), utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		fs          afero.Fs
+		id          string
+		expErr      error
+		expCurrent  string
+		expContexts []string
+		expClusters []string
+		expUsers    []string
+	}{
+		"different clusters": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, activeEU),
+			"dev-asia_dev-asia-1",
+			nil,
+			"dev-asia",
+			[]string{"dev-asia", "dev-eu"},
+			[]string{"dev-asia-1", "dev-eu-1"},
+			[]string{"dev-asia", "dev-eu"},
+		},
+		"shared cluster is deduplicated": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, admin, activeEU),
+			"dev-eu-admin_dev-eu-1",
+			nil,
+			"dev-eu-admin",
+			[]string{"dev-eu-admin", "dev-eu"},
+			[]string{"dev-eu-1"},
+			[]string{"dev-eu-admin", "dev-eu"},
+		},
+		"no active konf": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			"dev-asia_dev-asia-1",
+			fmt.Errorf("there is no active konf in this shell, which --merge-with-current could be combined with"),
+			"",
+			nil,
+			nil,
+			nil,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			sc := newSetCommand()
+			sc.fs = tc.fs
+			sc.mergeCurrent = true
+
+			err := sc.cmd.RunE(sc.cmd, []string{tc.id})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			b, err := afero.ReadFile(tc.fs, utils.ActivePathForPID(os.Getppid()))
+			if err != nil {
+				t.Fatalf("Could not read active konf: %q", err)
+			}
+			conf := parseKonf(t, b)
+			if conf.CurrentContext != tc.expCurrent {
+				t.Errorf("Exp current-context %q, got %q", tc.expCurrent, conf.CurrentContext)
+			}
+			contexts, clusters, users := []string{}, []string{}, []string{}
+			for _, ctx := range conf.Contexts {
+				contexts = append(contexts, ctx.Name)
+			}
+			for _, cl := range conf.Clusters {
+				clusters = append(clusters, cl.Name)
+			}
+			for _, u := range conf.AuthInfos {
+				users = append(users, u.Name)
+			}
+			if !cmp.Equal(tc.expContexts, contexts) || !cmp.Equal(tc.expClusters, clusters) || !cmp.Equal(tc.expUsers, users) {
+				t.Errorf("Exp contexts %v, clusters %v and users %v, got %v, %v and %v", tc.expContexts, tc.expClusters, tc.expUsers, contexts, clusters, users)
+			}
+
+			// the merged konf must still be identified as the konf that has been set
+			id, err := activeKonfID(tc.fs)
+			if err != nil || id != tc.id {
+				t.Errorf("Exp active konf %q, got %q (%v)", tc.id, id, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"sigs.k8s.io/yaml"
+)
+
+// metadata contains additional information on konfs, which cannot be stored inside the kubeconfigs themselves
+// It is kept in a single file next to the store. The store stays the single source of truth, which means
+// entries of konfs that are not in the store anymore are simply ignored and pruned by 'konf store reindex'
+type metadata struct {
+	Konfs  map[string]*konfMetadata `json:"konfs,omitempty"`
+	Toggle []string                 `json:"toggle,omitempty"`
+	// Aliases maps human friendly names to konf ids
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// konfMetadata contains all additional information on a single konf
+type konfMetadata struct {
+	Favorite bool              `json:"favorite,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	// Scratch konfs are removed by 'konf cleanup' once their cluster is gone or they have expired
+	Scratch bool       `json:"scratch,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	// Verified is the result of the last 'konf verify'
+	Verified *verifyResult `json:"verified,omitempty"`
+	// Namespace is the namespace that was last used with the konf. It is restored by 'konf set'
+	Namespace string `json:"namespace,omitempty"`
+	// Env contains environment variables, which the shellwrapper exports while the konf is set
+	Env map[string]string `json:"env,omitempty"`
+	// Note is shown whenever the konf is set
+	Note string `json:"note,omitempty"`
+	// FetchCommand is run by 'konf set' to retrieve the kubeconfig, e.g. from a secret manager. The store only
+	// holds a stub without credentials in this case
+	FetchCommand string `json:"fetchCommand,omitempty"`
+	// Source is where the konf has been imported from. It allows 'konf resync' to update the konf later on
+	Source *konfSource `json:"source,omitempty"`
+}
+
+// konfSource describes where a konf has been imported from and how. Either Path or URL is set
+type konfSource struct {
+	// Path is the absolute path of the imported file
+	Path string `json:"path,omitempty"`
+	URL  string `json:"url,omitempty"`
+	// Context is the name of the first context of the konf right after the import options have been applied. It is
+	// used to find the konf in the source again, even if it has been renamed due to a collision afterwards
+	Context         string `json:"context"`
+	Prefix          string `json:"prefix,omitempty"`
+	SplitBy         string `json:"splitBy,omitempty"`
+	SplitUsers      bool   `json:"splitUsers,omitempty"`
+	StripStaticAuth bool   `json:"stripStaticAuth,omitempty"`
+}
+
+// String returns the location of the source
+func (s *konfSource) String() string {
+	if s.URL != "" {
+		return s.URL
+	}
+	return s.Path
+}
+
+// verifyResult records whether the cluster of a konf was healthy at a certain point in time
+type verifyResult struct {
+	Healthy bool      `json:"healthy"`
+	At      time.Time `json:"at"`
+}
+
+// loadMetadata reads the metadata file. A missing file is treated as empty metadata
+func loadMetadata(f afero.Fs) (*metadata, error) {
+	m := &metadata{Konfs: map[string]*konfMetadata{}}
+
+	b, err := afero.ReadFile(f, config.MetadataFile())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return m, nil
+		}
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(b, m)
+	if err != nil {
+		return nil, err
+	}
+	if m.Konfs == nil {
+		m.Konfs = map[string]*konfMetadata{}
+	}
+
+	return m, nil
+}
+
+// saveMetadata writes the metadata file
+func saveMetadata(f afero.Fs, m *metadata) error {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return afero.WriteFile(f, config.MetadataFile(), b, utils.KonfPerm)
+}
+
+// konf returns the metadata for the konf with the supplied id. If there is none yet, an empty entry is created
+func (m *metadata) konf(id string) *konfMetadata {
+	km, ok := m.Konfs[id]
+	if !ok {
+		km = &konfMetadata{}
+		m.Konfs[id] = km
+	}
+	return km
+}
+
+// favorites returns the ids of all favorite konfs in alphabetical order
+func (m *metadata) favorites() []string {
+	favs := []string{}
+	for id, km := range m.Konfs {
+		if km.Favorite {
+			favs = append(favs, id)
+		}
+	}
+	sort.Strings(favs)
+	return favs
+}
+
+// prune removes all entries that do not hold any information or whose konf is not in inStore
+func (m *metadata) prune(inStore map[string]bool) {
+	for id, km := range m.Konfs {
+		if !inStore[id] || reflect.ValueOf(*km).IsZero() {
+			delete(m.Konfs, id)
+		}
+	}
+	for alias, id := range m.Aliases {
+		if !inStore[id] {
+			delete(m.Aliases, alias)
+		}
+	}
+}
+
+// renameKonf moves all information on the konf oldID over to newID
+func (m *metadata) renameKonf(oldID, newID string) {
+	if km, ok := m.Konfs[oldID]; ok {
+		m.Konfs[newID] = km
+		delete(m.Konfs, oldID)
+	}
+	for i, id := range m.Toggle {
+		if id == oldID {
+			m.Toggle[i] = newID
+		}
+	}
+	for alias, id := range m.Aliases {
+		if id == oldID {
+			m.Aliases[alias] = newID
+		}
+	}
+}
+
+// removeKonf drops all information on the konf id. A toggle pair containing it cannot be toggled anymore, which is
+// why it is dropped as a whole
+func (m *metadata) removeKonf(id string) {
+	delete(m.Konfs, id)
+	for _, t := range m.Toggle {
+		if t == id {
+			m.Toggle = nil
+			break
+		}
+	}
+	for alias, target := range m.Aliases {
+		if target == id {
+			delete(m.Aliases, alias)
+		}
+	}
+}
+
+// addAlias registers alias for the konf id. Aliases must neither shadow a konf id nor silently point somewhere else,
+// which is why such aliases are rejected
+func (m *metadata) addAlias(f afero.Fs, alias, id string) error {
+	if _, err := f.Stat(utils.ResolveStorePath(f, alias)); err == nil && alias != id {
+		return fmt.Errorf("%q is already the id of a konf", alias)
+	}
+	if cur, ok := m.Aliases[alias]; ok && cur != id {
+		return fmt.Errorf("%q is already an alias for the konf %q", alias, cur)
+	}
+	if m.Aliases == nil {
+		m.Aliases = map[string]string{}
+	}
+	m.Aliases[alias] = id
+	return nil
+}
+
+// resolveAlias returns the konf id an alias points to. Konf ids always take precedence over aliases, and anything
+// that is neither is returned unchanged
+func resolveAlias(f afero.Fs, name string) (string, error) {
+	if _, err := f.Stat(utils.ResolveStorePath(f, name)); err == nil {
+		return name, nil
+	}
+	m, err := loadMetadata(f)
+	if err != nil {
+		return "", err
+	}
+	if id, ok := m.Aliases[name]; ok {
+		return id, nil
+	}
+	return name, nil
+}
+
+// rebuildMetadata prunes the metadata file against the current store. konfs is not used, as fetchKonfs skips konfs
+// it cannot read, whose metadata would be lost for good
+func rebuildMetadata(f afero.Fs, konfs []tableOutput) error {
+	inStore, err := storeIDs(f)
+	if err != nil {
+		return err
+	}
+	m, err := loadMetadata(f)
+	if err != nil {
+		return err
+	}
+	m.prune(inStore)
+	return saveMetadata(f, m)
+}
+
+// storeIDs returns the ids of all files in every store directory, no matter whether they can be read. A store
+// directory that cannot be listed is an error, as its konfs cannot be told apart from deleted ones
+func storeIDs(f afero.Fs) (map[string]bool, error) {
+	ids := map[string]bool{}
+	for _, dir := range config.StoreDirs() {
+		files, err := storeFiles(f, dir)
+		if err != nil {
+			return nil, fmt.Errorf("could not list the store %q. Please make sure it is available, so its metadata does not get lost: %v", dir, err)
+		}
+		for _, file := range files {
+			ids[utils.IDFromFileInfo(file)] = true
+		}
+	}
+	return ids, nil
+}
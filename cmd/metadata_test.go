@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+// metadataFile writes the supplied content as metadata file
+func metadataFile(content string) func(afero.Fs) {
+	return func(f afero.Fs) {
+		afero.WriteFile(f, config.MetadataFile(), []byte(content), utils.KonfPerm)
+	}
+}
+
+func TestLoadMetadata(t *testing.T) {
+	tt := map[string]struct {
+		fs      afero.Fs
+		expMeta *metadata
+		expErr  bool
+	}{
+		"no metadata file": {
+			testhelper.FSWithFiles(),
+			&metadata{Konfs: map[string]*konfMetadata{}},
+			false,
+		},
+		"empty metadata file": {
+			testhelper.FSWithFiles(metadataFile("")),
+			&metadata{Konfs: map[string]*konfMetadata{}},
+			false,
+		},
+		"favorite": {
+			testhelper.FSWithFiles(metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    favorite: true\n")),
+			&metadata{Konfs: map[string]*konfMetadata{"dev-eu_dev-eu-1": {Favorite: true}}},
+			false,
+		},
+		"invalid metadata file": {
+			testhelper.FSWithFiles(metadataFile("I am no valid yaml")),
+			nil,
+			true,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			m, err := loadMetadata(tc.fs)
+
+			if (err != nil) != tc.expErr {
+				t.Errorf("Exp err to be %t, got %q", tc.expErr, err)
+			}
+
+			if !cmp.Equal(tc.expMeta, m, cmp.AllowUnexported(metadata{})) {
+				t.Errorf("Exp and given metadata differ:\n'%s'", cmp.Diff(tc.expMeta, m, cmp.AllowUnexported(metadata{})))
+			}
+		})
+	}
+}
+
+func TestSaveMetadataRoundtrip(t *testing.T) {
+	f := afero.NewMemMapFs()
+	m := &metadata{Konfs: map[string]*konfMetadata{}}
+	m.konf("dev-eu_dev-eu-1").Favorite = true
+
+	err := saveMetadata(f, m)
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	res, err := loadMetadata(f)
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if !cmp.Equal(m, res) {
+		t.Errorf("Exp and given metadata differ:\n'%s'", cmp.Diff(m, res))
+	}
+}
+
+func TestPruneMetadata(t *testing.T) {
+	m := &metadata{Konfs: map[string]*konfMetadata{
+		"dev-eu_dev-eu-1":     {Favorite: true},
+		"dev-asia_dev-asia-1": {},
+		"deleted_konf":        {Favorite: true},
+	}}
+	inStore := map[string]bool{"dev-eu_dev-eu-1": true, "dev-asia_dev-asia-1": true}
+
+	m.prune(inStore)
+
+	exp := &metadata{Konfs: map[string]*konfMetadata{
+		"dev-eu_dev-eu-1": {Favorite: true},
+	}}
+	if !cmp.Equal(exp, m) {
+		t.Errorf("Exp and given metadata differ:\n'%s'", cmp.Diff(exp, m))
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA,
+		metadataFile("aliases:\n  staging: dev-eu_dev-eu-1\n  dev-asia_dev-asia-1: dev-eu_dev-eu-1\n"))
+
+	tt := map[string]struct {
+		name  string
+		expID string
+	}{
+		"alias":                {"staging", "dev-eu_dev-eu-1"},
+		"konf id":              {"dev-eu_dev-eu-1", "dev-eu_dev-eu-1"},
+		"ids win over aliases": {"dev-asia_dev-asia-1", "dev-asia_dev-asia-1"},
+		"neither id nor alias": {"prod", "prod"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			id, err := resolveAlias(f, tc.name)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if id != tc.expID {
+				t.Errorf("Exp id %q, got %q", tc.expID, id)
+			}
+		})
+	}
+}
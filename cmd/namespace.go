@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	iofs "io/fs"
 	"os"
 
 	"github.com/lithammer/fuzzysearch/fuzzy"
 	"github.com/manifoldco/promptui"
+	log "github.com/simontheleg/konf-go/log"
 	"github.com/simontheleg/konf-go/prompt"
 	"github.com/simontheleg/konf-go/utils"
 	"github.com/spf13/afero"
@@ -26,20 +29,24 @@ type namespaceCmd struct {
 	promptFunc       prompt.RunFunc
 	selectNamespace  func(clientSetCreator, prompt.RunFunc, afero.Fs) (string, error)
 	setNamespace     func(afero.Fs, string) error
+	persistNamespace func(afero.Fs, string) error
 	clientSetCreator clientSetCreator
 
+	persist bool
+
 	cmd *cobra.Command
 }
 
 func newNamespaceCmd() *namespaceCmd {
 
-	fs := afero.NewOsFs()
+	fs := utils.NewFs()
 
 	cc := &namespaceCmd{
 		fs:               fs,
-		promptFunc:       prompt.Terminal,
+		promptFunc:       pickerPrompt,
 		selectNamespace:  selectNamespace,
 		setNamespace:     setNamespace,
+		persistNamespace: persistNamespace,
 		clientSetCreator: newKubeClientSet,
 	}
 
@@ -53,12 +60,15 @@ Can also be invoked via 'ns' alias
 Examples:
 	-> 'ns' run namespace selection
 	-> 'ns <namespace-name' set to a specific namespace
+	-> 'ns <namespace-name> --persist' set to a specific namespace and keep it for future 'konf set' calls
 `,
 		RunE:              cc.namespace,
 		Args:              cobra.MaximumNArgs(1),
 		ValidArgsFunction: cc.completeNamespace,
 	}
 
+	cc.cmd.Flags().BoolVar(&cc.persist, "persist", false, "additionally write the namespace into the konf store, so future 'konf set' calls default to it")
+
 	return cc
 }
 
@@ -80,6 +90,19 @@ func (c *namespaceCmd) namespace(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// remembering the namespace is only a convenience for the next 'konf set', so it should not fail the command
+	err = recordNamespace(c.fs, ns)
+	if err != nil {
+		log.Warn("could not remember namespace %q for the next 'konf set': %v\n", ns, err)
+	}
+
+	if c.persist {
+		err = c.persistNamespace(c.fs, ns)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -173,8 +196,12 @@ func newKubeClientSet(fs afero.Fs) (kubernetes.Interface, error) {
 	if err != nil {
 		return nil, err
 	}
+	return konfClientSet(b)
+}
 
-	conf, err := clientcmd.NewClientConfigFromBytes(b)
+// konfClientSet returns a clientset for the cluster of the kubeconfig konf
+func konfClientSet(konf []byte) (kubernetes.Interface, error) {
+	conf, err := clientcmd.NewClientConfigFromBytes(konf)
 	if err != nil {
 		return nil, err
 	}
@@ -197,6 +224,21 @@ func setNamespace(fs afero.Fs, ns string) error {
 	if err != nil {
 		return err
 	}
+	// in direct store mode the shell uses the konf from the store, which would turn every change into a persisted one
+	if utils.IsAnyStorePath(kPath) {
+		return fmt.Errorf("cannot change the namespace, because $KUBECONFIG points directly at the store. Please set the konf without --direct-store first")
+	}
+
+	return writeNamespace(fs, kPath, ns)
+}
+
+// persistNamespace writes the namespace into the store file the currently active konf originates from
+// As a result any future 'konf set' on this konf will start out with this namespace
+func persistNamespace(fs afero.Fs, ns string) error {
+	kPath, err := kubeconfigEnv()
+	if err != nil {
+		return err
+	}
 
 	b, err := afero.ReadFile(fs, kPath)
 	if err != nil {
@@ -209,18 +251,91 @@ func setNamespace(fs afero.Fs, ns string) error {
 		return err
 	}
 
-	if len(conf.Contexts) == 0 {
-		return fmt.Errorf("could not set namespace as contexts[] is empty in kubeconfig")
+	id, err := activeIDFromKonf(&conf)
+	if err != nil {
+		return err
+	}
+	// the id might have been recorded by an extension, which means there is no guarantee for a context
+	ctx := namespaceContext(&conf)
+	if ctx == nil {
+		return fmt.Errorf("could not persist namespace as contexts[] is empty in kubeconfig")
 	}
+	return persistNamespaceForID(fs, id, ctx.Name, ns)
+}
 
-	conf.Contexts[0].Context.Namespace = ns // this should be safe as konf import ensures we have only one context
+// persistNamespaceForID writes the namespace into the context of the store file of the konf with the supplied id
+func persistNamespaceForID(fs afero.Fs, id, context, ns string) error {
+	storePath, err := ownStorePath(fs, id)
+	if err != nil {
+		return err
+	}
+	_, err = fs.Stat(storePath)
+	if err != nil {
+		if errors.Is(err, iofs.ErrNotExist) {
+			return fmt.Errorf("could not persist namespace, because the konf %q is not in the store anymore", id)
+		}
+		return err
+	}
 
+	// store files might be encrypted, which is why they cannot be edited like the active konf
+	b, err := readStoreFile(fs, storePath)
+	if err != nil {
+		return err
+	}
+	var conf k8s.Config
+	err = yaml.Unmarshal(b, &conf)
+	if err != nil {
+		return err
+	}
+	ctx := storedContext(&conf, context)
+	if ctx == nil {
+		return fmt.Errorf("could not set namespace as contexts[] is empty in kubeconfig")
+	}
+	ctx.Context.Namespace = ns
 	retconf, err := yaml.Marshal(conf)
 	if err != nil {
 		return err
 	}
+	return writeStoreFile(fs, storePath, retconf)
+}
 
-	err = afero.WriteFile(fs, kPath, retconf, utils.KonfPerm)
+// recordNamespace remembers ns as the last used namespace of the currently active konf
+func recordNamespace(fs afero.Fs, ns string) error {
+	kPath, err := kubeconfigEnv()
+	if err != nil {
+		return err
+	}
+
+	id, err := activeKonfIDFromFile(fs, kPath)
+	if err != nil {
+		return err
+	}
+	// kubeconfigs that have been set without importing them first cannot be restored anyway
+	if _, err := fs.Stat(utils.ResolveStorePath(fs, id)); id == "" || err != nil {
+		return nil
+	}
+
+	m, err := loadMetadata(fs)
+	if err != nil {
+		return err
+	}
+	m.konf(id).Namespace = ns
+	return saveMetadata(fs, m)
+}
+
+// writeNamespace sets the namespace of the context in the kubeconfig at path and leaves everything else untouched
+func writeNamespace(fs afero.Fs, path, ns string) error {
+	b, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return err
+	}
+
+	retconf, err := setNamespaceInKubeconfig(b, ns)
+	if err != nil {
+		return err
+	}
+
+	err = afero.WriteFile(fs, path, retconf, utils.KonfPerm)
 	if err != nil {
 		return err
 	}
@@ -228,6 +343,45 @@ func setNamespace(fs afero.Fs, ns string) error {
 	return nil
 }
 
+// setNamespaceInKubeconfig returns the kubeconfig b with the namespace of its current context set to ns. An empty ns
+// removes the namespace, so kubectl falls back to "default". Every other field is kept as it is
+func setNamespaceInKubeconfig(b []byte, ns string) ([]byte, error) {
+	var conf k8s.Config
+	err := yaml.Unmarshal(b, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := namespaceContext(&conf)
+	if ctx == nil {
+		return nil, fmt.Errorf("could not set namespace as contexts[] is empty in kubeconfig")
+	}
+	ctx.Context.Namespace = ns
+
+	return yaml.Marshal(conf)
+}
+
+// namespaceContext returns the context of conf whose namespace kubectl uses. Konfs usually only contain a single
+// context, but those merged via 'konf set --merge-with-current' or split by cluster contain several of them
+func namespaceContext(conf *k8s.Config) *k8s.NamedContext {
+	if len(conf.Contexts) == 0 {
+		return nil
+	}
+	if i := findContext(conf.Contexts, conf.CurrentContext); i >= 0 {
+		return &conf.Contexts[i]
+	}
+	return &conf.Contexts[0]
+}
+
+// storedContext returns the context called name of the konf conf in the store. Konfs spanning multiple clusters are
+// set with a single one of their contexts, whose name might not be the current context of the store file
+func storedContext(conf *k8s.Config, name string) *k8s.NamedContext {
+	if i := findContext(conf.Contexts, name); i >= 0 {
+		return &conf.Contexts[i]
+	}
+	return namespaceContext(conf)
+}
+
 func init() {
 	rootCmd.AddCommand(newNamespaceCmd().cmd)
 }
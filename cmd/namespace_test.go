@@ -8,6 +8,7 @@ import (
 	"github.com/manifoldco/promptui"
 	"github.com/simontheleg/konf-go/prompt"
 	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -21,34 +22,47 @@ func TestNamespace(t *testing.T) {
 
 	selectNamespaceCalled := false
 	setNamespaceCalled := false
+	persistNamespaceCalled := false
 	var mockSelectNamespace = func(clientSetCreator, prompt.RunFunc, afero.Fs) (string, error) {
 		selectNamespaceCalled = true
 		return "", nil
 	}
 	var mockSetNamespace = func(afero.Fs, string) error { setNamespaceCalled = true; return nil }
+	var mockPersistNamespace = func(afero.Fs, string) error { persistNamespaceCalled = true; return nil }
 
 	nscmd := newNamespaceCmd()
 	nscmd.selectNamespace = mockSelectNamespace
 	nscmd.setNamespace = mockSetNamespace
+	nscmd.persistNamespace = mockPersistNamespace
 
 	type ExpCalls struct {
-		SelectNamespace bool
-		SetNamespace    bool
+		SelectNamespace  bool
+		SetNamespace     bool
+		PersistNamespace bool
 	}
 	tt := map[string]struct {
-		Args   []string
-		ExpErr error
+		Args    []string
+		Persist bool
+		ExpErr  error
 		ExpCalls
 	}{
 		"1 arg": {
 			[]string{"ns1"},
+			false,
 			nil,
-			ExpCalls{SelectNamespace: false, SetNamespace: true},
+			ExpCalls{SelectNamespace: false, SetNamespace: true, PersistNamespace: false},
 		},
 		"0 args": {
 			[]string{},
+			false,
 			nil,
-			ExpCalls{SelectNamespace: true, SetNamespace: true},
+			ExpCalls{SelectNamespace: true, SetNamespace: true, PersistNamespace: false},
+		},
+		"1 arg and persist": {
+			[]string{"ns1"},
+			true,
+			nil,
+			ExpCalls{SelectNamespace: false, SetNamespace: true, PersistNamespace: true},
 		},
 	}
 
@@ -56,6 +70,8 @@ func TestNamespace(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			selectNamespaceCalled = false
 			setNamespaceCalled = false
+			persistNamespaceCalled = false
+			nscmd.persist = tc.Persist
 			cmd := nscmd.cmd
 
 			err := cmd.RunE(cmd, tc.Args)
@@ -71,6 +87,10 @@ func TestNamespace(t *testing.T) {
 				t.Errorf("Exp SetNamespaceCalled to be %t, but got %t", tc.ExpCalls.SetNamespace, setNamespaceCalled)
 			}
 
+			if tc.ExpCalls.PersistNamespace != persistNamespaceCalled {
+				t.Errorf("Exp PersistNamespaceCalled to be %t, but got %t", tc.ExpCalls.PersistNamespace, persistNamespaceCalled)
+			}
+
 		})
 	}
 }
@@ -296,6 +316,12 @@ func TestSetNamespace(t *testing.T) {
 			"kube-system",
 			true,
 		},
+		"konf used directly from the store": {
+			"./konf/store/dev-eu_dev-eu-1.yaml",
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			"kube-system",
+			true,
+		},
 	}
 
 	for name, tc := range tt {
@@ -328,3 +354,201 @@ func TestSetNamespace(t *testing.T) {
 		})
 	}
 }
+
+func TestPersistNamespace(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		kubeenv string
+		Fs      afero.Fs
+		ns      string
+		ExpErr  error
+	}{
+		"no $KUBECONFIG set": {
+			"",
+			nil,
+			"",
+			fmt.Errorf("KUBECONFIG ist not set in your shell. Have you run konf set?"),
+		},
+		"konf in store": {
+			"./konf/active/dev-eu_dev-eu-1.yaml",
+			testhelper.FSWithFiles(fm.ActiveDir, fm.StoreDir, fm.SingleClusterSingleContextEU),
+			"kube-system",
+			nil,
+		},
+		"konf not in store anymore": {
+			"./konf/active/dev-eu_dev-eu-1.yaml",
+			testhelper.FSWithFiles(fm.ActiveDir, fm.StoreDir, fm.SingleClusterSingleContextEU, func(f afero.Fs) { f.Remove("./konf/store/dev-eu_dev-eu-1.yaml") }),
+			"kube-system",
+			fmt.Errorf("could not persist namespace, because the konf \"dev-eu_dev-eu-1\" is not in the store anymore"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("KUBECONFIG", tc.kubeenv)
+
+			err := persistNamespace(tc.Fs, tc.ns)
+
+			if !testhelper.EqualError(err, tc.ExpErr) {
+				t.Fatalf("Exp error %q, got %q", tc.ExpErr, err)
+			}
+
+			if tc.ExpErr == nil {
+				b, err := afero.ReadFile(tc.Fs, "./konf/store/dev-eu_dev-eu-1.yaml")
+				if err != nil {
+					t.Errorf("failed to read file %q", err)
+				}
+
+				var kconf k8s.Config
+				err = yaml.Unmarshal(b, &kconf)
+				if err != nil {
+					t.Errorf("failed to unmarshal %q", err)
+				}
+
+				if len(kconf.Contexts) != 1 {
+					t.Errorf("exp store file to contain a single context, but it contains %d", len(kconf.Contexts))
+				}
+				resNs := kconf.Contexts[0].Context.Namespace
+				if resNs != tc.ns {
+					t.Errorf("exp ns to be %q, but is %q", tc.ns, resNs)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordNamespace(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs    afero.Fs
+		expNS string
+	}{
+		"konf in store": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU),
+			"kube-system",
+		},
+		"konf not in store": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, func(f afero.Fs) {
+				sm := testhelper.SampleKonfManager{}
+				afero.WriteFile(f, utils.ActivePathForID("dev-eu_dev-eu-1"), []byte(sm.SingleClusterSingleContextEU()), utils.KonfPerm)
+			}),
+			"",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("KUBECONFIG", utils.ActivePathForID("dev-eu_dev-eu-1"))
+
+			err := recordNamespace(tc.fs, "kube-system")
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			ns := ""
+			if km, ok := m.Konfs["dev-eu_dev-eu-1"]; ok {
+				ns = km.Namespace
+			}
+			if ns != tc.expNS {
+				t.Errorf("Exp recorded namespace %q, got %q", tc.expNS, ns)
+			}
+		})
+	}
+}
+
+func TestSetNamespaceInKubeconfig(t *testing.T) {
+	konf := func(namespace string) string {
+		return `apiVersion: v1
+clusters:
+- cluster:
+    extensions:
+    - extension:
+        provider: eks
+      name: client.authentication.k8s.io/exec
+    server: https://10.1.1.0
+  name: dev-eu-1
+contexts:
+- context:
+    cluster: dev-eu-1
+` + namespace + `    user: dev-eu
+  name: dev-eu
+- context:
+    cluster: dev-eu-1
+    namespace: kube-public
+    user: legacy
+  name: dev-eu-legacy
+current-context: dev-eu
+kind: Config
+preferences: {}
+users:
+- name: dev-eu
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      args:
+      - token
+      command: aws-iam-authenticator
+      env: null
+      provideClusterInfo: false
+- name: legacy
+  user:
+    auth-provider:
+      config:
+        client-id: konf
+      name: oidc
+`
+	}
+
+	tt := map[string]struct {
+		in     string
+		ns     string
+		exp    string
+		expErr error
+	}{
+		"no namespace": {
+			konf(""),
+			"kube-system",
+			konf("    namespace: kube-system\n"),
+			nil,
+		},
+		"existing namespace": {
+			konf("    namespace: default\n"),
+			"kube-system",
+			konf("    namespace: kube-system\n"),
+			nil,
+		},
+		"unset namespace": {
+			konf("    namespace: kube-system\n"),
+			"",
+			konf(""),
+			nil,
+		},
+		"no contexts": {
+			"apiVersion: v1\nkind: Config\n",
+			"kube-system",
+			"",
+			fmt.Errorf("could not set namespace as contexts[] is empty in kubeconfig"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res, err := setNamespaceInKubeconfig([]byte(tc.in), tc.ns)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+			if string(res) != tc.exp {
+				t.Errorf("Exp and given kubeconfig differ: \n '%s'", cmp.Diff(tc.exp, string(res)))
+			}
+		})
+	}
+}
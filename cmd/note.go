@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type noteCmd struct {
+	fs afero.Fs
+
+	cmd *cobra.Command
+}
+
+func newNoteCmd() *noteCmd {
+	nc := &noteCmd{
+		fs: utils.NewFs(),
+	}
+
+	nc.cmd = &cobra.Command{
+		Use:   "note",
+		Short: "Manage notes of konfs",
+		Long: `Manage notes of konfs
+
+Notes are shown whenever a konf is set and in the details of the picker. They are meant as a reminder
+on how a cluster should be used.
+
+Examples:
+	-> 'note set <konfig id> read-only replica, do not run migrations' attach a note to a konf
+	-> 'note remove <konfig id>' remove the note of a konf
+`,
+	}
+
+	set := &cobra.Command{
+		Use:               "set",
+		Short:             "Attach a note to a konf",
+		Args:              cobra.MinimumNArgs(2),
+		RunE:              nc.set,
+		ValidArgsFunction: nc.completeNote,
+	}
+	remove := &cobra.Command{
+		Use:               "remove",
+		Aliases:           []string{"rm"},
+		Short:             "Remove the note of a konf",
+		Args:              cobra.ExactArgs(1),
+		RunE:              nc.remove,
+		ValidArgsFunction: nc.completeNote,
+	}
+	nc.cmd.AddCommand(set, remove)
+
+	return nc
+}
+
+func (c *noteCmd) set(cmd *cobra.Command, args []string) error {
+	id := args[0]
+	_, err := c.fs.Stat(utils.ResolveStorePath(c.fs, id))
+	if err != nil {
+		return &KonfNotFound{ID: id}
+	}
+
+	// the note does not need to be quoted, just like a commit message in 'git commit -m'
+	note := strings.TrimSpace(strings.Join(args[1:], " "))
+	if note == "" {
+		return fmt.Errorf("please supply a note. Use 'konf note remove' to remove a note")
+	}
+
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+	m.konf(id).Note = note
+
+	err = saveMetadata(c.fs, m)
+	if err != nil {
+		return err
+	}
+	log.Info("Attached note to konf %q\n", id)
+
+	return nil
+}
+
+func (c *noteCmd) remove(cmd *cobra.Command, args []string) error {
+	id := args[0]
+
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+	km, ok := m.Konfs[id]
+	if !ok || km.Note == "" {
+		return fmt.Errorf("konf %q does not have a note", id)
+	}
+	km.Note = ""
+
+	err = saveMetadata(c.fs, m)
+	if err != nil {
+		return err
+	}
+	log.Info("Removed note of konf %q\n", id)
+
+	return nil
+}
+
+func (c *noteCmd) completeNote(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return []string{}, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	konfs, err := fetchKonfs(c.fs)
+	if err != nil {
+		if errors.Is(err, &EmptyStore{}) {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	sug := []string{}
+	for _, konf := range konfs {
+		sug = append(sug, konf.ID())
+	}
+	return sug, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(newNoteCmd().cmd)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/spf13/afero"
+)
+
+func TestNote(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	withNote := metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    note: read-only replica\n")
+
+	tt := map[string]struct {
+		fs      afero.Fs
+		remove  bool
+		args    []string
+		expNote string
+		expErr  error
+	}{
+		"set note": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			false,
+			[]string{"dev-eu_dev-eu-1", "read-only", "replica,", "do not run migrations"},
+			"read-only replica, do not run migrations",
+			nil,
+		},
+		"replace note": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, withNote),
+			false,
+			[]string{"dev-eu_dev-eu-1", "primary"},
+			"primary",
+			nil,
+		},
+		"empty note": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			false,
+			[]string{"dev-eu_dev-eu-1", " "},
+			"",
+			fmt.Errorf("please supply a note. Use 'konf note remove' to remove a note"),
+		},
+		"konf does not exist": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			false,
+			[]string{"dev-eu_dev-eu-1", "primary"},
+			"",
+			&KonfNotFound{ID: "dev-eu_dev-eu-1"},
+		},
+		"remove note": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, withNote),
+			true,
+			[]string{"dev-eu_dev-eu-1"},
+			"",
+			nil,
+		},
+		"remove missing note": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			true,
+			[]string{"dev-eu_dev-eu-1"},
+			"",
+			fmt.Errorf("konf \"dev-eu_dev-eu-1\" does not have a note"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			nc := newNoteCmd()
+			nc.fs = tc.fs
+
+			run := nc.set
+			if tc.remove {
+				run = nc.remove
+			}
+			err := run(nc.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			if note := m.konf("dev-eu_dev-eu-1").Note; note != tc.expNote {
+				t.Errorf("Exp note %q, got %q", tc.expNote, note)
+			}
+		})
+	}
+}
+
+func TestSetPrintsNote(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	sc := newSetCommand()
+	sc.fs = testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    note: read-only replica\n"))
+	var out bytes.Buffer
+	sc.cmd.SetErr(&out)
+
+	err := sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	exp := "Note on konf \"dev-eu_dev-eu-1\": read-only replica\n"
+	if out.String() != exp {
+		t.Errorf("Exp output %q, got %q", exp, out.String())
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/afero"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// konfSummary contains everything 'konf set --preview' shows about a konf
+type konfSummary struct {
+	ID        string
+	Context   string
+	Cluster   string
+	Server    string
+	Namespace string
+	Auth      string
+	// Status is the glyph for the result of the last 'konf verify'. It is empty if the konf has never been verified
+	Status string
+}
+
+// summarizeKonf returns the summary of the konf with the supplied id. Context and cluster are taken from
+// fetchKonfs, just like in the picker. If the konf is not in the store, nil is returned
+func summarizeKonf(f afero.Fs, id string) (*konfSummary, error) {
+	konfs, err := fetchKonfs(f)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range konfs {
+		if k.ID() != id {
+			continue
+		}
+
+		b, err := readStoreFile(f, k.File)
+		if err != nil {
+			return nil, err
+		}
+		var conf k8s.Config
+		err = yaml.Unmarshal(b, &conf)
+		if err != nil {
+			return nil, err
+		}
+
+		s := &konfSummary{ID: id, Context: k.Context, Cluster: k.Cluster, Auth: "none", Status: k.Status}
+		for _, cl := range conf.Clusters {
+			if cl.Name == k.Cluster {
+				s.Server = cl.Cluster.Server
+			}
+		}
+		for _, ctx := range conf.Contexts {
+			if ctx.Name != k.Context {
+				continue
+			}
+			s.Namespace = ctx.Context.Namespace
+			for _, u := range conf.AuthInfos {
+				if u.Name == ctx.Context.AuthInfo {
+					s.Auth = authType(u.AuthInfo)
+				}
+			}
+		}
+		if s.Namespace == "" {
+			s.Namespace = "default"
+		}
+		return s, nil
+	}
+
+	return nil, nil
+}
+
+// authType describes how a user authenticates against the cluster
+func authType(u k8s.AuthInfo) string {
+	switch {
+	case u.Exec != nil:
+		return fmt.Sprintf("exec plugin %q", u.Exec.Command)
+	case u.AuthProvider != nil:
+		return fmt.Sprintf("auth provider %q", u.AuthProvider.Name)
+	case len(u.ClientCertificateData) > 0 || u.ClientCertificate != "":
+		return "client certificate"
+	case u.Token != "" || u.TokenFile != "":
+		return "token"
+	case u.Username != "":
+		return "basic auth"
+	default:
+		return "none"
+	}
+}
+
+func printSummary(w io.Writer, s *konfSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Konf:\t%s\n", s.ID)
+	fmt.Fprintf(tw, "Context:\t%s\n", s.Context)
+	fmt.Fprintf(tw, "Cluster:\t%s\n", s.Cluster)
+	fmt.Fprintf(tw, "Server:\t%s\n", s.Server)
+	fmt.Fprintf(tw, "Namespace:\t%s\n", s.Namespace)
+	fmt.Fprintf(tw, "Auth:\t%s\n", s.Auth)
+	if s.Status != "" {
+		fmt.Fprintf(tw, "Status:\t%s\n", s.Status)
+	}
+	return tw.Flush()
+}
+
+// previewKonf prints the summary of the konf with the supplied id to stderr and, in a terminal, asks whether
+// it should be set, if --preview is enabled. It returns false if the user declined. Konfs that are not in the
+// store are not previewed, as 'konf set' tries to find the konf the user meant first
+func (c *setCmd) previewKonf(id string) (bool, error) {
+	if !c.preview {
+		return true, nil
+	}
+
+	s, err := summarizeKonf(c.fs, id)
+	if err != nil || s == nil {
+		return true, err
+	}
+
+	err = printSummary(c.cmd.ErrOrStderr(), s)
+	if err != nil {
+		return false, err
+	}
+	if c.unattended || !c.isTerminal() {
+		return true, nil
+	}
+	return c.confirmFunc(&promptui.Prompt{Label: fmt.Sprintf("Set konf %q", id), IsConfirm: true, Stdout: os.Stderr})
+}
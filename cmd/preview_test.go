@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/testhelper"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+)
+
+func TestSetPreview(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	summary := "Konf:       dev-eu_dev-eu-1\n" +
+		"Context:    dev-eu\n" +
+		"Cluster:    dev-eu-1\n" +
+		"Server:     https://10.1.1.0\n" +
+		"Namespace:  kube-public\n" +
+		"Auth:       none\n"
+
+	tt := map[string]struct {
+		terminal  bool
+		confirm   bool
+		args      []string
+		expOut    string
+		expActive string
+	}{
+		"not a terminal": {
+			false, false,
+			[]string{"dev-eu_dev-eu-1"},
+			summary,
+			"dev-eu_dev-eu-1",
+		},
+		"confirmed": {
+			true, true,
+			[]string{"dev-eu_dev-eu-1"},
+			summary,
+			"dev-eu_dev-eu-1",
+		},
+		"declined": {
+			true, false,
+			[]string{"dev-eu_dev-eu-1"},
+			summary,
+			"",
+		},
+		"konf found by fallback": {
+			false, true,
+			[]string{"dev-eu"},
+			summary,
+			"dev-eu_dev-eu-1",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU)
+			sc := newSetCommand()
+			sc.fs = f
+			sc.preview = true
+			sc.isTerminal = func() bool { return tc.terminal }
+			sc.confirmFunc = func(*promptui.Prompt) (bool, error) { return tc.confirm, nil }
+			var out bytes.Buffer
+			sc.cmd.SetErr(&out)
+
+			err := sc.cmd.RunE(sc.cmd, tc.args)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if out.String() != tc.expOut {
+				t.Errorf("Exp preview %q, got %q", tc.expOut, out.String())
+			}
+
+			id, err := activeKonfID(f)
+			if err != nil {
+				t.Fatalf("Could not read active konf: %q", err)
+			}
+			if id != tc.expActive {
+				t.Errorf("Exp active konf %q, got %q", tc.expActive, id)
+			}
+		})
+	}
+}
+
+func TestAuthType(t *testing.T) {
+	tt := map[string]struct {
+		user k8s.AuthInfo
+		exp  string
+	}{
+		"exec":        {k8s.AuthInfo{Exec: &k8s.ExecConfig{Command: "aws"}}, `exec plugin "aws"`},
+		"provider":    {k8s.AuthInfo{AuthProvider: &k8s.AuthProviderConfig{Name: "oidc"}}, `auth provider "oidc"`},
+		"certificate": {k8s.AuthInfo{ClientCertificateData: []byte("cert")}, "client certificate"},
+		"token":       {k8s.AuthInfo{TokenFile: "/token"}, "token"},
+		"basic":       {k8s.AuthInfo{Username: "admin"}, "basic auth"},
+		"none":        {k8s.AuthInfo{}, "none"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if res := authType(tc.user); res != tc.exp {
+				t.Errorf("Exp auth type %q, got %q", tc.exp, res)
+			}
+		})
+	}
+}
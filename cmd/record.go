@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// switchRecord describes a single switch recorded via 'konf set --record'. It contains everything that is needed
+// to reproduce the switch later on, no matter whether the konf has been selected by id, label or the picker
+type switchRecord struct {
+	Time      time.Time `json:"time"`
+	ID        string    `json:"id"`
+	Context   string    `json:"context"`
+	Cluster   string    `json:"cluster"`
+	Server    string    `json:"server"`
+	Namespace string    `json:"namespace"`
+}
+
+// newSwitchRecord describes the switch to the konf with the supplied id, which has been written to path
+func newSwitchRecord(f afero.Fs, id, path string, now time.Time) (*switchRecord, error) {
+	b, err := afero.ReadFile(f, path)
+	if err != nil {
+		return nil, err
+	}
+	var conf k8s.Config
+	err = yaml.Unmarshal(b, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &switchRecord{Time: now.UTC(), ID: id, Context: conf.CurrentContext, Namespace: "default"}
+	for _, ctx := range conf.Contexts {
+		if ctx.Name != conf.CurrentContext {
+			continue
+		}
+		rec.Cluster = ctx.Context.Cluster
+		if ctx.Context.Namespace != "" {
+			rec.Namespace = ctx.Context.Namespace
+		}
+	}
+	for _, cl := range conf.Clusters {
+		if cl.Name == rec.Cluster {
+			rec.Server = cl.Cluster.Server
+		}
+	}
+	return rec, nil
+}
+
+// appendRecord appends rec as a single line of json to the record file, so it can be processed line by line
+func appendRecord(f afero.Fs, rec *switchRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	file, err := f.OpenFile(config.RecordFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, utils.KonfPerm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("could not write record: %v", err)
+	}
+	return nil
+}
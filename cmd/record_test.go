@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/spf13/afero"
+)
+
+func TestSetRecord(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextASIA, fm.SingleClusterSingleContextEU)
+
+	steps := []struct {
+		args      []string
+		namespace string
+	}{
+		// the picker lists dev-asia first
+		{[]string{}, ""},
+		{[]string{"dev-eu_dev-eu-1"}, "kube-system"},
+	}
+	for _, s := range steps {
+		sc := newSetCommand()
+		sc.fs = f
+		sc.record = true
+		sc.namespace = s.namespace
+		sc.selector = promptFunc(func(*promptui.Select) (int, error) { return 0, nil })
+
+		err := sc.cmd.RunE(sc.cmd, s.args)
+		if err != nil {
+			t.Fatalf("Exp no error, got %q", err)
+		}
+	}
+
+	b, err := afero.ReadFile(f, config.RecordFile())
+	if err != nil {
+		t.Fatalf("Could not read record file: %q", err)
+	}
+	recs := []switchRecord{}
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var rec switchRecord
+		err := json.Unmarshal([]byte(line), &rec)
+		if err != nil {
+			t.Fatalf("Could not parse record %q: %q", line, err)
+		}
+		if time.Since(rec.Time) > time.Minute {
+			t.Errorf("Exp record to be timestamped with the time of the switch, got %s", rec.Time)
+		}
+		recs = append(recs, rec)
+	}
+
+	exp := []switchRecord{
+		{ID: "dev-asia_dev-asia-1", Context: "dev-asia", Cluster: "dev-asia-1", Server: "https://10.1.1.0", Namespace: "kube-public"},
+		{ID: "dev-eu_dev-eu-1", Context: "dev-eu", Cluster: "dev-eu-1", Server: "https://10.1.1.0", Namespace: "kube-system"},
+	}
+	if !cmp.Equal(exp, recs, cmpopts.IgnoreFields(switchRecord{}, "Time")) {
+		t.Errorf("Exp and given records differ: \n '%s'", cmp.Diff(exp, recs, cmpopts.IgnoreFields(switchRecord{}, "Time")))
+	}
+}
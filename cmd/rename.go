@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+type renameCmd struct {
+	fs afero.Fs
+
+	cmd *cobra.Command
+}
+
+func newRenameCmd() *renameCmd {
+	rc := &renameCmd{
+		fs: utils.NewFs(),
+	}
+
+	rc.cmd = &cobra.Command{
+		Use:   "rename",
+		Short: "Rename the context of a konf",
+		Long: `Rename the context of a konf
+
+As the id of a konf is made up of its context and cluster, this also changes the id of the konf.
+Favorites, toggle pairs, aliases and the history for 'konf set -' are carried over to the new id.
+Konfs that are fetched by a command cannot be renamed, as the command has to keep on returning them
+under their id.
+
+Examples:
+	-> 'rename <konfig id> <new context name>' rename the context of a konf
+`,
+		Args:              cobra.ExactArgs(2),
+		RunE:              rc.rename,
+		ValidArgsFunction: rc.completeRename,
+	}
+
+	return rc
+}
+
+func (c *renameCmd) rename(cmd *cobra.Command, args []string) error {
+	_, err := renameKonf(c.fs, args[0], args[1])
+	return err
+}
+
+// renameKonf renames the context of the konf with the supplied id and carries its metadata over to the new id,
+// which is returned
+func renameKonf(f afero.Fs, id, context string) (string, error) {
+	m, err := loadMetadata(f)
+	if err != nil {
+		return "", err
+	}
+	// a fetched konf has to match its id, so the fetch command would fail on the next set
+	if km, ok := m.Konfs[id]; ok && km.FetchCommand != "" {
+		return "", fmt.Errorf("konf %q is fetched by a command on set, which has to return it under the same id. Please import it again with the new context name instead", id)
+	}
+
+	path, err := ownStorePath(f, id)
+	if err != nil {
+		return "", err
+	}
+	b, err := readStoreFile(f, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", &KonfNotFound{ID: id}
+		}
+		return "", err
+	}
+	var conf k8s.Config
+	err = yaml.Unmarshal(b, &conf)
+	if err != nil {
+		return "", err
+	}
+	if overloaded(&conf) {
+		return "", &KubeConfigOverload{Path: path}
+	}
+	// konfs split by cluster share a single id between all of their contexts
+	if len(conf.Contexts) != 1 {
+		return "", fmt.Errorf("konf %q contains multiple contexts, so it is unclear which of them to rename", id)
+	}
+
+	newID := utils.IDFromClusterAndContext(conf.Contexts[0].Context.Cluster, context)
+	if newID == id {
+		return "", fmt.Errorf("konf %q already uses the context name %q", id, context)
+	}
+
+	err = checkRenameCollision(f, m, context, newID)
+	if err != nil {
+		return "", err
+	}
+
+	conf.Contexts[0].Name = context
+	conf.CurrentContext = context
+	err = writeConfig(f, &konfFile{FilePath: utils.StorePathForID(newID), Content: conf})
+	if err != nil {
+		return "", err
+	}
+	err = f.Remove(path)
+	if err != nil {
+		return "", err
+	}
+
+	m.renameKonf(id, newID)
+	err = saveMetadata(f, m)
+	if err != nil {
+		return "", err
+	}
+	err = renameInHistory(f, map[string]string{id: newID})
+	if err != nil {
+		return "", err
+	}
+
+	log.Info("Renamed konf %q to %q\n", id, newID)
+	return newID, nil
+}
+
+// checkRenameCollision makes sure that neither the new id, nor the new context name are already in use
+// by another konf or an alias
+func checkRenameCollision(f afero.Fs, m *metadata, context string, newID string) error {
+	if _, err := f.Stat(utils.ResolveStorePath(f, newID)); err == nil {
+		return fmt.Errorf("cannot rename, because a konf with the id %q already exists in the store", newID)
+	}
+	for _, name := range []string{newID, context} {
+		if target, ok := m.Aliases[name]; ok {
+			return fmt.Errorf("cannot rename, because %q is already an alias for the konf %q", name, target)
+		}
+	}
+	return nil
+}
+
+func (c *renameCmd) completeRename(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// only the konf to rename can be completed, the new name is up to the user
+	if len(args) != 0 {
+		return []string{}, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	konfs, err := fetchKonfs(c.fs)
+	if err != nil {
+		if errors.Is(err, &EmptyStore{}) {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	sug := []string{}
+	for _, konf := range konfs {
+		sug = append(sug, utils.IDFromClusterAndContext(konf.Cluster, konf.Context))
+	}
+
+	return sug, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(newRenameCmd().cmd)
+}
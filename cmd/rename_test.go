@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRename(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	var latest = func(f afero.Fs) {
+		afero.WriteFile(f, config.LatestKonfFile(), []byte("dev-eu_dev-eu-1"), utils.KonfPerm)
+		afero.WriteFile(f, config.HistoryFile(), []byte("dev-eu_dev-eu-1\ndev-asia_dev-asia-1\ndev-eu_dev-eu-1\n"), utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		args   []string
+		expErr error
+		expID  string
+	}{
+		"rename": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, latest, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    favorite: true\ntoggle:\n- dev-eu_dev-eu-1\n- dev-asia_dev-asia-1\naliases:\n  eu: dev-eu_dev-eu-1\n")),
+			[]string{"dev-eu_dev-eu-1", "eu-prod"},
+			nil,
+			"eu-prod_dev-eu-1",
+		},
+		"fetched konf": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    fetchCommand: vault read eu\n")),
+			[]string{"dev-eu_dev-eu-1", "eu-prod"},
+			fmt.Errorf("konf \"dev-eu_dev-eu-1\" is fetched by a command on set, which has to return it under the same id. Please import it again with the new context name instead"),
+			"",
+		},
+		"konf does not exist": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			[]string{"dev-eu_dev-eu-1", "eu-prod"},
+			&KonfNotFound{ID: "dev-eu_dev-eu-1"},
+			"",
+		},
+		"multiple contexts of one cluster": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterMultiContext, func(f afero.Fs) {
+				// both contexts have to point at the same cluster, just like in konfs split by cluster
+				b, _ := afero.ReadFile(f, utils.StorePathForID("multi_konf"))
+				konf := strings.Replace(string(b), "cluster: dev-asia-1", "cluster: dev-eu-1", 1)
+				afero.WriteFile(f, utils.StorePathForID("multi_konf"), []byte(konf), utils.KonfPerm)
+			}),
+			[]string{"multi_konf", "eu-prod"},
+			fmt.Errorf("konf \"multi_konf\" contains multiple contexts, so it is unclear which of them to rename"),
+			"",
+		},
+		"same name": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"dev-eu_dev-eu-1", "dev-eu"},
+			fmt.Errorf("konf \"dev-eu_dev-eu-1\" already uses the context name \"dev-eu\""),
+			"",
+		},
+		"collision with konf in store": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, func(f afero.Fs) {
+				afero.WriteFile(f, utils.StorePathForID("eu-prod_dev-eu-1"), []byte{}, utils.KonfPerm)
+			}),
+			[]string{"dev-eu_dev-eu-1", "eu-prod"},
+			fmt.Errorf("cannot rename, because a konf with the id \"eu-prod_dev-eu-1\" already exists in the store"),
+			"",
+		},
+		"collision of the context name with an alias": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("aliases:\n  eu-prod: dev-asia_dev-asia-1\n")),
+			[]string{"dev-eu_dev-eu-1", "eu-prod"},
+			fmt.Errorf("cannot rename, because \"eu-prod\" is already an alias for the konf \"dev-asia_dev-asia-1\""),
+			"",
+		},
+		"collision of the id with an alias": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("aliases:\n  eu-prod_dev-eu-1: dev-asia_dev-asia-1\n")),
+			[]string{"dev-eu_dev-eu-1", "eu-prod"},
+			fmt.Errorf("cannot rename, because \"eu-prod_dev-eu-1\" is already an alias for the konf \"dev-asia_dev-asia-1\""),
+			"",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			rc := newRenameCmd()
+			rc.fs = tc.fs
+
+			err := rc.cmd.RunE(rc.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp error %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			if _, err := tc.fs.Stat(utils.StorePathForID(tc.args[0])); err == nil {
+				t.Errorf("Exp konf %q to be removed", tc.args[0])
+			}
+			b, err := afero.ReadFile(tc.fs, utils.StorePathForID(tc.expID))
+			if err != nil {
+				t.Fatalf("Exp konf %q to exist, but got: %q", tc.expID, err)
+			}
+			var conf k8s.Config
+			err = yaml.Unmarshal(b, &conf)
+			if err != nil {
+				t.Fatalf("Could not unmarshal konf: %q", err)
+			}
+			id, err := idFromKonf(&conf)
+			if err != nil || id != tc.expID {
+				t.Errorf("Exp the content of the konf to match id %q, got %q", tc.expID, id)
+			}
+			if conf.CurrentContext != tc.args[1] {
+				t.Errorf("Exp current-context to be %q, got %q", tc.args[1], conf.CurrentContext)
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			if !cmp.Equal(m.favorites(), []string{tc.expID}) {
+				t.Errorf("Exp favorite to be carried over, got %q", m.favorites())
+			}
+			if !cmp.Equal(m.Toggle, []string{tc.expID, "dev-asia_dev-asia-1"}) {
+				t.Errorf("Exp toggle pair to be carried over, got %q", m.Toggle)
+			}
+			if m.Aliases["eu"] != tc.expID {
+				t.Errorf("Exp alias to be carried over, got %q", m.Aliases["eu"])
+			}
+
+			b, _ = afero.ReadFile(tc.fs, config.LatestKonfFile())
+			if string(b) != tc.expID {
+				t.Errorf("Exp latest konf to be renamed to %q, got %q", tc.expID, b)
+			}
+			hist, _ := readHistory(tc.fs)
+			if !cmp.Equal(hist, []string{tc.expID, "dev-asia_dev-asia-1", tc.expID}) {
+				t.Errorf("Exp history to be renamed, got %q", hist)
+			}
+		})
+	}
+}
+
+func TestCompleteRename(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs           afero.Fs
+		args         []string
+		expComp      []string
+		expCompDirec cobra.ShellCompDirective
+	}{
+		"first arg": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			[]string{},
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+			cobra.ShellCompDirectiveNoFileComp,
+		},
+		"second arg": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			[]string{"dev-eu_dev-eu-1"},
+			[]string{},
+			cobra.ShellCompDirectiveNoFileComp,
+		},
+		"empty store": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			[]string{},
+			[]string{},
+			cobra.ShellCompDirectiveNoFileComp,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			rc := newRenameCmd()
+			rc.fs = tc.fs
+
+			res, compdirec := rc.completeRename(rc.cmd, tc.args, "")
+			if !cmp.Equal(res, tc.expComp) {
+				t.Errorf("Exp and given comps differ: \n '%s'", cmp.Diff(tc.expComp, res))
+			}
+			if compdirec != tc.expCompDirec {
+				t.Errorf("Exp compdirec %q, got %q", tc.expCompDirec, compdirec)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
+
+	"github.com/simontheleg/konf-go/config"
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+type resyncCmd struct {
+	fs afero.Fs
+
+	fetchURL func(*http.Client, string, []string) ([]byte, error)
+
+	cmd *cobra.Command
+}
+
+func newResyncCmd() *resyncCmd {
+	rc := &resyncCmd{
+		fs:       utils.NewFs(),
+		fetchURL: fetchURL,
+	}
+
+	rc.cmd = &cobra.Command{
+		Use:   "resync <konfig id>",
+		Short: "Update a konf from the file or url it has been imported from",
+		Long: `Update a konf from the file or url it has been imported from
+
+The source is split up with the same options as during the import, e.g. --context-prefix or --split-by. Konfs that
+have been imported from the clipboard, a bundle or via --fetch-command cannot be resynced. Headers of --url imports
+are not recorded, as they usually contain credentials.
+`,
+		Args:              cobra.ExactArgs(1),
+		RunE:              rc.resync,
+		ValidArgsFunction: rc.completeResync,
+	}
+
+	return rc
+}
+
+func (c *resyncCmd) resync(cmd *cobra.Command, args []string) error {
+	id, err := resolveAlias(c.fs, args[0])
+	if err != nil {
+		return err
+	}
+	// the resynced konf is written to the own store, where it would shadow the konf of a shared store
+	path, err := ownStorePath(c.fs, id)
+	if err != nil {
+		return err
+	}
+	b, err := readStoreFile(c.fs, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &KonfNotFound{ID: id}
+		}
+		return err
+	}
+	var stored k8s.Config
+	err = yaml.Unmarshal(b, &stored)
+	if err != nil || len(stored.Contexts) == 0 {
+		return fmt.Errorf("konf %q is not a valid kubeconfig. Please re-import it", id)
+	}
+
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+	km, ok := m.Konfs[id]
+	if !ok || km.Source == nil {
+		return fmt.Errorf("konf %q has not been imported from a file or url, so it cannot be resynced", id)
+	}
+	src := km.Source
+
+	confs, err := c.readSource(src)
+	if err != nil {
+		return err
+	}
+	conf := findSourceKonf(confs, src)
+	if conf == nil {
+		return fmt.Errorf("the source %q of konf %q does not contain the context %q anymore", src, id, src.Context)
+	}
+
+	// the konf might have been renamed since the import, which must not change its id
+	if conf.ID() != id {
+		conf = renameContext(conf, stored.Contexts[0].Name)
+	}
+	// a namespace persisted via 'konf ns' should survive a resync, unless the source sets one itself
+	if ctx := namespaceContext(&conf.Content); ctx != nil && ctx.Context.Namespace == "" {
+		if sctx := namespaceContext(&stored); sctx != nil {
+			ctx.Context.Namespace = sctx.Context.Namespace
+		}
+	}
+
+	res, err := compareWithStore(c.fs, conf)
+	if err != nil {
+		return err
+	}
+	if res == konfUnchanged {
+		log.Info("Konf %q is already up to date with %q\n", id, src)
+		return nil
+	}
+	err = writeConfig(c.fs, conf)
+	if err != nil {
+		return err
+	}
+	log.Info("Resynced konf %q from %q\n", id, src)
+	return nil
+}
+
+// readSource reads src and splits it up the same way the import did
+func (c *resyncCmd) readSource(src *konfSource) ([]*konfFile, error) {
+	var confs []*konfFile
+	var err error
+	if src.URL != "" {
+		var b []byte
+		b, err = c.fetchURL(&http.Client{Timeout: config.Timeout()}, src.URL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch the source %q: %v", src, err)
+		}
+		confs, err = splitConfigs(b, src.SplitUsers)
+	} else {
+		confs, err = determineConfigs(c.fs, src.Path, src.SplitUsers)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("the source %q does not exist anymore", src)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not split the source %q: %v", src, err)
+	}
+
+	if src.Prefix != "" {
+		confs = applyContextPrefix(confs, src.Prefix)
+	}
+	if src.SplitBy == splitByCluster {
+		confs = groupByCluster(confs)
+	}
+	if src.StripStaticAuth {
+		stripStaticAuth(confs)
+	}
+	return confs, nil
+}
+
+// findSourceKonf returns the konf of confs that has been imported from src
+func findSourceKonf(confs []*konfFile, src *konfSource) *konfFile {
+	for _, conf := range confs {
+		if conf.Content.Contexts[0].Name == src.Context {
+			return conf
+		}
+	}
+	return nil
+}
+
+// completeResync only suggests konfs that have a source to resync from
+func (c *resyncCmd) completeResync(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return []string{}, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	sug := []string{}
+	for id, km := range m.Konfs {
+		if km.Source == nil {
+			continue
+		}
+		// konfs of shared stores cannot be resynced
+		path, err := ownStorePath(c.fs, id)
+		if err != nil {
+			continue
+		}
+		if _, err := c.fs.Stat(path); err == nil {
+			sug = append(sug, id)
+		}
+	}
+	sort.Strings(sug)
+	return sug, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(newResyncCmd().cmd)
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestResync(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	moved := strings.ReplaceAll(sm.MultiClusterMultiContext(), "https://10.1.1.0", "https://10.2.2.2")
+
+	tt := map[string]struct {
+		url       string
+		prefix    string
+		source    func(afero.Fs)
+		id        string
+		expErr    error
+		expServer string
+	}{
+		"file": {
+			"", "",
+			importSource("/src/kubeconfig.yaml", moved),
+			"dev-eu_dev-eu-1",
+			nil,
+			"https://10.2.2.2",
+		},
+		"url": {
+			"https://example.com/kubeconfig", "",
+			func(afero.Fs) {},
+			"dev-eu_dev-eu-1",
+			nil,
+			"https://10.2.2.2",
+		},
+		"prefixed context": {
+			"", "aws",
+			importSource("/src/kubeconfig.yaml", moved),
+			"aws-dev-eu_dev-eu-1",
+			nil,
+			"https://10.2.2.2",
+		},
+		"source is gone": {
+			"", "",
+			func(f afero.Fs) { f.Remove("/src/kubeconfig.yaml") },
+			"dev-eu_dev-eu-1",
+			fmt.Errorf("the source \"/src/kubeconfig.yaml\" does not exist anymore"),
+			"",
+		},
+		"context is gone": {
+			"", "",
+			importSource("/src/kubeconfig.yaml", sm.SingleClusterSingleContextASIA()),
+			"dev-eu_dev-eu-1",
+			fmt.Errorf("the source \"/src/kubeconfig.yaml\" of konf \"dev-eu_dev-eu-1\" does not contain the context \"dev-eu\" anymore"),
+			"",
+		},
+		"konf without source": {
+			"", "",
+			func(f afero.Fs) { f.Remove(config.MetadataFile()) },
+			"dev-asia_dev-asia-1",
+			fmt.Errorf("konf \"dev-asia_dev-asia-1\" has not been imported from a file or url, so it cannot be resynced"),
+			"",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(importSource("/src/kubeconfig.yaml", sm.MultiClusterMultiContext()))
+			served := sm.MultiClusterMultiContext()
+			serve := func(*http.Client, string, []string) ([]byte, error) { return []byte(served), nil }
+
+			icmd := newImportCmd()
+			icmd.fs = f
+			icmd.prefix = tc.prefix
+			icmd.url = tc.url
+			icmd.fetchURL = serve
+			icmd.cmd.SetOut(io.Discard)
+			args := []string{"/src/kubeconfig.yaml"}
+			if tc.url != "" {
+				args = []string{}
+			}
+			err := icmd.cmd.RunE(icmd.cmd, args)
+			if err != nil {
+				t.Fatalf("Could not import source: %q", err)
+			}
+
+			tc.source(f)
+			served = moved
+			rc := newResyncCmd()
+			rc.fs = f
+			rc.fetchURL = serve
+
+			err = rc.cmd.RunE(rc.cmd, []string{tc.id})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			b, err := afero.ReadFile(f, utils.StorePathForID(tc.id))
+			if err != nil {
+				t.Fatalf("Could not read konf: %q", err)
+			}
+			conf := parseKonf(t, b)
+			if len(conf.Clusters) != 1 || conf.Clusters[0].Cluster.Server != tc.expServer {
+				t.Errorf("Exp konf %q to point at %q after the resync, got %v", tc.id, tc.expServer, conf.Clusters)
+			}
+			if len(conf.Contexts) != 1 || conf.Contexts[0].Name+"_dev-eu-1" != tc.id || conf.Contexts[0].Context.Namespace != "kube-public" {
+				t.Errorf("Exp konf %q to keep its context, got %v", tc.id, conf.Contexts)
+			}
+
+			// konfs of the same source are not touched
+			b, _ = afero.ReadFile(f, utils.StorePathForID(strings.Replace(tc.id, "eu", "asia", -1)))
+			if conf := parseKonf(t, b); conf.Clusters[0].Cluster.Server != "https://192.168.0.1" {
+				t.Errorf("Exp other konfs to stay untouched, got %v", conf.Clusters)
+			}
+		})
+	}
+}
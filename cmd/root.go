@@ -1,20 +1,41 @@
 package cmd
 
 import (
+	"fmt"
 	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/simontheleg/konf-go/config"
 	"github.com/simontheleg/konf-go/log"
 	"github.com/simontheleg/konf-go/utils"
-	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
 var (
-	konfDir string
-	silent  bool
+	configFile string
+	konfDir    string
+	silent     bool
+	timeout    time.Duration
+	trunc      int
+	stateless  bool
+
+	contextDisplayRegex   string
+	contextDisplayReplace string
+	storeBackend          string
+	picker                string
+	groupPicker           bool
+	directStore           bool
+	encrypt               bool
+	debugTiming           bool
 )
 
+// clock is used by everything that depends on the current time, like the expiry of scratch konfs or the age of verify
+// results. Tests replace it to control the time
+var clock utils.Clock = utils.RealClock{}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "konf",
@@ -36,9 +57,28 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(wrapInit)
 
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file to load settings from. Flags take precedence over it. Can also be set via $KONF_CONFIG")
 	rootCmd.PersistentFlags().StringVar(&konfDir, "konf-dir", "", "konfs directory for kubeconfigs and tracking active konfs (default is $HOME/.kube/konfs)")
 	rootCmd.PersistentFlags().BoolVar(&silent, "silent", false, "suppress log output if set to true (default is false)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "timeout for operations that have to wait on something outside of konf, like network requests or credential plugins (default is 10s)")
+	rootCmd.PersistentFlags().IntVar(&trunc, "trunc", 0, "maximum width of each column in the konf picker. Values below 7 are raised to 7 (default is 25)")
+	rootCmd.PersistentFlags().StringVar(&contextDisplayRegex, "context-display-regex", "", "regex that is replaced by --context-display-replace in context names shown by the picker. Only affects the display, never the konf ids")
+	rootCmd.PersistentFlags().StringVar(&contextDisplayReplace, "context-display-replace", "", "replacement for matches of --context-display-regex. Supports references like ${1}")
+	rootCmd.PersistentFlags().StringVar(&storeBackend, "store-backend", "", "filesystem that holds the konf store. Active konfs always stay on the local filesystem (default is os)")
+	rootCmd.PersistentFlags().StringVar(&picker, "picker", "", "program to pick konfs with. Either prompt or fzf. Falls back to prompt if fzf is not installed (default is prompt)")
+	rootCmd.PersistentFlags().BoolVar(&groupPicker, "group-by-cluster", false, "group konfs in the built-in picker below a header line for their cluster (default is false)")
+	rootCmd.PersistentFlags().BoolVar(&directStore, "direct-store", false, "point $KUBECONFIG directly at the konf in the store instead of a copy for the current shell. Namespaces cannot be changed in this mode (default is false)")
+	rootCmd.PersistentFlags().BoolVar(&encrypt, "encrypt", false, "encrypt konfs when writing them to the store. The passphrase is read from $KONF_PASSPHRASE or the output of passphraseCommand in the config file (default is false)")
+	rootCmd.PersistentFlags().BoolVar(&debugTiming, "debug-timing", false, "log how long the major phases of a command, like reading the store, take to stderr. Can also be enabled via $KONF_DEBUG_TIMING, which also covers the completion (default is false)")
+	rootCmd.PersistentFlags().BoolVar(&stateless, "stateless", false, "do not write the latest konf or any other history, only the active konf of the shell. Can also be enabled via $KONF_STATELESS (default is false)")
+	// users juggling multiple stores switch between them a lot, so their paths should be easy to complete
+	cobra.CheckErr(rootCmd.RegisterFlagCompletionFunc("konf-dir", completeDir))
+
+}
 
+// completeDir lets the shell complete directories only
+func completeDir(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveFilterDirs
 }
 
 // wrapInit is required as cobra.OnInitialize only accepts func() as interface
@@ -46,16 +86,97 @@ func wrapInit() {
 	conf, err := config.ConfFromHomeDir()
 	cobra.CheckErr(err)
 
+	if configFile == "" {
+		configFile = os.Getenv("KONF_CONFIG")
+	}
+	if configFile != "" {
+		err := conf.LoadFile(configFile)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("could not load config file %q: %w", configFile, err))
+		}
+	}
+
 	if konfDir != "" {
 		conf.KonfDir = konfDir
 	}
+	if timeout != 0 {
+		conf.Timeout = timeout
+	}
+	if trunc != 0 {
+		conf.ColumnsMaxWidth = trunc
+	}
+	if stateless || envEnabled("KONF_STATELESS") {
+		conf.Stateless = true
+	}
+	if contextDisplayRegex != "" {
+		conf.ContextDisplayRegex = contextDisplayRegex
+		conf.ContextDisplayReplace = contextDisplayReplace
+	}
+	cobra.CheckErr(conf.Compile())
+	if storeBackend != "" {
+		conf.StoreBackend = storeBackend
+	}
+	if _, ok := utils.StoreBackends[conf.StoreBackend]; !ok {
+		cobra.CheckErr(fmt.Errorf("unknown store backend %q", conf.StoreBackend))
+	}
+	if directStore {
+		conf.DirectStore = true
+	}
+	if encrypt {
+		conf.Encrypt = true
+	}
+	// kubectl would have to read the encrypted konf directly from the store in this case
+	if conf.Encrypt && conf.DirectStore {
+		cobra.CheckErr(fmt.Errorf("encrypted konfs cannot be used together with --direct-store"))
+	}
+	if conf.SafeModeServerRegex != "" {
+		_, err := regexp.Compile(conf.SafeModeServerRegex)
+		cobra.CheckErr(err)
+	}
+	for _, l := range conf.SafeModeLabels {
+		_, _, err := parseLabel(l)
+		cobra.CheckErr(err)
+	}
+	if conf.IDFormat != "" {
+		cobra.CheckErr(utils.ValidateIDFormat(conf.IDFormat))
+	}
+	if conf.ActiveFileTemplate != "" {
+		cobra.CheckErr(utils.ValidateActiveFileTemplate(conf.ActiveFileTemplate))
+	}
+	if picker != "" {
+		conf.Picker = picker
+	}
+	if conf.Picker != "prompt" && conf.Picker != "fzf" {
+		cobra.CheckErr(fmt.Errorf("unknown picker %q. Please use either prompt or fzf", conf.Picker))
+	}
+	if groupPicker {
+		conf.GroupByCluster = true
+	}
 	if silent {
 		conf.Silent = silent
+	}
+	if conf.Silent {
 		log.InitLogger(io.Discard, io.Discard)
 	}
+	// timings are requested explicitly, which is why they are not suppressed by --silent
+	if debugTiming || envEnabled("KONF_DEBUG_TIMING") {
+		log.EnableDebug(os.Stderr)
+	}
 
 	config.InitWithOverrides(conf)
 
-	err = utils.EnsureDir(afero.NewOsFs())
+	err = utils.EnsureDir(utils.NewFs())
 	cobra.CheckErr(err)
 }
+
+// logTiming logs how long phase has taken since start, if --debug-timing is enabled. It is meant to be deferred
+// right at the beginning of a phase
+func logTiming(phase string, start time.Time) {
+	log.Debug("%s took %s\n", phase, time.Since(start))
+}
+
+// envEnabled reports whether the environment variable key is set to a true value like "1" or "true"
+func envEnabled(key string) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	return err == nil && v
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/config"
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/spf13/afero"
+)
+
+// safeModeReason returns why the konf with the supplied id is protected by safe mode, together with its
+// summary. An empty reason means the konf is not protected
+func safeModeReason(f afero.Fs, id string) (string, *konfSummary, error) {
+	serverRegex, labels := config.SafeMode()
+	if serverRegex == "" && len(labels) == 0 {
+		return "", nil, nil
+	}
+
+	s, err := summarizeKonf(f, id)
+	if err != nil || s == nil {
+		return "", nil, err
+	}
+
+	if serverRegex != "" {
+		re, err := regexp.Compile(serverRegex)
+		if err != nil {
+			return "", nil, err
+		}
+		if re.MatchString(s.Server) {
+			return fmt.Sprintf("its server %q matches %q", s.Server, serverRegex), s, nil
+		}
+	}
+
+	m, err := loadMetadata(f)
+	if err != nil {
+		return "", nil, err
+	}
+	km := m.Konfs[id]
+	if km == nil {
+		return "", nil, nil
+	}
+	for _, l := range labels {
+		key, value, err := parseLabel(l)
+		if err != nil {
+			return "", nil, err
+		}
+		if v, ok := km.Labels[key]; ok && v == value {
+			return fmt.Sprintf("it is labeled %q", l), s, nil
+		}
+	}
+
+	return "", nil, nil
+}
+
+// confirmSafeMode makes the user type the context name of protected konfs, so they are never set by accident.
+// It returns whether the konf may be set
+func (c *setCmd) confirmSafeMode(id string) (bool, error) {
+	// nobody could have confirmed an unattended switch, which is why safe mode cannot be disabled for it
+	if !c.unattended && (c.noSafeMode || envEnabled("KONF_NO_SAFE_MODE")) {
+		return true, nil
+	}
+
+	reason, s, err := safeModeReason(c.fs, id)
+	if err != nil || reason == "" {
+		return true, err
+	}
+
+	if c.unattended {
+		return false, fmt.Errorf("konf %q is protected by safe mode, as %s. Please set it in a terminal", id, reason)
+	}
+
+	if !c.isTerminal() {
+		return false, fmt.Errorf("konf %q is protected by safe mode, as %s. Please set it in a terminal, or disable safe mode via --no-safe-mode or $KONF_NO_SAFE_MODE", id, reason)
+	}
+
+	log.Warn("Konf %q is protected by safe mode, as %s\n", id, reason)
+	res, err := c.inputFunc(&promptui.Prompt{Label: fmt.Sprintf("Type the context name %q to confirm", s.Context), Stdout: os.Stderr})
+	if err != nil {
+		return false, err
+	}
+	return res == s.Context, nil
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/spf13/afero"
+)
+
+func TestSetSafeMode(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	protectedErr := fmt.Errorf("konf \"dev-eu_dev-eu-1\" is protected by safe mode, as its server \"https://10.1.1.0\" matches \"10\\\\.1\\\\.\". Please set it in a terminal, or disable safe mode via --no-safe-mode or $KONF_NO_SAFE_MODE")
+
+	tt := map[string]struct {
+		serverRegex string
+		labels      []string
+		terminal    bool
+		input       string
+		noSafeMode  bool
+		args        []string
+		expErr      error
+		expPrompted bool
+		expActive   string
+	}{
+		"not protected": {
+			"^https://prod\\.", []string{"env=staging"}, true, "", false,
+			[]string{"dev-eu_dev-eu-1"},
+			nil, false, "dev-eu_dev-eu-1",
+		},
+		"server matches and confirmed": {
+			"10\\.1\\.", nil, true, "dev-eu", false,
+			[]string{"dev-eu_dev-eu-1"},
+			nil, true, "dev-eu_dev-eu-1",
+		},
+		"server matches and wrong name": {
+			"10\\.1\\.", nil, true, "dev-asia", false,
+			[]string{"dev-eu_dev-eu-1"},
+			nil, true, "",
+		},
+		"label matches": {
+			"", []string{"env=prod"}, true, "dev-eu", false,
+			[]string{"dev-eu_dev-eu-1"},
+			nil, true, "dev-eu_dev-eu-1",
+		},
+		"picker": {
+			"10\\.1\\.", nil, true, "dev-eu", false,
+			[]string{},
+			nil, true, "dev-eu_dev-eu-1",
+		},
+		"not a terminal": {
+			"10\\.1\\.", nil, false, "", false,
+			[]string{"dev-eu_dev-eu-1"},
+			protectedErr, false, "",
+		},
+		"disabled": {
+			"10\\.1\\.", nil, false, "", true,
+			[]string{"dev-eu_dev-eu-1"},
+			nil, false, "dev-eu_dev-eu-1",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			testhelper.OverrideConfig(t, func(c *config.Config) {
+				c.SafeModeServerRegex = tc.serverRegex
+				c.SafeModeLabels = tc.labels
+			})
+
+			f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    labels:\n      env: prod\n"))
+			sc := newSetCommand()
+			sc.fs = f
+			sc.noSafeMode = tc.noSafeMode
+			sc.isTerminal = func() bool { return tc.terminal }
+			sc.selector = promptFunc(func(*promptui.Select) (int, error) { return 0, nil })
+			prompted := false
+			sc.inputFunc = func(*promptui.Prompt) (string, error) { prompted = true; return tc.input, nil }
+
+			err := sc.cmd.RunE(sc.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if prompted != tc.expPrompted {
+				t.Errorf("Exp prompted to be %t, got %t", tc.expPrompted, prompted)
+			}
+
+			id, err := activeKonfID(f)
+			if err != nil {
+				t.Fatalf("Could not read active konf: %q", err)
+			}
+			if id != tc.expActive {
+				t.Errorf("Exp active konf %q, got %q", tc.expActive, id)
+			}
+		})
+	}
+}
+
+func TestSafeModeOtherSwitches(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	testhelper.OverrideConfig(t, func(c *config.Config) { c.SafeModeServerRegex = "10\\.1\\." })
+	// the server refuses protected konfs, even if safe mode has been disabled for its process
+	t.Setenv("KONF_NO_SAFE_MODE", "")
+
+	tt := map[string]struct {
+		set    func(t *testing.T, f afero.Fs) error
+		expErr error
+	}{
+		"toggle outside of a terminal": {
+			func(t *testing.T, f afero.Fs) error {
+				tcmd := newToggleCmd()
+				tcmd.fs = f
+				return tcmd.cmd.RunE(tcmd.cmd, []string{"dev-eu_dev-eu-1", "dev-asia_dev-asia-1"})
+			},
+			fmt.Errorf("konf \"dev-eu_dev-eu-1\" is protected by safe mode, as its server \"https://10.1.1.0\" matches \"10\\\\.1\\\\.\". Please set it in a terminal, or disable safe mode via --no-safe-mode or $KONF_NO_SAFE_MODE"),
+		},
+		"tui with wrong context name": {
+			func(t *testing.T, f afero.Fs) error {
+				tcmd := newTuiCmd()
+				tcmd.fs = f
+				tcmd.isTerminal = func() bool { return true }
+				// pick the EU konf and set it
+				sels := []int{1, 0}
+				tcmd.promptFunc = func(*promptui.Select) (int, error) {
+					sel := sels[0]
+					sels = sels[1:]
+					return sel, nil
+				}
+				tcmd.inputFunc = func(*promptui.Prompt) (string, error) { return "dev-asia", nil }
+				tcmd.cmd.SetErr(io.Discard)
+				return tcmd.cmd.RunE(tcmd.cmd, []string{})
+			},
+			nil,
+		},
+		"serve": {
+			func(t *testing.T, f afero.Fs) error {
+				t.Setenv("KONF_NO_SAFE_MODE", "true")
+				sc := newServeCmd()
+				sc.fs = f
+				_, err := sc.set(serveParams{ID: "dev-eu_dev-eu-1", PID: os.Getppid()})
+				return err
+			},
+			fmt.Errorf("konf \"dev-eu_dev-eu-1\" is protected by safe mode, as its server \"https://10.1.1.0\" matches \"10\\\\.1\\\\.\". Please set it in a terminal"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA)
+			f.RemoveAll("./konf/active")
+
+			err := tc.set(t, f)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			id, err := activeKonfID(f)
+			if err != nil {
+				t.Fatalf("Could not read active konf: %q", err)
+			}
+			if id != "" {
+				t.Errorf("Exp the protected konf not to be set, got %q", id)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/config"
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/prompt"
+)
+
+// Selector lets the user pick one of konfs and returns its position
+type Selector interface {
+	Select(konfs []tableOutput) (int, error)
+}
+
+// Select shows konfs in the built-in picker, which is run by pf. This makes every promptFunc a Selector, which
+// keeps them easy to mock in tests
+func (pf promptFunc) Select(konfs []tableOutput) (int, error) {
+	if config.GroupByCluster() {
+		return selectGrouped(pf, konfs)
+	}
+	return pf(createPrompt(konfs, config.ColumnsMaxWidth()))
+}
+
+// selectGrouped shows konfs below a header row for each cluster. promptui has no notion of rows that cannot be
+// selected, which is why selecting a header opens the picker again with the cursor on the first konf below it
+func selectGrouped(pf promptFunc, konfs []tableOutput) (int, error) {
+	rows, positions := groupRows(konfs)
+	cursor := 0
+	for {
+		p := createPrompt(rows, config.ColumnsMaxWidth())
+		p.CursorPos = cursor
+		pos, err := pf(p)
+		if err != nil {
+			return 0, err
+		}
+		if pos >= len(rows) {
+			return 0, fmt.Errorf("invalid selection %d", pos)
+		}
+		if positions[pos] >= 0 {
+			return positions[pos], nil
+		}
+		// every header is followed by at least one konf
+		cursor = pos + 1
+	}
+}
+
+// groupRows buckets konfs by cluster, in the order the clusters first appear in, and prepends a header row to each
+// bucket. positions maps each row to the position of its konf in konfs, or to -1 for headers
+func groupRows(konfs []tableOutput) (rows []tableOutput, positions []int) {
+	clusters := []string{}
+	buckets := map[string][]int{}
+	for i, k := range konfs {
+		if _, ok := buckets[k.Cluster]; !ok {
+			clusters = append(clusters, k.Cluster)
+		}
+		buckets[k.Cluster] = append(buckets[k.Cluster], i)
+	}
+
+	for _, cl := range clusters {
+		rows = append(rows, tableOutput{Cluster: cl, Header: true})
+		positions = append(positions, -1)
+		for _, i := range buckets[cl] {
+			rows = append(rows, konfs[i])
+			positions = append(positions, i)
+		}
+	}
+	return rows, positions
+}
+
+// fzfSelector picks konfs via fzf. run receives one line per konf and returns the selected line
+type fzfSelector struct {
+	run func(input string) (string, error)
+}
+
+func (s *fzfSelector) Select(konfs []tableOutput) (int, error) {
+	return newFzfPrompt(s.run)(&promptui.Select{Items: konfs})
+}
+
+// simpleSelector prints konfs as a numbered list to out and reads the number of the selection from in
+type simpleSelector struct {
+	in  io.Reader
+	out io.Writer
+}
+
+func (s *simpleSelector) Select(konfs []tableOutput) (int, error) {
+	return newSimplePrompt(s.in, s.out)(createPrompt(konfs, config.ColumnsMaxWidth()))
+}
+
+// pickerSelector picks konfs with the picker that is configured by the user. The picker is chosen on every
+// selection, as the config is only loaded after all commands have been created
+type pickerSelector struct{}
+
+func (pickerSelector) Select(konfs []tableOutput) (int, error) {
+	return selectorFor(os.Getenv("TERM"), runtime.GOOS, config.Picker(), exec.LookPath).Select(konfs)
+}
+
+// selectorFor returns the Selector for the supplied terminal and picker. Terminals which cannot render the
+// built-in picker get a numbered list instead. If fzf is configured, but not installed, the built-in picker is used
+func selectorFor(term, goos, picker string, lookPath func(string) (string, error)) Selector {
+	if dumbTerminal(term, goos) {
+		return &simpleSelector{in: os.Stdin, out: os.Stderr}
+	}
+	if picker == "fzf" {
+		if _, err := lookPath("fzf"); err == nil {
+			return &fzfSelector{run: runFzf}
+		}
+		log.Warn("fzf is configured as picker, but could not be found in $PATH. Falling back to the built-in picker\n")
+	}
+	return promptFunc(prompt.Terminal)
+}
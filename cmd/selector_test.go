@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+)
+
+func TestSelectorFor(t *testing.T) {
+	installed := func(string) (string, error) { return "/usr/bin/fzf", nil }
+	missing := func(string) (string, error) { return "", fmt.Errorf("not found") }
+
+	tt := map[string]struct {
+		term     string
+		goos     string
+		picker   string
+		lookPath func(string) (string, error)
+		expType  string
+	}{
+		"built-in picker": {"xterm-256color", "linux", "prompt", installed, "cmd.promptFunc"},
+		"fzf":             {"xterm-256color", "linux", "fzf", installed, "*cmd.fzfSelector"},
+		"fzf missing":     {"xterm-256color", "linux", "fzf", missing, "cmd.promptFunc"},
+		"dumb terminal":   {"dumb", "linux", "fzf", installed, "*cmd.simpleSelector"},
+		"windows console": {"", "windows", "prompt", installed, "cmd.promptFunc"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			s := selectorFor(tc.term, tc.goos, tc.picker, tc.lookPath)
+			if res := fmt.Sprintf("%T", s); res != tc.expType {
+				t.Errorf("Exp selector of type %s, got %s", tc.expType, res)
+			}
+		})
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	konfs := []tableOutput{
+		{Context: "dev-eu", Cluster: "dev-eu-1", File: "./konf/store/dev-eu_dev-eu-1.yaml"},
+		{Context: "dev-asia", Cluster: "dev-asia-1", File: "./konf/store/dev-asia_dev-asia-1.yaml"},
+	}
+
+	var items interface{}
+	tt := map[string]Selector{
+		"prompt": promptFunc(func(p *promptui.Select) (int, error) { items = p.Items; return 1, nil }),
+		"fzf": &fzfSelector{run: func(string) (string, error) {
+			return "1\tdev-asia\tdev-asia-1\t./konf/store/dev-asia_dev-asia-1.yaml", nil
+		}},
+		"simple": &simpleSelector{in: strings.NewReader("2\n"), out: &bytes.Buffer{}},
+	}
+
+	for name, s := range tt {
+		t.Run(name, func(t *testing.T) {
+			pos, err := s.Select(konfs)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if pos != 1 {
+				t.Errorf("Exp position 1, got %d", pos)
+			}
+		})
+	}
+
+	if !cmp.Equal(items, konfs) {
+		t.Errorf("Exp the built-in picker to show all konfs, got %v", items)
+	}
+}
+
+func TestSelectGrouped(t *testing.T) {
+	testhelper.OverrideConfig(t, func(c *config.Config) { c.GroupByCluster = true })
+
+	konfs := []tableOutput{
+		{Context: "dev-eu", Cluster: "eu-1"},
+		{Context: "dev-asia", Cluster: "asia-1"},
+		{Context: "dev-eu-admin", Cluster: "eu-1"},
+	}
+	expRows := []tableOutput{
+		{Cluster: "eu-1", Header: true},
+		{Context: "dev-eu", Cluster: "eu-1"},
+		{Context: "dev-eu-admin", Cluster: "eu-1"},
+		{Cluster: "asia-1", Header: true},
+		{Context: "dev-asia", Cluster: "asia-1"},
+	}
+
+	// the first selection lands on the header of asia-1, which must not be selectable
+	picks := []int{3, 4}
+	cursors := []int{}
+	pf := promptFunc(func(p *promptui.Select) (int, error) {
+		if !cmp.Equal(p.Items, expRows) {
+			t.Errorf("Exp rows %v, got %v", expRows, p.Items)
+		}
+		cursors = append(cursors, p.CursorPos)
+		pick := picks[0]
+		picks = picks[1:]
+		return pick, nil
+	})
+
+	id, err := selectKonf(konfs, pf)
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if id != "dev-asia_asia-1" {
+		t.Errorf("Exp konf %q to be selected, got %q", "dev-asia_asia-1", id)
+	}
+	if !cmp.Equal(cursors, []int{0, 4}) {
+		t.Errorf("Exp the picker to reopen below the selected header, got cursor positions %v", cursors)
+	}
+}
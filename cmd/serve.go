@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/simontheleg/konf-go/config"
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// serveRequest is a single request to 'konf serve'. Requests are newline-delimited JSON objects
+type serveRequest struct {
+	Method string      `json:"method"`
+	Params serveParams `json:"params,omitempty"`
+}
+
+type serveParams struct {
+	// ID of the konf to set
+	ID string `json:"id,omitempty"`
+	// PID of the shell the request is made for. As the server does not run inside the shell, it has to be supplied
+	// explicitly for current and set
+	PID int `json:"pid,omitempty"`
+}
+
+// serveResponse answers a single serveRequest. Result is only set if Error is empty
+type serveResponse struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// serveKonf is the representation of a konf in responses of 'konf serve'
+type serveKonf struct {
+	ID       string            `json:"id"`
+	Context  string            `json:"context"`
+	Cluster  string            `json:"cluster"`
+	File     string            `json:"file"`
+	Favorite bool              `json:"favorite,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+type serveCmd struct {
+	fs afero.Fs
+
+	socket string
+
+	cmd *cobra.Command
+}
+
+func newServeCmd() *serveCmd {
+	sc := &serveCmd{
+		fs: utils.NewFs(),
+	}
+
+	sc.cmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Answer requests of editors and plugins on a unix socket",
+		Long: `Answer requests of editors and plugins on a unix socket
+
+Instead of spawning konf for every single operation, tools can connect to the socket once and
+send their requests over it. Requests and responses are JSON objects, one per line:
+
+	-> {"method": "list"}
+	<- {"result": [{"id": "dev-eu_dev-eu-1", "context": "dev-eu", "cluster": "dev-eu-1", "file": "..."}]}
+	-> {"method": "current", "params": {"pid": 4242}}
+	<- {"result": "dev-eu_dev-eu-1"}
+	-> {"method": "set", "params": {"id": "dev-eu_dev-eu-1", "pid": 4242}}
+	<- {"result": {"kubeconfig": "/home/user/.kube/konfs/active/4242.yaml", "env": {"AWS_PROFILE": "dev"}}}
+
+The result of set contains the kubeconfig the shell with the supplied pid should point KUBECONFIG to,
+together with the environment variables of the konf it should export. Apart from that, set behaves
+just like 'konf set <konfig id>'. As nobody can answer a prompt, konfs protected by safe mode are
+refused. Failed requests are answered with {"error": "..."}.
+
+Only a single server can run per socket. It shuts down on SIGINT and SIGTERM.
+`,
+		Args: cobra.NoArgs,
+		RunE: sc.serve,
+	}
+
+	sc.cmd.Flags().StringVar(&sc.socket, "socket", "", "path of the unix socket to listen on. Defaults to konfDir/konf.sock")
+
+	return sc
+}
+
+func (c *serveCmd) serve(cmd *cobra.Command, args []string) error {
+	path := c.socket
+	if path == "" {
+		path = config.SocketFile()
+	}
+
+	l, err := listenSingleInstance(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Info("Listening on %q\n", path)
+	return c.serveListener(ctx, l)
+}
+
+// listenSingleInstance listens on the unix socket at path. Sockets left over by a server that did not shut down
+// cleanly are replaced, whereas an error is returned if another server is still answering on it
+func listenSingleInstance(path string) (net.Listener, error) {
+	conn, err := net.DialTimeout("unix", path, config.Timeout())
+	if err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("konf serve is already running on %q", path)
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("could not remove stale socket %q: %w", path, err)
+	}
+
+	return net.Listen("unix", path)
+}
+
+// serveListener answers requests on all connections of l until ctx is done. On shutdown, the listener and all
+// open connections are closed and in-flight requests are finished
+func (c *serveCmd) serveListener(ctx context.Context, l net.Listener) error {
+	var mu sync.Mutex
+	conns := map[net.Conn]bool{}
+	var wg sync.WaitGroup
+
+	go func() {
+		<-ctx.Done()
+		// closing the unix listener removes its socket file as well
+		l.Close()
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		mu.Lock()
+		conns[conn] = true
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.handleConn(conn)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// handleConn answers all requests on conn until it is closed
+func (c *serveCmd) handleConn(conn net.Conn) {
+	sc := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+	for sc.Scan() {
+		var req serveRequest
+		var resp serveResponse
+		err := json.Unmarshal(sc.Bytes(), &req)
+		if err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %v", err)
+		} else {
+			res, err := c.handle(req)
+			if err != nil {
+				resp.Error = err.Error()
+			} else {
+				resp.Result = res
+			}
+		}
+
+		err = enc.Encode(resp)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *serveCmd) handle(req serveRequest) (interface{}, error) {
+	switch req.Method {
+	case "list":
+		return c.list()
+	case "current":
+		return c.current(req.Params)
+	case "set":
+		return c.set(req.Params)
+	default:
+		return nil, fmt.Errorf("unknown method %q. Please use one of list, current or set", req.Method)
+	}
+}
+
+func (c *serveCmd) list() ([]serveKonf, error) {
+	konfs, err := fetchKonfs(c.fs)
+	if err != nil && !errors.Is(err, &EmptyStore{}) {
+		return nil, err
+	}
+
+	res := []serveKonf{}
+	for _, k := range konfs {
+		res = append(res, serveKonf{
+			ID:       k.ID(),
+			Context:  k.Context,
+			Cluster:  k.Cluster,
+			File:     k.File,
+			Favorite: k.Favorite,
+			Labels:   k.Labels,
+		})
+	}
+	return res, nil
+}
+
+func (c *serveCmd) current(p serveParams) (string, error) {
+	if p.PID == 0 {
+		return "", fmt.Errorf("please supply the pid of the shell")
+	}
+	return activeKonfIDFromFile(c.fs, utils.ActivePathForPID(p.PID))
+}
+
+// serveSet is the result of a set request. Env contains the environment variables of the konf, which the shell
+// should export next to KUBECONFIG
+type serveSet struct {
+	KubeConfig string            `json:"kubeconfig"`
+	Env        map[string]string `json:"env,omitempty"`
+}
+
+func (c *serveCmd) set(p serveParams) (*serveSet, error) {
+	if p.ID == "" {
+		return nil, fmt.Errorf("please supply the id of the konf to set")
+	}
+	if p.PID == 0 && !config.DirectStore() {
+		return nil, fmt.Errorf("please supply the pid of the shell")
+	}
+
+	// the switch behaves just like 'konf set <konfig id>' in the shell with the supplied pid. As the server does not
+	// run in a terminal, protected konfs are refused by safe mode
+	sc := newSetCommand()
+	sc.fs = c.fs
+	sc.pid = p.PID
+	sc.unattended = true
+	sc.isTerminal = func() bool { return false }
+	var res *serveSet
+	sc.handOver = func(path string, env map[string]string) error {
+		res = &serveSet{KubeConfig: path, Env: env}
+		return nil
+	}
+	err := sc.set(sc.cmd, []string{p.ID})
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, fmt.Errorf("konf %q has not been set", p.ID)
+	}
+	return res, nil
+}
+
+func init() {
+	rootCmd.AddCommand(newServeCmd().cmd)
+}
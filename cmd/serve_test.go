@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+)
+
+func TestServe(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    env:\n      AWS_PROFILE: dev\n"))
+	path := t.TempDir() + "/konf.sock"
+
+	l, err := listenSingleInstance(path)
+	if err != nil {
+		t.Fatalf("Could not listen on socket: %q", err)
+	}
+
+	sc := newServeCmd()
+	sc.fs = f
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- sc.serveListener(ctx, l) }()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Could not connect to socket: %q", err)
+	}
+	defer conn.Close()
+	resp := bufio.NewScanner(conn)
+
+	tt := []struct {
+		req     string
+		expResp string
+	}{
+		{`{"method": "list"}`, `{"result":[{"id":"dev-eu_dev-eu-1","context":"dev-eu","cluster":"dev-eu-1","file":"./konf/store/dev-eu_dev-eu-1.yaml"}]}`},
+		{`{"method": "current", "params": {"pid": 4242}}`, `{"result":""}`},
+		{`{"method": "set", "params": {"id": "dev-eu_dev-eu-1", "pid": 4242}}`, `{"result":{"kubeconfig":"./konf/active/4242.yaml","env":{"AWS_PROFILE":"dev"}}}`},
+		{`{"method": "current", "params": {"pid": 4242}}`, `{"result":"dev-eu_dev-eu-1"}`},
+		{`{"method": "set", "params": {"id": "dev-eu_dev-eu-1", "pid": 4242}}`, `{"result":{"kubeconfig":"./konf/active/4242.yaml","env":{"AWS_PROFILE":"dev"}}}`},
+		{`{"method": "set", "params": {"id": "no-konf", "pid": 4242}}`, fmt.Sprintf(`{"error":%q}`, (&KonfNotFound{ID: "no-konf"}).Error())},
+		{`{"method": "set", "params": {"id": "dev-eu_dev-eu-1"}}`, `{"error":"please supply the pid of the shell"}`},
+		{`{"method": "delete"}`, `{"error":"unknown method \"delete\". Please use one of list, current or set"}`},
+		{`not json`, `{"error":"invalid request: invalid character 'o' in literal null (expecting 'u')"}`},
+	}
+
+	for _, tc := range tt {
+		fmt.Fprintln(conn, tc.req)
+		if !resp.Scan() {
+			t.Fatalf("Exp a response to %s, got none: %v", tc.req, resp.Err())
+		}
+		if !cmp.Equal(resp.Text(), tc.expResp) {
+			t.Errorf("Exp and given responses to %s differ: \n '%s'", tc.req, cmp.Diff(tc.expResp, resp.Text()))
+		}
+	}
+
+	if _, err := f.Stat(utils.ActivePathForID("4242")); err != nil {
+		t.Errorf("Exp active konf to be written, got %q", err)
+	}
+	// setting the konf again in the same shell must not end up as a duplicate, just like with 'konf set'
+	hist, err := readHistory(f)
+	if err != nil {
+		t.Fatalf("Could not read history: %q", err)
+	}
+	if expHist := []string{"dev-eu_dev-eu-1"}; !cmp.Equal(hist, expHist) {
+		t.Errorf("Exp and given history differ: \n '%s'", cmp.Diff(expHist, hist))
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Exp clean shutdown, got %q", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Exp server to shut down, but it is still running")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Exp socket to be removed on shutdown, got %v", err)
+	}
+}
+
+func TestListenSingleInstance(t *testing.T) {
+	path := t.TempDir() + "/konf.sock"
+
+	// a socket file without a server behind it is a leftover of a crashed server
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Could not create stale socket: %q", err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	l, err = listenSingleInstance(path)
+	if err != nil {
+		t.Fatalf("Exp stale socket to be replaced, got %q", err)
+	}
+	defer l.Close()
+
+	_, err = listenSingleInstance(path)
+	expErr := fmt.Errorf("konf serve is already running on %q", path)
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp err %q, got %q", expErr, err)
+	}
+}
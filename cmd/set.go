@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	sprig "github.com/Masterminds/sprig/v3"
 	"github.com/lithammer/fuzzysearch/fuzzy"
@@ -19,6 +24,8 @@ import (
 	"github.com/simontheleg/konf-go/utils"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"k8s.io/apimachinery/pkg/runtime"
 	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
 	"sigs.k8s.io/yaml"
 )
@@ -26,13 +33,62 @@ import (
 type setCmd struct {
 	fs afero.Fs
 
+	selector     Selector
+	confirmFunc  prompt.ConfirmFunc
+	inputFunc    prompt.InputFunc
+	processAlive func(int) (bool, error)
+	healthCheck  func([]byte, time.Duration) error
+	isTerminal   func() bool
+	clipboard    func() ([]byte, error)
+	handOver     func(path string, env map[string]string) error
+
+	// pid is the shell the konf is set for
+	pid int
+	// unattended is set if konf is set on behalf of another program, e.g. via 'konf serve'. Nobody can answer a
+	// prompt then, which is why konfs are neither looked up via the picker nor confirmed
+	unattended bool
+
+	refreshCreds bool
+	oidcLogin    bool
+	checkContext bool
+	strict       bool
+	printCtx     bool
+	waitHealthy  bool
+	namespace    string
+	restoreNS    bool
+	persistNS    bool
+	discardNS    bool
+	file         string
+	fromClip     bool
+	idFile       string
+	idFD         int
+	allShells    bool
+	echoEnv      bool
+	shell        string
+	direnv       bool
+	labels       []string
+	index        int
+	preview      bool
+	noSafeMode   bool
+	record       bool
+	mergeCurrent bool
+
 	cmd *cobra.Command
 }
 
 func newSetCommand() *setCmd {
 
 	sc := &setCmd{
-		fs: afero.NewOsFs(),
+		fs:           utils.NewFs(),
+		selector:     pickerSelector{},
+		confirmFunc:  prompt.TerminalConfirm,
+		inputFunc:    prompt.TerminalInput,
+		processAlive: processAlive,
+		healthCheck:  waitHealthy,
+		isTerminal:   func() bool { return term.IsTerminal(int(os.Stderr.Fd())) },
+		clipboard:    readClipboard,
+		handOver:     handOverToShell,
+		pid:          os.Getppid(),
 	}
 
 	sc.cmd = &cobra.Command{
@@ -45,200 +101,1402 @@ func newSetCommand() *setCmd {
 		-> 'set' run konf selection
 		-> 'set <konfig id>' set a specific konf
 		-> 'set -' set to last used konf
+		-> 'set --file <path>' use a kubeconfig once without importing it
+		-> 'set --from-clipboard' use the kubeconfig in the clipboard once without importing it
+		-> 'set --id-file <path>' set the konf whose id is stored in a file
+		-> 'set <konfig id> --echo-env' print an export statement instead of relying on the shellwrapper
+		-> 'set <konfig id> --direnv' print an export statement for a direnv .envrc
+		-> 'set --label env=prod --label team=platform' set the konf with all of these labels
+		-> 'set --index 3' set the third konf as listed by 'konf ls --number'
+		-> 'set <konfig id> --wait-healthy --timeout 60s' set a konf once its cluster is ready
+		-> 'set <konfig id> -n kube-system' set a konf and start out in a specific namespace
+		-> 'set --record' pick a konf and record the switch in <konfDir>/records.jsonl
+		-> 'set <konfig id> --merge-with-current' use a konf together with the active one, e.g. for cross-cluster debugging
+		-> 'set <konfig id> --print-context' additionally print the context of the konf, e.g. to update a prompt
 	`,
 		RunE:              sc.set,
 		ValidArgsFunction: sc.completeSet,
 	}
-
-	return sc
+
+	sc.cmd.Flags().StringVarP(&sc.file, "file", "f", "", "use the kubeconfig at this path once, without importing it into the store")
+	sc.cmd.Flags().BoolVar(&sc.fromClip, "from-clipboard", false, "use the kubeconfig in the clipboard once, without importing it into the store")
+	sc.cmd.Flags().StringVar(&sc.idFile, "id-file", "", "read the konf id from this file instead of the arguments. Useful for ids that are awkward to pass through a shell")
+	sc.cmd.Flags().IntVar(&sc.idFD, "id-fd", -1, "read the konf id from this file descriptor instead of the arguments")
+	sc.cmd.Flags().BoolVar(&sc.echoEnv, "echo-env", false, "print statements that export $KUBECONFIG and the environment variables of the konf, which can be eval'd by shells without the shellwrapper")
+	sc.cmd.Flags().StringVar(&sc.shell, "shell", "sh", "shell to format the statement of --echo-env for. One of sh, bash, zsh, fish or powershell")
+	sc.cmd.Flags().BoolVar(&sc.preview, "preview", false, "print a summary of the konf to stderr before setting it. In a terminal, the switch has to be confirmed")
+	sc.cmd.Flags().BoolVar(&sc.noSafeMode, "no-safe-mode", false, "do not ask for the context name of konfs protected by safe mode. Can also be disabled via $KONF_NO_SAFE_MODE")
+	sc.cmd.Flags().BoolVar(&sc.record, "record", false, "append the resolved konf together with its server and namespace to the record file in the konf directory, e.g. to document runbooks")
+	sc.cmd.Flags().IntVar(&sc.index, "index", 0, "set the konf at this position of 'konf ls --number', starting at 1")
+	sc.cmd.Flags().StringArrayVar(&sc.labels, "label", []string{}, "select the konf by a label in the form of key=value. Can be specified multiple times, in which case all labels have to match. Multiple matches open the picker")
+	sc.cmd.Flags().BoolVar(&sc.direnv, "direnv", false, "print an export statement for direnv, which points $KUBECONFIG directly at the store. Neither an active konf nor the latest konf are written")
+	sc.cmd.Flags().BoolVar(&sc.allShells, "all-shells", false, "set the konf for every running shell, not just the current one. Other shells pick up the change on their next kubectl call")
+	sc.cmd.Flags().BoolVar(&sc.oidcLogin, "oidc-login", false, "log in to the OIDC provider of the konf after setting it, if its credentials have expired. Can also be enabled via oidcLogin in the config file")
+	sc.cmd.Flags().BoolVar(&sc.checkContext, "check-context", false, "load the active konf the same way kubectl does after setting it and make sure kubectl agrees on its context. Can also be enabled via checkContext in the config file")
+	sc.cmd.Flags().BoolVar(&sc.printCtx, "print-context", false, "print the context of the konf to stderr after setting it, so a prompt can show it right away. If $KONF_CONTEXT_FD is set, it is written to this file descriptor instead")
+	sc.cmd.Flags().BoolVar(&sc.strict, "strict", false, "fail on konfs that span multiple clusters, instead of picking one of their contexts. Can also be enabled via strictStore in the config file")
+	sc.cmd.Flags().BoolVar(&sc.waitHealthy, "wait-healthy", false, "wait until the API server of the konf is ready before switching to it. Gives up after --timeout")
+	sc.cmd.Flags().StringVarP(&sc.namespace, "namespace", "n", "", "namespace to start out with in the konf. Takes precedence over the namespace restored by --restore-namespace")
+	sc.cmd.Flags().BoolVar(&sc.restoreNS, "restore-namespace", true, "start out with the namespace that was last used with the konf via 'konf ns'")
+	sc.cmd.Flags().BoolVar(&sc.persistNS, "persist", false, "write the namespace of the konf you are switching away from into the store, if it has been changed without 'konf ns'")
+	sc.cmd.Flags().BoolVar(&sc.discardNS, "discard", false, "drop the namespace of the konf you are switching away from without a warning, if it has been changed without 'konf ns'")
+	sc.cmd.Flags().BoolVar(&sc.mergeCurrent, "merge-with-current", false, "combine the konf with the konf that is currently active in this shell, so the contexts of both are available. The new konf becomes the current context")
+	sc.cmd.Flags().BoolVar(&sc.refreshCreds, "refresh-credentials", false, "run the exec credential plugin of the konf once after setting it, so expired credentials are refreshed right away")
+
+	return sc
+}
+
+func (c *setCmd) set(cmd *cobra.Command, args []string) error {
+	// TODO if I stay with the mocking approach used in commands like
+	// namespace. This part should be refactored to allow for mocking
+	// the downstream funcs in order to test the if-else logic
+	var id string
+	var err error
+	// 'set -' restores the latest konf just as it has last been used
+	var fromLatest bool
+
+	if c.echoEnv {
+		if _, err := exportStatement(c.shell, ""); err != nil {
+			return err
+		}
+	}
+
+	if c.direnv {
+		return c.setDirenv(args)
+	}
+
+	if c.allShells && config.DirectStore() {
+		return fmt.Errorf("--all-shells cannot be used with --direct-store, because shells do not have their own copy of a konf")
+	}
+
+	// merging requires a copy of the konf for this shell, which is the only one that ever contains multiple contexts
+	if c.mergeCurrent && (c.file != "" || c.fromClip || c.allShells || config.DirectStore()) {
+		return fmt.Errorf("--merge-with-current cannot be combined with --file, --from-clipboard, --all-shells or --direct-store")
+	}
+
+	if c.fromClip {
+		if len(args) != 0 || c.file != "" {
+			return fmt.Errorf("--from-clipboard cannot be combined with a konf id or --file")
+		}
+		return c.setClipboard()
+	}
+
+	if c.file != "" {
+		if len(args) != 0 {
+			return fmt.Errorf("please either supply a konf id or --file, but not both")
+		}
+		return c.setFile()
+	}
+
+	if c.idFile != "" || c.idFD >= 0 {
+		if len(args) != 0 || (c.idFile != "" && c.idFD >= 0) {
+			return fmt.Errorf("please supply the konf id only once, either as argument, via --id-file or via --id-fd")
+		}
+		args, err = c.readIDArg()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.index != 0 {
+		if len(args) != 0 || len(c.labels) > 0 {
+			return fmt.Errorf("--index cannot be combined with a konf id or --label")
+		}
+		id, err = selectByIndex(c.fs, c.index)
+		if err != nil {
+			return err
+		}
+	} else if len(c.labels) > 0 {
+		if len(args) != 0 {
+			return fmt.Errorf("please either supply a konf id or --label, but not both")
+		}
+		id, err = selectByLabels(c.fs, c.labels, c.selector)
+		if err != nil {
+			return err
+		}
+	} else if len(args) == 0 {
+		id, err = selectContext(c.fs, c.selector)
+		if err != nil {
+			return err
+		}
+	} else if args[0] == "-" {
+		id, err = selectLastKonf(c.fs)
+		if err != nil {
+			return err
+		}
+		fromLatest = true
+	} else {
+		id, err = resolveAlias(c.fs, args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	// a broken active file should not prevent the user from switching away from it
+	prevID, _ := activeKonfIDFromFile(c.fs, utils.ActivePathForPID(c.pid))
+
+	// the active konf is replaced by the switch, which is why it has to be read beforehand
+	var current []byte
+	if c.mergeCurrent {
+		current, err = afero.ReadFile(c.fs, utils.ActivePathForPID(c.pid))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("there is no active konf in this shell, which --merge-with-current could be combined with")
+			}
+			return err
+		}
+	}
+
+	if c.persistNS && c.discardNS {
+		return fmt.Errorf("please use either --persist or --discard, but not both")
+	}
+
+	ok, err := c.confirmKonf(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Info("Not setting konf %q\n", id)
+		return nil
+	}
+	// in direct store mode there is no active konf that could differ from the store
+	if prevID != "" && !c.discardNS && !config.DirectStore() {
+		c.keepNamespace(prevID)
+	}
+
+	var konf []byte
+	if fromLatest {
+		konf, err = restoreLatestState(c.fs, id)
+		if err != nil {
+			return err
+		}
+	}
+	restored := konf != nil
+	if !restored {
+		konf, err = konfToSet(c.fs, id)
+	}
+	if errors.Is(err, fs.ErrNotExist) && !c.unattended {
+		// the user has most likely just mistyped the id, so we try to help them out
+		name := id
+		id, err = searchFallback(c.fs, id, c.selector, c.confirmFunc)
+		if err != nil {
+			return err
+		}
+		ok, err = c.confirmKonf(id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Info("Not setting konf %q\n", id)
+			return nil
+		}
+		konf, err = konfToSet(c.fs, id)
+		if err == nil {
+			konf, err = useContext(konf, id, name)
+		}
+	}
+	// konfs spanning multiple clusters are usually kept on purpose, so the user gets to pick which of their contexts to
+	// use. In direct store mode kubectl would always see the whole file though
+	if errors.Is(err, &KubeConfigOverload{}) && !c.strict && !config.StrictStore() && !config.DirectStore() {
+		konf, err = c.pickContextOf(id)
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.waitHealthy {
+		// neither the shell nor the latest konf are switched over before the cluster is ready, so subsequent
+		// commands do not run into a control plane that is still starting up
+		log.Info("Waiting for the cluster of konf %q to become ready\n", id)
+		err = c.healthCheck(konf, config.Timeout())
+		if err != nil {
+			return err
+		}
+	}
+
+	context, err := writeKonf(c.fs, id, konf, c.pid)
+	if err != nil {
+		return err
+	}
+	// re-setting the konf that is already active should not end up as a duplicate in the history
+	if id != prevID {
+		err = saveLatestKonf(c.fs, id, context)
+		if err != nil {
+			return fmt.Errorf("could not save latest konf. As a result 'konf set -' might not work: %q ", err)
+		}
+	}
+
+	// a restored state already contains the namespace the konf has last been used with
+	if !restored || c.namespace != "" {
+		err = c.applyNamespace(id, context)
+		if err != nil {
+			return err
+		}
+	}
+
+	if restored {
+		log.Info("Restored the state of konf %q from its last use\n", id)
+	}
+
+	if c.mergeCurrent {
+		err = mergeWithCurrent(c.fs, context, current)
+		if err != nil {
+			return err
+		}
+		log.Info("Merged konf %q with the previously active konf\n", id)
+	}
+
+	if c.checkContext || config.CheckContext() {
+		b, err := afero.ReadFile(c.fs, context)
+		if err != nil {
+			return err
+		}
+		err = checkKubectlContext(b)
+		if err != nil {
+			return fmt.Errorf("konf %q has been set, but kubectl does not agree with it: %v", id, err)
+		}
+	}
+
+	if c.record {
+		rec, err := newSwitchRecord(c.fs, id, context, clock.Now())
+		if err != nil {
+			return err
+		}
+		err = appendRecord(c.fs, rec)
+		if err != nil {
+			return err
+		}
+	}
+	log.Info("Setting context to %q\n", id)
+	c.printNote(id)
+
+	if c.allShells {
+		n, err := setAllShells(c.fs, id, c.processAlive)
+		if err != nil {
+			return err
+		}
+		log.Info("Updated %d shells to konf %q\n", n, id)
+	}
+
+	if c.refreshCreds {
+		b, err := afero.ReadFile(c.fs, context)
+		if err != nil {
+			return err
+		}
+		// a failed refresh should not prevent the switch, as the user might still want to fix the credentials afterwards
+		used, err := refreshCredentials(b, config.Timeout())
+		if err != nil {
+			log.Warn("could not refresh credentials of konf %q: %v\n", id, err)
+		} else if !used {
+			log.Info("konf %q does not use an exec credential plugin. Nothing to refresh\n", id)
+		}
+	}
+
+	if c.oidcLogin || config.OIDCLogin() {
+		b, err := afero.ReadFile(c.fs, context)
+		if err != nil {
+			return err
+		}
+		// just like a failed refresh, a failed login should not prevent the switch
+		_, err = oidcLogin(b, config.Timeout())
+		if err != nil {
+			log.Warn("could not log in to the OIDC provider of konf %q: %v\n", id, err)
+		}
+	}
+
+	if prevID != "" && prevID != id && !c.unattended {
+		printPreviousKonf(c.fs, prevID)
+	}
+
+	return c.printChange(context, konfEnv(c.fs, id))
+}
+
+// confirmKonf runs all checks the user might have to confirm before the konf with the supplied id is set. It is the
+// single gate for every switch to a konf of the store, which is why commands like toggle, tui or serve switch via set
+func (c *setCmd) confirmKonf(id string) (bool, error) {
+	ok, err := c.previewKonf(id)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return c.confirmSafeMode(id)
+}
+
+// keepNamespace makes sure the namespace of the konf with the supplied id is not lost silently, as the switch
+// overwrites the active konf of the shell. Namespaces set via 'konf ns' are restored anyway, whereas other
+// changes, e.g. via 'kubectl config set-context', are either persisted or result in a warning
+func (c *setCmd) keepNamespace(id string) {
+	ctx, unsaved, err := unsavedNamespace(c.fs, utils.ActivePathForPID(c.pid), id)
+	if err != nil {
+		// the namespace is only a convenience, so the switch should not be prevented
+		log.Warn("could not check the namespace of konf %q for unsaved changes: %v\n", id, err)
+		return
+	}
+	if !unsaved {
+		return
+	}
+	ns := ctx.Context.Namespace
+
+	if !c.persistNS {
+		log.Warn("The namespace %q of konf %q has not been saved and is lost by this switch. Use --persist to write it into the store or --discard to drop it silently\n", ns, id)
+		return
+	}
+	err = persistNamespaceForID(c.fs, id, ctx.Name, ns)
+	if err != nil {
+		log.Warn("could not persist the namespace %q of konf %q: %v\n", ns, id, err)
+		return
+	}
+	log.Info("Persisted namespace %q of konf %q into the store\n", ns, id)
+}
+
+// unsavedNamespace returns the context of the active konf at path, whose namespace kubectl uses, and whether its
+// namespace would be lost by a switch. This is the case if it differs from the namespace of the same context in the
+// store as well as the one recorded by 'konf ns'
+func unsavedNamespace(f afero.Fs, path, id string) (*k8s.NamedContext, bool, error) {
+	b, err := afero.ReadFile(f, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	var active k8s.Config
+	err = yaml.Unmarshal(b, &active)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx := namespaceContext(&active)
+	if ctx == nil {
+		return nil, false, nil
+	}
+	ns := ctx.Context.Namespace
+
+	b, err = readStoreFile(f, utils.ResolveStorePath(f, id))
+	if err != nil {
+		// kubeconfigs that have been set via --file or deleted from the store cannot be saved anyway
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	var stored k8s.Config
+	err = yaml.Unmarshal(b, &stored)
+	if err != nil {
+		return nil, false, err
+	}
+	if sctx := storedContext(&stored, ctx.Name); sctx != nil && sctx.Context.Namespace == ns {
+		return ctx, false, nil
+	}
+
+	m, err := loadMetadata(f)
+	if err != nil {
+		return nil, false, err
+	}
+	if km, ok := m.Konfs[id]; ok && km.Namespace == ns {
+		return ctx, false, nil
+	}
+	return ctx, true, nil
+}
+
+// printNote prints the note of the konf with the supplied id to stderr. As notes are meant to prevent mistakes,
+// they are printed even if --silent is set
+func (c *setCmd) printNote(id string) {
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		log.Warn("could not read the note of konf %q: %v\n", id, err)
+		return
+	}
+	if km, ok := m.Konfs[id]; ok && km.Note != "" {
+		fmt.Fprintf(c.cmd.ErrOrStderr(), "Note on konf %q: %s\n", id, km.Note)
+	}
+}
+
+// applyNamespace writes the namespace supplied via --namespace or the namespace that was last used with the
+// konf into the kubeconfig at path
+func (c *setCmd) applyNamespace(id, path string) error {
+	ns := c.namespace
+	if ns == "" && c.restoreNS {
+		// the namespace is only a convenience, so broken metadata should not prevent the switch
+		m, err := loadMetadata(c.fs)
+		if err != nil {
+			log.Warn("could not restore the last namespace of konf %q: %v\n", id, err)
+			return nil
+		}
+		if km, ok := m.Konfs[id]; ok {
+			ns = km.Namespace
+		}
+	}
+	if ns == "" {
+		return nil
+	}
+
+	// in direct store mode, changing the namespace would change the konf in the store
+	if utils.IsAnyStorePath(path) {
+		if c.namespace != "" {
+			return fmt.Errorf("cannot set the namespace, because $KUBECONFIG points directly at the store")
+		}
+		return nil
+	}
+	return writeNamespace(c.fs, path, ns)
+}
+
+// printChange hands the path of the new kubeconfig and the environment variables of the konf over to the
+// shellwrapper or prints them as export statements
+// if --echo-env is set
+func (c *setCmd) printChange(path string, env map[string]string) error {
+	if c.printCtx {
+		err := c.printContext(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !c.echoEnv {
+		return c.handOver(path, env)
+	}
+
+	stmt, err := exportStatement(c.shell, path)
+	if err != nil {
+		return err
+	}
+	envStmts, err := envStatements(c.shell, env)
+	if err != nil {
+		return err
+	}
+	for _, s := range append([]string{stmt}, envStmts...) {
+		_, err = fmt.Fprintln(c.cmd.OutOrStdout(), s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printContext prints the context of the kubeconfig at path for prompts. As stdout is reserved for KUBECONFIGCHANGE,
+// it is written to stderr, unless a separate file descriptor is supplied via $KONF_CONTEXT_FD
+func (c *setCmd) printContext(path string) error {
+	b, err := afero.ReadFile(c.fs, path)
+	if err != nil {
+		return err
+	}
+	var conf k8s.Config
+	err = yaml.Unmarshal(b, &conf)
+	if err != nil {
+		return err
+	}
+
+	w := c.cmd.ErrOrStderr()
+	if fd := os.Getenv("KONF_CONTEXT_FD"); fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return fmt.Errorf("could not print the context, because $KONF_CONTEXT_FD %q is not a file descriptor", fd)
+		}
+		f := os.NewFile(uintptr(n), "konf-context")
+		defer f.Close()
+		w = f
+	}
+	_, err = fmt.Fprintln(w, displayContext(conf.CurrentContext))
+	return err
+}
+
+// exportStatement returns a statement that sets $KUBECONFIG to path in the supplied shell. The path is quoted,
+// so the statement can be eval'd as is
+func exportStatement(shell, path string) (string, error) {
+	return exportVarStatement(shell, "KUBECONFIG", path)
+}
+
+// exportVarStatement returns a statement that sets the environment variable name to value in the supplied shell
+func exportVarStatement(shell, name, value string) (string, error) {
+	switch shell {
+	case "sh", "bash", "zsh":
+		return "export " + name + "='" + strings.ReplaceAll(value, "'", `'\''`) + "'", nil
+	case "fish":
+		value = strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value)
+		return "set -gx " + name + " '" + value + "'", nil
+	case "powershell", "pwsh":
+		return "$env:" + name + " = '" + strings.ReplaceAll(value, "'", "''") + "'", nil
+	default:
+		return "", fmt.Errorf("unsupported shell %q. Please use one of sh, bash, zsh, fish or powershell", shell)
+	}
+}
+
+// setDirenv prints the export statement for a konf in a direnv .envrc. direnv evaluates the .envrc in a
+// short-lived process and applies the result to every shell entering the directory. As there is no shell pid
+// an active konf could belong to, $KUBECONFIG points directly at the store, just like with --direct-store
+func (c *setCmd) setDirenv(args []string) error {
+	if len(args) != 1 || args[0] == "-" {
+		return fmt.Errorf("--direnv requires a konf id, as direnv cannot run the picker")
+	}
+	id := args[0]
+
+	err := ensureNotFetched(c.fs, id, "--direnv")
+	if err != nil {
+		return err
+	}
+	_, err = readValidKonf(c.fs, id)
+	if err != nil {
+		return err
+	}
+	path := utils.ResolveStorePath(c.fs, id)
+	enc, err := storeFileEncrypted(c.fs, path)
+	if err != nil {
+		return err
+	}
+	if enc {
+		return fmt.Errorf("konf %q is encrypted, which is why --direnv cannot point kubectl at it", id)
+	}
+
+	stmt, err := exportStatement("bash", path)
+	if err != nil {
+		return err
+	}
+	// direnv unsets the environment variables by itself, once the directory is left
+	envStmts, err := envStatements("bash", konfEnv(c.fs, id))
+	if err != nil {
+		return err
+	}
+	for _, s := range append([]string{stmt}, envStmts...) {
+		_, err = fmt.Fprintln(c.cmd.OutOrStdout(), s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readIDArg reads the konf id from --id-file or --id-fd and returns it in the same form as it would have been passed
+// as an argument
+func (c *setCmd) readIDArg() ([]string, error) {
+	var r io.Reader
+	if c.idFile != "" {
+		f, err := c.fs.Open(c.idFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		f := os.NewFile(uintptr(c.idFD), "konf-id")
+		if f == nil {
+			return nil, fmt.Errorf("%d is not a valid file descriptor", c.idFD)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	id, err := readID(r)
+	if err != nil {
+		return nil, err
+	}
+	return []string{id}, nil
+}
+
+// readID reads a konf id from r. Surrounding whitespace, like the trailing newline of a file, is ignored
+func readID(r io.Reader) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(b))
+	if id == "" {
+		return "", fmt.Errorf("could not read a konf id, because the input is empty")
+	}
+	return id, nil
+}
+
+// setFile sets a kubeconfig that is not part of the store. If it contains multiple contexts, the user can pick one.
+// Neither the store nor the latest konf are touched
+func (c *setCmd) setFile() error {
+	konfs, err := determineConfigs(c.fs, c.file, true)
+	if err != nil {
+		return err
+	}
+	return c.setEphemeral(konfs, c.file, fmt.Sprintf("file %q", c.file))
+}
+
+// setClipboard sets the kubeconfig in the clipboard just like setFile
+func (c *setCmd) setClipboard() error {
+	b, err := c.clipboard()
+	if err != nil {
+		return err
+	}
+	konfs, err := splitClipboard(b, true)
+	if err != nil {
+		return err
+	}
+	return c.setEphemeral(konfs, "clipboard", "the clipboard")
+}
+
+// setEphemeral writes one of konfs into the active konf of the shell. The origin is shown in the picker, whereas
+// source describes where the konfs came from in messages
+func (c *setCmd) setEphemeral(konfs []*konfFile, origin, source string) error {
+	if len(konfs) == 0 {
+		return fmt.Errorf("no contexts found in %s", source)
+	}
+
+	konf, err := c.pickKonfFile(konfs, origin)
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(konf.Content)
+	if err != nil {
+		return err
+	}
+	context, err := writeActiveKonfForShell(c.fs, b, c.pid)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Setting context to %q from %s\n", konf.Content.CurrentContext, source)
+
+	return c.printChange(context, nil)
+}
+
+// pickContextOf lets the user pick one of the contexts of the konf with the supplied id, which spans multiple clusters.
+// Only the picked context ends up in the returned konf
+func (c *setCmd) pickContextOf(id string) ([]byte, error) {
+	path := utils.ResolveStorePath(c.fs, id)
+	b, err := readStoreFile(c.fs, path)
+	if err != nil {
+		return nil, err
+	}
+	konfs, err := splitConfigs(b, true)
+	if err != nil {
+		return nil, fmt.Errorf("konf %q is not a valid kubeconfig: %v", id, err)
+	}
+	konf, err := c.pickKonfFile(konfs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Konf %q spans multiple clusters. Only setting its context %q\n", id, konf.Content.CurrentContext)
+	// without the id, the active konf could not be traced back to its konf in the store anymore
+	err = withStoreID(&konf.Content, id)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(konf.Content)
+}
+
+// pickKonfFile lets the user pick one of konfs, unless there is only a single one. The origin is shown as file of
+// every konf in the picker
+func (c *setCmd) pickKonfFile(konfs []*konfFile, origin string) (*konfFile, error) {
+	if len(konfs) == 1 {
+		return konfs[0], nil
+	}
+
+	options := []tableOutput{}
+	byID := map[string]*konfFile{}
+	for _, k := range konfs {
+		t := tableOutput{Context: k.Content.Contexts[0].Name, Cluster: k.Content.Contexts[0].Context.Cluster, File: origin}
+		options = append(options, t)
+		byID[utils.IDFromClusterAndContext(t.Cluster, t.Context)] = k
+	}
+	id, err := selectKonf(options, c.selector)
+	if err != nil {
+		return nil, err
+	}
+	return byID[id], nil
+}
+
+// printPreviousKonf hands the store path of the konf that was active before a switch over to the shellwrapper,
+// so it can offer an undo. As stdout is reserved for KUBECONFIGCHANGE, the shellwrapper supplies a temporary file
+// via $KONF_PREVIOUS_FILE. If it is not set, nothing is printed
+func printPreviousKonf(f afero.Fs, id string) {
+	path := os.Getenv("KONF_PREVIOUS_FILE")
+	if path == "" {
+		return
+	}
+	// the file has been created by the shellwrapper. konf never creates it on its own, so a stale variable cannot
+	// make it write to arbitrary locations
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		log.Warn("could not hand over the previous konf: %v\n", err)
+		return
+	}
+	defer out.Close()
+
+	err = writePreviousKonf(out, f, id)
+	if err != nil {
+		log.Warn("could not hand over the previous konf: %v\n", err)
+	}
+}
+
+func writePreviousKonf(w io.Writer, f afero.Fs, id string) error {
+	_, err := fmt.Fprintln(w, utils.ResolveStorePath(f, id))
+	return err
+}
+
+// handOverToShell hands the path of the new kubeconfig and the environment variables of the konf over to the
+// shellwrapper
+func handOverToShell(path string, env map[string]string) error {
+	printKonfChange(path)
+	printKonfEnv(env)
+	return nil
+}
+
+// printKonfChange hands the path of the new kubeconfig over to the shellwrapper
+func printKonfChange(path string) {
+	// konf always takes precedence over a $KUBECONFIG that has been set before. The shellwrapper preserves such a value
+	// in $KONF_ORIGINAL_KUBECONFIG, so we only need to make the user aware of it
+	if kc := os.Getenv("KUBECONFIG"); kc != "" && !utils.IsActivePath(kc) && !utils.IsAnyStorePath(kc) {
+		log.Warn("$KUBECONFIG was set to %q, which is not managed by konf. konf is taking over $KUBECONFIG for this shell. The previous value is preserved in $KONF_ORIGINAL_KUBECONFIG\n", kc)
+	}
+
+	// By printing out to stdout, we pass the value to our zsh hook, which then sets $KUBECONFIG to it
+	// Both operate on the convention to use "KUBECONFIGCHANGE:<new-path>". If you change this part in
+	// here, do not forget to update shellwraper.go
+	fmt.Println("KUBECONFIGCHANGE:" + path)
+}
+
+func (c *setCmd) completeSet(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	konfs, err := fetchKonfs(c.fs)
+	if err != nil {
+		// if the store is just empty, return no suggestions, instead of throwing an error
+		if errors.Is(err, &EmptyStore{}) {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	// the active konf is only a reminder, so failing to determine it should not break the completion
+	active, err := activeKonfID(c.fs)
+	if err != nil {
+		cobra.CompDebugln(err.Error(), true)
+	}
+
+	// konfs are filtered just like in the picker, so long lists can be narrowed down with any part of a konf.
+	// Shells that filter by prefix on their own might still drop some of the fuzzy matches
+	sug := []string{}
+	for i, konf := range konfs {
+		if toComplete != "" && !searchKonf(toComplete, &konfs[i]) {
+			continue
+		}
+		// with the current design of 'set', we need to return the ID here in the autocomplete as the first part of the completion
+		// as it is directly passed to set
+		id := utils.IDFromClusterAndContext(konf.Cluster, konf.Context)
+		if id == active {
+			// everything after the tab is shown as description by shells that support it
+			id += "\t(active)"
+		}
+		sug = append(sug, id)
+	}
+
+	// aliases are only a convenience, so broken metadata should not break the completion
+	if m, err := loadMetadata(c.fs); err == nil {
+		aliases := []string{}
+		for alias := range m.Aliases {
+			if toComplete != "" && !fuzzy.Match(toComplete, alias) {
+				continue
+			}
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+		sug = append(sug, aliases...)
+	}
+
+	return sug, cobra.ShellCompDirectiveNoFileComp
+}
+
+type promptFunc func(*promptui.Select) (int, error)
+
+func selectContext(f afero.Fs, s Selector) (string, error) {
+	k, err := fetchKonfs(f)
+	if err != nil {
+		return "", err
+	}
+
+	return selectKonf(k, s)
+}
+
+// selectKonf runs the picker on the supplied konfs and returns the id of the selection
+func selectKonf(k []tableOutput, s Selector) (string, error) {
+	selPos, err := s.Select(k)
+	if err != nil {
+		return "", err
+	}
+
+	if selPos >= len(k) {
+		return "", fmt.Errorf("invalid selection %d", selPos)
+	}
+	sel := k[selPos]
+
+	return utils.IDFromClusterAndContext(sel.Cluster, sel.Context), nil
+}
+
+// searchFallback is used when an id could not be found in the store. Most users remember the name of a context
+// rather than the id of its konf, which is why the id is first matched against the context and then the cluster
+// names of all konfs. Otherwise it runs searchKonf against all konfs and either suggests the single close match or
+// lets the user pick from all close matches
+func searchFallback(f afero.Fs, id string, s Selector, cf prompt.ConfirmFunc) (string, error) {
+	notFound := fmt.Errorf("%w. Run 'konf set' to pick from all available konfs", &KonfNotFound{ID: id})
+
+	k, err := fetchKonfs(f)
+	if err != nil {
+		if errors.Is(err, &EmptyStore{}) {
+			return "", notFound
+		}
+		return "", err
+	}
+
+	named := konfsNamed(k, id)
+	switch len(named) {
+	case 0:
+	case 1:
+		return named[0].ID(), nil
+	default:
+		log.Info("There are multiple konfs named %q. Please pick one\n", id)
+		return selectKonf(named, s)
+	}
+
+	matches := []tableOutput{}
+	for i := range k {
+		if searchKonf(id, &k[i]) {
+			matches = append(matches, k[i])
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", notFound
+	case 1:
+		sug := utils.IDFromClusterAndContext(matches[0].Cluster, matches[0].Context)
+		p := &promptui.Prompt{
+			Label:     fmt.Sprintf("Could not find konf %q. Did you mean %q", id, sug),
+			IsConfirm: true,
+			Stdout:    os.Stderr,
+		}
+		ok, err := cf(p)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", notFound
+		}
+		return sug, nil
+	default:
+		log.Info("Could not find konf %q. Please pick from the closest matches\n", id)
+		return selectKonf(matches, s)
+	}
+}
+
+// konfsNamed returns all konfs with a context called name. Besides the context that identifies a konf, this includes
+// all other contexts of konfs split by cluster. If there are none, it returns all konfs whose cluster is called name
+// instead
+func konfsNamed(konfs []tableOutput, name string) []tableOutput {
+	byContext, byCluster := []tableOutput{}, []tableOutput{}
+	for _, k := range konfs {
+		if k.hasContext(name) {
+			byContext = append(byContext, k)
+		} else if k.Cluster == name {
+			byCluster = append(byCluster, k)
+		}
+	}
+	if len(byContext) > 0 {
+		return byContext
+	}
+	return byCluster
+}
+
+// useContext makes the context called name the current-context of konf, if konf contains such a context besides its
+// current one. This is the case if the user picked a konf split by cluster by the name of one of its other contexts
+func useContext(konf []byte, id, name string) ([]byte, error) {
+	var conf k8s.Config
+	err := yaml.Unmarshal(konf, &conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf.CurrentContext == name {
+		return konf, nil
+	}
+	for _, c := range conf.Contexts {
+		if c.Name == name {
+			conf.CurrentContext = name
+			log.Info("Using context %q of konf %q\n", name, id)
+			return yaml.Marshal(conf)
+		}
+	}
+	return konf, nil
+}
+
+// selectByIndex returns the id of the konf at the 1-based position index in the order of fetchKonfs
+func selectByIndex(f afero.Fs, index int) (string, error) {
+	konfs, err := fetchKonfs(f)
+	if err != nil {
+		return "", err
+	}
+	if index < 1 || index > len(konfs) {
+		return "", fmt.Errorf("index %d is out of range. Please supply an index between 1 and %d, as listed by 'konf ls --number'", index, len(konfs))
+	}
+	return konfs[index-1].ID(), nil
+}
+
+func selectLastKonf(f afero.Fs) (string, error) {
+	if config.Stateless() {
+		return "", fmt.Errorf("could not select latest konf, because the history is disabled in stateless mode")
+	}
+	b, err := afero.ReadFile(f, config.LatestKonfFile())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("could not select latest konf, because no konf was yet set")
+		}
+		return "", err
+	}
+	return string(b), nil
 }
 
-func (c *setCmd) set(cmd *cobra.Command, args []string) error {
-	// TODO if I stay with the mocking approach used in commands like
-	// namespace. This part should be refactored to allow for mocking
-	// the downstream funcs in order to test the if-else logic
-	var id string
-	var err error
+func setContext(id string, f afero.Fs) (string, error) {
+	konf, err := konfToSet(f, id)
+	if err != nil {
+		return "", err
+	}
+	return writeKonf(f, id, konf, os.Getppid())
+}
 
-	if len(args) == 0 {
-		id, err = selectContext(c.fs, prompt.Terminal)
+// konfToSet returns the content of the konf with the supplied id, as it is going to be set
+func konfToSet(f afero.Fs, id string) ([]byte, error) {
+	defer logTiming(fmt.Sprintf("reading konf %q", id), time.Now())
+	if config.DirectStore() {
+		err := ensureNotFetched(f, id, "--direct-store")
 		if err != nil {
-			return err
+			return nil, err
 		}
-	} else if args[0] == "-" {
-		id, err = selectLastKonf(c.fs)
+	}
+	return readValidKonf(f, id)
+}
+
+// writeKonf makes konf the kubeconfig of the shell with the supplied pid and returns its path. In direct store mode
+// nothing is written, as the shell uses the konf with the supplied id in the store instead
+func writeKonf(f afero.Fs, id string, konf []byte, pid int) (string, error) {
+	if config.DirectStore() {
+		path := utils.ResolveStorePath(f, id)
+		enc, err := storeFileEncrypted(f, path)
 		if err != nil {
-			return err
+			return "", err
 		}
-	} else {
-		id = args[0]
+		if enc {
+			return "", fmt.Errorf("konf %q is encrypted, which is why --direct-store cannot point kubectl at it", id)
+		}
+		return path, nil
+	}
+	// the active konf always stays in plaintext, as kubectl has to be able to read it
+	return writeActiveKonfForShell(f, konf, pid)
+}
+
+// ownStorePath returns the path of the konf with the supplied id, if the konf can be changed. Konfs from shared
+// stores are only ever read, which is why a SharedKonf is returned for them instead
+func ownStorePath(f afero.Fs, id string) (string, error) {
+	path := utils.ResolveStorePath(f, id)
+	if !utils.IsStorePath(path) {
+		return "", &SharedKonf{ID: id, Path: path}
 	}
+	return path, nil
+}
 
-	context, err := setContext(id, c.fs)
+// readValidKonf returns the content of the konf with the supplied id, if it is a usable kubeconfig
+func readValidKonf(f afero.Fs, id string) ([]byte, error) {
+	path := utils.ResolveStorePath(f, id)
+	konf, err := readStoreFile(f, path)
 	if err != nil {
-		return err
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, &KonfNotFound{ID: id}
+		}
+		return nil, err
 	}
-	err = saveLatestKonf(c.fs, id)
+
+	// a broken store file would leave the shell with a broken kubeconfig, so we rather stop here
+	var conf k8s.Config
+	err = yaml.Unmarshal(konf, &conf)
 	if err != nil {
-		return fmt.Errorf("could not save latest konf. As a result 'konf set -' might not work: %q ", err)
+		return nil, fmt.Errorf("konf %q is not a valid kubeconfig: %v", id, err)
+	}
+	if len(conf.Contexts) == 0 {
+		return nil, fmt.Errorf("konf %q does not contain a context. Please re-import it", id)
+	}
+	// the file might have been edited since it was imported. Writing it whole would leave the shell with
+	// multiple clusters, which konf cannot tell apart later on
+	if overloaded(&conf) {
+		return nil, &KubeConfigOverload{Path: path}
 	}
 
-	log.Info("Setting context to %q\n", id)
+	command, err := fetchCommandFor(f, id)
+	if err != nil {
+		return nil, err
+	}
+	if command != "" {
+		return fetchKonf(id, command)
+	}
 
-	// By printing out to stdout, we pass the value to our zsh hook, which then sets $KUBECONFIG to it
-	// Both operate on the convention to use "KUBECONFIGCHANGE:<new-path>". If you change this part in
-	// here, do not forget to update shellwraper.go
-	fmt.Println("KUBECONFIGCHANGE:" + context)
+	// leftover users of a partial split would otherwise leak their credentials into every shell using this konf
+	if trimUnused(&conf) {
+		log.Warn("Konf %q contains users or clusters that none of its contexts reference. These are left out of the active konf. Run 'konf store compact' to remove them from the store\n", id)
+		return yaml.Marshal(conf)
+	}
 
-	return nil
+	return konf, nil
 }
 
-func (c *setCmd) completeSet(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	konfs, err := fetchKonfs(c.fs)
-	if err != nil {
-		// if the store is just empty, return no suggestions, instead of throwing an error
-		if _, ok := err.(*EmptyStore); ok {
-			return []string{}, cobra.ShellCompDirectiveNoFileComp
-		}
-
-		cobra.CompDebugln(err.Error(), true)
-		return nil, cobra.ShellCompDirectiveError
+// trimUnused removes all users and clusters from conf, which are not referenced by any of its contexts
+// It reports whether anything has been removed
+func trimUnused(conf *k8s.Config) bool {
+	users := map[string]bool{}
+	clusters := map[string]bool{}
+	for _, c := range conf.Contexts {
+		users[c.Context.AuthInfo] = true
+		clusters[c.Context.Cluster] = true
 	}
 
-	sug := []string{}
-	for _, konf := range konfs {
-		// with the current design of 'set', we need to return the ID here in the autocomplete as the first part of the completion
-		// as it is directly passed to set
-		sug = append(sug, utils.IDFromClusterAndContext(konf.Cluster, konf.Context))
+	authInfos := []k8s.NamedAuthInfo{}
+	for _, u := range conf.AuthInfos {
+		if users[u.Name] {
+			authInfos = append(authInfos, u)
+		}
+	}
+	namedClusters := []k8s.NamedCluster{}
+	for _, cl := range conf.Clusters {
+		if clusters[cl.Name] {
+			namedClusters = append(namedClusters, cl)
+		}
 	}
 
-	return sug, cobra.ShellCompDirectiveNoFileComp
+	trimmed := len(authInfos) != len(conf.AuthInfos) || len(namedClusters) != len(conf.Clusters)
+	if trimmed {
+		conf.AuthInfos = authInfos
+		conf.Clusters = namedClusters
+	}
+	return trimmed
 }
 
-type promptFunc func(*promptui.Select) (int, error)
+// setAllShells sets the konf with the supplied id for all running shells, that have an active konf
+// It returns the number of shells that have been updated
+func setAllShells(f afero.Fs, id string, alive func(int) (bool, error)) (int, error) {
+	konf, err := readValidKonf(f, id)
+	if err != nil {
+		return 0, err
+	}
 
-func selectContext(f afero.Fs, pf promptFunc) (string, error) {
-	k, err := fetchKonfs(f)
+	pids, err := liveShells(f, alive)
 	if err != nil {
-		return "", err
+		return 0, err
+	}
+	for _, pid := range pids {
+		err = afero.WriteFile(f, utils.ActivePathForPID(pid), konf, utils.KonfPerm)
+		if err != nil {
+			return 0, err
+		}
 	}
-	p := createPrompt(k)
-	selPos, err := pf(p)
+
+	return len(pids), nil
+}
+
+// writeActiveKonf writes konf as the active konf of the shell konf is called from and returns its path
+func writeActiveKonf(f afero.Fs, konf []byte) (string, error) {
+	return writeActiveKonfForShell(f, konf, os.Getppid())
+}
+
+// writeActiveKonfForShell writes konf as the active konf of the shell with the supplied pid
+func writeActiveKonfForShell(f afero.Fs, konf []byte, pid int) (string, error) {
+	activeKonf := utils.ActivePathForPID(pid)
+	err := afero.WriteFile(f, activeKonf, konf, utils.KonfPerm)
 	if err != nil {
 		return "", err
 	}
 
-	if selPos >= len(k) {
-		return "", fmt.Errorf("invalid selection %d", selPos)
-	}
-	sel := k[selPos]
+	return activeKonf, nil
+}
 
-	return utils.IDFromClusterAndContext(sel.Cluster, sel.Context), nil
+// activeKonfID returns the id of the konf that is currently active in the shell konf is called from
+// If no konf is active, an empty string is returned
+func activeKonfID(f afero.Fs) (string, error) {
+	return activeKonfIDFromFile(f, utils.ActivePathForPID(os.Getppid()))
 }
 
-func selectLastKonf(f afero.Fs) (string, error) {
-	b, err := afero.ReadFile(f, config.LatestKonfFile())
+// activeKonfIDFromFile returns the id of the konf stored in the active file at path
+// If the file does not exist, an empty string is returned
+func activeKonfIDFromFile(f afero.Fs, path string) (string, error) {
+	b, err := afero.ReadFile(f, path)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
-			return "", fmt.Errorf("could not select latest konf, because no konf was yet set")
+			return "", nil
 		}
 		return "", err
 	}
-	return string(b), nil
-}
 
-func setContext(id string, f afero.Fs) (string, error) {
-	konf, err := afero.ReadFile(f, utils.StorePathForID(id))
+	var conf k8s.Config
+	err = yaml.Unmarshal(b, &conf)
 	if err != nil {
 		return "", err
 	}
 
-	ppid := os.Getppid()
-	activeKonf := utils.ActivePathForID(fmt.Sprint(ppid))
-	err = afero.WriteFile(f, activeKonf, konf, utils.KonfPerm)
+	return activeIDFromKonf(&conf)
+}
+
+// overloaded reports whether a kubeconfig references more than a single cluster, which konf cannot handle.
+// Multiple contexts are fine as long as they share the cluster, which is what 'konf import --split-by cluster' creates
+func overloaded(conf *k8s.Config) bool {
+	if len(conf.Clusters) > 1 {
+		return true
+	}
+	clusters := map[string]bool{}
+	for _, c := range conf.Contexts {
+		clusters[c.Context.Cluster] = true
+	}
+	return len(clusters) > 1
+}
+
+// idFromKonf determines the id of a single context kubeconfig
+// storeIDExtension names the extension of active konfs, that only contain one of the contexts of a konf spanning
+// multiple clusters. It records the id of the konf in the store, as it cannot be derived from the picked context
+const storeIDExtension = "konf-store-id"
+
+// withStoreID records id as the konf conf has been picked from
+func withStoreID(conf *k8s.Config, id string) error {
+	raw, err := json.Marshal(id)
 	if err != nil {
-		return "", err
+		return err
 	}
+	conf.Extensions = append(conf.Extensions, k8s.NamedExtension{Name: storeIDExtension, Extension: runtime.RawExtension{Raw: raw}})
+	return nil
+}
 
-	return activeKonf, nil
+// activeIDFromKonf returns the id of the konf in the store, that the active konf conf has been set from
+func activeIDFromKonf(conf *k8s.Config) (string, error) {
+	for _, ext := range conf.Extensions {
+		var id string
+		if ext.Name == storeIDExtension && json.Unmarshal(ext.Extension.Raw, &id) == nil && id != "" {
+			return id, nil
+		}
+	}
+	return idFromKonf(conf)
+}
 
+func idFromKonf(conf *k8s.Config) (string, error) {
+	if len(conf.Contexts) == 0 {
+		return "", fmt.Errorf("could not determine konf id as contexts[] is empty in kubeconfig")
+	}
+	return utils.IDFromClusterAndContext(conf.Contexts[0].Context.Cluster, conf.Contexts[0].Name), nil
 }
 
-func saveLatestKonf(f afero.Fs, id string) error {
+// saveLatestKonf records id as the latest konf, which has been written to path. If id is the latest konf already
+// and has been written to the same path, or konf runs in stateless mode, nothing is written
+func saveLatestKonf(f afero.Fs, id, path string) error {
+	if config.Stateless() {
+		return nil
+	}
+
+	// all shells share the latest konf and the history, so concurrent switches must not overwrite each others entries.
+	// Both are only read while holding the lock, as another shell could otherwise switch in between
+	unlock, err := lockLatestKonf(f)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cur, err := afero.ReadFile(f, config.LatestKonfFile())
+	sameID := err == nil && string(cur) == id
+	if sameID {
+		last, err := lastHistoryRecord(f)
+		if err == nil && last.Path == path {
+			return nil
+		}
+	}
+
+	// a konf that is set again in another shell replaces its own record, so it does not end up as a duplicate
+	if sameID {
+		err = replaceLastHistoryPath(f, path)
+	} else {
+		err = pushHistory(f, id, path)
+	}
+	if err != nil {
+		return err
+	}
+	// the state of the previous latest konf cannot be restored by 'konf set -' anymore
+	err = f.Remove(config.LatestStateFile())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
 	return afero.WriteFile(f, config.LatestKonfFile(), []byte(id), utils.KonfPerm)
 }
 
-// KubeConfigOverload describes a state in which a kubeconfig has multiple Contexts or Clusters
-// This can be undesirable for konf when such a kubeconfig is in its store
-type KubeConfigOverload struct {
-	path string
+// historyMaxLength describes how many konfs are kept in the history
+const historyMaxLength = 100
+
+// lockLatestKonf acquires the lock that guards the latest konf and the history, which are shared by all shells
+func lockLatestKonf(f afero.Fs) (func() error, error) {
+	return utils.Lock(f, config.LatestKonfFile()+".lock", config.Timeout())
 }
 
-func (k *KubeConfigOverload) Error() string {
-	return fmt.Sprintf("Impure Store: The kubeconfig %q contains multiple contexts and/or clusters. Please only use 'konf import' for populating the store\n", k.path)
+// historyRecord describes a single switch. Path refers to the active konf the switch has written, which allows
+// 'konf set -' to restore the state of the konf as it has been used in that shell
+type historyRecord struct {
+	ID   string `json:"id"`
+	Path string `json:"path,omitempty"`
 }
 
-// EmptyStore describes a state in which no kubeconfig is inside the store
-// It makes sense to have this in a separate case as it does not matter for some operations (e.g. importing) but detrimental for others (e.g. running the selection prompt)
-type EmptyStore struct{}
+// pushHistory adds id on top of the history stack. It must only be called while holding the lock of the latest konf
+func pushHistory(f afero.Fs, id, path string) error {
+	hist, err := readHistoryRecords(f)
+	if err != nil {
+		return err
+	}
+
+	hist = append(hist, historyRecord{ID: id, Path: path})
+	if len(hist) > historyMaxLength {
+		hist = hist[len(hist)-historyMaxLength:]
+	}
+	return writeHistoryRecords(f, hist)
+}
 
-func (k *EmptyStore) Error() string {
-	return fmt.Sprintf("The konf store at %q is empty. Please run 'konf import' to populate it", config.StoreDir())
+// replaceLastHistoryPath points the most recent record of the history to path. It must only be called while
+// holding the lock of the latest konf
+func replaceLastHistoryPath(f afero.Fs, path string) error {
+	hist, err := readHistoryRecords(f)
+	if err != nil {
+		return err
+	}
+	if len(hist) == 0 {
+		return nil
+	}
+	hist[len(hist)-1].Path = path
+	return writeHistoryRecords(f, hist)
 }
 
-// fetchKonfs returns a list of all konfs currently in konfDir/store. Additionally it returns metadata on these konfs for easier usage of the information
-func fetchKonfs(f afero.Fs) ([]tableOutput, error) {
-	var konfs []fs.FileInfo
+// writeHistoryRecords writes each record as a line of JSON, as context names and paths might contain any character
+func writeHistoryRecords(f afero.Fs, hist []historyRecord) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, rec := range hist {
+		err := enc.Encode(rec)
+		if err != nil {
+			return err
+		}
+	}
+	return afero.WriteFile(f, config.HistoryFile(), b.Bytes(), utils.KonfPerm)
+}
 
-	err := afero.Walk(f, config.StoreDir(), func(path string, info fs.FileInfo, err error) error {
-		// do not add directories. This is important as later we check the number of items in konf to determine whether store is empty or not
-		// without this check we would display an empty prompt if the user has only directories in their storeDir
-		if info.IsDir() && path != config.StoreDir() {
-			return filepath.SkipDir
+// readHistoryRecords returns all records of the history, with the most recent one being last. Histories written
+// by previous versions of konf are read as well. Back then a line consisted of the id, optionally followed by a tab
+// and the path
+func readHistoryRecords(f afero.Fs) ([]historyRecord, error) {
+	b, err := afero.ReadFile(f, config.HistoryFile())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []historyRecord{}, nil
 		}
+		return nil, err
+	}
 
-		// skip any hidden files
-		if strings.HasPrefix(info.Name(), ".") {
-			// I have decided to not print any log line on this, which differs from the logic
-			// for malformed kubeconfigs. I think this makes sense as konf import will never produce
-			// a hidden file and the purpose of this check is rather to protect against
-			// automatically created files like the .DS_Store on MacOs. On the other side however
-			// it is quite easy to create a malformed kubeconfig without noticing
-			return nil
+	hist := []historyRecord{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var rec historyRecord
+		if strings.HasPrefix(line, "{") {
+			err = json.Unmarshal([]byte(line), &rec)
+			if err != nil {
+				return nil, fmt.Errorf("could not read history %q: %v", config.HistoryFile(), err)
+			}
+		} else {
+			parts := strings.SplitN(line, "\t", 2)
+			rec.ID = strings.TrimSpace(parts[0])
+			if len(parts) == 2 {
+				rec.Path = parts[1]
+			}
 		}
+		hist = append(hist, rec)
+	}
+	return hist, nil
+}
 
-		konfs = append(konfs, info)
-		return nil
-	})
+// lastHistoryRecord returns the most recent record of the history. It is empty if there is no history yet
+func lastHistoryRecord(f afero.Fs) (historyRecord, error) {
+	hist, err := readHistoryRecords(f)
+	if err != nil || len(hist) == 0 {
+		return historyRecord{}, err
+	}
+	return hist[len(hist)-1], nil
+}
+
+// readHistory returns the ids of all previously set konfs, with the most recent one being last
+func readHistory(f afero.Fs) ([]string, error) {
+	hist, err := readHistoryRecords(f)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, rec := range hist {
+		ids = append(ids, rec.ID)
+	}
+	return ids, nil
+}
+
+// reads of store files are retried, as networked stores might fail temporarily
+const (
+	readAttempts       = 3
+	readBackoffInitial = 50 * time.Millisecond
+)
 
+// fetchKonfs returns a list of all konfs currently in konfDir/store. Additionally it returns metadata on these konfs for easier usage of the information
+func fetchKonfs(f afero.Fs) ([]tableOutput, error) {
+	// walking a file would simply treat the store as empty, which hides what is actually wrong
+	err := utils.CheckDir(f, config.StoreDir())
 	if err != nil {
 		return nil, err
 	}
 
-	// cut out the root element, which gets added in the previous step
-	// this is safe as the element is guaranteed to be at the first position
-	konfs = konfs[1:]
+	start := time.Now()
+	// paths maps the id of each konf to its file in the first store that contains it
+	paths := map[string]string{}
+	var konfs []fs.FileInfo
+	for i, dir := range config.StoreDirs() {
+		files, err := storeFiles(f, dir)
+		if err != nil {
+			// a shared store might for example be on a network drive that is currently unavailable, which should
+			// not hide the konfs of all other stores
+			if i > 0 {
+				log.Warn("could not read shared store %q. Skipping it: %v\n", dir, err)
+				continue
+			}
+			return nil, err
+		}
+		for _, file := range files {
+			id := utils.IDFromFileInfo(file)
+			if _, ok := paths[id]; ok {
+				continue
+			}
+			paths[id] = utils.StorePathForIDInDir(dir, id)
+			konfs = append(konfs, file)
+		}
+	}
 
 	// similar to fs.ReadDir, sort the entries for easier viewing for the user and to
 	// be consistent with what shells return during auto-completion
 	sort.Slice(konfs, func(i, j int) bool { return konfs[i].Name() < konfs[j].Name() })
 
+	logTiming(fmt.Sprintf("walking the store (%d files)", len(konfs)), start)
+
 	if len(konfs) == 0 {
 		return nil, &EmptyStore{}
 	}
 
+	start = time.Now()
 	out := []tableOutput{}
 	// TODO the logic of this loop should be extracted into the walkFn above to avoid looping twice
 	// TODO (possibly the walkfunction should also be extracted into its own function)
 	for _, konf := range konfs {
 
-		id := utils.IDFromFileInfo(konf)
-		path := utils.StorePathForID(id)
-		file, err := f.Open(path)
+		path := paths[utils.IDFromFileInfo(konf)]
+		raw, err := readFileWithRetry(f, path)
 		if err != nil {
-			return nil, err
+			// a single unreadable file should not hide all other konfs
+			log.Warn("could not read file %q. Skipping for evaluation: %v\n", path, err)
+			continue
 		}
-		val, err := afero.ReadAll(file)
+		val, err := decodeStoreFile(path, raw)
 		if err != nil {
 			return nil, err
 		}
@@ -249,24 +1507,132 @@ func fetchKonfs(f afero.Fs) ([]tableOutput, error) {
 			continue
 		}
 
-		if len(kubeconf.Contexts) > 1 || len(kubeconf.Clusters) > 1 {
+		if overloaded(kubeconf) {
 			// This directly returns, as an impure store is a danger for other usage down the road
-			return nil, &KubeConfigOverload{path}
+			return nil, &KubeConfigOverload{Path: path}
 		}
 
 		t := tableOutput{}
 		t.Context = kubeconf.Contexts[0].Name
 		t.Cluster = kubeconf.Clusters[0].Name
 		t.File = path
+		for _, c := range kubeconf.Contexts[1:] {
+			t.OtherContexts = append(t.OtherContexts, c.Name)
+		}
+		if d := displayContext(t.Context); d != t.Context {
+			t.DisplayContext = d
+		}
 		out = append(out, t)
 	}
+
+	logTiming(fmt.Sprintf("parsing %d konfs", len(konfs)), start)
+
+	defer logTiming("loading metadata", time.Now())
+	// metadata only adds convenience, so it should never prevent the user from seeing their konfs
+	m, err := loadMetadata(f)
+	if err != nil {
+		log.Warn("could not load konf metadata. Favorites will not be shown: %v\n", err)
+		return out, nil
+	}
+	verified := false
+	for _, km := range m.Konfs {
+		verified = verified || km.Verified != nil
+	}
+	now := clock.Now()
+	for i := range out {
+		km, ok := m.Konfs[utils.IDFromClusterAndContext(out[i].Cluster, out[i].Context)]
+		if ok {
+			out[i].Favorite = km.Favorite
+			out[i].Labels = km.Labels
+			out[i].Note = km.Note
+		}
+		// without any verify results we keep the status column out of the way entirely
+		if verified {
+			if !ok {
+				km = &konfMetadata{}
+			}
+			out[i].Status = konfStatus(km.Verified, now)
+		}
+	}
+	// float favorites to the top, while keeping the alphabetical order within both groups
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Favorite && !out[j].Favorite })
+
 	return out, nil
 }
 
-func createPrompt(options []tableOutput) *promptui.Select {
-	// TODO use ssh/terminal to get the terminalsize and set trunc accordingly https://stackoverflow.com/questions/16569433/get-terminal-size-in-go
-	trunc := 25
-	promptInactive, promptActive, label := prepareTable(trunc)
+// storeFiles returns all files of the store at dir, which might contain a konf
+func storeFiles(f afero.Fs, dir string) ([]fs.FileInfo, error) {
+	var files []fs.FileInfo
+	err := afero.Walk(f, dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// do not add directories. This is important as later we check the number of items in konf to determine whether store is empty or not
+		// without this check we would display an empty prompt if the user has only directories in their storeDir
+		if info.IsDir() {
+			if path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// skip any hidden files
+		if strings.HasPrefix(info.Name(), ".") {
+			// I have decided to not print any log line on this, which differs from the logic
+			// for malformed kubeconfigs. I think this makes sense as konf import will never produce
+			// a hidden file and the purpose of this check is rather to protect against
+			// automatically created files like the .DS_Store on MacOs. On the other side however
+			// it is quite easy to create a malformed kubeconfig without noticing
+			return nil
+		}
+
+		files = append(files, info)
+		return nil
+	})
+	return files, err
+}
+
+// readFileWithRetry reads the file at path. Reads are retried with backoff, as networked stores might fail
+// temporarily. A missing file is not retried
+func readFileWithRetry(f afero.Fs, path string) ([]byte, error) {
+	backoff := readBackoffInitial
+	for attempt := 1; ; attempt++ {
+		b, err := afero.ReadFile(f, path)
+		if err == nil || errors.Is(err, fs.ErrNotExist) || attempt == readAttempts {
+			return b, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// konfStatus returns the glyph for the result of the last verify. Results older than verifyMaxAge are not
+// trustworthy anymore and are therefore shown as unknown, just like konfs that have never been verified
+func konfStatus(res *verifyResult, now time.Time) string {
+	if res == nil || now.Sub(res.At) > verifyMaxAge {
+		return statusUnknown
+	}
+	if res.Healthy {
+		return statusHealthy
+	}
+	return statusUnhealthy
+}
+
+// createPrompt creates the konf picker, where trunc is the maximum width of each column
+func createPrompt(options []tableOutput, trunc int) *promptui.Select {
+	defer logTiming("building the picker", time.Now())
+	status := false
+	for _, o := range options {
+		status = status || o.Status != ""
+	}
+	promptInactive, promptActive, label := prepareTable(trunc, status)
+	for _, o := range options {
+		if o.Header {
+			promptInactive = `{{ if .Header }}{{ .Cluster | bold }}{{ else }}` + promptInactive + `{{ end }}`
+			promptActive = `{{ if .Header }}{{ .Cluster | bold | cyan }}{{ else }}` + promptActive + `{{ end }}`
+			break
+		}
+	}
 
 	// Wrapper is required as we need access to options, but the methodSignature from promptUI
 	// requires you to only pass an index not the whole func
@@ -281,21 +1647,69 @@ func createPrompt(options []tableOutput) *promptui.Select {
 		Templates: &promptui.SelectTemplates{
 			Active:   promptActive,
 			Inactive: promptInactive,
+			Details:  `{{ if .Note }}{{ "Note:" | faint }} {{ .Note }}{{ end }}`,
 			FuncMap:  newTemplateFuncMap(),
 		},
 		HideSelected: true,
 		Stdout:       os.Stderr,
 		Searcher:     wrapSearchKonf,
-		Size:         15,
+		Size:         promptSize(len(options), terminalHeight()),
 	}
 	return &prompt
 }
 
+const (
+	// defaultPromptSize is the number of konfs the picker shows at once, if the height of the terminal is unknown
+	defaultPromptSize = 15
+	// promptOverhead is the number of lines the picker needs besides the konfs, like the label, the help and the
+	// details of the selected konf
+	promptOverhead = 5
+)
+
+// promptSize returns how many of items konfs the picker should show at once. The picker uses the available height
+// of the terminal, but never renders more rows than there are konfs. If height is unknown, it is 0 or less
+func promptSize(items, height int) int {
+	size := defaultPromptSize
+	if height > 0 {
+		size = height - promptOverhead
+	}
+	if size > items {
+		size = items
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// terminalHeight returns the height of the terminal the picker is rendered to, or 0 if it cannot be determined
+func terminalHeight() int {
+	_, height, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil {
+		return 0
+	}
+	return height
+}
+
 func searchKonf(searchTerm string, curItem *tableOutput) bool {
 	// since there is no weight on any of the table entries, we can just combine them to one string
 	// and run the contains on it, which automatically is going to match any of the three values
 	r := fmt.Sprintf("%s %s %s", curItem.Context, curItem.Cluster, curItem.File)
-	return fuzzy.Match(searchTerm, r)
+	if curItem.DisplayContext != "" {
+		r += " " + curItem.DisplayContext
+	}
+	// searching should be forgiving, so "prod" also finds "PROD" and "cafe" finds "café"
+	return fuzzy.MatchNormalizedFold(searchTerm, r)
+}
+
+// displayContext applies the configured display transform to a context name. If no transform is configured,
+// the name is returned unchanged
+func displayContext(context string) string {
+	re, repl := config.ContextDisplayTransform()
+	if re == nil {
+		return context
+	}
+	return re.ReplaceAllString(context, repl)
 }
 
 // TODO only inject the funcs I am actually using
@@ -327,22 +1741,67 @@ func newTemplateFuncMap() template.FuncMap {
 // tableOutput describes a formatting of kubekonf information, that is being used to present the user a nice table selection
 type tableOutput struct {
 	// Since we have no other use for structured information, we can safely leave this in set.go for now
-	Context string
-	Cluster string
-	File    string
+	Context  string
+	Cluster  string
+	File     string
+	Favorite bool
+	Labels   map[string]string
+	// Note is the note attached via 'konf note'
+	Note string
+	// Status is the glyph for the result of the last 'konf verify'. It is empty if no konf has been verified yet
+	Status string
+	// DisplayContext is the context after applying the configured display transform. It is only set if the transform
+	// changes the context and must never be used to determine the id of a konf
+	DisplayContext string
+	// Header marks a row of the picker, which only names the cluster of the konfs below it and cannot be selected
+	Header bool
+	// OtherContexts are the names of all contexts but the first one, which konfs split by cluster contain
+	OtherContexts []string
+}
+
+// hasContext reports whether the konf contains a context called name
+func (t tableOutput) hasContext(name string) bool {
+	if t.Context == name {
+		return true
+	}
+	for _, c := range t.OtherContexts {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ID returns the id of the konf
+func (t tableOutput) ID() string {
+	return utils.IDFromClusterAndContext(t.Cluster, t.Context)
+}
+
+// Display returns the context as it should be shown to the user
+func (t tableOutput) Display() string {
+	if t.DisplayContext != "" {
+		return t.DisplayContext
+	}
+	return t.Context
 }
 
 // prepareTable takes in the max length of each column and returns table rows for active, inactive and header
-func prepareTable(maxColumnLen int) (inactive, active, label string) {
+// If status is set, the rows are prefixed with the status glyph of each konf
+func prepareTable(maxColumnLen int, status bool) (inactive, active, label string) {
 	// minColumnLen is determined by the length of the largest word in the label line
 	minColumnLen := 7
 	if maxColumnLen < minColumnLen {
 		maxColumnLen = minColumnLen
 	}
+	statusCol, statusLabel := "", ""
+	if status {
+		statusCol = fmt.Sprintf(`{{ if eq .Status %q }}{{ .Status | green }}{{ else if eq .Status %q }}{{ .Status | red }}{{ else }}{{ .Status | faint }}{{ end }} `, statusHealthy, statusUnhealthy)
+		statusLabel = "  "
+	}
 	// TODO figure out if we can do abbreviation using '...' somehow
-	inactive = fmt.Sprintf(`  {{ repeat %[1]d " " | print .Context | trunc %[1]d | %[2]s }} | {{ repeat %[1]d " " | print .Cluster | trunc %[1]d | %[2]s }} | {{ repeat %[1]d  " " | print .File | trunc %[1]d | %[2]s }} |`, maxColumnLen, "")
-	active = fmt.Sprintf(`▸ {{ repeat %[1]d " " | print .Context | trunc %[1]d | %[2]s }} | {{ repeat %[1]d " " | print .Cluster | trunc %[1]d | %[2]s }} | {{ repeat %[1]d  " " | print .File | trunc %[1]d | %[2]s }} |`, maxColumnLen, "bold | cyan")
-	label = fmt.Sprint("  Context" + strings.Repeat(" ", maxColumnLen-7) + " | " + "Cluster" + strings.Repeat(" ", maxColumnLen-7) + " | " + "File" + strings.Repeat(" ", maxColumnLen-4) + " ") // repeat = trunc - length of the word before it
+	inactive = fmt.Sprintf(`{{ if .Favorite }}★ {{ else }}  {{ end }}%[3]s{{ repeat %[1]d " " | print .Display | trunc %[1]d | %[2]s }} | {{ repeat %[1]d " " | print .Cluster | trunc %[1]d | %[2]s }} | {{ repeat %[1]d  " " | print .File | trunc %[1]d | %[2]s }} |`, maxColumnLen, "", statusCol)
+	active = fmt.Sprintf(`▸ %[3]s{{ repeat %[1]d " " | print .Display | trunc %[1]d | %[2]s }} | {{ repeat %[1]d " " | print .Cluster | trunc %[1]d | %[2]s }} | {{ repeat %[1]d  " " | print .File | trunc %[1]d | %[2]s }} |`, maxColumnLen, "bold | cyan", statusCol)
+	label = fmt.Sprint("  " + statusLabel + "Context" + strings.Repeat(" ", maxColumnLen-7) + " | " + "Cluster" + strings.Repeat(" ", maxColumnLen-7) + " | " + "File" + strings.Repeat(" ", maxColumnLen-4) + " ") // repeat = trunc - length of the word before it
 	return inactive, active, label
 }
 
@@ -4,19 +4,26 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/manifoldco/promptui"
 	"github.com/simontheleg/konf-go/config"
+	log "github.com/simontheleg/konf-go/log"
 	"github.com/simontheleg/konf-go/testhelper"
 	"github.com/simontheleg/konf-go/utils"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
 )
 
 func TestSelectLastKonf(t *testing.T) {
@@ -61,19 +68,40 @@ func TestCompleteSet(t *testing.T) {
 
 	tt := map[string]struct {
 		fs           afero.Fs
+		toComplete   string
 		expComp      []string
 		expCompDirec cobra.ShellCompDirective
 	}{
 		"normal results": {
 			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextASIA, fm.SingleClusterSingleContextEU),
+			"",
 			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
 			cobra.ShellCompDirectiveNoFileComp,
 		},
+		"fuzzy match": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextASIA, fm.SingleClusterSingleContextEU, metadataFile("aliases:\n  europe: dev-eu_dev-eu-1\n  asia: dev-asia_dev-asia-1\n")),
+			"eu",
+			[]string{"dev-eu_dev-eu-1", "europe"},
+			cobra.ShellCompDirectiveNoFileComp,
+		},
+		"no fuzzy match": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextASIA, fm.SingleClusterSingleContextEU),
+			"prod",
+			[]string{},
+			cobra.ShellCompDirectiveNoFileComp,
+		},
 		"no results": {
 			testhelper.FSWithFiles(fm.StoreDir),
+			"dev",
 			[]string{},
 			cobra.ShellCompDirectiveNoFileComp,
 		},
+		"active konf": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextASIA, fm.SingleClusterSingleContextEU, activeEU),
+			"",
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1\t(active)"},
+			cobra.ShellCompDirectiveNoFileComp,
+		},
 	}
 
 	for name, tc := range tt {
@@ -81,7 +109,7 @@ func TestCompleteSet(t *testing.T) {
 			scmd := newSetCommand()
 			scmd.fs = tc.fs
 
-			res, compdirec := scmd.completeSet(scmd.cmd, []string{}, "")
+			res, compdirec := scmd.completeSet(scmd.cmd, []string{}, tc.toComplete)
 
 			if !cmp.Equal(res, tc.expComp) {
 				t.Errorf("Exp and given comps differ: \n '%s'", cmp.Diff(tc.expComp, res))
@@ -99,7 +127,7 @@ func TestSaveLatestKonf(t *testing.T) {
 	expID := "context_cluster"
 
 	f := afero.NewMemMapFs()
-	err := saveLatestKonf(f, expID)
+	err := saveLatestKonf(f, expID, "")
 	if err != nil {
 		t.Errorf("Could not save last konf: %q", err)
 	}
@@ -116,6 +144,89 @@ func TestSaveLatestKonf(t *testing.T) {
 	}
 }
 
+func TestSaveLatestKonfDedupe(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	// every write of the latest konf pushes a record onto the history, which does not exist yet
+	f := testhelper.FSWithFiles(fm.LatestKonf)
+	err := saveLatestKonf(f, "context_cluster", "")
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if _, err := f.Stat(config.HistoryFile()); err == nil {
+		t.Errorf("Exp no write for an unchanged latest konf, but the history has been written")
+	}
+
+	err = saveLatestKonf(f, "other_cluster", "")
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if _, err := f.Stat(config.HistoryFile()); err != nil {
+		t.Errorf("Exp a write for a changed latest konf, but nothing was written: %q", err)
+	}
+}
+
+func TestSaveLatestKonfConcurrent(t *testing.T) {
+	// MemMapFs cannot create files exclusively, which is why a real filesystem is used here
+	dir := t.TempDir()
+	testhelper.OverrideConfig(t, func(c *config.Config) { c.KonfDir = dir })
+	f := afero.NewOsFs()
+
+	n := 20
+	expIDs := []string{}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("context-%02d_cluster", i)
+		expIDs = append(expIDs, id)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := saveLatestKonf(f, id, "")
+			if err != nil {
+				t.Errorf("Exp no error, got %q", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	hist, err := readHistory(f)
+	if err != nil {
+		t.Fatalf("Could not read history: %q", err)
+	}
+	latest, err := afero.ReadFile(f, config.LatestKonfFile())
+	if err != nil {
+		t.Fatalf("Could not read latest konf: %q", err)
+	}
+	if len(hist) != n {
+		t.Fatalf("Exp all %d switches in the history, got %d: %q", n, len(hist), hist)
+	}
+	if hist[n-1] != string(latest) {
+		t.Errorf("Exp latest konf %q to be on top of the history, got %q", latest, hist[n-1])
+	}
+	sort.Strings(hist)
+	if !cmp.Equal(hist, expIDs) {
+		t.Errorf("Exp and given history differ: \n '%s'", cmp.Diff(expIDs, hist))
+	}
+	if _, err := f.Stat(config.LatestKonfFile() + ".lock"); err == nil {
+		t.Errorf("Exp lock to be released")
+	}
+}
+
+func TestPushHistoryMaxLength(t *testing.T) {
+	f := afero.NewMemMapFs()
+	for i := 0; i < historyMaxLength+5; i++ {
+		err := pushHistory(f, fmt.Sprint(i), "")
+		if err != nil {
+			t.Fatalf("Exp no error, got %q", err)
+		}
+	}
+
+	hist, _ := readHistory(f)
+	if len(hist) != historyMaxLength || hist[0] != "5" {
+		t.Errorf("Exp history to keep the %d most recent entries, got %d starting with %q", historyMaxLength, len(hist), hist[0])
+	}
+}
+
 func TestSetContext(t *testing.T) {
 	storeDir := config.StoreDir()
 	ppid := os.Getppid()
@@ -126,18 +237,42 @@ func TestSetContext(t *testing.T) {
 		StoreExists bool
 		ExpErr      error
 		ExpKonfPath string
+		InContent   string
 	}{
 		"normal write": {
 			"dev-eu_dev-eu",
 			true,
 			nil,
 			utils.ActivePathForID(fmt.Sprint(ppid)),
+			sm.SingleClusterSingleContextEU(),
 		},
 		"invalid id": {
 			"i-am-invalid",
 			false,
 			fs.ErrNotExist,
 			"",
+			"",
+		},
+		"zero byte store file": {
+			"dev-eu_dev-eu",
+			true,
+			fmt.Errorf("konf \"dev-eu_dev-eu\" does not contain a context. Please re-import it"),
+			"",
+			"",
+		},
+		"invalid store file": {
+			"dev-eu_dev-eu",
+			true,
+			fmt.Errorf("konf \"dev-eu_dev-eu\" is not a valid kubeconfig: error unmarshaling JSON: while decoding JSON: json: cannot unmarshal string into Go value of type v1.Config"),
+			"",
+			"I am no valid yaml",
+		},
+		"store file with multiple contexts": {
+			"dev-eu_dev-eu",
+			true,
+			&KubeConfigOverload{Path: utils.StorePathForID("dev-eu_dev-eu")},
+			"",
+			sm.MultiClusterMultiContext(),
 		},
 	}
 
@@ -147,14 +282,19 @@ func TestSetContext(t *testing.T) {
 			f := afero.NewMemMapFs()
 
 			if tc.StoreExists {
-				afero.WriteFile(f, storeDir+"/"+tc.InID+".yaml", []byte(sm.SingleClusterSingleContextEU()), utils.KonfPerm)
+				afero.WriteFile(f, storeDir+"/"+tc.InID+".yaml", []byte(tc.InContent), utils.KonfPerm)
 			}
 
 			resKonfPath, resError := setContext(tc.InID, f)
 
-			if !errors.Is(resError, tc.ExpErr) {
+			if !errors.Is(resError, tc.ExpErr) && !testhelper.EqualError(resError, tc.ExpErr) {
 				t.Errorf("Want error '%s', got '%s'", tc.ExpErr, resError)
 			}
+			if tc.ExpKonfPath == "" {
+				if _, err := f.Stat(utils.ActivePathForID(fmt.Sprint(ppid))); err == nil {
+					t.Errorf("Exp no active file to be written, but it was")
+				}
+			}
 
 			if resKonfPath != tc.ExpKonfPath {
 				t.Errorf("Want konfPath '%s', got '%s'", tc.ExpKonfPath, resKonfPath)
@@ -182,6 +322,41 @@ func TestSetContext(t *testing.T) {
 	}
 }
 
+func TestActiveKonfID(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		expID  string
+		expErr error
+	}{
+		"active konf": {
+			testhelper.FSWithFiles(fm.ActiveDir, activeEU),
+			"dev-eu_dev-eu-1",
+			nil,
+		},
+		"no active konf": {
+			testhelper.FSWithFiles(fm.ActiveDir),
+			"",
+			nil,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			id, err := activeKonfID(tc.fs)
+
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			if id != tc.expID {
+				t.Errorf("Exp id %q, got %q", tc.expID, id)
+			}
+		})
+	}
+}
+
 func TestPrepareTemplates(t *testing.T) {
 	tt := map[string]struct {
 		Values      tableOutput
@@ -192,9 +367,9 @@ func TestPrepareTemplates(t *testing.T) {
 	}{
 		"values < trunc": {
 			tableOutput{
-				"kind-eu",
-				"cluster-eu",
-				"kind-eu.cluster-eu.yaml",
+				Context: "kind-eu",
+				Cluster: "cluster-eu",
+				File:    "kind-eu.cluster-eu.yaml",
 			},
 			25,
 			"  kind-eu                   | cluster-eu                | kind-eu.cluster-eu.yaml   |",
@@ -203,9 +378,9 @@ func TestPrepareTemplates(t *testing.T) {
 		},
 		"values == trunc": {
 			tableOutput{
-				"0123456789",
-				"0123456789",
-				"xyz.yaml",
+				Context: "0123456789",
+				Cluster: "0123456789",
+				File:    "xyz.yaml",
 			},
 			10,
 			"  0123456789 | 0123456789 | xyz.yaml   |",
@@ -214,20 +389,44 @@ func TestPrepareTemplates(t *testing.T) {
 		},
 		"values > trunc": {
 			tableOutput{
-				"0123456789-andlotsmore",
-				"0123456789-andlotsmore",
-				"xyz.yaml",
+				Context: "0123456789-andlotsmore",
+				Cluster: "0123456789-andlotsmore",
+				File:    "xyz.yaml",
 			},
 			10,
 			"  0123456789 | 0123456789 | xyz.yaml   |",
 			"▸ 0123456789 | 0123456789 | xyz.yaml   |",
 			"  Context    | Cluster    | File       ",
 		},
+		"favorite": {
+			tableOutput{
+				Context:  "0123456789",
+				Cluster:  "0123456789",
+				File:     "xyz.yaml",
+				Favorite: true,
+			},
+			10,
+			"★ 0123456789 | 0123456789 | xyz.yaml   |",
+			"▸ 0123456789 | 0123456789 | xyz.yaml   |",
+			"  Context    | Cluster    | File       ",
+		},
+		"display context": {
+			tableOutput{
+				Context:        "arn:aws:eks:eu-central-1:123456789:cluster/prod",
+				Cluster:        "0123456789",
+				File:           "xyz.yaml",
+				DisplayContext: "prod",
+			},
+			10,
+			"  prod       | 0123456789 | xyz.yaml   |",
+			"▸ prod       | 0123456789 | xyz.yaml   |",
+			"  Context    | Cluster    | File       ",
+		},
 		"trunc is below minLength": {
 			tableOutput{
-				"0123456789",
-				"0123456789",
-				"xyz.yaml",
+				Context: "0123456789",
+				Cluster: "0123456789",
+				File:    "xyz.yaml",
 			},
 			5,
 			"  0123456 | 0123456 | xyz.yam |",
@@ -238,7 +437,7 @@ func TestPrepareTemplates(t *testing.T) {
 
 	for name, tc := range tt {
 		t.Run(name, func(t *testing.T) {
-			inactive, active, label := prepareTable(tc.Trunc)
+			inactive, active, label := prepareTable(tc.Trunc, false)
 
 			checkTemplate(t, inactive, tc.Values, tc.ExpInactive)
 			checkTemplate(t, active, tc.Values, tc.ExpActive)
@@ -247,6 +446,58 @@ func TestPrepareTemplates(t *testing.T) {
 	}
 }
 
+func TestPrepareTableStatus(t *testing.T) {
+	val := tableOutput{Context: "0123456789", Cluster: "0123456789", File: "xyz.yaml", Status: statusUnhealthy}
+	// checkTemplate strips the reset code, but keeps the color
+	red := func(s string) string { return "\x1b[31m" + s }
+
+	inactive, active, label := prepareTable(10, true)
+
+	checkTemplate(t, inactive, val, "  "+red(statusUnhealthy)+" 0123456789 | 0123456789 | xyz.yaml   |")
+	checkTemplate(t, active, val, "▸ "+red(statusUnhealthy)+" 0123456789 | 0123456789 | xyz.yaml   |")
+	checkTemplate(t, label, val, "    Context    | Cluster    | File       ")
+}
+
+func TestCreatePromptTrunc(t *testing.T) {
+	options := []tableOutput{{Context: "kind-eu", Cluster: "cluster-eu", File: "kind-eu.cluster-eu.yaml"}}
+
+	for _, trunc := range []int{3, 7, 25, 40} {
+		t.Run(fmt.Sprint(trunc), func(t *testing.T) {
+			p := createPrompt(options, trunc)
+			expInactive, expActive, expLabel := prepareTable(trunc, false)
+
+			if p.Label != expLabel {
+				t.Errorf("Exp label %q, got %q", expLabel, p.Label)
+			}
+			if p.Templates.Inactive != expInactive {
+				t.Errorf("Exp inactive template %q, got %q", expInactive, p.Templates.Inactive)
+			}
+			if p.Templates.Active != expActive {
+				t.Errorf("Exp active template %q, got %q", expActive, p.Templates.Active)
+			}
+		})
+	}
+}
+
+func TestSelectContextUsesConfiguredTrunc(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU)
+
+	testhelper.OverrideConfig(t, func(c *config.Config) { c.ColumnsMaxWidth = 40 })
+
+	_, _, expLabel := prepareTable(40, false)
+	var resLabel interface{}
+	pf := promptFunc(func(p *promptui.Select) (int, error) { resLabel = p.Label; return 0, nil })
+
+	_, err := selectContext(f, pf)
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if resLabel != expLabel {
+		t.Errorf("Exp label %q, got %q", expLabel, resLabel)
+	}
+}
+
 func checkTemplate(t *testing.T, stpl string, val tableOutput, exp string) {
 
 	tmpl, err := template.New("t").Funcs(newTemplateFuncMap()).Parse(stpl)
@@ -278,7 +529,7 @@ func TestFetchKonfs(t *testing.T) {
 
 	tt := map[string]struct {
 		FSIn        afero.Fs
-		CheckError  func(*testing.T, error) // currently this convoluted mess is needed so we can accurately check for types. errors.As does not work in our case
+		CheckError  func(*testing.T, error)
 		ExpTableOut []tableOutput
 	}{
 		"empty store": {
@@ -334,11 +585,44 @@ func TestFetchKonfs(t *testing.T) {
 				},
 			},
 		},
+		"favorites float to the top": {
+			FSIn:       testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    favorite: true\n")),
+			CheckError: expNil,
+			ExpTableOut: []tableOutput{
+				{
+					Context:  "dev-eu",
+					Cluster:  "dev-eu-1",
+					File:     "./konf/store/dev-eu_dev-eu-1.yaml",
+					Favorite: true,
+				},
+				{
+					Context: "dev-asia",
+					Cluster: "dev-asia-1",
+					File:    "./konf/store/dev-asia_dev-asia-1.yaml",
+				},
+			},
+		},
+		"invalid metadata does not prevent listing": {
+			FSIn:       testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("I am no valid yaml")),
+			CheckError: expNil,
+			ExpTableOut: []tableOutput{
+				{
+					Context: "dev-eu",
+					Cluster: "dev-eu-1",
+					File:    "./konf/store/dev-eu_dev-eu-1.yaml",
+				},
+			},
+		},
 		"only directories in store": {
 			FSIn:        testhelper.FSWithFiles(fm.StoreDir, fm.EmptyDir),
 			CheckError:  expEmptyStore,
 			ExpTableOut: nil,
 		},
+		"store is a file": {
+			FSIn:        testhelper.FSWithFiles(func(f afero.Fs) { afero.WriteFile(f, "./konf/store", []byte{}, utils.KonfPerm) }),
+			CheckError:  expNotADir,
+			ExpTableOut: nil,
+		},
 	}
 
 	for name, tc := range tt {
@@ -404,18 +688,179 @@ func TestSelectContext(t *testing.T) {
 	}
 }
 
+// splitByClusterEU places a konf into the store, which has been imported with --split-by cluster. Besides the context
+// identifying it, it contains the context dev-eu-admin
+func splitByClusterEU(f afero.Fs) {
+	sm := testhelper.SampleKonfManager{}
+	konf := strings.Replace(sm.SingleClusterSingleContextEU(), "current-context:", `  - context:
+      cluster: dev-eu-1
+      user: dev-eu
+    name: dev-eu-admin
+current-context:`, 1)
+	afero.WriteFile(f, utils.StorePathForID("dev-eu_dev-eu-1"), []byte(konf), utils.KonfPerm)
+}
+
+func TestSetOtherContextOfSplitKonf(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	f := testhelper.FSWithFiles(fm.StoreDir, splitByClusterEU)
+
+	sc := newSetCommand()
+	sc.fs = f
+	err := sc.set(sc.cmd, []string{"dev-eu-admin"})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	b, err := afero.ReadFile(f, utils.ActivePathForPID(os.Getppid()))
+	if err != nil {
+		t.Fatalf("Could not read active konf: %q", err)
+	}
+	conf := parseKonf(t, b)
+	if conf.CurrentContext != "dev-eu-admin" {
+		t.Errorf("Exp the matched context to be the current one, got %q", conf.CurrentContext)
+	}
+	if len(conf.Contexts) != 2 {
+		t.Errorf("Exp all contexts of the konf to be kept, got %d", len(conf.Contexts))
+	}
+}
+
+func TestSearchFallback(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	// secondEU adds another konf with the context of the EU konf, but on a different cluster
+	var secondEU = func(f afero.Fs) {
+		konf := strings.ReplaceAll(sm.SingleClusterSingleContextEU(), "dev-eu-1", "dev-eu-2")
+		afero.WriteFile(f, utils.StorePathForID("dev-eu_dev-eu-2"), []byte(konf), utils.KonfPerm)
+	}
+
+	var confirm = func(ans bool) func(*promptui.Prompt) (bool, error) {
+		return func(*promptui.Prompt) (bool, error) { return ans, nil }
+	}
+	var pick = func(sel int) func(*promptui.Select) (int, error) {
+		return func(*promptui.Select) (int, error) { return sel, nil }
+	}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		id     string
+		pf     promptFunc
+		cf     func(*promptui.Prompt) (bool, error)
+		expID  string
+		expErr error
+	}{
+		"single match confirmed": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			"dev-eu_dev-eu1",
+			pick(0),
+			confirm(true),
+			"dev-eu_dev-eu-1",
+			nil,
+		},
+		"single match declined": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			"dev-eu_dev-eu1",
+			pick(0),
+			confirm(false),
+			"",
+			fmt.Errorf("could not find konf %q in the store. Run 'konf set' to pick from all available konfs", "dev-eu_dev-eu1"),
+		},
+		"multiple matches open picker": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			"dev",
+			pick(1),
+			confirm(false),
+			"dev-eu_dev-eu-1",
+			nil,
+		},
+		"no match": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			"oranges",
+			pick(0),
+			confirm(true),
+			"",
+			fmt.Errorf("could not find konf %q in the store. Run 'konf set' to pick from all available konfs", "oranges"),
+		},
+		"empty store": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			"oranges",
+			pick(0),
+			confirm(true),
+			"",
+			fmt.Errorf("could not find konf %q in the store. Run 'konf set' to pick from all available konfs", "oranges"),
+		},
+		"context name": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			"dev-eu",
+			pick(0),
+			confirm(false),
+			"dev-eu_dev-eu-1",
+			nil,
+		},
+		"cluster name": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			"dev-asia-1",
+			pick(0),
+			confirm(false),
+			"dev-asia_dev-asia-1",
+			nil,
+		},
+		"other context of a konf split by cluster": {
+			testhelper.FSWithFiles(fm.StoreDir, splitByClusterEU, fm.SingleClusterSingleContextASIA),
+			"dev-eu-admin",
+			pick(0),
+			confirm(false),
+			"dev-eu_dev-eu-1",
+			nil,
+		},
+		"context name of multiple konfs": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, secondEU),
+			"dev-eu",
+			func(p *promptui.Select) (int, error) {
+				if items := p.Items.([]tableOutput); len(items) != 2 {
+					return 0, fmt.Errorf("exp only the konfs named dev-eu to be offered, got %v", items)
+				}
+				return 1, nil
+			},
+			confirm(false),
+			"dev-eu_dev-eu-2",
+			nil,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res, err := searchFallback(tc.fs, tc.id, tc.pf, tc.cf)
+
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			if res != tc.expID {
+				t.Errorf("Exp id %q, got %q", tc.expID, res)
+			}
+		})
+	}
+}
+
 func expEmptyStore(t *testing.T, err error) {
-	if _, ok := err.(*EmptyStore); !ok {
+	if !errors.Is(err, &EmptyStore{}) {
 		t.Errorf("Expected err to be of type EmptyStore")
 	}
 }
 
 func expKubeConfigOverload(t *testing.T, err error) {
-	if _, ok := err.(*KubeConfigOverload); !ok {
+	if !errors.Is(err, &KubeConfigOverload{}) {
 		t.Errorf("Expected err to be of type KubeConfigOverload")
 	}
 }
 
+func expNotADir(t *testing.T, err error) {
+	if !errors.Is(err, &utils.NotADir{}) {
+		t.Errorf("Expected err to be of type NotADir, got %q", err)
+	}
+}
+
 func expNil(t *testing.T, err error) {
 	if err != nil {
 		t.Errorf("Expected err to be nil, but got %q", err)
@@ -430,24 +875,54 @@ func TestSearchKonf(t *testing.T) {
 	}{
 		"full match across all": {
 			"a b c",
-			&tableOutput{"a", "b", "c"},
+			&tableOutput{Context: "a", Cluster: "b", File: "c"},
 			true,
 		},
 		"full match across all - fuzzy": {
 			"abc",
-			&tableOutput{"a", "b", "c"},
+			&tableOutput{Context: "a", Cluster: "b", File: "c"},
 			true,
 		},
 		"partial match across fields": {
 			"textclu",
-			&tableOutput{"context", "cluster", "file"},
+			&tableOutput{Context: "context", Cluster: "cluster", File: "file"},
 			true,
 		},
 		"no match": {
 			"oranges",
-			&tableOutput{"apples", "and", "bananas"},
+			&tableOutput{Context: "apples", Cluster: "and", File: "bananas"},
 			false,
 		},
+		"match on original context": {
+			"arn:aws",
+			&tableOutput{Context: "arn:aws:eks:prod", Cluster: "b", File: "c", DisplayContext: "prod"},
+			true,
+		},
+		"match on display context": {
+			"short",
+			&tableOutput{Context: "very-long-name", Cluster: "b", File: "c", DisplayContext: "short"},
+			true,
+		},
+		"mixed case": {
+			"prod",
+			&tableOutput{Context: "PROD-eu", Cluster: "Prod-Cluster", File: "c"},
+			true,
+		},
+		"mixed case search term": {
+			"ProdEU",
+			&tableOutput{Context: "prod-eu", Cluster: "b", File: "c"},
+			true,
+		},
+		"accents": {
+			"cafe",
+			&tableOutput{Context: "café", Cluster: "b", File: "c"},
+			true,
+		},
+		"empty search": {
+			"",
+			&tableOutput{Context: "a", Cluster: "b", File: "c"},
+			true,
+		},
 	}
 
 	for name, tc := range tt {
@@ -459,3 +934,1101 @@ func TestSearchKonf(t *testing.T) {
 		})
 	}
 }
+
+func TestWritePreviousKonf(t *testing.T) {
+	var buf bytes.Buffer
+	err := writePreviousKonf(&buf, afero.NewMemMapFs(), "dev-eu_dev-eu-1")
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	exp := "./konf/store/dev-eu_dev-eu-1.yaml\n"
+	if buf.String() != exp {
+		t.Errorf("Exp previous konf %q, got %q", exp, buf.String())
+	}
+}
+
+func TestStateless(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	testhelper.OverrideConfig(t, func(c *config.Config) { c.Stateless = true })
+
+	f := testhelper.FSWithFiles(fm.LatestKonf)
+
+	err := saveLatestKonf(f, "other_cluster", "")
+	if err != nil {
+		t.Errorf("Exp no error, got %q", err)
+	}
+	b, _ := afero.ReadFile(f, config.LatestKonfFile())
+	if string(b) != "context_cluster" {
+		t.Errorf("Exp latest konf to stay untouched in stateless mode, but it is %q", b)
+	}
+
+	expErr := fmt.Errorf("could not select latest konf, because the history is disabled in stateless mode")
+	_, err = selectLastKonf(f)
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp error %q, got %q", expErr, err)
+	}
+}
+
+func TestSetFile(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+
+	var file = func(content string) func(afero.Fs) {
+		return func(f afero.Fs) { afero.WriteFile(f, "./some-kubeconfig.yaml", []byte(content), utils.KonfPerm) }
+	}
+
+	tt := map[string]struct {
+		fs         afero.Fs
+		args       []string
+		sel        int
+		expErr     error
+		expContext string
+	}{
+		"single context": {
+			testhelper.FSWithFiles(file(sm.SingleClusterSingleContextEU())),
+			[]string{},
+			0,
+			nil,
+			"dev-eu",
+		},
+		"multiple contexts": {
+			testhelper.FSWithFiles(file(sm.MultiClusterMultiContext())),
+			[]string{},
+			1,
+			nil,
+			"dev-eu",
+		},
+		"file and id": {
+			testhelper.FSWithFiles(file(sm.SingleClusterSingleContextEU())),
+			[]string{"dev-eu_dev-eu-1"},
+			0,
+			fmt.Errorf("please either supply a konf id or --file, but not both"),
+			"",
+		},
+		"no contexts": {
+			testhelper.FSWithFiles(file("apiVersion: v1\nkind: Config\n")),
+			[]string{},
+			0,
+			fmt.Errorf("no contexts found in file \"./some-kubeconfig.yaml\""),
+			"",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			sc := newSetCommand()
+			sc.fs = tc.fs
+			sc.file = "./some-kubeconfig.yaml"
+			sc.selector = promptFunc(func(*promptui.Select) (int, error) { return tc.sel, nil })
+
+			err := sc.cmd.RunE(sc.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			if _, err := tc.fs.Stat(config.StoreDir()); err == nil {
+				t.Errorf("Exp store to stay untouched, but it was created")
+			}
+			if _, err := tc.fs.Stat(config.LatestKonfFile()); err == nil {
+				t.Errorf("Exp latest konf to stay untouched, but it was written")
+			}
+
+			if tc.expErr != nil {
+				return
+			}
+			b, err := afero.ReadFile(tc.fs, utils.ActivePathForID(fmt.Sprint(os.Getppid())))
+			if err != nil {
+				t.Fatalf("Could not read active file: %q", err)
+			}
+			var conf k8s.Config
+			err = yaml.Unmarshal(b, &conf)
+			if err != nil {
+				t.Fatalf("Could not unmarshal active file: %q", err)
+			}
+			if len(conf.Contexts) != 1 || conf.Contexts[0].Name != tc.expContext || conf.CurrentContext != tc.expContext {
+				t.Errorf("Exp active file to only contain context %q, got %v", tc.expContext, conf.Contexts)
+			}
+		})
+	}
+}
+
+func TestDisplayContext(t *testing.T) {
+	tt := map[string]struct {
+		regex   string
+		replace string
+		in      string
+		exp     string
+	}{
+		"no transform": {
+			"",
+			"",
+			"arn:aws:eks:eu-central-1:123456789:cluster/prod",
+			"arn:aws:eks:eu-central-1:123456789:cluster/prod",
+		},
+		"transform": {
+			"^arn:aws:eks:([^:]+):[0-9]+:cluster/(.*)$",
+			"${2} (${1})",
+			"arn:aws:eks:eu-central-1:123456789:cluster/prod",
+			"prod (eu-central-1)",
+		},
+		"no match": {
+			"^gke_",
+			"",
+			"arn:aws:eks:eu-central-1:123456789:cluster/prod",
+			"arn:aws:eks:eu-central-1:123456789:cluster/prod",
+		},
+		"invalid regex": {
+			"^arn:(",
+			"",
+			"arn:aws:eks:eu-central-1:123456789:cluster/prod",
+			"arn:aws:eks:eu-central-1:123456789:cluster/prod",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			testhelper.OverrideConfig(t, func(c *config.Config) {
+				c.ContextDisplayRegex = tc.regex
+				c.ContextDisplayReplace = tc.replace
+			})
+
+			res := displayContext(tc.in)
+			if res != tc.exp {
+				t.Errorf("Exp %q, got %q", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestSelectKonfIgnoresDisplayContext(t *testing.T) {
+	konfs := []tableOutput{{Context: "very-long-context", Cluster: "cluster", File: "x", DisplayContext: "short"}}
+
+	id, err := selectKonf(konfs, promptFunc(func(*promptui.Select) (int, error) { return 0, nil }))
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if exp := "very-long-context_cluster"; id != exp {
+		t.Errorf("Exp id %q, got %q", exp, id)
+	}
+}
+
+func TestSetAllShells(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	var shells = func(pids ...string) func(afero.Fs) {
+		return func(f afero.Fs) {
+			for _, pid := range pids {
+				afero.WriteFile(f, utils.ActivePathForID(pid), []byte(sm.SingleClusterSingleContextASIA()), utils.KonfPerm)
+			}
+		}
+	}
+	// only even pids are considered alive
+	var alive = func(pid int) (bool, error) { return pid%2 == 0, nil }
+
+	tt := map[string]struct {
+		fs       afero.Fs
+		id       string
+		expErr   error
+		expN     int
+		expEU    []string
+		expNotEU []string
+	}{
+		"live and dead shells": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, shells("2", "4", "5")),
+			"dev-eu_dev-eu-1",
+			nil,
+			2,
+			[]string{"2", "4"},
+			[]string{"5"},
+		},
+		"no shells": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, func(f afero.Fs) { f.RemoveAll(config.ActiveDir()) }),
+			"dev-eu_dev-eu-1",
+			nil,
+			0,
+			[]string{},
+			[]string{},
+		},
+		"konf not in store": {
+			testhelper.FSWithFiles(fm.StoreDir, shells("2")),
+			"dev-eu_dev-eu-1",
+			&KonfNotFound{ID: "dev-eu_dev-eu-1"},
+			0,
+			[]string{},
+			[]string{"2"},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			n, err := setAllShells(tc.fs, tc.id, alive)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if n != tc.expN {
+				t.Errorf("Exp %d updated shells, got %d", tc.expN, n)
+			}
+
+			for _, pid := range tc.expEU {
+				b, _ := afero.ReadFile(tc.fs, utils.ActivePathForID(pid))
+				if string(b) != sm.SingleClusterSingleContextEU() {
+					t.Errorf("Exp shell %q to use the new konf, but it does not", pid)
+				}
+			}
+			for _, pid := range tc.expNotEU {
+				b, _ := afero.ReadFile(tc.fs, utils.ActivePathForID(pid))
+				if string(b) == sm.SingleClusterSingleContextEU() {
+					t.Errorf("Exp shell %q to keep its konf, but it was changed", pid)
+				}
+			}
+		})
+	}
+}
+
+func TestSetIDFile(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	var idFile = func(content string) func(afero.Fs) {
+		return func(f afero.Fs) { afero.WriteFile(f, "./konf-id", []byte(content), utils.KonfPerm) }
+	}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		args   []string
+		expErr error
+		expID  string
+	}{
+		"id with trailing newline": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, idFile("dev-eu_dev-eu-1\n")),
+			[]string{},
+			nil,
+			"dev-eu_dev-eu-1",
+		},
+		"unknown id": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, idFile("does-not_exist")),
+			[]string{},
+			fmt.Errorf("could not find konf %q in the store. Run 'konf set' to pick from all available konfs", "does-not_exist"),
+			"",
+		},
+		"empty file": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, idFile("\n")),
+			[]string{},
+			fmt.Errorf("could not read a konf id, because the input is empty"),
+			"",
+		},
+		"id-file and id": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, idFile("dev-eu_dev-eu-1")),
+			[]string{"dev-eu_dev-eu-1"},
+			fmt.Errorf("please supply the konf id only once, either as argument, via --id-file or via --id-fd"),
+			"",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			sc := newSetCommand()
+			sc.fs = tc.fs
+			sc.idFile = "./konf-id"
+
+			err := sc.cmd.RunE(sc.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			b, err := afero.ReadFile(tc.fs, config.LatestKonfFile())
+			if err != nil {
+				t.Fatalf("Could not read latest konf: %q", err)
+			}
+			if string(b) != tc.expID {
+				t.Errorf("Exp latest konf to be %q, got %q", tc.expID, b)
+			}
+		})
+	}
+}
+
+func TestExportStatement(t *testing.T) {
+	tt := map[string]struct {
+		shell  string
+		path   string
+		exp    string
+		expErr error
+	}{
+		"sh": {
+			"sh",
+			"/home/user/.kube/konfs/active/123.yaml",
+			"export KUBECONFIG='/home/user/.kube/konfs/active/123.yaml'",
+			nil,
+		},
+		"bash with spaces and quotes": {
+			"bash",
+			"/home/some user/it's/123.yaml",
+			`export KUBECONFIG='/home/some user/it'\''s/123.yaml'`,
+			nil,
+		},
+		"fish": {
+			"fish",
+			`/home/some user/it's\123.yaml`,
+			`set -gx KUBECONFIG '/home/some user/it\'s\\123.yaml'`,
+			nil,
+		},
+		"powershell": {
+			"powershell",
+			`C:\Users\some user\it's\123.yaml`,
+			`$env:KUBECONFIG = 'C:\Users\some user\it''s\123.yaml'`,
+			nil,
+		},
+		"unsupported shell": {
+			"tcsh",
+			"/tmp/123.yaml",
+			"",
+			fmt.Errorf("unsupported shell \"tcsh\". Please use one of sh, bash, zsh, fish or powershell"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res, err := exportStatement(tc.shell, tc.path)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if res != tc.exp {
+				t.Errorf("Exp statement %q, got %q", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestSetEchoEnv(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	sc := newSetCommand()
+	sc.fs = testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU)
+	sc.echoEnv = true
+	var out bytes.Buffer
+	sc.cmd.SetOut(&out)
+
+	err := sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	exp := "export KUBECONFIG='" + utils.ActivePathForID(fmt.Sprint(os.Getppid())) + "'\n"
+	if out.String() != exp {
+		t.Errorf("Exp output %q, got %q", exp, out.String())
+	}
+}
+
+func TestDirectStore(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	testhelper.OverrideConfig(t, func(c *config.Config) { c.DirectStore = true })
+
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU)
+	path, err := setContext("dev-eu_dev-eu-1", f)
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if path != utils.StorePathForID("dev-eu_dev-eu-1") {
+		t.Errorf("Exp $KUBECONFIG to point at the store, got %q", path)
+	}
+	if _, err := f.Stat(utils.ActivePathForID(fmt.Sprint(os.Getppid()))); err == nil {
+		t.Errorf("Exp no active konf to be written")
+	}
+
+	sc := newSetCommand()
+	sc.fs = f
+	sc.allShells = true
+	expErr := fmt.Errorf("--all-shells cannot be used with --direct-store, because shells do not have their own copy of a konf")
+	err = sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+	if !testhelper.EqualError(err, expErr) {
+		t.Errorf("Exp error %q, got %q", expErr, err)
+	}
+}
+
+func TestSetDirenv(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		args   []string
+		expOut string
+		expErr error
+	}{
+		"konf id": {
+			[]string{"dev-eu_dev-eu-1"},
+			"export KUBECONFIG='./konf/store/dev-eu_dev-eu-1.yaml'\n",
+			nil,
+		},
+		"unknown konf": {
+			[]string{"does-not_exist"},
+			"",
+			&KonfNotFound{ID: "does-not_exist"},
+		},
+		"no konf id": {
+			[]string{},
+			"",
+			fmt.Errorf("--direnv requires a konf id, as direnv cannot run the picker"),
+		},
+		"latest konf": {
+			[]string{"-"},
+			"",
+			fmt.Errorf("--direnv requires a konf id, as direnv cannot run the picker"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU)
+			sc := newSetCommand()
+			sc.fs = f
+			sc.direnv = true
+			var out bytes.Buffer
+			sc.cmd.SetOut(&out)
+
+			err := sc.cmd.RunE(sc.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if out.String() != tc.expOut {
+				t.Errorf("Exp output %q, got %q", tc.expOut, out.String())
+			}
+			if _, err := f.Stat(utils.ActivePathForID(fmt.Sprint(os.Getppid()))); err == nil {
+				t.Errorf("Exp no active konf to be written")
+			}
+			if _, err := f.Stat(config.LatestKonfFile()); err == nil {
+				t.Errorf("Exp latest konf to stay untouched")
+			}
+		})
+	}
+}
+
+func TestSetWaitHealthy(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		healthErr error
+		expOut    string
+	}{
+		"cluster ready":     {nil, "export KUBECONFIG='" + utils.ActivePathForID(fmt.Sprint(os.Getppid())) + "'\n"},
+		"cluster not ready": {fmt.Errorf("cluster \"https://10.1.1.0\" did not become ready within 10s"), ""},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			sc := newSetCommand()
+			sc.fs = testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU)
+			sc.waitHealthy = true
+			sc.echoEnv = true
+			checked := false
+			sc.healthCheck = func(b []byte, timeout time.Duration) error {
+				checked = true
+				return tc.healthErr
+			}
+			var out bytes.Buffer
+			sc.cmd.SetOut(&out)
+
+			err := sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+			if !testhelper.EqualError(err, tc.healthErr) {
+				t.Errorf("Exp err %q, got %q", tc.healthErr, err)
+			}
+			if !checked {
+				t.Errorf("Exp the cluster to be checked for readiness, but it was not")
+			}
+			// the shell must only be switched once the cluster is ready
+			if out.String() != tc.expOut {
+				t.Errorf("Exp output %q, got %q", tc.expOut, out.String())
+			}
+			_, activeErr := sc.fs.Stat(utils.ActivePathForID(fmt.Sprint(os.Getppid())))
+			_, latestErr := sc.fs.Stat(config.LatestKonfFile())
+			if switched := activeErr == nil && latestErr == nil; switched != (tc.healthErr == nil) {
+				t.Errorf("Exp the active konf and the latest konf to be written only for a ready cluster, got active %v and latest %v", activeErr, latestErr)
+			}
+		})
+	}
+}
+
+func TestSetRestoreNamespace(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	lastNS := metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    namespace: monitoring\n")
+
+	tt := map[string]struct {
+		fs        afero.Fs
+		namespace string
+		restore   bool
+		expNS     string
+	}{
+		"restore last namespace": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, lastNS),
+			"", true,
+			"monitoring",
+		},
+		"namespace flag takes precedence": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, lastNS),
+			"kube-system", true,
+			"kube-system",
+		},
+		"restore disabled": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, lastNS),
+			"", false,
+			"kube-public",
+		},
+		"no last namespace": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			"", true,
+			"kube-public",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			sc := newSetCommand()
+			sc.fs = tc.fs
+			sc.namespace = tc.namespace
+			sc.restoreNS = tc.restore
+
+			err := sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			b, err := afero.ReadFile(tc.fs, utils.ActivePathForID(fmt.Sprint(os.Getppid())))
+			if err != nil {
+				t.Fatalf("Could not read active konf: %q", err)
+			}
+			conf := parseKonf(t, b)
+			if conf.Contexts[0].Context.Namespace != tc.expNS {
+				t.Errorf("Exp namespace %q, got %q", tc.expNS, conf.Contexts[0].Context.Namespace)
+			}
+		})
+	}
+}
+
+// flakyFs fails to open a file for the supplied number of times, before it opens it successfully
+type flakyFs struct {
+	afero.Fs
+	failures map[string]int
+}
+
+func (f *flakyFs) Open(name string) (afero.File, error) {
+	if f.failures[name] > 0 {
+		f.failures[name]--
+		return nil, fmt.Errorf("connection reset by peer")
+	}
+	return f.Fs.Open(name)
+}
+
+func TestFetchKonfsRetry(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		failures int
+		expIDs   []string
+	}{
+		"transient failure": {readAttempts - 1, []string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"}},
+		"permanent failure": {readAttempts, []string{"dev-asia_dev-asia-1"}},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := &flakyFs{
+				Fs:       testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+				failures: map[string]int{utils.StorePathForID("dev-eu_dev-eu-1"): tc.failures},
+			}
+
+			konfs, err := fetchKonfs(f)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			ids := []string{}
+			for _, k := range konfs {
+				ids = append(ids, k.ID())
+			}
+			if !cmp.Equal(ids, tc.expIDs) {
+				t.Errorf("Exp and given konfs differ: \n '%s'", cmp.Diff(tc.expIDs, ids))
+			}
+		})
+	}
+}
+
+func TestSetIndex(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		index  int
+		args   []string
+		expID  string
+		expErr error
+	}{
+		"first konf": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			1, []string{},
+			"dev-asia_dev-asia-1",
+			nil,
+		},
+		"favorites come first": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    favorite: true\n")),
+			1, []string{},
+			"dev-eu_dev-eu-1",
+			nil,
+		},
+		"out of range": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			3, []string{},
+			"",
+			fmt.Errorf("index 3 is out of range. Please supply an index between 1 and 2, as listed by 'konf ls --number'"),
+		},
+		"negative": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			-1, []string{},
+			"",
+			fmt.Errorf("index -1 is out of range. Please supply an index between 1 and 1, as listed by 'konf ls --number'"),
+		},
+		"combined with an id": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			1, []string{"dev-eu_dev-eu-1"},
+			"",
+			fmt.Errorf("--index cannot be combined with a konf id or --label"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			sc := newSetCommand()
+			sc.fs = tc.fs
+			sc.index = tc.index
+
+			err := sc.cmd.RunE(sc.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			id, err := activeKonfID(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not read active konf: %q", err)
+			}
+			if id != tc.expID {
+				t.Errorf("Exp konf %q to be set, got %q", tc.expID, id)
+			}
+		})
+	}
+}
+
+func TestSetUnsavedNamespace(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+	activeEdited := func(f afero.Fs) {
+		b, _ := setNamespaceInKubeconfig([]byte(sm.SingleClusterSingleContextEU()), "edited")
+		afero.WriteFile(f, utils.ActivePathForID(fmt.Sprint(os.Getppid())), b, utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		fs      afero.Fs
+		persist bool
+		discard bool
+		expNS   string
+		expErr  error
+	}{
+		"warn only": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, activeEdited),
+			false, false,
+			"kube-public",
+			nil,
+		},
+		"persist": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, activeEdited),
+			true, false,
+			"edited",
+			nil,
+		},
+		"discard": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, activeEdited),
+			false, true,
+			"kube-public",
+			nil,
+		},
+		"namespace recorded by konf ns is restored anyway": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, activeEdited, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    namespace: edited\n")),
+			true, false,
+			"kube-public",
+			nil,
+		},
+		"persist and discard": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, activeEdited),
+			true, true,
+			"kube-public",
+			fmt.Errorf("please use either --persist or --discard, but not both"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			sc := newSetCommand()
+			sc.fs = tc.fs
+			sc.persistNS = tc.persist
+			sc.discardNS = tc.discard
+
+			err := sc.cmd.RunE(sc.cmd, []string{"dev-asia_dev-asia-1"})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			b, err := afero.ReadFile(tc.fs, utils.StorePathForID("dev-eu_dev-eu-1"))
+			if err != nil {
+				t.Fatalf("Could not read konf from store: %q", err)
+			}
+			conf := parseKonf(t, b)
+			if conf.Contexts[0].Context.Namespace != tc.expNS {
+				t.Errorf("Exp namespace %q in the store, got %q", tc.expNS, conf.Contexts[0].Context.Namespace)
+			}
+		})
+	}
+}
+
+func TestSetTrimsUnusedUsers(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	extraUser := sm.SingleClusterSingleContextEU() + "  - name: dev-asia\n    user:\n      token: secret\n"
+
+	tt := map[string]struct {
+		konf     string
+		expUsers []string
+	}{
+		"unused user": {
+			extraUser,
+			[]string{"dev-eu"},
+		},
+		"no unused user": {
+			sm.SingleClusterSingleContextEU(),
+			[]string{"dev-eu"},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := afero.NewMemMapFs()
+			afero.WriteFile(f, utils.StorePathForID("dev-eu_dev-eu-1"), []byte(tc.konf), utils.KonfPerm)
+
+			sc := newSetCommand()
+			sc.fs = f
+			err := sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			b, err := afero.ReadFile(f, utils.ActivePathForPID(os.Getppid()))
+			if err != nil {
+				t.Fatalf("Could not read active file: %q", err)
+			}
+			conf := parseKonf(t, b)
+			users := []string{}
+			for _, u := range conf.AuthInfos {
+				users = append(users, u.Name)
+			}
+			if !cmp.Equal(tc.expUsers, users) {
+				t.Errorf("Exp users %v in the active konf, got %v", tc.expUsers, users)
+			}
+			if len(conf.Clusters) != 1 || conf.CurrentContext != "dev-eu" {
+				t.Errorf("Exp active konf to keep its cluster and context, got %v", conf)
+			}
+
+			stored, _ := afero.ReadFile(f, utils.StorePathForID("dev-eu_dev-eu-1"))
+			if string(stored) != tc.konf {
+				t.Errorf("Exp store file to stay untouched, got %q", stored)
+			}
+		})
+	}
+}
+
+func TestPromptSize(t *testing.T) {
+	tt := map[string]struct {
+		items   int
+		height  int
+		expSize int
+	}{
+		"few konfs":                      {3, 50, 3},
+		"many konfs":                     {500, 50, 45},
+		"unknown height":                 {500, 0, 15},
+		"unknown height, few konfs":      {3, 0, 3},
+		"terminal smaller than overhead": {500, 3, 1},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if res := promptSize(tc.items, tc.height); res != tc.expSize {
+				t.Errorf("Exp size %d, got %d", tc.expSize, res)
+			}
+		})
+	}
+}
+
+func TestSetMultiClusterKonf(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	var multiKonf = func(f afero.Fs) {
+		afero.WriteFile(f, utils.StorePathForID("combined"), []byte(sm.MultiClusterMultiContext()), utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		strict     bool
+		expErr     error
+		expContext string
+	}{
+		"pick a context": {
+			false,
+			nil,
+			"dev-eu",
+		},
+		"strict": {
+			true,
+			&KubeConfigOverload{Path: utils.StorePathForID("combined")},
+			"",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(multiKonf)
+			sc := newSetCommand()
+			sc.fs = f
+			sc.strict = tc.strict
+			sc.selector = promptFunc(func(*promptui.Select) (int, error) { return 1, nil })
+
+			err := sc.cmd.RunE(sc.cmd, []string{"combined"})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			b, err := afero.ReadFile(f, utils.ActivePathForPID(os.Getppid()))
+			if err != nil {
+				t.Fatalf("Could not read active file: %q", err)
+			}
+			var conf k8s.Config
+			err = yaml.Unmarshal(b, &conf)
+			if err != nil {
+				t.Fatalf("Could not unmarshal active file: %q", err)
+			}
+			if conf.CurrentContext != tc.expContext || len(conf.Contexts) != 1 || len(conf.Clusters) != 1 || len(conf.AuthInfos) != 1 {
+				t.Errorf("Exp active file to only contain context %q with its cluster and user, got %v", tc.expContext, conf)
+			}
+
+			latest, _ := afero.ReadFile(f, config.LatestKonfFile())
+			if string(latest) != "combined" {
+				t.Errorf("Exp latest konf to be %q, got %q", "combined", latest)
+			}
+		})
+	}
+}
+
+func TestActiveMultiClusterKonf(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU, func(f afero.Fs) {
+		afero.WriteFile(f, utils.StorePathForID("combined"), []byte(sm.MultiClusterMultiContext()), utils.KonfPerm)
+	})
+
+	// dev-asia is not the current context of the konf in the store
+	sc := newSetCommand()
+	sc.fs = f
+	sc.selector = promptFunc(func(*promptui.Select) (int, error) { return 0, nil })
+	err := sc.cmd.RunE(sc.cmd, []string{"combined"})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+
+	id, err := activeKonfID(f)
+	if err != nil || id != "combined" {
+		t.Errorf("Exp the active konf to be traced back to %q, got %q, %v", "combined", id, err)
+	}
+
+	dc := newDeleteCommand()
+	dc.fs = f
+	asked := false
+	dc.confirmFunc = func(*promptui.Prompt) (bool, error) { asked = true; return false, nil }
+	err = dc.cmd.RunE(dc.cmd, []string{"combined"})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if _, err := f.Stat(utils.StorePathForID("combined")); !asked || err != nil {
+		t.Errorf("Exp deleting the active konf to be confirmed first, got asked %t and %v", asked, err)
+	}
+
+	err = writeNamespace(f, utils.ActivePathForPID(os.Getppid()), "team")
+	if err != nil {
+		t.Fatalf("Could not change namespace: %q", err)
+	}
+	sc = newSetCommand()
+	sc.fs = f
+	sc.persistNS = true
+	err = sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	b, _ := afero.ReadFile(f, utils.StorePathForID("combined"))
+	conf := parseKonf(t, b)
+	for _, ctx := range conf.Contexts {
+		exp := "kube-public"
+		if ctx.Name == "dev-asia" {
+			exp = "team"
+		}
+		if ctx.Context.Namespace != exp {
+			t.Errorf("Exp context %q to have namespace %q after the switch, got %q", ctx.Name, exp, ctx.Context.Namespace)
+		}
+	}
+}
+
+func TestSetPrintContext(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fd     string
+		expErr error
+		expOut string
+	}{
+		"stderr": {
+			"",
+			nil,
+			"dev-eu\n",
+		},
+		"invalid file descriptor": {
+			"stderr",
+			fmt.Errorf("could not print the context, because $KONF_CONTEXT_FD \"stderr\" is not a file descriptor"),
+			"",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("KONF_CONTEXT_FD", tc.fd)
+			sc := newSetCommand()
+			sc.fs = testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU)
+			sc.printCtx = true
+			var stderr bytes.Buffer
+			sc.cmd.SetErr(&stderr)
+
+			err := sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if stderr.String() != tc.expOut {
+				t.Errorf("Exp context %q to be printed, got %q", tc.expOut, stderr.String())
+			}
+		})
+	}
+}
+
+func TestSharedStores(t *testing.T) {
+	testhelper.OverrideConfig(t, func(c *config.Config) { c.SharedStoreDirs = []string{"/team/store", "/missing/store"} })
+
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+	var team = func(f afero.Fs) {
+		afero.WriteFile(f, "/team/store/dev-eu_dev-eu-1.yaml", []byte(strings.ReplaceAll(sm.SingleClusterSingleContextEU(), "https://10.1.1.0", "https://10.2.2.2")), utils.KonfPerm)
+		afero.WriteFile(f, "/team/store/dev-asia_dev-asia-1.yaml", []byte(sm.SingleClusterSingleContextASIA()), utils.KonfPerm)
+	}
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, team)
+
+	konfs, err := fetchKonfs(f)
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	exp := []tableOutput{
+		{Context: "dev-asia", Cluster: "dev-asia-1", File: "/team/store/dev-asia_dev-asia-1.yaml"},
+		{Context: "dev-eu", Cluster: "dev-eu-1", File: "./konf/store/dev-eu_dev-eu-1.yaml"},
+	}
+	if !cmp.Equal(exp, konfs) {
+		t.Errorf("Exp the own store to take precedence over the shared ones: \n '%s'", cmp.Diff(exp, konfs))
+	}
+
+	for _, id := range []string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"} {
+		sc := newSetCommand()
+		sc.fs = f
+		err = sc.cmd.RunE(sc.cmd, []string{id})
+		if err != nil {
+			t.Fatalf("Exp konf %q from any store to be set, got %q", id, err)
+		}
+		b, _ := afero.ReadFile(f, utils.ActivePathForPID(os.Getppid()))
+		if conf := parseKonf(t, b); conf.Clusters[0].Cluster.Server == "https://10.2.2.2" {
+			t.Errorf("Exp konf %q to be read from the own store first", id)
+		}
+	}
+}
+
+func TestSharedStoreKonfs(t *testing.T) {
+	testhelper.OverrideConfig(t, func(c *config.Config) { c.SharedStoreDirs = []string{"/team/store"} })
+
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+	id := "dev-asia_dev-asia-1"
+	sharedErr := &SharedKonf{ID: id, Path: "/team/store/dev-asia_dev-asia-1.yaml"}
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, func(f afero.Fs) {
+		afero.WriteFile(f, "/team/store/dev-asia_dev-asia-1.yaml", []byte(sm.SingleClusterSingleContextASIA()), utils.KonfPerm)
+	})
+
+	// metadata is kept in the own konf dir, so it can be added to shared konfs as well
+	fc := newFavoriteCmd()
+	fc.fs = f
+	if err := fc.add(fc.cmd, []string{id}); err != nil {
+		t.Errorf("Exp shared konf to become a favorite, got %q", err)
+	}
+	nc := newNoteCmd()
+	nc.fs = f
+	if err := nc.set(nc.cmd, []string{id, "team cluster"}); err != nil {
+		t.Errorf("Exp shared konf to get a note, got %q", err)
+	}
+	ec := newEnvCmd()
+	ec.fs = f
+	ec.cmd.SetOut(io.Discard)
+	if err := ec.env(ec.cmd, []string{id, "AWS_PROFILE=team"}); err != nil {
+		t.Errorf("Exp shared konf to get env vars, got %q", err)
+	}
+	if _, n, err := exportBundle(f, []string{id}); err != nil || n != 1 {
+		t.Errorf("Exp shared konf to be bundled, got %d konfs and %q", n, err)
+	}
+
+	// the shared store itself is never written to
+	if err := persistNamespaceForID(f, id, "", "team"); !testhelper.EqualError(err, sharedErr) {
+		t.Errorf("Exp err %q, got %q", sharedErr, err)
+	}
+	if err := deleteKonf(f, id); !testhelper.EqualError(err, sharedErr) {
+		t.Errorf("Exp err %q, got %q", sharedErr, err)
+	}
+	rc := newRenameCmd()
+	rc.fs = f
+	if err := rc.rename(rc.cmd, []string{id, "team"}); !testhelper.EqualError(err, sharedErr) {
+		t.Errorf("Exp err %q, got %q", sharedErr, err)
+	}
+	if _, err := f.Stat("/team/store/dev-asia_dev-asia-1.yaml"); err != nil {
+		t.Errorf("Exp shared konf to be kept, got %q", err)
+	}
+}
+
+func TestDebugTiming(t *testing.T) {
+	var out bytes.Buffer
+	log.EnableDebug(&out)
+	t.Cleanup(func() { log.EnableDebug(io.Discard) })
+
+	fm := testhelper.FilesystemManager{}
+	konfs, err := fetchKonfs(testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA))
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	createPrompt(konfs, 25)
+
+	phases := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		phases = append(phases, line[:strings.LastIndex(line, " took ")])
+	}
+	exp := []string{"DEBUG: walking the store (2 files)", "DEBUG: parsing 2 konfs", "DEBUG: loading metadata", "DEBUG: building the picker"}
+	if !cmp.Equal(exp, phases) {
+		t.Errorf("Exp and given timings differ: \n '%s'", cmp.Diff(exp, phases))
+	}
+}
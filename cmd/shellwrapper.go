@@ -32,17 +32,72 @@ See https://github.com/SimonTheLeg/konf-go#installation on how to do so
 func (c *shellwrapperCmd) shellwrapper(cmd *cobra.Command, args []string) error {
 	var wrapper string
 	var zsh = `
+# konf takes over $KUBECONFIG on 'konf set'. Preserve any value that has been set before sourcing the wrapper
+if [[ -n "${KUBECONFIG}" && -z "${KONF_ORIGINAL_KUBECONFIG}" ]]
+then
+  export KONF_ORIGINAL_KUBECONFIG="${KUBECONFIG}"
+fi
 konf() {
+  # konf-go hands over the previously active konf in a temporary file, so a switch can be undone using $KONF_PREVIOUS.
+  # Redirects or env vars on the konf-go call itself would make the shell fork, which changes the pid konf-go sees as its parent.
+  # A file keeps all file descriptors of the shell untouched
+  local prevfile="$(mktemp)"
+  export KONF_PREVIOUS_FILE="${prevfile}"
   res=$(konf-go $@)
+  unset KONF_PREVIOUS_FILE
   # only change $KUBECONFIG if instructed by konf-go
   if [[ $res == "KUBECONFIGCHANGE:"* ]]
   then
-    # this basically takes the line and cuts out the KUBECONFIGCHANGE Part
-    export KUBECONFIG="${res#*KUBECONFIGCHANGE:}"
+    # the environment variables of the previous konf must not leak into the new one
+    local line key
+    while IFS= read -r key
+    do
+      [[ -n "${key}" ]] && unset "${key}"
+    done <<< "${KONF_ENV_KEYS}"
+    KONF_ENV_KEYS=""
+    # the first line contains the new $KUBECONFIG, followed by a "KONFENV:KEY=VALUE" line for every
+    # environment variable of the konf
+    while IFS= read -r line
+    do
+      case "${line}" in
+        KUBECONFIGCHANGE:*)
+          export KUBECONFIG="${line#KUBECONFIGCHANGE:}"
+          ;;
+        KONFENV:*)
+          line="${line#KONFENV:}"
+          export "${line}"
+          KONF_ENV_KEYS="${KONF_ENV_KEYS}${line%%=*}"$'\n'
+          ;;
+      esac
+    done <<< "${res}"
+    if [[ -s "${prevfile}" ]]
+    then
+      KONF_PREVIOUS="$(cat "${prevfile}")"
+    fi
+  elif [[ $res == "KUBECONFIGUNSET"* ]]
+  then
+    local key
+    while IFS= read -r key
+    do
+      [[ -n "${key}" ]] && unset "${key}"
+    done <<< "${KONF_ENV_KEYS}"
+    KONF_ENV_KEYS=""
+    # a $KUBECONFIG that has been set before konf took over is restored
+    if [[ -n "${KONF_ORIGINAL_KUBECONFIG}" ]]
+    then
+      export KUBECONFIG="${KONF_ORIGINAL_KUBECONFIG}"
+    else
+      unset KUBECONFIG
+    fi
+    if [[ -s "${prevfile}" ]]
+    then
+      KONF_PREVIOUS="$(cat "${prevfile}")"
+    fi
   else
     # this makes --help work
     echo "${res}"
   fi
+  rm -f "${prevfile}"
 }
 konf_cleanup() {
   konf-go cleanup
@@ -51,17 +106,72 @@ add-zsh-hook zshexit konf_cleanup
 `
 
 	var bash = `
+# konf takes over $KUBECONFIG on 'konf set'. Preserve any value that has been set before sourcing the wrapper
+if [[ -n "${KUBECONFIG}" && -z "${KONF_ORIGINAL_KUBECONFIG}" ]]
+then
+  export KONF_ORIGINAL_KUBECONFIG="${KUBECONFIG}"
+fi
 konf() {
+  # konf-go hands over the previously active konf in a temporary file, so a switch can be undone using $KONF_PREVIOUS.
+  # Redirects or env vars on the konf-go call itself would make the shell fork, which changes the pid konf-go sees as its parent.
+  # A file keeps all file descriptors of the shell untouched
+  local prevfile="$(mktemp)"
+  export KONF_PREVIOUS_FILE="${prevfile}"
   res=$(konf-go $@)
+  unset KONF_PREVIOUS_FILE
   # only change $KUBECONFIG if instructed by konf-go
   if [[ $res == "KUBECONFIGCHANGE:"* ]]
   then
-    # this basically takes the line and cuts out the KUBECONFIGCHANGE Part
-    export KUBECONFIG="${res#*KUBECONFIGCHANGE:}"
+    # the environment variables of the previous konf must not leak into the new one
+    local line key
+    while IFS= read -r key
+    do
+      [[ -n "${key}" ]] && unset "${key}"
+    done <<< "${KONF_ENV_KEYS}"
+    KONF_ENV_KEYS=""
+    # the first line contains the new $KUBECONFIG, followed by a "KONFENV:KEY=VALUE" line for every
+    # environment variable of the konf
+    while IFS= read -r line
+    do
+      case "${line}" in
+        KUBECONFIGCHANGE:*)
+          export KUBECONFIG="${line#KUBECONFIGCHANGE:}"
+          ;;
+        KONFENV:*)
+          line="${line#KONFENV:}"
+          export "${line}"
+          KONF_ENV_KEYS="${KONF_ENV_KEYS}${line%%=*}"$'\n'
+          ;;
+      esac
+    done <<< "${res}"
+    if [[ -s "${prevfile}" ]]
+    then
+      KONF_PREVIOUS="$(cat "${prevfile}")"
+    fi
+  elif [[ $res == "KUBECONFIGUNSET"* ]]
+  then
+    local key
+    while IFS= read -r key
+    do
+      [[ -n "${key}" ]] && unset "${key}"
+    done <<< "${KONF_ENV_KEYS}"
+    KONF_ENV_KEYS=""
+    # a $KUBECONFIG that has been set before konf took over is restored
+    if [[ -n "${KONF_ORIGINAL_KUBECONFIG}" ]]
+    then
+      export KUBECONFIG="${KONF_ORIGINAL_KUBECONFIG}"
+    else
+      unset KUBECONFIG
+    fi
+    if [[ -s "${prevfile}" ]]
+    then
+      KONF_PREVIOUS="$(cat "${prevfile}")"
+    fi
   else
     # this makes --help work
     echo "${res}"
   fi
+  rm -f "${prevfile}"
 }
 konf_cleanup() {
   konf-go cleanup
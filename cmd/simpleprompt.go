@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/manifoldco/promptui"
+)
+
+// dumbTerminal reports whether the terminal lacks the cursor control the built-in picker relies on.
+// Windows does not set $TERM, but its console works fine with the built-in picker
+func dumbTerminal(term, goos string) bool {
+	return term == "dumb" || (term == "" && goos != "windows")
+}
+
+// newSimplePrompt returns a promptFunc, which prints the items of the prompt as a numbered list to out and reads
+// the number of the selection from in. It works on any terminal, as it neither moves the cursor nor uses colors
+func newSimplePrompt(in io.Reader, out io.Writer) promptFunc {
+	return func(p *promptui.Select) (int, error) {
+		items := reflect.ValueOf(p.Items)
+		if items.Kind() != reflect.Slice {
+			return -1, fmt.Errorf("cannot list items of type %T", p.Items)
+		}
+
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		if _, ok := p.Items.([]tableOutput); ok {
+			fmt.Fprintln(tw, "\tCONTEXT\tCLUSTER\tFILE")
+		}
+		for i := 0; i < items.Len(); i++ {
+			switch item := items.Index(i).Interface().(type) {
+			case tableOutput:
+				fmt.Fprintf(tw, "%d)\t%s\t%s\t%s\n", i+1, item.Display(), item.Cluster, item.File)
+			default:
+				fmt.Fprintf(tw, "%d)\t%v\n", i+1, item)
+			}
+		}
+		tw.Flush()
+		fmt.Fprintf(out, "Select a number: ")
+
+		line, err := bufio.NewReader(in).ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if err != nil && err != io.EOF {
+				return -1, err
+			}
+			return -1, fmt.Errorf("prompt failed %w", promptui.ErrInterrupt)
+		}
+
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 1 || n > items.Len() {
+			return -1, fmt.Errorf("invalid selection %q. Please enter a number between 1 and %d", line, items.Len())
+		}
+		return n - 1, nil
+	}
+}
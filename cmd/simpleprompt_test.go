@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/testhelper"
+)
+
+func TestDumbTerminal(t *testing.T) {
+	tt := map[string]struct {
+		term string
+		goos string
+		exp  bool
+	}{
+		"dumb":               {"dumb", "linux", true},
+		"no term":            {"", "darwin", true},
+		"no term on windows": {"", "windows", false},
+		"xterm":              {"xterm-256color", "linux", false},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if res := dumbTerminal(tc.term, tc.goos); res != tc.exp {
+				t.Errorf("Exp dumbTerminal to be %t, got %t", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestSimplePrompt(t *testing.T) {
+	konfs := []tableOutput{
+		{Context: "dev-eu", Cluster: "dev-eu-1", File: "./konf/store/dev-eu_dev-eu-1.yaml"},
+		{Context: "dev-asia", Cluster: "dev-asia-1", File: "./konf/store/dev-asia_dev-asia-1.yaml"},
+	}
+
+	tt := map[string]struct {
+		items  interface{}
+		input  string
+		expPos int
+		expOut string
+		expErr error
+	}{
+		"konfs": {
+			konfs,
+			"2\n",
+			1,
+			"    CONTEXT   CLUSTER     FILE\n" +
+				"1)  dev-eu    dev-eu-1    ./konf/store/dev-eu_dev-eu-1.yaml\n" +
+				"2)  dev-asia  dev-asia-1  ./konf/store/dev-asia_dev-asia-1.yaml\n" +
+				"Select a number: ",
+			nil,
+		},
+		"other items": {
+			[]string{"default", "kube-system"},
+			" 1 \n",
+			0,
+			"1)  default\n2)  kube-system\nSelect a number: ",
+			nil,
+		},
+		"out of range": {
+			konfs,
+			"3\n",
+			-1,
+			"",
+			fmt.Errorf("invalid selection \"3\". Please enter a number between 1 and 2"),
+		},
+		"no number": {
+			konfs,
+			"dev-eu\n",
+			-1,
+			"",
+			fmt.Errorf("invalid selection \"dev-eu\". Please enter a number between 1 and 2"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			pos, err := newSimplePrompt(strings.NewReader(tc.input), &out)(&promptui.Select{Items: tc.items})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if pos != tc.expPos {
+				t.Errorf("Exp position %d, got %d", tc.expPos, pos)
+			}
+			if tc.expOut != "" && out.String() != tc.expOut {
+				t.Errorf("Exp output %q, got %q", tc.expOut, out.String())
+			}
+		})
+	}
+}
+
+func TestSimplePromptAborted(t *testing.T) {
+	_, err := newSimplePrompt(strings.NewReader(""), &bytes.Buffer{})(&promptui.Select{Items: []string{"a"}})
+	if !errors.Is(err, promptui.ErrInterrupt) {
+		t.Errorf("Exp an interrupt, got %q", err)
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// snapshotLatestState keeps the active konf at path, if it belongs to the latest konf. It has to be called before
+// the active konf is removed, so 'konf set -' can restore its state, e.g. a namespace changed via kubectl, even
+// after the shell has been closed
+func snapshotLatestState(f afero.Fs, path string) error {
+	if config.Stateless() {
+		return nil
+	}
+	last, err := lastHistoryRecord(f)
+	if err != nil || last.Path != path {
+		return err
+	}
+
+	id, err := activeKonfIDFromFile(f, path)
+	if err != nil || id != last.ID {
+		return err
+	}
+	// just like the store, the snapshot never holds the credentials of a fetched konf
+	cmd, err := fetchCommandFor(f, id)
+	if err != nil || cmd != "" {
+		return err
+	}
+
+	b, err := afero.ReadFile(f, path)
+	if err != nil {
+		return err
+	}
+	// the snapshot is kept for longer than any active konf, so it is encrypted just like the store
+	return writeStoreFile(f, config.LatestStateFile(), b)
+}
+
+// restoreLatestState returns the latest state of the konf with the supplied id, so it can be written as the active
+// konf of the current shell. If there is no state to restore, nil is returned
+func restoreLatestState(f afero.Fs, id string) ([]byte, error) {
+	// in direct store mode there is no state besides the store itself
+	if config.DirectStore() {
+		return nil, nil
+	}
+	return latestState(f, id)
+}
+
+// latestState returns the active konf of the latest konf with the supplied id as it has last been used. As long as
+// its shell is still running, it is taken from there. Afterwards the snapshot taken on cleanup is used. If neither
+// exist, nil is returned
+func latestState(f afero.Fs, id string) ([]byte, error) {
+	last, err := lastHistoryRecord(f)
+	if err != nil || last.ID != id || !utils.IsActivePath(last.Path) {
+		return nil, err
+	}
+	// fetched konfs are fetched again on every set, so they never carry over expired credentials
+	cmd, err := fetchCommandFor(f, id)
+	if err != nil || cmd != "" {
+		return nil, err
+	}
+
+	activeID, err := activeKonfIDFromFile(f, last.Path)
+	if err != nil {
+		return nil, err
+	}
+	if activeID == id {
+		return afero.ReadFile(f, last.Path)
+	}
+
+	b, err := readStoreFile(f, config.LatestStateFile())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var conf k8s.Config
+	err = yaml.Unmarshal(b, &conf)
+	if err != nil {
+		return nil, err
+	}
+	// the snapshot is removed whenever the latest konf changes, so this only guards against manual edits
+	if snapID, err := activeIDFromKonf(&conf); err != nil || snapID != id {
+		return nil, nil
+	}
+	return b, nil
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestSetLatestRestoresState(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	otherShell := utils.ActivePathForID("999")
+	edited := strings.Replace(sm.SingleClusterSingleContextEU(), "namespace: kube-public", "namespace: edited", 1)
+
+	var latest = func(f afero.Fs) {
+		afero.WriteFile(f, config.LatestKonfFile(), []byte("dev-eu_dev-eu-1"), utils.KonfPerm)
+		afero.WriteFile(f, config.HistoryFile(), []byte("dev-eu_dev-eu-1\t"+otherShell+"\n"), utils.KonfPerm)
+	}
+	var activeIn = func(path, konf string) func(afero.Fs) {
+		return func(f afero.Fs) { afero.WriteFile(f, path, []byte(konf), utils.KonfPerm) }
+	}
+	var snapshot = func(konf string) func(afero.Fs) {
+		return func(f afero.Fs) { afero.WriteFile(f, config.LatestStateFile(), []byte(konf), utils.KonfPerm) }
+	}
+	restoredNS := metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    namespace: restored\n")
+
+	tt := map[string]struct {
+		fs        afero.Fs
+		namespace string
+		expNS     string
+	}{
+		"shell still running": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, latest, restoredNS, activeIn(otherShell, edited)),
+			"",
+			"edited",
+		},
+		"shell closed": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, latest, restoredNS, snapshot(edited)),
+			"",
+			"edited",
+		},
+		"shell switched to another konf": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, latest, restoredNS, activeIn(otherShell, sm.SingleClusterSingleContextASIA())),
+			"",
+			"restored",
+		},
+		"no state": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, latest, restoredNS),
+			"",
+			"restored",
+		},
+		"explicit namespace": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, latest, restoredNS, snapshot(edited)),
+			"kube-system",
+			"kube-system",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			sc := newSetCommand()
+			sc.fs = tc.fs
+			sc.namespace = tc.namespace
+
+			err := sc.cmd.RunE(sc.cmd, []string{"-"})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			b, err := afero.ReadFile(tc.fs, utils.ActivePathForPID(os.Getppid()))
+			if err != nil {
+				t.Fatalf("Could not read active konf: %q", err)
+			}
+			if ns := parseKonf(t, b).Contexts[0].Context.Namespace; ns != tc.expNS {
+				t.Errorf("Exp namespace %q, got %q", tc.expNS, ns)
+			}
+		})
+	}
+}
+
+func TestSnapshotLatestState(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	path := utils.ActivePathForID("999")
+
+	tt := map[string]struct {
+		history     string
+		active      string
+		expSnapshot bool
+	}{
+		"latest konf": {
+			"dev-eu_dev-eu-1\t" + path + "\n",
+			sm.SingleClusterSingleContextEU(),
+			true,
+		},
+		"different shell": {
+			"dev-eu_dev-eu-1\t" + utils.ActivePathForID("1000") + "\n",
+			sm.SingleClusterSingleContextEU(),
+			false,
+		},
+		"shell switched to another konf": {
+			"dev-eu_dev-eu-1\t" + path + "\n",
+			sm.SingleClusterSingleContextASIA(),
+			false,
+		},
+		"history without paths": {
+			"dev-eu_dev-eu-1\n",
+			sm.SingleClusterSingleContextEU(),
+			false,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := afero.NewMemMapFs()
+			afero.WriteFile(f, config.HistoryFile(), []byte(tc.history), utils.KonfPerm)
+			afero.WriteFile(f, path, []byte(tc.active), utils.KonfPerm)
+
+			err := snapshotLatestState(f, path)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			_, err = f.Stat(config.LatestStateFile())
+			if snap := err == nil; snap != tc.expSnapshot {
+				t.Errorf("Exp snapshot to be %t, got %t", tc.expSnapshot, snap)
+			}
+		})
+	}
+}
+
+func TestSaveLatestKonfRecordsPath(t *testing.T) {
+	f := afero.NewMemMapFs()
+	afero.WriteFile(f, config.LatestStateFile(), []byte("stale"), utils.KonfPerm)
+
+	steps := []struct {
+		id   string
+		path string
+	}{
+		{"dev-eu_dev-eu-1", "./konf/active/1.yaml"},
+		{"dev-asia_dev-asia-1", "./konf/active/1.yaml"},
+		{"dev-asia_dev-asia-1", "./konf/active/2.yaml"},
+	}
+	for _, s := range steps {
+		err := saveLatestKonf(f, s.id, s.path)
+		if err != nil {
+			t.Fatalf("Exp no error, got %q", err)
+		}
+	}
+
+	exp := `{"id":"dev-eu_dev-eu-1","path":"./konf/active/1.yaml"}` + "\n" + `{"id":"dev-asia_dev-asia-1","path":"./konf/active/2.yaml"}` + "\n"
+	b, _ := afero.ReadFile(f, config.HistoryFile())
+	if string(b) != exp {
+		t.Errorf("Exp history %q, got %q", exp, b)
+	}
+	hist, _ := readHistory(f)
+	if len(hist) != 2 || hist[1] != "dev-asia_dev-asia-1" {
+		t.Errorf("Exp ids of the history, got %q", hist)
+	}
+	if _, err := f.Stat(config.LatestStateFile()); err == nil {
+		t.Errorf("Exp the state of the previous latest konf to be removed")
+	}
+}
+
+func TestHistoryRecords(t *testing.T) {
+	tt := map[string]struct {
+		history string
+		exp     []historyRecord
+	}{
+		"json lines": {
+			`{"id":"dev eu_dev-eu-1","path":"./konf/active/1.yaml"}` + "\n" + `{"id":"dev-asia_dev-asia-1"}` + "\n",
+			[]historyRecord{{ID: "dev eu_dev-eu-1", Path: "./konf/active/1.yaml"}, {ID: "dev-asia_dev-asia-1"}},
+		},
+		"tab separated": {
+			"dev-eu_dev-eu-1\t./konf/active/1.yaml\ndev-asia_dev-asia-1\n",
+			[]historyRecord{{ID: "dev-eu_dev-eu-1", Path: "./konf/active/1.yaml"}, {ID: "dev-asia_dev-asia-1"}},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := afero.NewMemMapFs()
+			afero.WriteFile(f, config.HistoryFile(), []byte(tc.history), utils.KonfPerm)
+
+			hist, err := readHistoryRecords(f)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if !cmp.Equal(hist, tc.exp) {
+				t.Errorf("Exp and given records differ: \n '%s'", cmp.Diff(tc.exp, hist))
+			}
+
+			// records are always written back as json, which keeps whitespace in ids and paths intact
+			err = writeHistoryRecords(f, hist)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			hist, err = readHistoryRecords(f)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if !cmp.Equal(hist, tc.exp) {
+				t.Errorf("Exp and given records after writing differ: \n '%s'", cmp.Diff(tc.exp, hist))
+			}
+		})
+	}
+}
@@ -0,0 +1,548 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/simontheleg/konf-go/config"
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8s "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+type storeCmd struct {
+	cmd *cobra.Command
+}
+
+func newStoreCmd() *storeCmd {
+	sc := &storeCmd{}
+
+	sc.cmd = &cobra.Command{
+		Use:   "store",
+		Short: "Maintenance commands for the konf store",
+		Long: `Maintenance commands for the konf store
+
+The store contains one kubeconfig per context. These files are the single source of truth
+for which konfs exist. Next to them, konf keeps metadata like favorites, aliases and notes. It
+cannot be recreated from the store, but its entries for konfs that are gone can be pruned.`,
+	}
+
+	sc.cmd.AddCommand(newStoreReindexCmd().cmd, newStoreImportMergeCmd().cmd, newStoreCompactCmd().cmd, newStoreMigrateCmd().cmd)
+
+	return sc
+}
+
+// storeIndex describes an auxiliary file which refers to the konfs in the store and therefore has to be brought in
+// line with it after the store has been edited manually. Rebuilding must never drop anything that belongs to a konf
+// that still exists, as some of these files, like the metadata, hold data the user entered
+type storeIndex struct {
+	name    string
+	rebuild func(afero.Fs, []tableOutput) error
+}
+
+// storeIndexes contains all indexes konf currently maintains. Any feature that adds a new file referring to the
+// konfs in the store should register it in here, so 'konf store reindex' picks it up
+var storeIndexes = []storeIndex{
+	{name: "metadata", rebuild: rebuildMetadata},
+}
+
+type storeReindexCmd struct {
+	fs afero.Fs
+
+	indexes []storeIndex
+
+	cmd *cobra.Command
+}
+
+func newStoreReindexCmd() *storeReindexCmd {
+	rc := &storeReindexCmd{
+		fs:      utils.NewFs(),
+		indexes: storeIndexes,
+	}
+
+	rc.cmd = &cobra.Command{
+		Use:   "reindex",
+		Short: "Bring all auxiliary files in line with the store",
+		Long: `Bring all auxiliary files in line with the store
+
+Use this after you have edited files in the store manually. Metadata of konfs that are no longer in
+the store is pruned, while the metadata of all other konfs is kept. It is safe to run at any time.`,
+		Args: cobra.NoArgs,
+		RunE: rc.reindex,
+	}
+
+	return rc
+}
+
+func (c *storeReindexCmd) reindex(cmd *cobra.Command, args []string) error {
+	konfs, err := fetchKonfs(c.fs)
+	// an empty store is still a valid state, all indexes simply need to be emptied
+	if err != nil && !errors.Is(err, &EmptyStore{}) {
+		return err
+	}
+
+	if len(c.indexes) == 0 {
+		log.Info("Store contains %d konfs. There are no auxiliary files that need to be reindexed\n", len(konfs))
+		return nil
+	}
+
+	for _, idx := range c.indexes {
+		err := idx.rebuild(c.fs, konfs)
+		if err != nil {
+			return err
+		}
+		log.Info("Reindexed %s against %d konfs\n", idx.name, len(konfs))
+	}
+
+	return nil
+}
+
+type storeImportMergeCmd struct {
+	fs afero.Fs
+
+	cmd *cobra.Command
+}
+
+func newStoreImportMergeCmd() *storeImportMergeCmd {
+	mc := &storeImportMergeCmd{
+		fs: utils.NewFs(),
+	}
+
+	mc.cmd = &cobra.Command{
+		Use:   "import-merge",
+		Short: "Split kubeconfigs with multiple contexts that ended up in the store",
+		Long: `Split kubeconfigs with multiple contexts that ended up in the store
+
+Every file in the store that contains multiple contexts or clusters is split up just like 'konf import'
+would have done it. The original file is removed afterwards. If one of its contexts collides with a
+different konf that is already in the store, the original file is kept, so nothing gets lost.`,
+		Args: cobra.NoArgs,
+		RunE: mc.importMerge,
+	}
+
+	return mc
+}
+
+func (c *storeImportMergeCmd) importMerge(cmd *cobra.Command, args []string) error {
+	paths, err := overloadedStoreFiles(c.fs)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		log.Info("Store does not contain any kubeconfigs with multiple contexts. Nothing to split\n")
+		return nil
+	}
+
+	for _, path := range paths {
+		err := splitStoreFile(c.fs, path)
+		if err != nil {
+			return fmt.Errorf("could not split %q: %v", path, err)
+		}
+	}
+
+	return nil
+}
+
+// overloadedStoreFiles returns the paths of all files in the store, that contain multiple contexts or clusters
+func overloadedStoreFiles(f afero.Fs) ([]string, error) {
+	files, err := afero.ReadDir(f, config.StoreDir())
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(config.StoreDir(), file.Name())
+		b, err := readStoreFile(f, path)
+		if err != nil {
+			return nil, err
+		}
+		var conf k8s.Config
+		err = yaml.Unmarshal(b, &conf)
+		if err != nil {
+			// broken files are reported by every other command, so there is no need to do it here as well
+			continue
+		}
+		if overloaded(&conf) {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
+
+// splitStoreFile splits the kubeconfig at path into konfs and removes it afterwards. If a konf collides with
+// a different konf in the store, it is skipped and the kubeconfig is kept
+func splitStoreFile(f afero.Fs, path string) error {
+	konfs, err := determineConfigs(f, path, true)
+	if err != nil {
+		return err
+	}
+
+	keep := false
+	// a file can be named after one of its own contexts. This konf replaces the file, which is why it has to be written last
+	var replacement *konfFile
+	for _, konf := range konfs {
+		if filepath.Clean(konf.FilePath) == filepath.Clean(path) {
+			replacement = konf
+			continue
+		}
+
+		res, err := compareWithStore(f, konf)
+		if err != nil {
+			return err
+		}
+		switch res {
+		case konfUnchanged:
+			log.Info("Konf %q is already in the store\n", konf.FilePath)
+		case konfUpdated:
+			keep = true
+			log.Warn("Skipped konf %q, as it already exists in the store with a different content\n", konf.FilePath)
+		default:
+			err = writeConfig(f, konf)
+			if err != nil {
+				return err
+			}
+			log.Info("Split konf %q out of %q\n", konf.FilePath, path)
+		}
+	}
+
+	if keep {
+		log.Warn("Kept %q, as not all of its contexts could be split out of it. Please resolve the collisions and run this command again\n", path)
+		return nil
+	}
+	if replacement != nil {
+		log.Info("Split konf %q out of %q\n", replacement.FilePath, path)
+		return writeConfig(f, replacement)
+	}
+	return f.Remove(path)
+}
+
+type storeCompactCmd struct {
+	fs afero.Fs
+
+	cmd *cobra.Command
+}
+
+func newStoreCompactCmd() *storeCompactCmd {
+	cc := &storeCompactCmd{
+		fs: utils.NewFs(),
+	}
+
+	cc.cmd = &cobra.Command{
+		Use:   "compact",
+		Short: "Rewrite all files in the store in a canonical, minimal form",
+		Long: `Rewrite all files in the store in a canonical, minimal form
+
+Imported kubeconfigs vary in formatting and often contain empty optional fields. Compacting writes every
+konf with sorted keys and without empty fields, which keeps the store tidy and diffs meaningful. Users and
+clusters that none of the contexts of a konf reference, e.g. leftovers of konfs imported before each konf only
+received its own user, are removed. Files that are already compact are left untouched. Files with fields konf
+does not know are skipped, so nothing gets lost.`,
+		Args: cobra.NoArgs,
+		RunE: cc.compact,
+	}
+
+	return cc
+}
+
+func (c *storeCompactCmd) compact(cmd *cobra.Command, args []string) error {
+	files, err := afero.ReadDir(c.fs, config.StoreDir())
+	if err != nil {
+		return err
+	}
+
+	var total, compacted int
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		total++
+		path := filepath.Join(config.StoreDir(), file.Name())
+		b, err := readStoreFile(c.fs, path)
+		if err != nil {
+			return err
+		}
+
+		compact, err := compactKonf(b)
+		if err != nil {
+			log.Warn("Skipped %q: %v\n", path, err)
+			continue
+		}
+		if bytes.Equal(compact, b) {
+			continue
+		}
+
+		err = writeStoreFile(c.fs, path, compact)
+		if err != nil {
+			return err
+		}
+		compacted++
+		log.Info("Compacted %q\n", path)
+	}
+
+	log.Info("Compacted %d of %d files in the store\n", compacted, total)
+	return nil
+}
+
+// compactKonf returns the canonical form of the kubeconfig b. Serializing goes through json, which sorts all
+// keys and drops empty optional fields. It fails rather than dropping fields it does not know, or changing
+// the kubeconfig in any other way. Users and clusters that none of its contexts reference are removed, as they would
+// only leak their credentials
+func compactKonf(b []byte) ([]byte, error) {
+	var conf k8s.Config
+	err := yaml.UnmarshalStrict(normalizeKubeconfig(b), &conf)
+	if err != nil {
+		return nil, fmt.Errorf("could not be parsed strictly: %v", err)
+	}
+	// without any context, there is no telling which users and clusters are left over
+	if len(conf.Contexts) > 0 {
+		trimUnused(&conf)
+	}
+
+	compact, err := yaml.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var check k8s.Config
+	err = yaml.Unmarshal(compact, &check)
+	if err != nil {
+		return nil, err
+	}
+	// the semantic comparison treats empty and unset fields the same, which is exactly what compacting drops
+	if !equality.Semantic.DeepEqual(conf, check) {
+		return nil, fmt.Errorf("compacting would change the kubeconfig")
+	}
+
+	return compact, nil
+}
+
+type storeMigrateCmd struct {
+	fs afero.Fs
+
+	cmd *cobra.Command
+}
+
+func newStoreMigrateCmd() *storeMigrateCmd {
+	mc := &storeMigrateCmd{
+		fs: utils.NewFs(),
+	}
+
+	mc.cmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Rename all konfs in the store according to the configured id format",
+		Long: `Rename all konfs in the store according to the configured id format
+
+The id of a konf also names its file in the store. After changing idFormat in the config file, run this
+command to rename all existing konfs. Their metadata, like aliases or favorites, and the history for
+'konf set -' move along with them. Konfs whose new id is already taken are skipped, unless the konf
+taking it is migrated as well. Konfs that would take over each others ids, for example because they swap
+context and cluster name, are reported and have to be renamed first.`,
+		Args: cobra.NoArgs,
+		RunE: mc.migrate,
+	}
+
+	return mc
+}
+
+// migration moves the konf at path from oldID to newID
+type migration struct {
+	path  string
+	oldID string
+	newID string
+}
+
+func (c *storeMigrateCmd) migrate(cmd *cobra.Command, args []string) error {
+	files, err := afero.ReadDir(c.fs, config.StoreDir())
+	if err != nil {
+		return err
+	}
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+
+	// all renames are planned upfront, so konfs whose new id is still taken by another konf that gets migrated
+	// as well can simply wait for it
+	var plan []migration
+	var total int
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		total++
+		oldID := utils.IDFromFileInfo(file)
+		path := filepath.Join(config.StoreDir(), file.Name())
+		b, err := readStoreFile(c.fs, path)
+		if err != nil {
+			return err
+		}
+		var conf k8s.Config
+		err = yaml.Unmarshal(b, &conf)
+		if err != nil {
+			log.Warn("Skipped %q, as it does not contain a valid kubeconfig: %v\n", path, err)
+			continue
+		}
+		newID, err := idFromKonf(&conf)
+		if err != nil {
+			log.Warn("Skipped %q: %v\n", path, err)
+			continue
+		}
+		if newID == oldID {
+			continue
+		}
+		plan = append(plan, migration{path: path, oldID: oldID, newID: newID})
+	}
+
+	renamed, err := c.runMigrations(plan, m)
+
+	// konfs that have been renamed before an error occurred must not lose their metadata and history either
+	if len(renamed) > 0 {
+		perr := saveMetadata(c.fs, m)
+		if perr == nil {
+			perr = renameInHistory(c.fs, renamed)
+		}
+		if perr != nil {
+			if err != nil {
+				return fmt.Errorf("%v. Additionally the metadata and history of migrated konfs could not be updated: %v", err, perr)
+			}
+			return perr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("Migrated %d of %d konfs in the store\n", len(renamed), total)
+	return nil
+}
+
+// runMigrations renames the konfs of plan and their metadata in m. A migration only runs once its new id is free,
+// which might be the case only after another migration has moved its konf out of the way. It returns the old and
+// new ids of all konfs that have been renamed, even if an error occurred
+func (c *storeMigrateCmd) runMigrations(plan []migration, m *metadata) (map[string]string, error) {
+	renamed := map[string]string{}
+	pending := plan
+	for progress := true; progress; {
+		progress = false
+		var blocked []migration
+		for _, mig := range pending {
+			newPath := utils.StorePathForID(mig.newID)
+			if _, err := c.fs.Stat(newPath); err == nil {
+				blocked = append(blocked, mig)
+				continue
+			}
+			// the file is moved as is, so encrypted konfs stay encrypted
+			err := c.fs.Rename(mig.path, newPath)
+			if err != nil {
+				return renamed, err
+			}
+			m.renameKonf(mig.oldID, mig.newID)
+			renamed[mig.oldID] = mig.newID
+			log.Info("Migrated konf %q to %q\n", mig.oldID, mig.newID)
+			progress = true
+		}
+		pending = blocked
+	}
+
+	byOldID := map[string]migration{}
+	for _, mig := range pending {
+		byOldID[mig.oldID] = mig
+	}
+	reported := map[string]bool{}
+	for _, mig := range pending {
+		if reported[mig.oldID] {
+			continue
+		}
+		cycle := migrationCycle(byOldID, mig)
+		if cycle == nil {
+			log.Warn("Skipped konf %q, as a konf with the id %q already exists in the store\n", mig.oldID, mig.newID)
+			continue
+		}
+		for _, id := range cycle {
+			reported[id] = true
+		}
+		log.Warn("Skipped konfs %q, as each of them would take over the id of the next one. Please rename one of them via 'konf rename' and migrate again\n", cycle)
+	}
+
+	return renamed, nil
+}
+
+// migrationCycle returns the old ids of all konfs, whose migrations wait for each other in a cycle that starts with
+// mig, like two konfs that swap their ids. If there is no such cycle, nil is returned
+func migrationCycle(byOldID map[string]migration, mig migration) []string {
+	cycle := []string{mig.oldID}
+	seen := map[string]bool{mig.oldID: true}
+	for cur := mig.newID; ; {
+		next, ok := byOldID[cur]
+		if !ok {
+			return nil
+		}
+		if next.oldID == mig.oldID {
+			return cycle
+		}
+		if seen[next.oldID] {
+			// mig waits for a cycle, but is not part of it
+			return nil
+		}
+		seen[next.oldID] = true
+		cycle = append(cycle, next.oldID)
+		cur = next.newID
+	}
+}
+
+// renameInHistory replaces all ids in the history and the latest konf according to renamed, which maps old ids to
+// new ones. This keeps 'konf set -' working after konfs have been renamed
+func renameInHistory(f afero.Fs, renamed map[string]string) error {
+	unlock, err := lockLatestKonf(f)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hist, err := readHistoryRecords(f)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for i, rec := range hist {
+		if newID, ok := renamed[rec.ID]; ok {
+			hist[i].ID = newID
+			changed = true
+		}
+	}
+	if changed {
+		err = writeHistoryRecords(f, hist)
+		if err != nil {
+			return err
+		}
+	}
+
+	latest, err := afero.ReadFile(f, config.LatestKonfFile())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if newID, ok := renamed[string(latest)]; ok {
+		return afero.WriteFile(f, config.LatestKonfFile(), []byte(newID), utils.KonfPerm)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(newStoreCmd().cmd)
+}
@@ -0,0 +1,525 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestStoreReindex(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	var rebuiltWith []tableOutput
+	var rebuildCalled int
+	var mockIndex = storeIndex{
+		name: "mock index",
+		rebuild: func(f afero.Fs, konfs []tableOutput) error {
+			rebuildCalled++
+			rebuiltWith = konfs
+			return nil
+		},
+	}
+	var failingIndex = storeIndex{
+		name:    "failing index",
+		rebuild: func(afero.Fs, []tableOutput) error { return fmt.Errorf("could not rebuild") },
+	}
+
+	tt := map[string]struct {
+		fs          afero.Fs
+		indexes     []storeIndex
+		expErr      error
+		expRebuilds int
+		expNumKonfs int
+	}{
+		"no indexes": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]storeIndex{},
+			nil,
+			0,
+			0,
+		},
+		"rebuild index": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			[]storeIndex{mockIndex},
+			nil,
+			1,
+			2,
+		},
+		"empty store": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			[]storeIndex{mockIndex},
+			nil,
+			1,
+			0,
+		},
+		"impure store": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterMultiContext),
+			[]storeIndex{mockIndex},
+			&KubeConfigOverload{Path: "./konf/store/multi_konf.yaml"},
+			0,
+			0,
+		},
+		"failing index": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]storeIndex{failingIndex, mockIndex},
+			fmt.Errorf("could not rebuild"),
+			0,
+			0,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			rebuildCalled = 0
+			rebuiltWith = nil
+
+			rc := newStoreReindexCmd()
+			rc.fs = tc.fs
+			rc.indexes = tc.indexes
+			cmd := rc.cmd
+
+			err := cmd.RunE(cmd, []string{})
+			if !testhelper.EqualError(tc.expErr, err) {
+				t.Errorf("Exp error %q, got %q", tc.expErr, err)
+			}
+
+			if rebuildCalled != tc.expRebuilds {
+				t.Errorf("Exp rebuild to be called %d times, but got %d", tc.expRebuilds, rebuildCalled)
+			}
+
+			if len(rebuiltWith) != tc.expNumKonfs {
+				t.Errorf("Exp rebuild to receive %d konfs, but got %d", tc.expNumKonfs, len(rebuiltWith))
+			}
+		})
+	}
+}
+
+func TestStoreImportMerge(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	sm := testhelper.SampleKonfManager{}
+
+	var collidingKonf = func(f afero.Fs) {
+		afero.WriteFile(f, utils.StorePathForID("dev-eu_dev-eu-1"), []byte(sm.SingleClusterSingleContextASIA()), utils.KonfPerm)
+	}
+	var selfNamedKonf = func(f afero.Fs) {
+		afero.WriteFile(f, utils.StorePathForID("dev-eu_dev-eu-1"), []byte(sm.MultiClusterMultiContext()), utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		fs         afero.Fs
+		expKept    []string
+		expRemoved []string
+		expIDs     []string
+	}{
+		"split overloaded file": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.MultiClusterMultiContext),
+			[]string{},
+			[]string{utils.StorePathForID("multi_multi_konf")},
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+		},
+		"file named after one of its contexts": {
+			testhelper.FSWithFiles(fm.StoreDir, selfNamedKonf),
+			[]string{},
+			[]string{},
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+		},
+		"collision keeps the original": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.MultiClusterMultiContext, collidingKonf),
+			[]string{utils.StorePathForID("multi_multi_konf")},
+			[]string{},
+			nil,
+		},
+		"nothing to split": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{},
+			[]string{},
+			[]string{"dev-eu_dev-eu-1"},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			mc := newStoreImportMergeCmd()
+			mc.fs = tc.fs
+
+			err := mc.cmd.RunE(mc.cmd, []string{})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			for _, p := range tc.expKept {
+				if _, err := tc.fs.Stat(p); err != nil {
+					t.Errorf("Exp %q to be kept, got %q", p, err)
+				}
+			}
+			for _, p := range tc.expRemoved {
+				if _, err := tc.fs.Stat(p); err == nil {
+					t.Errorf("Exp %q to be removed", p)
+				}
+			}
+
+			if tc.expIDs == nil {
+				return
+			}
+			konfs, err := fetchKonfs(tc.fs)
+			if err != nil {
+				t.Fatalf("Exp a pure store after splitting, got %q", err)
+			}
+			ids := []string{}
+			for _, k := range konfs {
+				ids = append(ids, k.ID())
+			}
+			if !cmp.Equal(ids, tc.expIDs) {
+				t.Errorf("Exp and given konfs differ: \n '%s'", cmp.Diff(tc.expIDs, ids))
+			}
+		})
+	}
+}
+
+func TestStoreCompact(t *testing.T) {
+	var storeFile = func(id, content string) func(afero.Fs) {
+		return func(f afero.Fs) {
+			afero.WriteFile(f, utils.StorePathForID(id), []byte(content), utils.KonfPerm)
+		}
+	}
+
+	canonical := `apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: Y2EtZGF0YQ==
+    server: https://10.1.1.0
+  name: dev-eu-1
+contexts:
+- context:
+    cluster: dev-eu-1
+    user: dev-eu
+  name: dev-eu
+current-context: dev-eu
+kind: Config
+preferences: {}
+users:
+- name: dev-eu
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      args:
+      - token
+      command: aws
+      env: null
+      provideClusterInfo: false
+`
+	messy := `kind: Config
+apiVersion: v1
+current-context: dev-eu
+clusters:
+  - name: dev-eu-1
+    cluster:
+      server: https://10.1.1.0
+      certificate-authority-data: Y2EtZGF0YQ==
+      proxy-url: ""
+      extensions: []
+contexts:
+  - name: dev-eu
+    context:
+      user: dev-eu
+      cluster: dev-eu-1
+      namespace: ""
+users:
+  - name: dev-eu
+    user:
+      token: ""
+      exec:
+        command: aws
+        args: [token]
+        apiVersion: client.authentication.k8s.io/v1beta1
+`
+	// leftovers of a partial split, that none of the contexts reference
+	leftovers := strings.Replace(canonical, "contexts:", `- cluster:
+    server: https://10.1.1.1
+  name: dev-asia-1
+contexts:`, 1) + `- name: dev-asia
+  user:
+    token: secret
+`
+	unknownField := "apiVersion: v1\nkind: Config\nclusters: []\ncontexts: []\nusers: []\ncolor: red\n"
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		id     string
+		expOut string
+	}{
+		"messy file": {
+			testhelper.FSWithFiles(storeFile("dev-eu_dev-eu-1", messy)),
+			"dev-eu_dev-eu-1",
+			canonical,
+		},
+		"canonical file": {
+			testhelper.FSWithFiles(storeFile("dev-eu_dev-eu-1", canonical)),
+			"dev-eu_dev-eu-1",
+			canonical,
+		},
+		"unused users and clusters": {
+			testhelper.FSWithFiles(storeFile("dev-eu_dev-eu-1", leftovers)),
+			"dev-eu_dev-eu-1",
+			canonical,
+		},
+		"unknown field": {
+			testhelper.FSWithFiles(storeFile("dev-eu_dev-eu-1", unknownField)),
+			"dev-eu_dev-eu-1",
+			unknownField,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			cc := newStoreCompactCmd()
+			cc.fs = tc.fs
+
+			err := cc.cmd.RunE(cc.cmd, []string{})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			b, err := afero.ReadFile(tc.fs, utils.StorePathForID(tc.id))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tc.expOut {
+				t.Errorf("Exp and given store files differ: \n '%s'", cmp.Diff(tc.expOut, string(b)))
+			}
+		})
+	}
+}
+
+func TestStoreMigrate(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	latest := func(f afero.Fs) {
+		afero.WriteFile(f, config.LatestKonfFile(), []byte("dev-eu_dev-eu-1"), utils.KonfPerm)
+		afero.WriteFile(f, config.HistoryFile(), []byte("dev-asia_dev-asia-1\ndev-eu_dev-eu-1\n"), utils.KonfPerm)
+	}
+	// dev-asia-1_dev-asia is already taken by a konf that has been migrated before
+	taken := func(f afero.Fs) {
+		b, _ := afero.ReadFile(f, utils.StorePathForID("dev-asia_dev-asia-1"))
+		afero.WriteFile(f, utils.StorePathForID("dev-asia-1_dev-asia"), b, utils.KonfPerm)
+	}
+
+	tt := map[string]struct {
+		fs        afero.Fs
+		expFiles  []string
+		expLatest string
+	}{
+		"migrate": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, latest, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    favorite: true\naliases:\n  europe: dev-eu_dev-eu-1\n")),
+			[]string{"dev-asia-1_dev-asia.yaml", "dev-eu-1_dev-eu.yaml"},
+			"dev-eu-1_dev-eu",
+		},
+		"new id taken": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, taken, latest, metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    favorite: true\naliases:\n  europe: dev-eu_dev-eu-1\n")),
+			[]string{"dev-asia-1_dev-asia.yaml", "dev-asia_dev-asia-1.yaml", "dev-eu-1_dev-eu.yaml"},
+			"dev-eu-1_dev-eu",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			testhelper.OverrideConfig(t, func(c *config.Config) { c.IDFormat = "{{.Cluster}}_{{.Context}}" })
+
+			mc := newStoreMigrateCmd()
+			mc.fs = tc.fs
+			err := mc.cmd.RunE(mc.cmd, []string{})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+
+			files, err := afero.ReadDir(tc.fs, config.StoreDir())
+			if err != nil {
+				t.Fatalf("Could not read store: %q", err)
+			}
+			names := []string{}
+			for _, file := range files {
+				names = append(names, file.Name())
+			}
+			if !cmp.Equal(tc.expFiles, names) {
+				t.Errorf("Exp files %v in the store, got %v", tc.expFiles, names)
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			if !m.Konfs["dev-eu-1_dev-eu"].Favorite || m.Aliases["europe"] != "dev-eu-1_dev-eu" {
+				t.Errorf("Exp metadata to move along with the konf, got %v", m)
+			}
+
+			b, _ := afero.ReadFile(tc.fs, config.LatestKonfFile())
+			if string(b) != tc.expLatest {
+				t.Errorf("Exp latest konf %q, got %q", tc.expLatest, b)
+			}
+			hist, _ := readHistory(tc.fs)
+			if hist[len(hist)-1] != tc.expLatest {
+				t.Errorf("Exp history to be migrated, got %v", hist)
+			}
+		})
+	}
+}
+
+// failingRenameFs fails to rename the file at path
+type failingRenameFs struct {
+	afero.Fs
+	path string
+}
+
+func (f *failingRenameFs) Rename(oldname, newname string) error {
+	if filepath.Clean(oldname) == filepath.Clean(f.path) {
+		return fmt.Errorf("input/output error")
+	}
+	return f.Fs.Rename(oldname, newname)
+}
+
+func TestStoreMigrateOrder(t *testing.T) {
+	sm := testhelper.SampleKonfManager{}
+	// konf places a konf with the supplied context and cluster under id in the store
+	var konf = func(id, context, cluster string) func(afero.Fs) {
+		return func(f afero.Fs) {
+			k := strings.ReplaceAll(sm.SingleClusterSingleContextEU(), "dev-eu-1", cluster)
+			k = strings.ReplaceAll(k, "dev-eu", context)
+			afero.WriteFile(f, utils.StorePathForID(id), []byte(k), utils.KonfPerm)
+		}
+	}
+	var latest = func(id string) func(afero.Fs) {
+		return func(f afero.Fs) {
+			afero.WriteFile(f, config.LatestKonfFile(), []byte(id), utils.KonfPerm)
+			afero.WriteFile(f, config.HistoryFile(), []byte(id+"\n"), utils.KonfPerm)
+		}
+	}
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs        afero.Fs
+		expErr    bool
+		expFiles  []string
+		expLatest string
+	}{
+		"konf waits for the konf taking its new id": {
+			// ctx_cl becomes cl_ctx, which is only free after the konf with that id has become ours_other
+			testhelper.FSWithFiles(fm.StoreDir, konf("ctx_cl", "ctx", "cl"), konf("cl_ctx", "other", "ours"), latest("ctx_cl")),
+			false,
+			[]string{"cl_ctx.yaml", "ours_other.yaml"},
+			"cl_ctx",
+		},
+		"swapped ids are skipped": {
+			testhelper.FSWithFiles(fm.StoreDir, konf("north_south", "north", "south"), konf("south_north", "south", "north"), konf("ctx_cl", "ctx", "cl"), latest("ctx_cl")),
+			false,
+			[]string{"cl_ctx.yaml", "north_south.yaml", "south_north.yaml"},
+			"cl_ctx",
+		},
+		"failed migration keeps the history of migrated konfs": {
+			&failingRenameFs{
+				Fs:   testhelper.FSWithFiles(fm.StoreDir, konf("ctx_cl", "ctx", "cl"), konf("north_south", "north", "south"), latest("ctx_cl")),
+				path: utils.StorePathForID("north_south"),
+			},
+			true,
+			[]string{"cl_ctx.yaml", "north_south.yaml"},
+			"cl_ctx",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			testhelper.OverrideConfig(t, func(c *config.Config) { c.IDFormat = "{{.Cluster}}_{{.Context}}" })
+
+			mc := newStoreMigrateCmd()
+			mc.fs = tc.fs
+			err := mc.cmd.RunE(mc.cmd, []string{})
+			if (err != nil) != tc.expErr {
+				t.Fatalf("Exp error %t, got %q", tc.expErr, err)
+			}
+
+			files, err := afero.ReadDir(tc.fs, config.StoreDir())
+			if err != nil {
+				t.Fatalf("Could not read store: %q", err)
+			}
+			names := []string{}
+			for _, file := range files {
+				names = append(names, file.Name())
+			}
+			if !cmp.Equal(tc.expFiles, names) {
+				t.Errorf("Exp files %v in the store, got %v", tc.expFiles, names)
+			}
+
+			b, _ := afero.ReadFile(tc.fs, config.LatestKonfFile())
+			if string(b) != tc.expLatest {
+				t.Errorf("Exp latest konf %q, got %q", tc.expLatest, b)
+			}
+			hist, _ := readHistory(tc.fs)
+			if len(hist) != 1 || hist[0] != tc.expLatest {
+				t.Errorf("Exp history to be migrated, got %v", hist)
+			}
+		})
+	}
+}
+
+func TestReindexKeepsMetadataOfUnreadableKonfs(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	var teamStore = func(f afero.Fs) {
+		f.MkdirAll("/team/store", utils.KonfDirPerm)
+	}
+	meta := metadataFile("konfs:\n  dev-eu_dev-eu-1:\n    favorite: true\n  dev-asia_dev-asia-1:\n    note: asia\n  deleted_konf:\n    favorite: true\n")
+
+	tt := map[string]struct {
+		sharedStores []string
+		fs           afero.Fs
+		expErr       error
+		expKonfs     []string
+	}{
+		"unreadable konf": {
+			nil,
+			&flakyFs{
+				Fs:       testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, meta),
+				failures: map[string]int{utils.StorePathForID("dev-eu_dev-eu-1"): readAttempts},
+			},
+			nil,
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+		},
+		"unavailable shared store": {
+			[]string{"/team/store", "/missing/store"},
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, teamStore, meta),
+			fmt.Errorf("could not list the store \"/missing/store\". Please make sure it is available, so its metadata does not get lost: open /missing/store: file does not exist"),
+			[]string{"deleted_konf", "dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			testhelper.OverrideConfig(t, func(c *config.Config) { c.SharedStoreDirs = tc.sharedStores })
+
+			rc := newStoreReindexCmd()
+			rc.fs = tc.fs
+			err := rc.cmd.RunE(rc.cmd, []string{})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp error %q, got %q", tc.expErr, err)
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			ids := []string{}
+			for id := range m.Konfs {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			if !cmp.Equal(ids, tc.expKonfs) {
+				t.Errorf("Exp metadata of konfs %q, got %q", tc.expKonfs, ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type toggleCmd struct {
+	fs afero.Fs
+
+	cmd *cobra.Command
+}
+
+func newToggleCmd() *toggleCmd {
+	tc := &toggleCmd{
+		fs: utils.NewFs(),
+	}
+
+	tc.cmd = &cobra.Command{
+		Use:   "toggle",
+		Short: "Toggle between two konfs",
+		Long: `Toggle between two konfs
+
+Switches to whichever konf of the configured pair is not active in the current shell.
+If neither of them is active, the first one is set. The pair is remembered, so it only
+needs to be supplied once. Apart from that, toggle behaves just like 'konf set <konfig id>'.
+
+Examples:
+	-> 'toggle <konfig id> <konfig id>' configure the pair and toggle
+	-> 'toggle' toggle using the configured pair
+`,
+		Args:              toggleArgs,
+		RunE:              tc.toggle,
+		ValidArgsFunction: tc.completeToggle,
+	}
+
+	return tc
+}
+
+// toggleArgs ensures that either a full pair or no konf at all is supplied
+func toggleArgs(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 && len(args) != 2 {
+		return fmt.Errorf("please supply either both konfs of the pair or none")
+	}
+	return nil
+}
+
+func (c *toggleCmd) toggle(cmd *cobra.Command, args []string) error {
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 2 {
+		for _, id := range args {
+			if _, err := c.fs.Stat(utils.ResolveStorePath(c.fs, id)); err != nil {
+				return &KonfNotFound{ID: id}
+			}
+		}
+		m.Toggle = args
+		err = saveMetadata(c.fs, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(m.Toggle) != 2 {
+		return fmt.Errorf("no toggle pair configured yet. Please run 'konf toggle <konfig id> <konfig id>' once")
+	}
+
+	active, err := activeKonfID(c.fs)
+	if err != nil {
+		// an unreadable active konf is treated like no active konf at all
+		log.Warn("could not determine the active konf of this shell: %v\n", err)
+	}
+	id := toggleTarget(active, m.Toggle)
+
+	// the switch itself behaves exactly like 'konf set <konfig id>'
+	sc := newSetCommand()
+	sc.fs = c.fs
+	return sc.set(sc.cmd, []string{id})
+}
+
+// toggleTarget returns the konf of the pair that is not active. Falls back to the first one
+// if neither is active
+func toggleTarget(active string, pair []string) string {
+	if active == pair[0] {
+		return pair[1]
+	}
+	return pair[0]
+}
+
+func (c *toggleCmd) completeToggle(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) >= 2 {
+		return []string{}, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	konfs, err := fetchKonfs(c.fs)
+	if err != nil {
+		if errors.Is(err, &EmptyStore{}) {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	sug := []string{}
+	for _, konf := range konfs {
+		id := utils.IDFromClusterAndContext(konf.Cluster, konf.Context)
+		if len(args) == 1 && args[0] == id {
+			continue
+		}
+		sug = append(sug, id)
+	}
+
+	return sug, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(newToggleCmd().cmd)
+}
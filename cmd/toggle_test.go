@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/simontheleg/konf-go/config"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestToggleTarget(t *testing.T) {
+	pair := []string{"dev-eu_dev-eu-1", "dev-asia_dev-asia-1"}
+
+	tt := map[string]struct {
+		active string
+		exp    string
+	}{
+		"first is active":   {"dev-eu_dev-eu-1", "dev-asia_dev-asia-1"},
+		"second is active":  {"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+		"neither is active": {"other_konf", "dev-eu_dev-eu-1"},
+		"nothing is active": {"", "dev-eu_dev-eu-1"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res := toggleTarget(tc.active, pair)
+			if res != tc.exp {
+				t.Errorf("Exp target %q, got %q", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestToggle(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	pairMeta := metadataFile("toggle:\n- dev-eu_dev-eu-1\n- dev-asia_dev-asia-1\n")
+
+	tt := map[string]struct {
+		fs        afero.Fs
+		args      []string
+		expErr    error
+		expLatest string
+		expPair   []string
+	}{
+		"configure pair": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+			nil,
+			"dev-asia_dev-asia-1",
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+		},
+		"toggle away from active": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, activeEU, pairMeta),
+			[]string{},
+			nil,
+			"dev-asia_dev-asia-1",
+			[]string{"dev-eu_dev-eu-1", "dev-asia_dev-asia-1"},
+		},
+		"no pair configured": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU),
+			[]string{},
+			fmt.Errorf("no toggle pair configured yet. Please run 'konf toggle <konfig id> <konfig id>' once"),
+			"",
+			nil,
+		},
+		"konf of pair does not exist": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU),
+			[]string{"dev-eu_dev-eu-1", "i-dont-exist"},
+			&KonfNotFound{ID: "i-dont-exist"},
+			"",
+			nil,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			tcmd := newToggleCmd()
+			tcmd.fs = tc.fs
+			cmd := tcmd.cmd
+
+			err := cmd.RunE(cmd, tc.args)
+			if !testhelper.EqualError(tc.expErr, err) {
+				t.Fatalf("Exp error %q, got %q", tc.expErr, err)
+			}
+
+			latest, _ := afero.ReadFile(tc.fs, config.LatestKonfFile())
+			if string(latest) != tc.expLatest {
+				t.Errorf("Exp latest konf to be %q, got %q", tc.expLatest, latest)
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if !cmp.Equal(tc.expPair, m.Toggle) {
+				t.Errorf("Exp and given pair differ:\n'%s'", cmp.Diff(tc.expPair, m.Toggle))
+			}
+		})
+	}
+}
+
+func TestToggleBehavesLikeSet(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	f := testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA,
+		metadataFile("toggle:\n- dev-eu_dev-eu-1\n- dev-asia_dev-asia-1\nkonfs:\n  dev-eu_dev-eu-1:\n    namespace: monitoring\n"))
+
+	tcmd := newToggleCmd()
+	tcmd.fs = f
+	err := tcmd.cmd.RunE(tcmd.cmd, []string{})
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	b, err := afero.ReadFile(f, utils.ActivePathForID(fmt.Sprint(os.Getppid())))
+	if err != nil {
+		t.Fatalf("Could not read active konf: %q", err)
+	}
+	if ns := parseKonf(t, b).Contexts[0].Context.Namespace; ns != "monitoring" {
+		t.Errorf("Exp the last namespace of the konf to be restored, got %q", ns)
+	}
+}
+
+func TestToggleArgs(t *testing.T) {
+	for _, n := range []int{1, 3} {
+		args := make([]string, n)
+		if err := toggleArgs(nil, args); err == nil {
+			t.Errorf("Exp %d args to be rejected", n)
+		}
+	}
+	for _, n := range []int{0, 2} {
+		args := make([]string, n)
+		if err := toggleArgs(nil, args); err != nil {
+			t.Errorf("Exp %d args to be accepted, got %q", n, err)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/manifoldco/promptui"
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/prompt"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"k8s.io/client-go/kubernetes"
+)
+
+// tuiAction is an action that can be run on a konf from within the tui
+type tuiAction string
+
+const (
+	tuiSet       tuiAction = "set"
+	tuiNamespace tuiAction = "choose namespace"
+	tuiVerify    tuiAction = "verify"
+	tuiRename    tuiAction = "rename"
+	tuiFavorite  tuiAction = "toggle favorite"
+	tuiDelete    tuiAction = "delete"
+	tuiBack      tuiAction = "back"
+	tuiQuit      tuiAction = "quit"
+)
+
+var tuiActions = []tuiAction{tuiSet, tuiNamespace, tuiVerify, tuiRename, tuiFavorite, tuiDelete, tuiBack, tuiQuit}
+
+type tuiCmd struct {
+	fs afero.Fs
+
+	promptFunc  promptFunc
+	confirmFunc prompt.ConfirmFunc
+	inputFunc   prompt.InputFunc
+	isTerminal  func() bool
+	healthCheck func([]byte, time.Duration) error
+	clientSet   func([]byte) (kubernetes.Interface, error)
+
+	cmd *cobra.Command
+}
+
+func newTuiCmd() *tuiCmd {
+	tc := &tuiCmd{
+		fs:          utils.NewFs(),
+		promptFunc:  pickerPrompt,
+		confirmFunc: prompt.TerminalConfirm,
+		inputFunc:   prompt.TerminalInput,
+		isTerminal:  func() bool { return term.IsTerminal(int(os.Stderr.Fd())) },
+		healthCheck: checkHealthy,
+		clientSet:   konfClientSet,
+	}
+
+	tc.cmd = &cobra.Command{
+		Use:   "tui",
+		Short: "Manage your konfs interactively",
+		Long: `Manage your konfs interactively
+
+Pick a konf and run an action on it. Besides setting it, a konf can be verified, renamed,
+marked as favorite or deleted. Choosing a namespace makes the konf start out with it on
+its next set. The picker shows the result of the last verify of each konf, the summary
+of a picked konf also contains its namespace.
+
+The tui keeps on running until a konf is set or you quit. If konf is not run in a terminal,
+the regular picker of 'konf set' is used instead.
+`,
+		Args: cobra.NoArgs,
+		RunE: tc.tui,
+	}
+
+	return tc
+}
+
+func (c *tuiCmd) tui(cmd *cobra.Command, args []string) error {
+	if !c.isTerminal() {
+		id, err := selectContext(c.fs, c.promptFunc)
+		if err != nil {
+			return err
+		}
+		return c.setKonf(id)
+	}
+
+	for {
+		konfs, err := fetchKonfs(c.fs)
+		if err != nil {
+			return err
+		}
+
+		active, err := activeKonfID(c.fs)
+		if err != nil {
+			log.Warn("could not determine the active konf of this shell: %v\n", err)
+		}
+		if active != "" {
+			log.Info("Active konf: %q\n", active)
+		}
+
+		id, err := selectKonf(konfs, c.promptFunc)
+		if err != nil {
+			if errors.Is(err, promptui.ErrInterrupt) {
+				return nil
+			}
+			return err
+		}
+
+		err = c.printSummary(id)
+		if err != nil {
+			return err
+		}
+		action, err := c.selectAction(id)
+		if err != nil {
+			if errors.Is(err, promptui.ErrInterrupt) {
+				return nil
+			}
+			return err
+		}
+
+		switch action {
+		case tuiSet:
+			return c.setKonf(id)
+		case tuiNamespace:
+			err = c.chooseNamespace(id)
+		case tuiVerify:
+			err = c.verifyKonf(id)
+		case tuiRename:
+			err = c.renameKonf(id)
+		case tuiFavorite:
+			err = toggleFavorite(c.fs, id)
+		case tuiDelete:
+			err = c.deleteKonf(id, active)
+		case tuiQuit:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *tuiCmd) selectAction(id string) (tuiAction, error) {
+	p := &promptui.Select{
+		Label:        fmt.Sprintf("What do you want to do with konf %q", id),
+		Items:        tuiActions,
+		HideSelected: true,
+		Stdout:       os.Stderr,
+	}
+	sel, err := c.promptFunc(p)
+	if err != nil {
+		return "", err
+	}
+	if sel < 0 || sel >= len(tuiActions) {
+		return "", fmt.Errorf("invalid selection %d", sel)
+	}
+	return tuiActions[sel], nil
+}
+
+func (c *tuiCmd) setKonf(id string) error {
+	// the switch itself behaves exactly like 'konf set <konfig id>'
+	sc := newSetCommand()
+	sc.fs = c.fs
+	sc.selector = c.promptFunc
+	sc.confirmFunc = c.confirmFunc
+	sc.inputFunc = c.inputFunc
+	sc.isTerminal = c.isTerminal
+	return sc.set(sc.cmd, []string{id})
+}
+
+// printSummary prints everything 'konf set --preview' shows about the konf with the supplied id to stderr. The
+// namespace is the one the konf starts out with on its next set
+func (c *tuiCmd) printSummary(id string) error {
+	s, err := summarizeKonf(c.fs, id)
+	if err != nil || s == nil {
+		return err
+	}
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+	if km, ok := m.Konfs[id]; ok && km.Namespace != "" {
+		s.Namespace = km.Namespace
+	}
+	return printSummary(c.cmd.ErrOrStderr(), s)
+}
+
+// chooseNamespace lets the user pick one of the namespaces of the cluster of the konf with the supplied id. Just
+// like a namespace set via 'konf ns', it is restored on the next set of the konf
+func (c *tuiCmd) chooseNamespace(id string) error {
+	konf, err := readValidKonf(c.fs, id)
+	if err != nil {
+		return err
+	}
+	csc := func(afero.Fs) (kubernetes.Interface, error) { return c.clientSet(konf) }
+	ns, err := selectNamespace(csc, prompt.RunFunc(c.promptFunc), c.fs)
+	if err != nil {
+		return err
+	}
+
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+	m.konf(id).Namespace = ns
+	err = saveMetadata(c.fs, m)
+	if err != nil {
+		return err
+	}
+	log.Info("Konf %q is going to start out with namespace %q\n", id, ns)
+	return nil
+}
+
+// verifyKonf checks whether the cluster of the konf with the supplied id is healthy, just like 'konf verify'. The
+// result shows up in the picker right away
+func (c *tuiCmd) verifyKonf(id string) error {
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+	_, err = verifyKonf(c.fs, m, id, c.healthCheck)
+	if err != nil {
+		return err
+	}
+	return saveMetadata(c.fs, m)
+}
+
+// renameKonf asks for a new context name of the konf with the supplied id and renames it just like 'konf rename'
+func (c *tuiCmd) renameKonf(id string) error {
+	context, err := c.inputFunc(&promptui.Prompt{Label: fmt.Sprintf("New context name of konf %q", id), Stdout: os.Stderr})
+	if err != nil {
+		return err
+	}
+	// a name that is already taken should not end the tui, so the user can simply try again
+	_, err = renameKonf(c.fs, id, context)
+	if err != nil {
+		log.Warn("could not rename konf %q: %v\n", id, err)
+	}
+	return nil
+}
+
+func (c *tuiCmd) deleteKonf(id string, active string) error {
+	label := fmt.Sprintf("Delete konf %q", id)
+	if id == active {
+		label = fmt.Sprintf("Konf %q is currently active in this shell. Your shell will keep on using an orphaned copy of it. Delete anyway", id)
+	}
+	ok, err := c.confirmFunc(&promptui.Prompt{Label: label, IsConfirm: true, Stdout: os.Stderr})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Info("Skipping deletion of konf %q\n", id)
+		return nil
+	}
+
+	err = deleteKonf(c.fs, id)
+	if err != nil {
+		return err
+	}
+	log.Info("Deleted konf %q\n", id)
+	return nil
+}
+
+// toggleFavorite marks a konf as favorite or removes the mark if it is one already
+func toggleFavorite(f afero.Fs, id string) error {
+	m, err := loadMetadata(f)
+	if err != nil {
+		return err
+	}
+	km := m.konf(id)
+	km.Favorite = !km.Favorite
+	if km.Favorite {
+		log.Info("Added konf %q to favorites\n", id)
+	} else {
+		log.Info("Removed konf %q from favorites\n", id)
+	}
+	return saveMetadata(f, m)
+}
+
+func init() {
+	rootCmd.AddCommand(newTuiCmd().cmd)
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/manifoldco/promptui"
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTui(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	// picks returns a promptFunc that answers all prompts in the order of sels
+	var picks = func(sels ...int) func(*promptui.Select) (int, error) {
+		i := 0
+		return func(*promptui.Select) (int, error) {
+			if i >= len(sels) {
+				return 0, fmt.Errorf("prompt failed %w", promptui.ErrInterrupt)
+			}
+			i++
+			return sels[i-1], nil
+		}
+	}
+	var index = func(a tuiAction) int {
+		for i, action := range tuiActions {
+			if action == a {
+				return i
+			}
+		}
+		t.Fatalf("unknown action %q", a)
+		return -1
+	}
+
+	tt := map[string]struct {
+		fs           afero.Fs
+		terminal     bool
+		promptFunc   func(*promptui.Select) (int, error)
+		confirm      bool
+		expErr       error
+		expActive    string
+		expStored    []string
+		expFavorites []string
+	}{
+		"set konf": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			true,
+			picks(1, index(tuiSet)),
+			false,
+			nil,
+			"dev-eu_dev-eu-1",
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+			[]string{},
+		},
+		"delete konf and quit": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			true,
+			picks(0, index(tuiDelete), 0, index(tuiQuit)),
+			true,
+			nil,
+			"",
+			[]string{"dev-eu_dev-eu-1"},
+			[]string{},
+		},
+		"declined delete": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			true,
+			picks(0, index(tuiDelete), 0, index(tuiQuit)),
+			false,
+			nil,
+			"",
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+			[]string{},
+		},
+		"favorite and go back until interrupted": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			true,
+			picks(1, index(tuiFavorite), 0, index(tuiBack)),
+			false,
+			nil,
+			"",
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+			[]string{"dev-eu_dev-eu-1"},
+		},
+		"no terminal falls back to picker": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			false,
+			picks(0),
+			false,
+			nil,
+			"dev-asia_dev-asia-1",
+			[]string{"dev-asia_dev-asia-1", "dev-eu_dev-eu-1"},
+			[]string{},
+		},
+		"empty store": {
+			testhelper.FSWithFiles(fm.StoreDir),
+			true,
+			picks(),
+			false,
+			&EmptyStore{},
+			"",
+			[]string{},
+			[]string{},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			// the sample konfs are also placed in the active dir, which is not what we want here
+			tc.fs.RemoveAll("./konf/active")
+
+			tcmd := newTuiCmd()
+			tcmd.fs = tc.fs
+			tcmd.promptFunc = tc.promptFunc
+			tcmd.confirmFunc = func(*promptui.Prompt) (bool, error) { return tc.confirm, nil }
+			tcmd.isTerminal = func() bool { return tc.terminal }
+			tcmd.cmd.SetErr(io.Discard)
+
+			err := tcmd.cmd.RunE(tcmd.cmd, []string{})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			active, err := activeKonfIDFromFile(tc.fs, utils.ActivePathForID(fmt.Sprint(os.Getppid())))
+			if err != nil {
+				t.Fatalf("Could not read active konf: %q", err)
+			}
+			if active != tc.expActive {
+				t.Errorf("Exp active konf %q, got %q", tc.expActive, active)
+			}
+
+			for _, id := range tc.expStored {
+				if _, err := tc.fs.Stat(utils.StorePathForID(id)); err != nil {
+					t.Errorf("Exp konf %q to still be in the store, but got: %q", id, err)
+				}
+			}
+			if tc.expErr == nil {
+				konfs, err := fetchKonfs(tc.fs)
+				if err != nil {
+					t.Fatalf("Could not fetch konfs: %q", err)
+				}
+				if len(konfs) != len(tc.expStored) {
+					t.Errorf("Exp %d konfs in the store, got %d", len(tc.expStored), len(konfs))
+				}
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			if fav := m.favorites(); !cmp.Equal(fav, tc.expFavorites, cmpopts.EquateEmpty()) {
+				t.Errorf("Exp favorites %q, got %q", tc.expFavorites, fav)
+			}
+		})
+	}
+}
+
+func TestTuiKonfActions(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	var answers = func(sels ...int) func(*promptui.Select) (int, error) {
+		i := 0
+		return func(*promptui.Select) (int, error) {
+			if i >= len(sels) {
+				return 0, fmt.Errorf("prompt failed %w", promptui.ErrInterrupt)
+			}
+			i++
+			return sels[i-1], nil
+		}
+	}
+	var index = func(a tuiAction) int {
+		for i, action := range tuiActions {
+			if action == a {
+				return i
+			}
+		}
+		t.Fatalf("unknown action %q", a)
+		return -1
+	}
+
+	tt := map[string]struct {
+		promptFunc func(*promptui.Select) (int, error)
+		newName    string
+		check      func(t *testing.T, f afero.Fs)
+	}{
+		"choose namespace": {
+			// the namespaces of the cluster are sorted, so the second one is kube-system
+			answers(1, index(tuiNamespace), 1, 1, index(tuiSet)),
+			"",
+			func(t *testing.T, f afero.Fs) {
+				b, err := afero.ReadFile(f, utils.ActivePathForID(fmt.Sprint(os.Getppid())))
+				if err != nil {
+					t.Fatalf("Could not read active konf: %q", err)
+				}
+				if ns := parseKonf(t, b).Contexts[0].Context.Namespace; ns != "kube-system" {
+					t.Errorf("Exp the konf to be set with the chosen namespace, got %q", ns)
+				}
+			},
+		},
+		"verify": {
+			answers(1, index(tuiVerify)),
+			"",
+			func(t *testing.T, f afero.Fs) {
+				m, err := loadMetadata(f)
+				if err != nil {
+					t.Fatalf("Could not load metadata: %q", err)
+				}
+				if km, ok := m.Konfs["dev-eu_dev-eu-1"]; !ok || km.Verified == nil || !km.Verified.Healthy {
+					t.Errorf("Exp a healthy verify result for konf %q, got %+v", "dev-eu_dev-eu-1", km)
+				}
+			},
+		},
+		"rename": {
+			answers(1, index(tuiRename)),
+			"dev-eu-renamed",
+			func(t *testing.T, f afero.Fs) {
+				if _, err := f.Stat(utils.StorePathForID("dev-eu-renamed_dev-eu-1")); err != nil {
+					t.Errorf("Exp konf to be renamed, got %q", err)
+				}
+				if _, err := f.Stat(utils.StorePathForID("dev-eu_dev-eu-1")); err == nil {
+					t.Errorf("Exp the konf with the old id to be gone")
+				}
+			},
+		},
+		"failed rename keeps the tui running": {
+			answers(1, index(tuiRename), 0, index(tuiBack)),
+			"dev-eu",
+			func(t *testing.T, f afero.Fs) {
+				if _, err := f.Stat(utils.StorePathForID("dev-eu_dev-eu-1")); err != nil {
+					t.Errorf("Exp konf to keep its id, got %q", err)
+				}
+			},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA)
+			f.RemoveAll("./konf/active")
+
+			tcmd := newTuiCmd()
+			tcmd.fs = f
+			tcmd.promptFunc = tc.promptFunc
+			tcmd.isTerminal = func() bool { return true }
+			tcmd.cmd.SetErr(io.Discard)
+			tcmd.inputFunc = func(*promptui.Prompt) (string, error) { return tc.newName, nil }
+			tcmd.healthCheck = func([]byte, time.Duration) error { return nil }
+			tcmd.clientSet = func([]byte) (kubernetes.Interface, error) {
+				return fake.NewSimpleClientset(testhelper.NamespaceFromName("default"), testhelper.NamespaceFromName("kube-system")), nil
+			}
+
+			err := tcmd.cmd.RunE(tcmd.cmd, []string{})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			tc.check(t, f)
+		})
+	}
+}
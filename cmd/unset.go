@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type unsetCmd struct {
+	fs afero.Fs
+
+	cmd *cobra.Command
+}
+
+func newUnsetCmd() *unsetCmd {
+	uc := &unsetCmd{
+		fs: utils.NewFs(),
+	}
+
+	uc.cmd = &cobra.Command{
+		Use:   "unset",
+		Short: "Return the current shell to a state without an active konf",
+		Long: `Return the current shell to a state without an active konf
+
+Removes the active konf of the current shell and instructs the shellwrapper to unset $KUBECONFIG.
+If $KUBECONFIG has been set before konf took over, it is restored instead. 'konf set -' still
+brings back the latest konf afterwards.
+`,
+		Args: cobra.NoArgs,
+		RunE: uc.unset,
+	}
+
+	return uc
+}
+
+func (c *unsetCmd) unset(cmd *cobra.Command, args []string) error {
+	path := utils.ActivePathForPID(os.Getppid())
+	// a broken active file should not prevent the user from getting rid of it
+	id, _ := activeKonfID(c.fs)
+
+	// just like on cleanup, 'konf set -' should restore the konf as it has last been used
+	if err := snapshotLatestState(c.fs, path); err != nil {
+		log.Warn("could not keep the state of the latest konf: %v\n", err)
+	}
+	err := c.fs.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if id != "" {
+		log.Info("Unset konf %q\n", id)
+		printPreviousKonf(c.fs, id)
+	} else {
+		log.Info("No konf is active in this shell\n")
+	}
+	printKonfUnset()
+	return nil
+}
+
+// printKonfUnset instructs the shellwrapper to unset $KUBECONFIG. Just like KUBECONFIGCHANGE, the convention
+// "KUBECONFIGUNSET" is shared with shellwrapper.go
+func printKonfUnset() {
+	fmt.Println("KUBECONFIGUNSET")
+}
+
+func init() {
+	rootCmd.AddCommand(newUnsetCmd().cmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+)
+
+func TestUnset(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		activeKonf bool
+	}{
+		"active konf":    {true},
+		"no active konf": {false},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := testhelper.FSWithFiles(fm.StoreDir, fm.ActiveDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA)
+			path := utils.ActivePathForPID(os.Getppid())
+			if tc.activeKonf {
+				sc := newSetCommand()
+				sc.fs = f
+				err := sc.cmd.RunE(sc.cmd, []string{"dev-eu_dev-eu-1"})
+				if err != nil {
+					t.Fatalf("Could not set konf: %q", err)
+				}
+			}
+
+			uc := newUnsetCmd()
+			uc.fs = f
+			err := uc.cmd.RunE(uc.cmd, []string{})
+			if err != nil {
+				t.Fatalf("Exp no error, got %q", err)
+			}
+			if _, err := f.Stat(path); err == nil {
+				t.Errorf("Exp active konf %q to be removed", path)
+			}
+			if !tc.activeKonf {
+				return
+			}
+
+			// the konf that has been unset is still the latest one
+			sc := newSetCommand()
+			sc.fs = f
+			err = sc.cmd.RunE(sc.cmd, []string{"-"})
+			if err != nil {
+				t.Fatalf("Exp 'set -' to work after unset, got %q", err)
+			}
+			id, err := activeKonfID(f)
+			if err != nil || id != "dev-eu_dev-eu-1" {
+				t.Errorf("Exp konf %q to be active again, got %q (%v)", "dev-eu_dev-eu-1", id, err)
+			}
+		})
+	}
+}
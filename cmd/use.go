@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// dirKonfFile is the name of the file, which pins a konf to a directory and all of its subdirectories
+const dirKonfFile = ".konf"
+
+type useCmd struct {
+	fs afero.Fs
+
+	getwd func() (string, error)
+
+	cmd *cobra.Command
+}
+
+func newUseCmd() *useCmd {
+	uc := &useCmd{
+		fs:    utils.NewFs(),
+		getwd: os.Getwd,
+	}
+
+	uc.cmd = &cobra.Command{
+		Use:   "use",
+		Short: "Set the konf pinned to the current directory",
+		Long: `Set the konf pinned to the current directory
+
+Similar to .nvmrc or .tool-versions, a project can pin a konf by placing a .konf file in its directory,
+which contains the id or alias of the konf. 'konf use' looks for the file in the current directory and
+all of its parents and sets the konf of the closest one, just like 'konf set <konfig id>' would.
+
+Examples:
+	-> 'echo dev-eu_dev-eu-1 > .konf' pin a konf to the current directory
+	-> 'use' set the konf pinned to the current directory
+`,
+		Args: cobra.NoArgs,
+		RunE: uc.use,
+	}
+
+	return uc
+}
+
+func (c *useCmd) use(cmd *cobra.Command, args []string) error {
+	wd, err := c.getwd()
+	if err != nil {
+		return err
+	}
+	id, path, err := findDirKonf(c.fs, wd)
+	if err != nil {
+		return err
+	}
+
+	id, err = resolveAlias(c.fs, id)
+	if err != nil {
+		return err
+	}
+	// set would offer similar konfs instead, which hides that the file itself needs fixing
+	if _, err := c.fs.Stat(utils.ResolveStorePath(c.fs, id)); err != nil {
+		return fmt.Errorf("%q pins the konf %q, which is not in the store", path, id)
+	}
+
+	sc := newSetCommand()
+	sc.fs = c.fs
+	return sc.set(sc.cmd, []string{id})
+}
+
+// findDirKonf walks up from dir until it finds a .konf file and returns the konf id it contains together with its path.
+// Empty lines and lines starting with # are ignored
+func findDirKonf(f afero.Fs, dir string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		path := filepath.Join(dir, dirKonfFile)
+		b, err := afero.ReadFile(f, path)
+		if err == nil {
+			for _, line := range strings.Split(string(b), "\n") {
+				line = strings.TrimSpace(line)
+				if line != "" && !strings.HasPrefix(line, "#") {
+					return line, path, nil
+				}
+			}
+			return "", "", fmt.Errorf("%q does not contain a konf id", path)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", fmt.Errorf("could not find a %s file in the current directory or any of its parents", dirKonfFile)
+		}
+		dir = parent
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(newUseCmd().cmd)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+)
+
+func TestUse(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+	dirKonf := func(path, content string) func(afero.Fs) {
+		return func(f afero.Fs) {
+			afero.WriteFile(f, path, []byte(content), utils.KonfPerm)
+		}
+	}
+
+	tt := map[string]struct {
+		fs     afero.Fs
+		wd     string
+		expID  string
+		expErr error
+	}{
+		"current directory": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, dirKonf("/project/.konf", "dev-eu_dev-eu-1\n")),
+			"/project",
+			"dev-eu_dev-eu-1",
+			nil,
+		},
+		"parent directory": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, dirKonf("/project/.konf", "dev-eu_dev-eu-1\n")),
+			"/project/deploy/prod",
+			"dev-eu_dev-eu-1",
+			nil,
+		},
+		"closest file wins": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA, dirKonf("/project/.konf", "dev-eu_dev-eu-1\n"), dirKonf("/project/asia/.konf", "dev-asia_dev-asia-1\n")),
+			"/project/asia",
+			"dev-asia_dev-asia-1",
+			nil,
+		},
+		"comments and alias": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, metadataFile("aliases:\n  europe: dev-eu_dev-eu-1\n"), dirKonf("/project/.konf", "# the cluster of this project\n\neurope\n")),
+			"/project",
+			"dev-eu_dev-eu-1",
+			nil,
+		},
+		"konf not in store": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, dirKonf("/project/.konf", "dev-us_dev-us-1\n")),
+			"/project",
+			"",
+			fmt.Errorf("\"/project/.konf\" pins the konf \"dev-us_dev-us-1\", which is not in the store"),
+		},
+		"empty file": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, dirKonf("/project/.konf", "\n")),
+			"/project",
+			"",
+			fmt.Errorf("\"/project/.konf\" does not contain a konf id"),
+		},
+		"no file": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			"/project",
+			"",
+			fmt.Errorf("could not find a .konf file in the current directory or any of its parents"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			uc := newUseCmd()
+			uc.fs = tc.fs
+			uc.getwd = func() (string, error) { return tc.wd, nil }
+
+			err := uc.cmd.RunE(uc.cmd, []string{})
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			id, err := activeKonfIDFromFile(tc.fs, utils.ActivePathForPID(os.Getppid()))
+			if err != nil {
+				t.Fatalf("Could not read active konf: %q", err)
+			}
+			if id != tc.expID {
+				t.Errorf("Exp konf %q to be set, got %q", tc.expID, id)
+			}
+		})
+	}
+}
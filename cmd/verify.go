@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/simontheleg/konf-go/config"
+	log "github.com/simontheleg/konf-go/log"
+	"github.com/simontheleg/konf-go/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// verifyMaxAge is the age after which a verify result is not shown anymore
+const verifyMaxAge = 24 * time.Hour
+
+// status glyphs for the result of the last verify of a konf
+const (
+	statusHealthy   = "✓"
+	statusUnhealthy = "✗"
+	statusUnknown   = "?"
+)
+
+type verifyCmd struct {
+	fs afero.Fs
+
+	check func([]byte, time.Duration) error
+
+	cmd *cobra.Command
+}
+
+func newVerifyCmd() *verifyCmd {
+	vc := &verifyCmd{
+		fs:    utils.NewFs(),
+		check: checkHealthy,
+	}
+
+	vc.cmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Check whether the clusters of konfs are healthy",
+		Long: `Check whether the clusters of konfs are healthy
+
+Each konf is checked once against the readiness endpoint of its API server. The results are kept
+and shown as status in the picker and 'konf ls' for a day.
+
+Examples:
+	-> 'verify' verify all konfs in the store
+	-> 'verify <konfig id> [<konfig id>...]' verify specific konfs
+`,
+		RunE:              vc.verify,
+		ValidArgsFunction: vc.completeVerify,
+	}
+
+	return vc
+}
+
+func (c *verifyCmd) verify(cmd *cobra.Command, args []string) error {
+	ids := args
+	if len(ids) == 0 {
+		konfs, err := fetchKonfs(c.fs)
+		if err != nil {
+			return err
+		}
+		for _, k := range konfs {
+			ids = append(ids, k.ID())
+		}
+	}
+
+	m, err := loadMetadata(c.fs)
+	if err != nil {
+		return err
+	}
+
+	unhealthy := 0
+	for _, id := range ids {
+		healthy, err := verifyKonf(c.fs, m, id, c.check)
+		if err != nil {
+			return err
+		}
+		if !healthy {
+			unhealthy++
+		}
+	}
+
+	err = saveMetadata(c.fs, m)
+	if err != nil {
+		return err
+	}
+
+	if unhealthy > 0 {
+		return fmt.Errorf("%d of %d konfs are not healthy", unhealthy, len(ids))
+	}
+	return nil
+}
+
+// verifyKonf checks whether the cluster of the konf with the supplied id is healthy and records the result in m
+func verifyKonf(f afero.Fs, m *metadata, id string, check func([]byte, time.Duration) error) (bool, error) {
+	konf, err := readValidKonf(f, id)
+	if err != nil {
+		return false, err
+	}
+
+	err = check(konf, config.Timeout())
+	m.konf(id).Verified = &verifyResult{Healthy: err == nil, At: clock.Now()}
+	if err != nil {
+		log.Warn("Konf %q is not healthy: %v\n", id, err)
+		return false, nil
+	}
+	log.Info("Konf %q is healthy\n", id)
+	return true, nil
+}
+
+func (c *verifyCmd) completeVerify(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	konfs, err := fetchKonfs(c.fs)
+	if err != nil {
+		if errors.Is(err, &EmptyStore{}) {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		cobra.CompDebugln(err.Error(), true)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	sug := []string{}
+	for _, konf := range konfs {
+		sug = append(sug, konf.ID())
+	}
+
+	return sug, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(newVerifyCmd().cmd)
+}
+
+// verifyKonfs checks whether the cluster of each konf in confs is healthy, just like 'konf verify' does, and returns
+// the result for each of them
+func verifyKonfs(confs []*konfFile, check func([]byte, time.Duration) error) []bool {
+	healthy := []bool{}
+	for _, conf := range confs {
+		konf, err := yaml.Marshal(conf.Content)
+		if err == nil {
+			err = check(konf, config.Timeout())
+		}
+		healthy = append(healthy, err == nil)
+		if err != nil {
+			log.Warn("Konf %q is not reachable: %v\n", conf.ID(), err)
+			continue
+		}
+		log.Info("Konf %q is healthy\n", conf.ID())
+	}
+	return healthy
+}
+
+// reachableKonfs returns only those konfs of confs, that are healthy according to verifyKonfs
+func reachableKonfs(confs []*konfFile, healthy []bool) ([]*konfFile, []bool) {
+	reachable, stillHealthy := []*konfFile{}, []bool{}
+	for i, conf := range confs {
+		if !healthy[i] {
+			log.Info("Skipping konf %q, as its cluster is not reachable\n", conf.ID())
+			continue
+		}
+		reachable = append(reachable, conf)
+		stillHealthy = append(stillHealthy, true)
+	}
+	return reachable, stillHealthy
+}
+
+// recordVerified keeps the result of a check for each konf id in verified, so it shows up just like the result of
+// 'konf verify'
+func recordVerified(f afero.Fs, verified map[string]bool, at time.Time) error {
+	m, err := loadMetadata(f)
+	if err != nil {
+		return err
+	}
+	for id, healthy := range verified {
+		m.konf(id).Verified = &verifyResult{Healthy: healthy, At: at}
+	}
+	return saveMetadata(f, m)
+}
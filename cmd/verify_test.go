@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/simontheleg/konf-go/testhelper"
+	"github.com/spf13/afero"
+)
+
+func TestVerify(t *testing.T) {
+	fm := testhelper.FilesystemManager{}
+
+	tt := map[string]struct {
+		fs         afero.Fs
+		args       []string
+		expHealthy map[string]bool
+		expErr     error
+	}{
+		"all konfs": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			[]string{},
+			map[string]bool{"dev-eu_dev-eu-1": true, "dev-asia_dev-asia-1": false},
+			fmt.Errorf("1 of 2 konfs are not healthy"),
+		},
+		"single konf": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU, fm.SingleClusterSingleContextASIA),
+			[]string{"dev-eu_dev-eu-1"},
+			map[string]bool{"dev-eu_dev-eu-1": true},
+			nil,
+		},
+		"unknown konf": {
+			testhelper.FSWithFiles(fm.StoreDir, fm.SingleClusterSingleContextEU),
+			[]string{"no-konf"},
+			map[string]bool{},
+			&KonfNotFound{ID: "no-konf"},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			vc := newVerifyCmd()
+			vc.fs = tc.fs
+			vc.check = func(konf []byte, timeout time.Duration) error {
+				conf := parseKonf(t, konf)
+				if conf.Contexts[0].Name == "dev-asia" {
+					return fmt.Errorf("connection refused")
+				}
+				return nil
+			}
+
+			err := vc.cmd.RunE(vc.cmd, tc.args)
+			if !testhelper.EqualError(err, tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+
+			m, err := loadMetadata(tc.fs)
+			if err != nil {
+				t.Fatalf("Could not load metadata: %q", err)
+			}
+			verified := 0
+			for id, km := range m.Konfs {
+				if km.Verified == nil {
+					continue
+				}
+				verified++
+				if km.Verified.Healthy != tc.expHealthy[id] {
+					t.Errorf("Exp konf %q to be healthy: %t, got %t", id, tc.expHealthy[id], km.Verified.Healthy)
+				}
+			}
+			if verified != len(tc.expHealthy) {
+				t.Errorf("Exp %d verified konfs, got %d", len(tc.expHealthy), verified)
+			}
+		})
+	}
+}
+
+func TestKonfStatus(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tt := map[string]struct {
+		res *verifyResult
+		exp string
+	}{
+		"never verified": {nil, statusUnknown},
+		"healthy":        {&verifyResult{Healthy: true, At: now.Add(-time.Hour)}, statusHealthy},
+		"unhealthy":      {&verifyResult{Healthy: false, At: now.Add(-time.Hour)}, statusUnhealthy},
+		"stale":          {&verifyResult{Healthy: true, At: now.Add(-verifyMaxAge - time.Minute)}, statusUnknown},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res := konfStatus(tc.res, now)
+			if res != tc.exp {
+				t.Errorf("Exp status %q, got %q", tc.exp, res)
+			}
+		})
+	}
+}
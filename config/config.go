@@ -1,22 +1,73 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"regexp"
+	"time"
+
+	"sigs.k8s.io/yaml"
 )
 
 var curConf *Config
 
 // Config describes all values that can currently be configured for konf
 type Config struct {
-	KonfDir string
-	Silent  bool
+	KonfDir string `json:"konfDir,omitempty"`
+	Silent  bool   `json:"silent,omitempty"`
+	// Timeout is read from config files as a duration string like "30s". See LoadFile
+	Timeout         time.Duration `json:"-"`
+	ColumnsMaxWidth int           `json:"trunc,omitempty"`
+	Stateless       bool          `json:"stateless,omitempty"`
+	// ContextDisplayRegex and ContextDisplayReplace describe a regex replacement, which is applied to context names
+	// before they are displayed. It never affects the ids of konfs
+	ContextDisplayRegex   string `json:"contextDisplayRegex,omitempty"`
+	ContextDisplayReplace string `json:"contextDisplayReplace,omitempty"`
+	// contextDisplayRe is ContextDisplayRegex compiled, which happens once when the config is initialized
+	contextDisplayRe *regexp.Regexp
+	// StoreBackend is the name of the filesystem that holds the store
+	StoreBackend string `json:"storeBackend,omitempty"`
+	// SharedStoreDirs are additional store directories, e.g. the store of a team. konf only reads from them
+	SharedStoreDirs []string `json:"sharedStoreDirs,omitempty"`
+	// DirectStore makes 'konf set' point $KUBECONFIG directly at the store instead of a copy of the konf
+	DirectStore bool `json:"directStore,omitempty"`
+	// OIDCLogin makes 'konf set' log in to the OIDC provider of a konf, if its credentials have expired
+	OIDCLogin bool `json:"oidcLogin,omitempty"`
+	// CheckContext makes 'konf set' load the active konf like kubectl does and compare the contexts afterwards
+	CheckContext bool `json:"checkContext,omitempty"`
+	// StrictStore makes 'konf set' fail on konfs spanning multiple clusters instead of letting the user pick a context
+	StrictStore bool `json:"strictStore,omitempty"`
+	// Picker is the name of the program that is used to pick konfs. Either "prompt" or "fzf"
+	Picker string `json:"picker,omitempty"`
+	// GroupByCluster makes the built-in picker list konfs below a header line for their cluster
+	GroupByCluster bool `json:"groupByCluster,omitempty"`
+	// Encrypt makes konf encrypt konfs at rest when writing them to the store
+	Encrypt bool `json:"encrypt,omitempty"`
+	// PassphraseCommand is run by the shell to retrieve the passphrase for encrypted konfs, e.g. from a keychain.
+	// $KONF_PASSPHRASE takes precedence over it
+	PassphraseCommand string `json:"passphraseCommand,omitempty"`
+	// ActiveFileTemplate is a Go template for the file name of the active konf of a shell, without the .yaml extension.
+	// It supports .PID, .User and .Host and has to contain .PID
+	ActiveFileTemplate string `json:"activeFileTemplate,omitempty"`
+	// SafeModeServerRegex and SafeModeLabels describe konfs that are protected by safe mode. Setting a konf whose
+	// server matches the regex, or which carries any of the labels in the form of key=value, has to be confirmed by
+	// typing its context name
+	SafeModeServerRegex string   `json:"safeModeServerRegex,omitempty"`
+	SafeModeLabels      []string `json:"safeModeLabels,omitempty"`
+	// IDFormat is a Go template for the ids of konfs, which also name their files in the store. It supports .Context
+	// and .Cluster and defaults to "{{.Context}}_{{.Cluster}}". Existing konfs have to be renamed via 'konf store migrate'
+	IDFormat string `json:"idFormat,omitempty"`
 }
 
 // This is mainly used to provide some sane and lively defaults for unit tests
 // TODO with the new config system in place, it might make sense to rework all the test-cases and remove the "./konf" reference
 func init() {
 	curConf = &Config{
-		KonfDir: "./konf",
+		KonfDir:         "./konf",
+		Timeout:         10 * time.Second,
+		ColumnsMaxWidth: 25,
+		StoreBackend:    "os",
+		Picker:          "prompt",
 	}
 }
 
@@ -31,15 +82,72 @@ func ConfFromHomeDir() (*Config, error) {
 
 	c.KonfDir = home + "/.kube/konfs"
 	c.Silent = false
+	c.Timeout = 10 * time.Second
+	c.ColumnsMaxWidth = 25
+	c.StoreBackend = "os"
+	c.Picker = "prompt"
 
 	return c, nil
 }
 
-// InitWithOverrides sets the config to the config supplied as its argument
+// LoadFile overrides all values of c that are set in the config file at path
+func (c *Config) LoadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// time.Duration would only be read as nanoseconds, which is why the timeout is parsed from a string
+	fc := struct {
+		*Config
+		Timeout string `json:"timeout,omitempty"`
+	}{Config: c}
+	err = yaml.UnmarshalStrict(b, &fc)
+	if err != nil {
+		return err
+	}
+	if fc.Timeout != "" {
+		c.Timeout, err = time.ParseDuration(fc.Timeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Compile validates and compiles all regular expressions of c, so they do not have to be compiled on every use
+func (c *Config) Compile() error {
+	c.contextDisplayRe = nil
+	if c.ContextDisplayRegex == "" {
+		return nil
+	}
+	re, err := regexp.Compile(c.ContextDisplayRegex)
+	if err != nil {
+		return fmt.Errorf("invalid contextDisplayRegex %q: %v", c.ContextDisplayRegex, err)
+	}
+	c.contextDisplayRe = re
+	return nil
+}
+
+// InitWithOverrides sets the config to the config supplied as its argument. Regular expressions that have not been
+// validated via Compile before and turn out to be invalid are ignored
 func InitWithOverrides(or *Config) {
+	or.Compile()
 	curConf = or
 }
 
+// Override applies modify to a copy of the current config, which becomes the current config afterwards. The returned
+// func restores the previous config. It is meant for tests, that only need to change a few values
+func Override(modify func(*Config)) (restore func()) {
+	prev := curConf
+	c := *curConf
+	modify(&c)
+	c.Compile()
+	curConf = &c
+	return func() { curConf = prev }
+}
+
 // Currently there is no need to customize store and active configs individually.
 // Setting the konfDir should be enough
 
@@ -53,7 +161,128 @@ func StoreDir() string {
 	return curConf.KonfDir + "/store"
 }
 
+// StoreDirs returns all store directories in the order konfs are looked up in. The own store always comes first, so
+// its konfs take precedence over konfs with the same id in any of the shared stores
+func StoreDirs() []string {
+	return append([]string{StoreDir()}, curConf.SharedStoreDirs...)
+}
+
+// MetadataFile returns the currently configured file for storing additional information on konfs
+func MetadataFile() string {
+	return curConf.KonfDir + "/metadata.yaml"
+}
+
 // LatestKonfFile returns the currently configured latest konf file
 func LatestKonfFile() string {
 	return curConf.KonfDir + "/latestkonf"
 }
+
+// LatestStateFile returns the currently configured file, which keeps the active konf of the latest konf after its
+// shell has been closed
+func LatestStateFile() string {
+	return curConf.KonfDir + "/lateststate.yaml"
+}
+
+// RecordFile returns the currently configured file, which keeps the switches recorded via 'konf set --record'
+func RecordFile() string {
+	return curConf.KonfDir + "/records.jsonl"
+}
+
+// HistoryFile returns the currently configured file, which keeps the ids of previously set konfs
+func HistoryFile() string {
+	return curConf.KonfDir + "/history"
+}
+
+// SaltFile returns the currently configured file, which holds the salt for deriving the key of encrypted konfs
+func SaltFile() string {
+	return curConf.KonfDir + "/store.salt"
+}
+
+// SocketFile returns the currently configured unix socket 'konf serve' listens on
+func SocketFile() string {
+	return curConf.KonfDir + "/konf.sock"
+}
+
+// Timeout returns the currently configured timeout for any operation that has to wait on something outside of konf
+func Timeout() time.Duration {
+	return curConf.Timeout
+}
+
+// ColumnsMaxWidth returns the currently configured maximum width of a column in table outputs
+func ColumnsMaxWidth() int {
+	return curConf.ColumnsMaxWidth
+}
+
+// Stateless returns whether konf should refrain from writing any state besides the active konf of a shell
+func Stateless() bool {
+	return curConf.Stateless
+}
+
+// ContextDisplayTransform returns the currently configured regex and its replacement for displaying context names.
+// The regex is nil if no transform is configured
+func ContextDisplayTransform() (*regexp.Regexp, string) {
+	return curConf.contextDisplayRe, curConf.ContextDisplayReplace
+}
+
+// DirectStore returns whether konfs are used directly from the store instead of being copied for each shell
+func DirectStore() bool {
+	return curConf.DirectStore
+}
+
+// OIDCLogin returns whether 'konf set' should log in to the OIDC provider of a konf right away
+func OIDCLogin() bool {
+	return curConf.OIDCLogin
+}
+
+// CheckContext returns whether 'konf set' should make sure kubectl agrees on the context of the konf it has set
+func CheckContext() bool {
+	return curConf.CheckContext
+}
+
+// StrictStore returns whether 'konf set' should fail on konfs that span multiple clusters
+func StrictStore() bool {
+	return curConf.StrictStore
+}
+
+// Picker returns the name of the currently configured program for picking konfs
+func Picker() string {
+	return curConf.Picker
+}
+
+// IDFormat returns the template for the ids of konfs. An empty string means the default format
+func IDFormat() string {
+	return curConf.IDFormat
+}
+
+// GroupByCluster returns whether the built-in picker groups konfs by their cluster
+func GroupByCluster() bool {
+	return curConf.GroupByCluster
+}
+
+// StoreBackend returns the name of the currently configured filesystem for the store
+func StoreBackend() string {
+	return curConf.StoreBackend
+}
+
+// Encrypt returns whether konfs should be encrypted when writing them to the store
+func Encrypt() bool {
+	return curConf.Encrypt
+}
+
+// PassphraseCommand returns the currently configured command for retrieving the passphrase of encrypted konfs
+func PassphraseCommand() string {
+	return curConf.PassphraseCommand
+}
+
+// ActiveFileTemplate returns the currently configured template for the file names of active konfs
+func ActiveFileTemplate() string {
+	if curConf.ActiveFileTemplate == "" {
+		return "{{.PID}}"
+	}
+	return curConf.ActiveFileTemplate
+}
+
+// SafeMode returns the currently configured server regex and labels of konfs that are protected by safe mode
+func SafeMode() (string, []string) {
+	return curConf.SafeModeServerRegex, curConf.SafeModeLabels
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestLoadFile(t *testing.T) {
+	tt := map[string]struct {
+		content string
+		exp     *Config
+		expErr  bool
+	}{
+		"overrides set values": {
+			"trunc: 40\ntimeout: 30s\n",
+			&Config{KonfDir: "/konfs", Timeout: 30 * time.Second, ColumnsMaxWidth: 40, StoreBackend: "os"},
+			false,
+		},
+		"empty file keeps defaults": {
+			"",
+			&Config{KonfDir: "/konfs", Timeout: 10 * time.Second, ColumnsMaxWidth: 25, StoreBackend: "os"},
+			false,
+		},
+		"unknown key": {
+			"truncate: 40\n",
+			nil,
+			true,
+		},
+		"invalid timeout": {
+			"timeout: forever\n",
+			nil,
+			true,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			err := os.WriteFile(path, []byte(tc.content), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := &Config{KonfDir: "/konfs", Timeout: 10 * time.Second, ColumnsMaxWidth: 25, StoreBackend: "os"}
+			err = c.LoadFile(path)
+			if (err != nil) != tc.expErr {
+				t.Fatalf("Exp error to be %t, got %v", tc.expErr, err)
+			}
+			if tc.expErr {
+				return
+			}
+			// compiled regexes are only set by Compile
+			if !cmp.Equal(c, tc.exp, cmpopts.IgnoreUnexported(Config{})) {
+				t.Errorf("Exp and given configs differ: \n '%s'", cmp.Diff(tc.exp, c, cmpopts.IgnoreUnexported(Config{})))
+			}
+		})
+	}
+}
+
+func TestLoadFileMissing(t *testing.T) {
+	c := &Config{}
+	err := c.LoadFile(filepath.Join(t.TempDir(), "does-not-exist.yaml"))
+	if !os.IsNotExist(err) {
+		t.Errorf("Exp a not exist error, got %v", err)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	prev := curConf
+	restore := Override(func(c *Config) { c.ColumnsMaxWidth = 40 })
+
+	if ColumnsMaxWidth() != 40 {
+		t.Errorf("Exp the override to be applied, got %d", ColumnsMaxWidth())
+	}
+	if KonfDir := curConf.KonfDir; KonfDir != prev.KonfDir {
+		t.Errorf("Exp all other values to be kept, got konfDir %q", KonfDir)
+	}
+	if prev.ColumnsMaxWidth == 40 {
+		t.Errorf("Exp the previous config not to be modified")
+	}
+
+	restore()
+	if curConf != prev {
+		t.Errorf("Exp the previous config to be restored")
+	}
+}
+
+func TestCompile(t *testing.T) {
+	c := &Config{ContextDisplayRegex: "^gke_(.*)$"}
+	err := c.Compile()
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	if c.contextDisplayRe == nil || c.contextDisplayRe.String() != "^gke_(.*)$" {
+		t.Errorf("Exp the display regex to be compiled, got %v", c.contextDisplayRe)
+	}
+
+	c.ContextDisplayRegex = "^gke_("
+	err = c.Compile()
+	if err == nil {
+		t.Errorf("Exp an error for an invalid regex")
+	}
+	if c.contextDisplayRe != nil {
+		t.Errorf("Exp no display regex for an invalid regex, got %v", c.contextDisplayRe)
+	}
+}
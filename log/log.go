@@ -8,6 +8,7 @@ import (
 
 var infoL *log.Logger
 var warnL *log.Logger
+var debugL = log.New(io.Discard, "DEBUG: ", 0)
 
 // InitLogger initializes a new logger
 // Initialization must be done, before logging funcs can be called
@@ -26,6 +27,16 @@ func Warn(format string, v ...interface{}) {
 	warnL.Printf(format, v...)
 }
 
+// EnableDebug makes the Debug logger print to w. Debug output is discarded until then
+func EnableDebug(w io.Writer) {
+	debugL = log.New(w, "DEBUG: ", 0)
+}
+
+// Debug prints the supplied format string using the Debug logger
+func Debug(format string, v ...interface{}) {
+	debugL.Printf(format, v...)
+}
+
 func init() {
 	InitLogger(os.Stderr, os.Stderr)
 }
@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/manifoldco/promptui"
@@ -15,7 +16,38 @@ type RunFunc func(*promptui.Select) (int, error)
 func Terminal(prompt *promptui.Select) (sel int, err error) {
 	pos, _, err := prompt.Run()
 	if err != nil {
-		return -1, fmt.Errorf("prompt failed %v", err)
+		return -1, fmt.Errorf("prompt failed %w", err)
 	}
 	return pos, nil
 }
+
+// ConfirmFunc describes a generic function of a yes/no prompt. It returns whether the user agreed.
+// Its main purpose is to be easily mockable for unit-tests
+type ConfirmFunc func(*promptui.Prompt) (bool, error)
+
+// TerminalConfirm runs a given confirmation prompt in the terminal of the user and
+// returns whether the user has confirmed
+func TerminalConfirm(prompt *promptui.Prompt) (bool, error) {
+	_, err := prompt.Run()
+	if err != nil {
+		// promptui signals a declined confirmation via ErrAbort, which is a valid answer for us
+		if errors.Is(err, promptui.ErrAbort) {
+			return false, nil
+		}
+		return false, fmt.Errorf("prompt failed %v", err)
+	}
+	return true, nil
+}
+
+// InputFunc describes a generic function of a prompt for free text. It returns the text the user entered.
+// Its main purpose is to be easily mockable for unit-tests
+type InputFunc func(*promptui.Prompt) (string, error)
+
+// TerminalInput runs a given text prompt in the terminal of the user and returns the entered text
+func TerminalInput(prompt *promptui.Prompt) (string, error) {
+	res, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("prompt failed %v", err)
+	}
+	return res, nil
+}
@@ -23,13 +23,49 @@ source <(konf-go shellwrapper ${shell})
 
 KONFDIR=$(mktemp -d)
 mkdir ${KONFDIR}/store
-KONF=${KONFDIR}/store/test_test.yaml
-touch ${KONF}
+for id in test other
+do
+  cat > ${KONFDIR}/store/${id}_${id}.yaml <<EOF
+apiVersion: v1
+clusters:
+  - cluster:
+      server: https://10.1.1.0
+    name: ${id}
+contexts:
+  - context:
+      cluster: ${id}
+      user: ${id}
+    name: ${id}
+current-context: ${id}
+kind: Config
+users:
+  - name: ${id}
+    user: {}
+EOF
+done
 konf --konf-dir=${KONFDIR} set test_test
 
 # if kubeconfig points to something in the active
 if [[ $KUBECONFIG != "${KONFDIR}/active"* ]]; then
   echo "Expected KUBECONFIG to point to a file inside '${KONFDIR}/active', but got '${KUBECONFIG}'"
+  exit 1
+fi
+
+echo "KUBECONFIG points to '${KUBECONFIG}', which looks fine"
+
+# file descriptors of the shell must survive a switch
+exec 3>"${KONFDIR}/fd3"
+konf --konf-dir=${KONFDIR} set other_other
+echo "still open" >&3
+exec 3>&-
+if [[ $(cat "${KONFDIR}/fd3") != "still open" ]]; then
+  echo "Expected fd 3 of the shell to be left untouched by konf"
+  exit 1
+fi
+
+if [[ $KONF_PREVIOUS != "${KONFDIR}/store/test_test.yaml" ]]; then
+  echo "Expected KONF_PREVIOUS to point to '${KONFDIR}/store/test_test.yaml', but got '${KONF_PREVIOUS}'"
+  exit 1
 fi
 
-echo "KUBECONFIG points to '${KUBECONFIG}', which looks fine"
\ No newline at end of file
+echo "KONF_PREVIOUS points to '${KONF_PREVIOUS}', which looks fine"
\ No newline at end of file
@@ -1,6 +1,10 @@
 package testhelper
 
 import (
+	"sync"
+	"testing"
+	"time"
+
 	"github.com/simontheleg/konf-go/config"
 	"github.com/simontheleg/konf-go/utils"
 	"github.com/spf13/afero"
@@ -8,12 +12,52 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// OverrideConfig applies modify to a copy of the current config for the duration of the test t. The previous config
+// is restored once the test has finished
+func OverrideConfig(t *testing.T, modify func(*config.Config)) {
+	t.Cleanup(config.Override(modify))
+}
+
 // EqualError reports whether errors a and b are considered equal.
 // They're equal if both are nil, or both are not nil and a.Error() == b.Error().
 func EqualError(a, b error) bool {
 	return a == nil && b == nil || a != nil && b != nil && a.Error() == b.Error()
 }
 
+// FakeClock is a utils.Clock that only moves on when it is advanced. It can be used from multiple goroutines
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock that starts at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// After advances the clock by d right away and returns a channel that already holds the new time, so code waiting on
+// the clock does not actually sleep
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.Advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
 type filefunc = func(afero.Fs)
 
 // FSWithFiles is a testhelper that can be used to quickly setup a MemMapFs with required Files
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/simontheleg/konf-go/config"
+)
+
+// activeFileData contains all fields that can be used in the activeFileTemplate
+type activeFileData struct {
+	PID  string
+	User string
+	Host string
+}
+
+// pidMarker replaces the pid when rendering a template in order to find out where the pid ends up in file names
+const pidMarker = "\x00"
+
+func renderActiveFileName(tmpl, pid string) (string, error) {
+	t, err := template.New("activeFileTemplate").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	data := activeFileData{PID: pid}
+	if u, err := user.Current(); err == nil {
+		data.User = u.Username
+	}
+	data.Host, _ = os.Hostname()
+
+	var sb strings.Builder
+	err = t.Execute(&sb, data)
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// ValidateActiveFileTemplate makes sure that tmpl results in a valid file name inside the activeDir, which is
+// unique for each shell
+func ValidateActiveFileTemplate(tmpl string) error {
+	a, err := renderActiveFileName(tmpl, "1")
+	if err != nil {
+		return fmt.Errorf("invalid activeFileTemplate %q: %v", tmpl, err)
+	}
+	b, err := renderActiveFileName(tmpl, "2")
+	if err != nil {
+		return fmt.Errorf("invalid activeFileTemplate %q: %v", tmpl, err)
+	}
+	if a == b {
+		return fmt.Errorf("invalid activeFileTemplate %q: it has to contain {{.PID}}, so shells do not share their active konf", tmpl)
+	}
+	if strings.ContainsAny(a, `/\`) || strings.HasPrefix(a, ".") {
+		return fmt.Errorf("invalid activeFileTemplate %q: it has to result in a plain file name inside the active dir", tmpl)
+	}
+	return nil
+}
+
+// ActivePathForPID returns the path of the active konf of the shell with the supplied pid. Its name is
+// determined by the configured activeFileTemplate
+func ActivePathForPID(pid int) string {
+	name, err := renderActiveFileName(config.ActiveFileTemplate(), fmt.Sprint(pid))
+	// the template is validated when the config is loaded, so this only guards against a broken environment
+	if err != nil {
+		name = fmt.Sprint(pid)
+	}
+	return ActivePathForID(name)
+}
+
+// PIDFromActiveFile returns the pid of the shell an active konf with the supplied file name belongs to. It
+// reverses ActivePathForPID and returns false for files that have not been created by it
+func PIDFromActiveFile(name string) (int, bool) {
+	rendered, err := renderActiveFileName(config.ActiveFileTemplate(), pidMarker)
+	if err != nil {
+		return 0, false
+	}
+
+	parts := strings.Split(rendered, pidMarker)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, `(\d+)`) + regexp.QuoteMeta(".yaml") + "$")
+	if err != nil {
+		return 0, false
+	}
+
+	m := re.FindStringSubmatch(name)
+	if m == nil {
+		return 0, false
+	}
+	// templates might contain the pid multiple times, which all have to match
+	for _, p := range m[2:] {
+		if p != m[1] {
+			return 0, false
+		}
+	}
+	pid, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return pid, true
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/simontheleg/konf-go/config"
+)
+
+func TestValidateActiveFileTemplate(t *testing.T) {
+	tt := map[string]struct {
+		tmpl   string
+		expErr error
+	}{
+		"pid only":           {"{{.PID}}", nil},
+		"with user and host": {"{{.User}}@{{.Host}}-{{.PID}}", nil},
+		"without pid": {
+			"{{.User}}",
+			fmt.Errorf("invalid activeFileTemplate \"{{.User}}\": it has to contain {{.PID}}, so shells do not share their active konf"),
+		},
+		"with directory": {
+			"shells/{{.PID}}",
+			fmt.Errorf("invalid activeFileTemplate \"shells/{{.PID}}\": it has to result in a plain file name inside the active dir"),
+		},
+		"unknown field": {
+			"{{.Shell}}-{{.PID}}",
+			fmt.Errorf("invalid activeFileTemplate \"{{.Shell}}-{{.PID}}\": template: activeFileTemplate:1:2: executing \"activeFileTemplate\" at <.Shell>: can't evaluate field Shell in type utils.activeFileData"),
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateActiveFileTemplate(tc.tmpl)
+			if fmt.Sprint(err) != fmt.Sprint(tc.expErr) {
+				t.Errorf("Exp err %q, got %q", tc.expErr, err)
+			}
+		})
+	}
+}
+
+func TestActivePathForPID(t *testing.T) {
+	tt := map[string]struct {
+		tmpl    string
+		expPath string
+	}{
+		"default": {"", "./konf/active/1234.yaml"},
+		"custom":  {"shell-{{.PID}}-{{.PID}}", "./konf/active/shell-1234-1234.yaml"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			t.Cleanup(config.Override(func(c *config.Config) { c.ActiveFileTemplate = tc.tmpl }))
+
+			path := ActivePathForPID(1234)
+			if path != tc.expPath {
+				t.Errorf("Exp path %q, got %q", tc.expPath, path)
+			}
+
+			pid, ok := PIDFromActiveFile(path[len("./konf/active/"):])
+			if !ok || pid != 1234 {
+				t.Errorf("Exp pid 1234 to be parsed from %q, got %d", path, pid)
+			}
+		})
+	}
+
+	t.Cleanup(config.Override(func(c *config.Config) { c.ActiveFileTemplate = "shell-{{.PID}}-{{.PID}}" }))
+	for _, name := range []string{"1234.yaml", "shell-1234-4321.yaml", "shell-abc-abc.yaml", ".DS_Store"} {
+		if pid, ok := PIDFromActiveFile(name); ok {
+			t.Errorf("Exp %q not to be an active konf, got pid %d", name, pid)
+		}
+	}
+}
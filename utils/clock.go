@@ -0,0 +1,24 @@
+package utils
+
+import "time"
+
+// Clock tells the current time. Time-based features like the expiry of scratch konfs or the backoff of health checks
+// use it instead of time.Now, so tests can control the time
+type Clock interface {
+	Now() time.Time
+	// After waits for d to pass and then sends the current time on the returned channel
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the Clock that tells the actual time
+type RealClock struct{}
+
+// Now returns the current local time
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// After waits for d to pass in real time
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
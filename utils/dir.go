@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"io/fs"
 
 	"github.com/simontheleg/konf-go/config"
@@ -19,15 +21,46 @@ const IntegrationtestDir = "/tmp/konfs"
 // EnsureDir makes sure that konf store and active dirs exist
 func EnsureDir(f afero.Fs) error {
 
-	err := f.MkdirAll(config.StoreDir()+"/", KonfDirPerm)
-	if err != nil {
-		return err
+	for _, dir := range []string{config.StoreDir(), config.ActiveDir()} {
+		err := CheckDir(f, dir)
+		if err != nil {
+			return err
+		}
+		err = f.MkdirAll(dir+"/", KonfDirPerm)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = f.MkdirAll(config.ActiveDir()+"/", KonfDirPerm)
+	return nil
+}
+
+// NotADir describes a state in which a file exists at a path, where konf expects one of its directories
+type NotADir struct {
+	Path string
+}
+
+func (n *NotADir) Error() string {
+	return fmt.Sprintf("%q is a file, but konf expects a directory there. Please remove or move the file", n.Path)
+}
+
+// Is reports whether target is a NotADir
+func (n *NotADir) Is(target error) bool {
+	_, ok := target.(*NotADir)
+	return ok
+}
+
+// CheckDir returns a NotADir error if path exists, but is not a directory. A path that does not exist is fine
+func CheckDir(f afero.Fs, path string) error {
+	info, err := f.Stat(path)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
 		return err
 	}
-
+	if !info.IsDir() {
+		return &NotADir{Path: path}
+	}
 	return nil
 }
@@ -26,3 +26,14 @@ func TestEnsureDir(t *testing.T) {
 		t.Errorf("Expected %s to be a dir, but it is not %q", r.Name(), r)
 	}
 }
+
+func TestEnsureDirNotADir(t *testing.T) {
+	f := afero.NewMemMapFs()
+	afero.WriteFile(f, "./konf/store", []byte{}, KonfPerm)
+
+	err := EnsureDir(f)
+	exp := "\"./konf/store\" is a file, but konf expects a directory there. Please remove or move the file"
+	if err == nil || err.Error() != exp {
+		t.Errorf("Exp err %q, got %q", exp, err)
+	}
+}
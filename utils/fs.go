@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simontheleg/konf-go/config"
+	"github.com/spf13/afero"
+)
+
+// StoreBackends contains all filesystems that can be selected to hold the konf store. Adapters for remote
+// storage like object stores only need to provide an afero.Fs and be registered here
+var StoreBackends = map[string]func() (afero.Fs, error){
+	"os": func() (afero.Fs, error) { return afero.NewOsFs(), nil },
+}
+
+// NewFs returns the filesystem konf operates on. All paths inside the store are served by the configured
+// store backend, while everything else, like active konfs or the latest konf, always stays on the local filesystem
+// The backend is only determined on first use, as commands are constructed before the config is loaded
+func NewFs() afero.Fs {
+	return &routingFs{local: afero.NewOsFs(), backends: StoreBackends}
+}
+
+// IsStorePath reports whether path points to the configured storeDir or a file inside of it
+func IsStorePath(path string) bool {
+	return isInDir(config.StoreDir(), path)
+}
+
+// IsAnyStorePath reports whether path points to any of the store directories, including shared ones, or a file
+// inside of them
+func IsAnyStorePath(path string) bool {
+	for _, dir := range config.StoreDirs() {
+		if isInDir(dir, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInDir(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+type routingFs struct {
+	local    afero.Fs
+	backends map[string]func() (afero.Fs, error)
+
+	once  sync.Once
+	store afero.Fs
+	err   error
+}
+
+func (r *routingFs) fsFor(path string) (afero.Fs, error) {
+	if !IsStorePath(path) {
+		return r.local, nil
+	}
+
+	r.once.Do(func() {
+		name := config.StoreBackend()
+		newFs, ok := r.backends[name]
+		if !ok {
+			r.err = fmt.Errorf("unknown store backend %q", name)
+			return
+		}
+		r.store, r.err = newFs()
+	})
+	return r.store, r.err
+}
+
+func (r *routingFs) Create(name string) (afero.File, error) {
+	f, err := r.fsFor(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.Create(name)
+}
+
+func (r *routingFs) Mkdir(name string, perm os.FileMode) error {
+	f, err := r.fsFor(name)
+	if err != nil {
+		return err
+	}
+	return f.Mkdir(name, perm)
+}
+
+func (r *routingFs) MkdirAll(path string, perm os.FileMode) error {
+	f, err := r.fsFor(path)
+	if err != nil {
+		return err
+	}
+	return f.MkdirAll(path, perm)
+}
+
+func (r *routingFs) Open(name string) (afero.File, error) {
+	f, err := r.fsFor(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.Open(name)
+}
+
+func (r *routingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	f, err := r.fsFor(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.OpenFile(name, flag, perm)
+}
+
+func (r *routingFs) Remove(name string) error {
+	f, err := r.fsFor(name)
+	if err != nil {
+		return err
+	}
+	return f.Remove(name)
+}
+
+func (r *routingFs) RemoveAll(path string) error {
+	f, err := r.fsFor(path)
+	if err != nil {
+		return err
+	}
+	return f.RemoveAll(path)
+}
+
+// Rename only works within one filesystem. Moving files between the store and the local filesystem is not supported
+func (r *routingFs) Rename(oldname, newname string) error {
+	if IsStorePath(oldname) != IsStorePath(newname) {
+		return fmt.Errorf("cannot rename %q to %q, as only one of them is inside the store", oldname, newname)
+	}
+	f, err := r.fsFor(oldname)
+	if err != nil {
+		return err
+	}
+	return f.Rename(oldname, newname)
+}
+
+func (r *routingFs) Stat(name string) (os.FileInfo, error) {
+	f, err := r.fsFor(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.Stat(name)
+}
+
+func (r *routingFs) Name() string {
+	return "routingFs"
+}
+
+func (r *routingFs) Chmod(name string, mode os.FileMode) error {
+	f, err := r.fsFor(name)
+	if err != nil {
+		return err
+	}
+	return f.Chmod(name, mode)
+}
+
+func (r *routingFs) Chown(name string, uid, gid int) error {
+	f, err := r.fsFor(name)
+	if err != nil {
+		return err
+	}
+	return f.Chown(name, uid, gid)
+}
+
+func (r *routingFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	f, err := r.fsFor(name)
+	if err != nil {
+		return err
+	}
+	return f.Chtimes(name, atime, mtime)
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/simontheleg/konf-go/config"
+	"github.com/spf13/afero"
+)
+
+func TestIsStorePath(t *testing.T) {
+	tt := map[string]struct {
+		In  string
+		Exp bool
+	}{
+		"store file": {
+			StorePathForID("dev-eu_dev-eu-1"),
+			true,
+		},
+		"store dir": {
+			config.StoreDir(),
+			true,
+		},
+		"active file": {
+			ActivePathForID("1234"),
+			false,
+		},
+		"latest konf": {
+			config.LatestKonfFile(),
+			false,
+		},
+		"escaping the store": {
+			"./konf/store/../active/1234.yaml",
+			false,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res := IsStorePath(tc.In)
+			if res != tc.Exp {
+				t.Errorf("Exp %t, got %t", tc.Exp, res)
+			}
+		})
+	}
+}
+
+func TestRoutingFs(t *testing.T) {
+	local := afero.NewMemMapFs()
+	store := afero.NewMemMapFs()
+	f := &routingFs{
+		local:    local,
+		backends: map[string]func() (afero.Fs, error){"os": func() (afero.Fs, error) { return store, nil }},
+	}
+
+	err := EnsureDir(f)
+	if err != nil {
+		t.Fatalf("Exp no error, got %q", err)
+	}
+	afero.WriteFile(f, StorePathForID("dev-eu_dev-eu-1"), []byte("store"), KonfPerm)
+	afero.WriteFile(f, ActivePathForID("1234"), []byte("active"), KonfPerm)
+
+	if ok, _ := afero.Exists(store, StorePathForID("dev-eu_dev-eu-1")); !ok {
+		t.Errorf("Exp store file to be written to the store backend")
+	}
+	if ok, _ := afero.Exists(local, StorePathForID("dev-eu_dev-eu-1")); ok {
+		t.Errorf("Exp store file to not be written to the local filesystem")
+	}
+	if ok, _ := afero.Exists(local, ActivePathForID("1234")); !ok {
+		t.Errorf("Exp active file to be written to the local filesystem")
+	}
+	if ok, _ := afero.Exists(store, ActivePathForID("1234")); ok {
+		t.Errorf("Exp active file to not be written to the store backend")
+	}
+
+	err = f.Rename(ActivePathForID("1234"), StorePathForID("1234"))
+	if err == nil {
+		t.Errorf("Exp renaming between store and local filesystem to fail")
+	}
+}
+
+func TestRoutingFsUnknownBackend(t *testing.T) {
+	f := &routingFs{local: afero.NewMemMapFs(), backends: map[string]func() (afero.Fs, error){}}
+
+	expErr := fmt.Errorf("unknown store backend %q", "os")
+	_, err := f.Stat(StorePathForID("dev-eu_dev-eu-1"))
+	if err == nil || err.Error() != expErr.Error() {
+		t.Errorf("Exp error %q, got %q", expErr, err)
+	}
+
+	// everything outside of the store must keep on working
+	err = afero.WriteFile(f, ActivePathForID("1234"), []byte("active"), KonfPerm)
+	if err != nil {
+		t.Errorf("Exp no error for files outside the store, got %q", err)
+	}
+}
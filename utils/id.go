@@ -1,11 +1,15 @@
 package utils
 
 import (
+	"fmt"
 	"io/fs"
 	"path/filepath"
 	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/simontheleg/konf-go/config"
+	"github.com/spf13/afero"
 )
 
 // ID unifies ID and File management that konf uses
@@ -13,10 +17,23 @@ import (
 // I have chosen this combination as it is fairly unique among multiple configs. I decided against using just context.name as a lot of times the context is just called "default", which results in lots of naming collisions
 // Some special characters that are reserved by the filesystem, will be replaced by a "-" character
 
-// IDFromClusterAndContext creates an id based on the cluster and context
+// DefaultIDFormat is the template that is used for ids, if no other format has been configured
+const DefaultIDFormat = "{{.Context}}_{{.Cluster}}"
+
+// idFields are the fields that can be used in an id format
+type idFields struct {
+	Context string
+	Cluster string
+}
+
+// IDFromClusterAndContext creates an id based on the cluster and context, using the configured id format
 // It escapes any illegal file characters and is filesafe
 func IDFromClusterAndContext(cluster, context string) string {
-	id := context + "_" + cluster
+	id, err := formatID(config.IDFormat(), cluster, context)
+	if err != nil {
+		// the format is validated when the config is loaded, so this only happens if it has been bypassed
+		id = context + "_" + cluster
+	}
 
 	illegalChars := []string{"/", ":"}
 	for _, c := range illegalChars {
@@ -26,6 +43,56 @@ func IDFromClusterAndContext(cluster, context string) string {
 	return id
 }
 
+// formatID applies the id format template to cluster and context. An empty format results in DefaultIDFormat
+func formatID(format, cluster, context string) (string, error) {
+	if format == "" {
+		format = DefaultIDFormat
+	}
+	tmpl, err := idTemplate(format)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, idFields{Context: context, Cluster: cluster})
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// idTemplates caches the parsed templates of id formats, as ids are formatted for every konf in the store
+var idTemplates sync.Map
+
+// idTemplate returns the parsed template of format
+func idTemplate(format string) (*template.Template, error) {
+	if tmpl, ok := idTemplates.Load(format); ok {
+		return tmpl.(*template.Template), nil
+	}
+	tmpl, err := template.New("id").Option("missingkey=error").Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	idTemplates.Store(format, tmpl)
+	return tmpl, nil
+}
+
+// ValidateIDFormat makes sure that format is a template, which results in different ids for different contexts
+// Otherwise konfs would silently overwrite each other in the store
+func ValidateIDFormat(format string) error {
+	a, err := formatID(format, "cluster", "a")
+	if err != nil {
+		return fmt.Errorf("invalid id format %q: %v", format, err)
+	}
+	b, err := formatID(format, "cluster", "b")
+	if err != nil {
+		return fmt.Errorf("invalid id format %q: %v", format, err)
+	}
+	if a == b {
+		return fmt.Errorf("invalid id format %q: it has to contain {{.Context}}, so every context gets its own id", format)
+	}
+	return nil
+}
+
 // IDFromFileInfo creates an ID from the name of a file
 func IDFromFileInfo(fi fs.FileInfo) string {
 	return strings.TrimSuffix(fi.Name(), filepath.Ext(fi.Name()))
@@ -36,11 +103,38 @@ func StorePathForID(id string) string {
 	return genIDPath(config.StoreDir(), id)
 }
 
+// StorePathForIDInDir creates a valid filepath for the konf with the supplied id inside the store directory dir
+func StorePathForIDInDir(dir, id string) string {
+	return genIDPath(dir, id)
+}
+
+// ResolveStorePath returns the path of the konf with the supplied id in the first store directory that contains it.
+// If none of them does, the path inside the own store is returned
+func ResolveStorePath(f afero.Fs, id string) string {
+	for _, dir := range config.StoreDirs() {
+		path := genIDPath(dir, id)
+		if _, err := f.Stat(path); err == nil {
+			return path
+		}
+	}
+	return StorePathForID(id)
+}
+
 // ActivePathForID creates a valid filepath inside the configured activeDir
 func ActivePathForID(id string) string {
 	return genIDPath(config.ActiveDir(), id)
 }
 
+// IsActivePath reports whether path points to a file inside the configured activeDir
+// This can be used to determine whether a kubeconfig is managed by konf or not
+func IsActivePath(path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(config.ActiveDir()), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != "." && !strings.HasPrefix(rel, "..")
+}
+
 func genIDPath(path, id string) string {
 	return path + "/" + id + ".yaml"
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/simontheleg/konf-go/config"
 	"github.com/spf13/afero"
 )
 
@@ -47,6 +48,100 @@ func TestIDFromClusterAndContext(t *testing.T) {
 	}
 }
 
+func TestIDFormat(t *testing.T) {
+	tt := map[string]struct {
+		format string
+		expID  string
+		expErr error
+	}{
+		"default":           {"", "dev-eu_dev-eu-1", nil},
+		"cluster first":     {"{{.Cluster}}_{{.Context}}", "dev-eu-1_dev-eu", nil},
+		"context only":      {"{{.Context}}", "dev-eu", nil},
+		"illegal character": {"{{.Cluster}}/{{.Context}}", "dev-eu-1-dev-eu", nil},
+		"no context":        {"{{.Cluster}}", "", fmt.Errorf("invalid id format \"{{.Cluster}}\": it has to contain {{.Context}}, so every context gets its own id")},
+		"unknown field":     {"{{.Namespace}}", "", fmt.Errorf("invalid id format \"{{.Namespace}}\": template: id:1:2: executing \"id\" at <.Namespace>: can't evaluate field Namespace in type utils.idFields")},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateIDFormat(tc.format)
+			if fmt.Sprint(err) != fmt.Sprint(tc.expErr) {
+				t.Fatalf("Exp err %q, got %q", tc.expErr, err)
+			}
+			if tc.expErr != nil {
+				return
+			}
+
+			t.Cleanup(config.Override(func(c *config.Config) { c.IDFormat = tc.format }))
+			if res := IDFromClusterAndContext("dev-eu-1", "dev-eu"); res != tc.expID {
+				t.Errorf("Exp ID %q, got %q", tc.expID, res)
+			}
+		})
+	}
+}
+
+func TestResolveStorePath(t *testing.T) {
+	t.Cleanup(config.Override(func(c *config.Config) { c.SharedStoreDirs = []string{"/team/store", "/org/store"} }))
+
+	f := afero.NewMemMapFs()
+	for _, path := range []string{"./konf/store/own.yaml", "/team/store/own.yaml", "/team/store/team.yaml", "/org/store/team.yaml", "/org/store/org.yaml"} {
+		afero.WriteFile(f, path, []byte{}, KonfPerm)
+	}
+
+	tt := map[string]string{
+		"own":     "./konf/store/own.yaml",
+		"team":    "/team/store/team.yaml",
+		"org":     "/org/store/org.yaml",
+		"missing": "./konf/store/missing.yaml",
+	}
+	for id, exp := range tt {
+		if res := ResolveStorePath(f, id); res != exp {
+			t.Errorf("Exp konf %q to be resolved to %q, got %q", id, exp, res)
+		}
+	}
+
+	if !IsAnyStorePath("/org/store/org.yaml") || IsStorePath("/org/store/org.yaml") {
+		t.Errorf("Exp shared stores to only be part of all store paths, but not of the own store")
+	}
+}
+
+func TestIsActivePath(t *testing.T) {
+	tt := map[string]struct {
+		In  string
+		Exp bool
+	}{
+		"active file": {
+			ActivePathForID("1234"),
+			true,
+		},
+		"active file with unclean path": {
+			"konf/active/../active/1234.yaml",
+			true,
+		},
+		"active dir itself": {
+			"./konf/active",
+			false,
+		},
+		"store file": {
+			StorePathForID("dev-eu_dev-eu-1"),
+			false,
+		},
+		"foreign kubeconfig": {
+			"/home/user/.kube/config",
+			false,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			res := IsActivePath(tc.In)
+			if res != tc.Exp {
+				t.Errorf("Exp IsActivePath(%q) to be %t, got %t", tc.In, tc.Exp, res)
+			}
+		})
+	}
+}
+
 type mockFileInfo struct{ name string }
 
 func (m *mockFileInfo) Name() string       { return m.name }
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// lockRetryInterval describes how long Lock waits before it tries to acquire a held lock again
+const lockRetryInterval = 10 * time.Millisecond
+
+// lockStaleAfter describes the age after which a lock is considered to be left over by a crashed process.
+// Locks are only held for a few file operations, so this is plenty
+const lockStaleAfter = 30 * time.Second
+
+// lockCount makes the owner tokens of locks acquired by the same process unique
+var lockCount uint64
+
+// Lock acquires an exclusive lock by creating the file at path. It is meant to guard read-modify-write cycles
+// on files that are shared by all shells, like the konf history. If the lock is held by someone else, Lock retries
+// until timeout has passed. A lock that is older than lockStaleAfter is taken over. The returned func releases the lock
+//
+// Each lock file contains a token that is unique to its owner. A stale lock is never removed, as another process
+// could create a fresh lock in between the removal and our own attempt. Instead it is replaced in a single rename,
+// after which the token is read back to verify that no other process replaced it at the same time
+func Lock(f afero.Fs, path string, timeout time.Duration) (func() error, error) {
+	token := fmt.Sprintf("%d-%d-%d", os.Getpid(), time.Now().UnixNano(), atomic.AddUint64(&lockCount, 1))
+	unlock := func() error { return releaseLock(f, path, token) }
+
+	deadline := time.Now().Add(timeout)
+	for {
+		lf, err := f.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, KonfPerm)
+		if err == nil {
+			_, err = lf.WriteString(token)
+			lf.Close()
+			if err != nil {
+				f.Remove(path)
+				return nil, err
+			}
+			return unlock, nil
+		}
+		if !errors.Is(err, fs.ErrExist) && !errors.Is(err, afero.ErrFileExists) {
+			return nil, err
+		}
+
+		if info, err := f.Stat(path); err == nil && time.Since(info.ModTime()) > lockStaleAfter {
+			ok, err := takeOverLock(f, path, token)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return unlock, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("could not acquire lock %q within %s. If no other konf is running, it is safe to remove it", path, timeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// takeOverLock replaces the stale lock at path with a lock owned by token. It reports whether the lock is ours
+// afterwards, which is not the case if another process took it over first
+func takeOverLock(f afero.Fs, path string, token string) (bool, error) {
+	stale, err := afero.ReadFile(f, path)
+	if err != nil {
+		// the owner released the lock in the meantime
+		return false, nil
+	}
+
+	tmp := path + "." + token
+	err = afero.WriteFile(f, tmp, []byte(token), KonfPerm)
+	if err != nil {
+		return false, err
+	}
+	defer f.Remove(tmp)
+
+	// only replace the lock if it is still the stale one. Another process that took it over already has written
+	// its own token
+	cur, err := afero.ReadFile(f, path)
+	if err != nil || string(cur) != string(stale) {
+		return false, nil
+	}
+	err = f.Rename(tmp, path)
+	if err != nil {
+		return false, err
+	}
+
+	cur, err = afero.ReadFile(f, path)
+	return err == nil && string(cur) == token, nil
+}
+
+// releaseLock removes the lock at path, unless it has been taken over by someone else in the meantime
+func releaseLock(f afero.Fs, path string, token string) error {
+	cur, err := afero.ReadFile(f, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if string(cur) != token {
+		return nil
+	}
+	return f.Remove(path)
+}
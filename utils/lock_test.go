@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestLock(t *testing.T) {
+	f := afero.NewOsFs()
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	unlock, err := Lock(f, path, time.Second)
+	if err != nil {
+		t.Fatalf("Exp to acquire free lock, got %q", err)
+	}
+
+	_, err = Lock(f, path, 50*time.Millisecond)
+	if err == nil {
+		t.Errorf("Exp held lock to time out, but it was acquired")
+	}
+
+	err = unlock()
+	if err != nil {
+		t.Fatalf("Exp no error on unlock, got %q", err)
+	}
+	unlock, err = Lock(f, path, time.Second)
+	if err != nil {
+		t.Fatalf("Exp to acquire released lock, got %q", err)
+	}
+	unlock()
+}
+
+func TestLockStale(t *testing.T) {
+	f := afero.NewOsFs()
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	err := afero.WriteFile(f, path, []byte{}, KonfPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Minute)
+	err = os.Chtimes(path, old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := Lock(f, path, time.Second)
+	if err != nil {
+		t.Fatalf("Exp stale lock to be taken over, got %q", err)
+	}
+	unlock()
+}
+
+func TestLockStaleReleaseKeepsNewOwner(t *testing.T) {
+	f := afero.NewOsFs()
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	// the first owner keeps on running, but holds its lock for so long that it is taken over
+	unlockOld, err := Lock(f, path, time.Second)
+	if err != nil {
+		t.Fatalf("Exp to acquire free lock, got %q", err)
+	}
+	old := time.Now().Add(-time.Minute)
+	err = os.Chtimes(path, old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unlockNew, err := Lock(f, path, time.Second)
+	if err != nil {
+		t.Fatalf("Exp stale lock to be taken over, got %q", err)
+	}
+
+	err = unlockOld()
+	if err != nil {
+		t.Fatalf("Exp no error on unlock, got %q", err)
+	}
+	_, err = Lock(f, path, 50*time.Millisecond)
+	if err == nil {
+		t.Errorf("Exp the lock of the new owner to be kept, but it was acquired again")
+	}
+
+	matches, _ := filepath.Glob(path + ".*")
+	if len(matches) != 0 {
+		t.Errorf("Exp no leftover files of the takeover, got %q", matches)
+	}
+	unlockNew()
+}